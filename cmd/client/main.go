@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
+	"os/signal"
 
 	"github.com/toivjon/go-rps/internal/client"
+	"github.com/toivjon/go-rps/internal/com"
 )
 
 const (
@@ -15,26 +21,144 @@ const (
 	defaultHost = "localhost"
 )
 
+var (
+	errModeFlags     = errors.New("only one of the flags -room, -create-room, -spectate, -tournament and -bot can be used")
+	errAutoplayFlags = errors.New("the flag -strategy cannot be used with -spectate")
+	errAccountFlags  = errors.New("only one of the flags -login and -register can be used")
+)
+
+// options contains the command line options which specify how the client connects and plays.
+type options struct {
+	name       string
+	room       string
+	bot        string
+	createRoom bool
+	spectate   bool
+	tournament bool
+	strategy   string
+	matches    int
+	login      bool
+	register   bool
+	tls        bool
+	tlsCA      string
+	tlsCert    string
+	tlsKey     string
+	insecure   bool
+}
+
 func main() {
 	port := flag.Uint("port", defaultPort, "The port of the server.")
 	host := flag.String("host", defaultHost, "The IP address or hostname of the server.")
+	room := flag.String("room", "", "The code of a private room to join.")
+	createRoom := flag.Bool("create-room", false, "Create a private room and share its code with the opponent.")
+	spectate := flag.Bool("spectate", false, "Watch the game sessions of other players instead of playing.")
+	tournament := flag.Bool("tournament", false, "Register to a tournament instead of playing a single match.")
+	bot := flag.String("bot", "", "Play against server bots: random, frequency, markov or beat-last.")
+	name := flag.String("name", "", "The player name to join with instead of asking it.")
+	strategy := flag.String("strategy", "", "Play unattended with a strategy: random, cycle or adaptive.")
+	matches := flag.Int("matches", 1, "The number of matches to play unattended (0 plays until stopped).")
+	login := flag.Bool("login", false, "Log in to a player account before joining.")
+	register := flag.Bool("register", false, "Register a new player account before joining.")
+	useTLS := flag.Bool("tls", false, "Connect to the server over TLS.")
+	tlsCA := flag.String("tls-ca", "", "The CA file to verify the server certificate with (implies -tls).")
+	insecure := flag.Bool("insecure", false, "Skip the verification of the server certificate (implies -tls).")
+	tlsCert := flag.String("tls-cert", "", "The client certificate file for mutual TLS (implies -tls).")
+	tlsKey := flag.String("tls-key", "", "The private key file of the client certificate.")
 	flag.Parse()
 
 	log.Println("Welcome to the RPS client")
-	if err := run(*port, *host); err != nil {
+	opts := options{
+		name:       *name,
+		room:       *room,
+		bot:        *bot,
+		createRoom: *createRoom,
+		spectate:   *spectate,
+		tournament: *tournament,
+		strategy:   *strategy,
+		matches:    *matches,
+		login:      *login,
+		register:   *register,
+		tls:        *useTLS || *tlsCA != "" || *insecure || *tlsCert != "" || *tlsKey != "",
+		tlsCA:      *tlsCA,
+		tlsCert:    *tlsCert,
+		tlsKey:     *tlsKey,
+		insecure:   *insecure,
+	}
+	if err := run(*port, *host, opts); err != nil {
 		log.Fatalf("Client was closed due an error: %v", err)
 	}
 	log.Println("Client was closed successfully.")
 }
 
-func run(port uint, host string) error {
-	log.Printf("Connecting to server: %s:%d", host, port)
-	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%d", host, port))
+func run(port uint, host string, opts options) error {
+	modes := 0
+	for _, set := range []bool{opts.room != "", opts.createRoom, opts.spectate, opts.tournament, opts.bot != ""} {
+		if set {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return errModeFlags
+	}
+	if opts.login && opts.register {
+		return errAccountFlags
+	}
+	dial := new(net.Dialer).DialContext
+	if opts.tls {
+		config, err := com.ClientTLSConfig(opts.tlsCA, opts.tlsCert, opts.tlsKey, opts.insecure)
+		if err != nil {
+			return fmt.Errorf("failed to build TLS configuration. %w", err)
+		}
+		if name := com.CertificateName(config); name != "" && opts.name == "" {
+			opts.name = name
+		}
+		dial = (&tls.Dialer{NetDialer: nil, Config: config}).DialContext
+	}
+	var autoplay *client.Autoplay
+	if opts.strategy != "" {
+		if opts.spectate {
+			return errAutoplayFlags
+		}
+		strategy, err := client.LookupStrategy(opts.strategy)
+		if err != nil {
+			return fmt.Errorf("failed to find strategy. %w", err)
+		}
+		autoplay = &client.Autoplay{Strategy: strategy, Matches: opts.matches, Played: 0}
+		if opts.name == "" {
+			opts.name = fmt.Sprintf("%s bot", strategy)
+		}
+	}
+	log.Printf("Connecting to server: %s:%d (TLS: %t)", host, port, opts.tls)
+	address := net.JoinHostPort(host, fmt.Sprint(port))
+	conn, err := dial(context.Background(), "tcp", address)
 	if err != nil {
 		return fmt.Errorf("failed to open TCP connection. %w", err)
 	}
 	defer conn.Close()
-	if err := client.Run(client.NewContext(os.Stdin, conn), client.Connected); err != nil {
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	clientCtx := client.NewContext(os.Stdin, conn)
+	clientCtx.Name = opts.name
+	clientCtx.RoomCode = opts.room
+	clientCtx.CreateRoom = opts.createRoom
+	clientCtx.Spectate = opts.spectate
+	clientCtx.Tournament = opts.tournament
+	clientCtx.Bot = opts.bot
+	clientCtx.Account.Login = opts.login
+	clientCtx.Account.Register = opts.register
+	clientCtx.HideInput = hideInput
+	clientCtx.Autoplay = autoplay
+	clientCtx.Dial = func(ctx context.Context) (io.ReadWriter, error) {
+		conn, err := dial(ctx, "tcp", address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open TCP connection. %w", err)
+		}
+		return conn, nil
+	}
+	if err := client.Run(ctx, clientCtx, client.Handshaking); err != nil &&
+		!errors.Is(err, context.Canceled) {
 		return fmt.Errorf("failed to run client. %w", err)
 	}
 	return nil
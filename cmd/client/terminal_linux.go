@@ -0,0 +1,39 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// hideInput disables the echo of the standard input terminal and returns a function which restores the echo.
+func hideInput() (func(), error) {
+	fd := os.Stdin.Fd()
+	state := new(syscall.Termios)
+	if err := ioctl(fd, syscall.TCGETS, state); err != nil {
+		return nil, fmt.Errorf("failed to read terminal state. %w", err)
+	}
+	hidden := *state
+	hidden.Lflag &^= syscall.ECHO
+	if err := ioctl(fd, syscall.TCSETS, &hidden); err != nil {
+		return nil, fmt.Errorf("failed to disable terminal echo. %w", err)
+	}
+	return func() {
+		if err := ioctl(fd, syscall.TCSETS, state); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to restore terminal echo. %s\n", err)
+		}
+		// The line break typed by the user was not echoed either.
+		fmt.Fprintln(os.Stderr)
+	}, nil
+}
+
+func ioctl(fd, request uintptr, termios *syscall.Termios) error {
+	//nolint:gosec
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(unsafe.Pointer(termios))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+var errHideInput = errors.New("hiding the terminal input is not supported on this platform")
+
+// hideInput reports that the echo of the standard input terminal cannot be disabled on this platform.
+func hideInput() (func(), error) {
+	return nil, errHideInput
+}
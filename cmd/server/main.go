@@ -1,46 +1,222 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net"
-	"os"
+	"net/http"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/toivjon/go-rps/internal/com"
+	"github.com/toivjon/go-rps/internal/game"
 	"github.com/toivjon/go-rps/internal/server"
+	"github.com/toivjon/go-rps/internal/web"
+	"github.com/toivjon/go-rps/internal/ws"
 )
 
 const (
-	defaultPort = 7777
-	defaultHost = "localhost"
+	defaultPort   = 7777
+	defaultHost   = "localhost"
+	defaultFormat = "bo1"
+	defaultRules  = "classic"
+
+	defaultTournamentSize = 4
+
+	defaultRoundTimeout  = time.Minute
+	defaultShutdownGrace = 0 * time.Second
+	defaultResumeGrace   = 30 * time.Second
+	defaultBotWait       = 0 * time.Second
+	defaultBotStrategy   = "random"
+
+	webSocketPath     = "/ws"
+	readHeaderTimeout = 10 * time.Second
+)
+
+var (
+	errTLSFlags      = errors.New("the flags -tls-cert and -tls-key must be used together")
+	errClientCAFlags = errors.New("the flag -tls-client-ca requires the flags -tls-cert and -tls-key")
+	errPortFlags     = errors.New("at least one of the flags -port and -ws-port must be non-zero")
+	errWebFlags      = errors.New("the flag -web requires the flag -ws-port")
 )
 
 func main() {
-	port := flag.Uint("port", defaultPort, "The port to listen for connections.")
+	port := flag.Uint("port", defaultPort, "The port to listen for TCP connections (0 disables).")
+	wsPort := flag.Uint("ws-port", 0, "The port to listen for WebSocket connections at /ws (0 disables).")
+	serveWeb := flag.Bool("web", false, "Serve the web client at the WebSocket port.")
+	wsOrigins := flag.String("ws-origins", "", "The other web origins to accept WebSockets from (comma-separated or *).")
 	host := flag.String("host", defaultHost, "The network address to listen for connections.")
+	format := flag.String("format", defaultFormat, "The match format e.g. bo3 (best of 3) or ft2 (first to 2).")
+	rules := flag.String("rules", defaultRules, "The rule set to play with: classic, rpsls or rps7.")
+	players := flag.Int("players", server.MinPlayers, "The number of players in a game session (2-8).")
+	tournamentSize := flag.Int("tournament-size", defaultTournamentSize, "The number of players in a tournament (2-64).")
+	roundTimeout := flag.Duration("round-timeout", defaultRoundTimeout, "The round selection time limit (0 disables).")
+	shutdownGrace := flag.Duration("shutdown-grace", defaultShutdownGrace, "The time to finish rounds on shutdown.")
+	resumeGrace := flag.Duration("resume-grace", defaultResumeGrace, "The time to hold a lost player's seat (0 disables).")
+	botWait := flag.Duration("bot-wait", defaultBotWait, "The queue time before pairing a player with bots (0 disables).")
+	botStrategy := flag.String("bot-strategy", defaultBotStrategy, "The strategy of the bots e.g. markov or beat-last.")
+	tlsCert := flag.String("tls-cert", "", "The certificate file to accept TLS connections with.")
+	tlsKey := flag.String("tls-key", "", "The private key file of the TLS certificate.")
+	tlsClientCA := flag.String("tls-client-ca", "", "The CA file to verify client certificates with (enables mTLS).")
+	guests := flag.Bool("guests", true, "Let the players join without logging in to a player account.")
+	accountsFile := flag.String("accounts", "", "The file to store the player accounts in (empty keeps them in memory).")
+	historyFile := flag.String("history", "", "The file to append the decided matches to (empty keeps them in memory).")
 	flag.Parse()
 
 	log.Println("Welcome to the RPS server")
-	if err := run(*port, *host); err != nil {
+	config := server.DefaultConfig()
+	if err := server.ValidatePlayers(*players); err != nil {
+		log.Fatalf("Server was closed due an invalid argument: %v", err)
+	}
+	config.Players = *players
+	if err := server.ValidateTournamentSize(*tournamentSize); err != nil {
+		log.Fatalf("Server was closed due an invalid argument: %v", err)
+	}
+	config.TournamentSize = *tournamentSize
+	matchFormat, err := game.ParseFormat(*format)
+	if err != nil {
+		log.Fatalf("Server was closed due an invalid argument: %v", err)
+	}
+	config.Format = matchFormat
+	ruleSet, err := game.LookupRuleSet(*rules)
+	if err != nil {
+		log.Fatalf("Server was closed due an invalid argument: %v", err)
+	}
+	config.Rules = ruleSet
+	config.RoundTimeout = *roundTimeout
+	config.ShutdownGrace = *shutdownGrace
+	config.ResumeGrace = *resumeGrace
+	strategy, err := server.LookupStrategy(*botStrategy)
+	if err != nil {
+		log.Fatalf("Server was closed due an invalid argument: %v", err)
+	}
+	config.BotWait = *botWait
+	config.BotStrategy = strategy
+	config.Guests = *guests
+	accounts := server.NewAccounts()
+	if *accountsFile != "" {
+		if accounts, err = server.LoadAccounts(*accountsFile); err != nil {
+			log.Fatalf("Server was closed due an invalid argument: %v", err)
+		}
+	}
+	var history server.History = server.NewMemoryHistory()
+	if *historyFile != "" {
+		if history, err = server.OpenFileHistory(*historyFile); err != nil {
+			log.Fatalf("Server was closed due an invalid argument: %v", err)
+		}
+	}
+	var tlsConfig *tls.Config
+	if *tlsCert != "" || *tlsKey != "" || *tlsClientCA != "" {
+		if tlsConfig, err = loadTLSConfig(*tlsCert, *tlsKey, *tlsClientCA); err != nil {
+			log.Fatalf("Server was closed due an invalid argument: %v", err)
+		}
+	}
+	if *serveWeb && *wsPort == 0 {
+		log.Fatalf("Server was closed due an invalid argument: %v", errWebFlags)
+	}
+	var origins []string
+	if *wsOrigins != "" {
+		origins = strings.Split(*wsOrigins, ",")
+	}
+	if err := run(*port, *wsPort, *host, *serveWeb, origins, tlsConfig, accounts, history, config); err != nil {
 		log.Fatalf("Server was closed due an error: %v", err)
 	}
 	log.Println("Server was closed successfully.")
 }
 
-func run(port uint, host string) error {
-	log.Printf("Starting up server: %s:%d", host, port)
-	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", host, port))
+// loadTLSConfig builds the TLS configuration from the given certificate, key and client CA files.
+func loadTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		return nil, errClientCAFlags
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errTLSFlags
+	}
+	config, err := com.ServerTLSConfig(certFile, keyFile, clientCAFile)
 	if err != nil {
-		return fmt.Errorf("failed to start listening TCP socket on port %d. %w", port, err)
+		return nil, fmt.Errorf("failed to build TLS configuration. %w", err)
+	}
+	return config, nil
+}
+
+func run(port, wsPort uint, host string, serveWeb bool, origins []string, tlsConfig *tls.Config,
+	accounts *server.Accounts, history server.History, config server.Config,
+) error {
+	defer func() {
+		if err := history.Close(); err != nil {
+			log.Printf("Failed to close match history. %s", err)
+		}
+	}()
+	if port == 0 && wsPort == 0 {
+		return errPortFlags
+	}
+	var listeners []net.Listener
+	if port != 0 {
+		log.Printf("Starting up server: %s:%d", host, port)
+		listener, err := listen(host, port, tlsConfig)
+		if err != nil {
+			return fmt.Errorf("failed to start listening TCP socket on port %d. %w", port, err)
+		}
+		listeners = append(listeners, listener)
 	}
+	if wsPort != 0 {
+		log.Printf("Starting up WebSocket server: %s:%d%s", host, wsPort, webSocketPath)
+		listener, err := listen(host, wsPort, tlsConfig)
+		if err != nil {
+			for _, listener := range listeners {
+				listener.Close()
+			}
+			return fmt.Errorf("failed to start listening TCP socket on port %d. %w", wsPort, err)
+		}
+		wsListener := ws.NewListener(listener.Addr(), origins...)
+		mux := http.NewServeMux()
+		mux.Handle(webSocketPath, wsListener)
+		if serveWeb {
+			log.Printf("Serving web client: %s:%d", host, wsPort)
+			mux.Handle("/", web.Handler())
+		}
+		httpServer := new(http.Server)
+		httpServer.Handler = mux
+		httpServer.ReadHeaderTimeout = readHeaderTimeout
+		go func() {
+			if err := httpServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("Failed to serve HTTP requests. %s", err)
+			}
+		}()
+		defer httpServer.Close()
+		listeners = append(listeners, wsListener)
+	}
+	listener := server.NewMultiListener(listeners...)
 	defer listener.Close()
 
-	shutdown := make(chan os.Signal, 1)
-	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	server := server.NewServer(listener, shutdown)
-	server.Run()
+	server := server.NewServer(listener, config)
+	server.Accounts = accounts
+	server.History = history
+	if err := server.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("failed to run server. %w", err)
+	}
 	return nil
 }
+
+// listen starts listening the TCP socket on the given port, which accepts TLS connections when the TLS
+// configuration is given.
+func listen(host string, port uint, tlsConfig *tls.Config) (net.Listener, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", host, port))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen TCP socket. %w", err)
+	}
+	if tlsConfig != nil {
+		log.Printf("Accepting TLS connections on port %d (mutual authentication: %t)", port, tlsConfig.ClientCAs != nil)
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	return listener, nil
+}
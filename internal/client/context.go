@@ -1,19 +1,182 @@
 package client
 
 import (
+	"bufio"
+	"context"
+	"fmt"
 	"io"
+	"sync"
+	"time"
+
+	"github.com/toivjon/go-rps/internal/game"
 )
 
-// Context represents a client processing context.
+// Dialer opens a new connection to the server.
+type Dialer func(ctx context.Context) (io.ReadWriter, error)
+
+// Context represents a client processing context. The dial is used to reconnect after a dropped connection
+// and reconnecting is disabled when it is nil. The client joins the private room with the room code or hosts
+// a new private room when the create room is set. The client plays against the server-side bots with the bot
+// strategy when it is set. Otherwise the client is paired with any waiting player.
+// When the spectate is set, the client watches the game sessions of other players instead of playing. When
+// the tournament is set, the client registers to a tournament and plays the matches of the tournament bracket.
+// The client joins with the name without asking the user when it is set, and plays unattended when the
+// autoplay is set. The hide input disables the echo of the input while a password is typed and returns a
+// function which restores the echo. The password is echoed when the hide input is nil.
 type Context struct {
-	Input io.Reader
-	Conn  io.ReadWriter
+	Input      *Input
+	Conn       io.ReadWriter
+	Dial       Dialer
+	Name       string
+	RoomCode   string
+	CreateRoom bool
+	Spectate   bool
+	Tournament bool
+	Bot        string
+	Account    *Account
+	HideInput  func() (func(), error)
+	Autoplay   *Autoplay
+	Match      *Match
+	Spectated  *Spectated
+}
+
+// Account contains the settings and the state of the player account. The client logs in to the account or
+// registers a new account before joining when the login or the register is set, or when the server requires
+// an account. The name and the session token are set once the server has authenticated the client.
+type Account struct {
+	Login    bool
+	Register bool
+	Name     string
+	Token    string
+}
+
+// Autoplay contains the settings and the progress of a client which plays without the user input. The strategy
+// makes the round selections and the client plays against new opponents until it has played the given number
+// of matches. The matches are played until the client is stopped when the number of matches is not positive.
+type Autoplay struct {
+	Strategy Strategy
+	Matches  int
+	Played   int
+}
+
+// Done checks whether the client has played every match it should play.
+func (a *Autoplay) Done() bool {
+	return a.Matches > 0 && a.Played >= a.Matches
+}
+
+// Match contains the state of the ongoing game session match. The opponents and their scores are in the seat
+// order. The tournament is set when the match is a pairing of a tournament bracket. The selection is the one
+// made in the ongoing round and the history contains the rounds played so far. The commit reveal is set when
+// the selections are committed with the nonce before they are revealed.
+type Match struct {
+	Opponents      []string
+	Format         game.Format
+	Rules          game.RuleSet
+	RoundTimeout   time.Duration
+	Round          int
+	Score          int
+	OpponentScores []int
+	ResumeToken    string
+	Tournament     bool
+	Selection      game.Selection
+	History        []Round
+	CommitReveal   bool
+	Nonce          string
+}
+
+// Scores returns the score of the client followed by the scores of the opponents.
+func (m *Match) Scores() []int {
+	return append([]int{m.Score}, m.OpponentScores...)
+}
+
+// Spectated contains the state of the game session which is being watched by a spectator.
+type Spectated struct {
+	SessionID int
+	Players   []string
+	Format    game.Format
+}
+
+// Input reads the lines of the user input in a single background routine which is started by the first wait
+// for a line. A blocking read from the input cannot be interrupted, so a line which is read after a wait has
+// been cancelled is kept for the next wait instead of being lost.
+type Input struct {
+	reader io.Reader
+	start  *sync.Once
+	lines  chan string
+	err    error
+}
+
+// NewInput builds a new user input which reads the lines from the given reader.
+func NewInput(reader io.Reader) *Input {
+	return &Input{reader: reader, start: new(sync.Once), lines: make(chan string), err: nil}
+}
+
+// ReadLine waits for the next line of the user input.
+func (i *Input) ReadLine(ctx context.Context) (string, error) {
+	i.start.Do(func() { go i.scan() })
+	select {
+	case line, ok := <-i.lines:
+		if !ok {
+			return "", fmt.Errorf("failed to scan user input. %w", i.err)
+		}
+		return line, nil
+	case <-ctx.Done():
+		return "", fmt.Errorf("user input was cancelled. %w", ctx.Err())
+	}
+}
+
+// scan passes the lines of the input to the waiting reads until the input fails or ends. The lines channel is
+// closed after the error has been stored, so the failure is reported to every later read.
+func (i *Input) scan() {
+	defer close(i.lines)
+	scanner := bufio.NewScanner(i.reader)
+	for scanner.Scan() {
+		i.lines <- scanner.Text()
+	}
+	if i.err = scanner.Err(); i.err == nil {
+		i.err = io.EOF
+	}
 }
 
 // NewContext builds a new client context with the given input and connection.
 func NewContext(input io.Reader, conn io.ReadWriter) Context {
 	return Context{
-		Input: input,
-		Conn:  conn,
+		Input:      NewInput(input),
+		Conn:       conn,
+		Dial:       nil,
+		Name:       "",
+		RoomCode:   "",
+		CreateRoom: false,
+		Spectate:   false,
+		Tournament: false,
+		Bot:        "",
+		Account: &Account{
+			Login:    false,
+			Register: false,
+			Name:     "",
+			Token:    "",
+		},
+		HideInput: nil,
+		Autoplay:  nil,
+		Match: &Match{
+			Opponents:      nil,
+			Format:         game.BestOf(1),
+			Rules:          game.Classic(),
+			RoundTimeout:   0,
+			Round:          1,
+			Score:          0,
+			OpponentScores: nil,
+			ResumeToken:    "",
+			Tournament:     false,
+			Selection:      game.SelectionNone,
+			History:        nil,
+			CommitReveal:   false,
+			Nonce:          "",
+		},
+		Spectated: &Spectated{
+			SessionID: 0,
+			Players:   nil,
+			Format:    game.BestOf(1),
+		},
 	}
 }
@@ -1,6 +1,10 @@
 package client_test
 
 import (
+	"context"
+	"errors"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/toivjon/go-rps/internal/client"
@@ -44,19 +48,64 @@ func (r writerMock) Write(b []byte) (int, error) {
 }
 
 type readWriterMock struct {
-	readerMock
+	io.Reader
 	writerMock
 }
 
 func TestNewContext(t *testing.T) {
 	t.Parallel()
-	input := new(readerMock)
 	conn := new(readWriterMock)
-	ctx := client.NewContext(input, conn)
-	if ctx.Input != input {
-		t.Fatalf("Expected to contain input member %#p but had %#p", input, &ctx.Input)
+	ctx := client.NewContext(succeedingReaderMock("x"), conn)
+	if line, err := ctx.Input.ReadLine(context.Background()); line != "x" || err != nil {
+		t.Fatalf("Expected to read input line \"x\" but read %q with %v", line, err)
 	}
 	if ctx.Conn != conn {
 		t.Fatalf("Expected to contain conn member %#p but had %#p", conn, &ctx.Conn)
 	}
+	if ctx.Match == nil {
+		t.Fatal("Expected to contain non-nil match member but had nil")
+	}
+}
+
+func TestInputReadLine(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnLinesInOrder", func(t *testing.T) {
+		t.Parallel()
+		input := client.NewInput(strings.NewReader("rock\npaper\n"))
+		for _, expected := range []string{"rock", "paper"} {
+			if line, err := input.ReadLine(context.Background()); line != expected || err != nil {
+				t.Fatalf("Expected line %q, but was %q with %v", expected, line, err)
+			}
+		}
+	})
+	t.Run("KeepLineForNextReadWhenCancelled", func(t *testing.T) {
+		t.Parallel()
+		reader, writer := io.Pipe()
+		input := client.NewInput(reader)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := input.ReadLine(ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", context.Canceled, err)
+		}
+		go writer.Write([]byte("rock\n")) //nolint:errcheck
+		if line, err := input.ReadLine(context.Background()); line != "rock" || err != nil {
+			t.Fatalf("Expected line \"rock\", but was %q with %v", line, err)
+		}
+	})
+	t.Run("ReturnErrorWhenInputEnds", func(t *testing.T) {
+		t.Parallel()
+		input := client.NewInput(strings.NewReader(""))
+		for i := 0; i < 2; i++ {
+			if _, err := input.ReadLine(context.Background()); !errors.Is(err, io.EOF) {
+				t.Fatalf("Expected %q error in the chain %q, but did not exists!", io.EOF, err)
+			}
+		}
+	})
+	t.Run("ReturnErrorWhenInputFails", func(t *testing.T) {
+		t.Parallel()
+		input := client.NewInput(failingReaderMock(io.ErrUnexpectedEOF))
+		if _, err := input.ReadLine(context.Background()); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", io.ErrUnexpectedEOF, err)
+		}
+	})
 }
@@ -1,31 +1,81 @@
 package client
 
 import (
-	"bufio"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/toivjon/go-rps/internal/com"
 	"github.com/toivjon/go-rps/internal/game"
 )
 
 var (
-	ErrEnd         = errors.New("end")
-	ErrNameTooLong = fmt.Errorf("player name must not contain more than %d characters", NameMaxLength)
+	ErrEnd                = errors.New("end")
+	ErrReconnectFailed    = errors.New("failed to reconnect to server")
+	ErrRejected           = errors.New("server rejected the connection")
+	ErrShutdown           = errors.New("server was shut down")
+	ErrUnexpectedMessage  = errors.New("unexpected message type")
+	ErrUnsupportedVersion = errors.New("server protocol version is not supported")
 )
 
-// NameMaxLength specifies the maximum length of the player's name.
-const NameMaxLength = 64
+const (
+	// countdownInterval specifies how often the client reports the time left to make a round selection.
+	countdownInterval = 10 * time.Second
+	// reconnectAttempts specifies how many times the client tries to resume a session after a dropped connection.
+	reconnectAttempts = 6
+	// reconnectBackoff specifies the delay before the first reconnect attempt. The delay is doubled after each
+	// failed attempt until it reaches the maxReconnectBackoff.
+	reconnectBackoff    = 500 * time.Millisecond
+	maxReconnectBackoff = 8 * time.Second
+)
 
 // State represents a reference to a client state which may return a next state or an error.
-type State func(ctx Context) (State, error)
+type State func(ctx context.Context, c Context) (State, error)
 
-// Run executes the client logic with the given context and the provided initial state.
-func Run(ctx Context, state State) error {
+// Run executes the client logic with the given client context and the provided initial state. When the ctx
+// is done, the connection gets closed and an error describing the cancellation is returned. A dropped
+// connection during a game session is automatically reconnected and the session resumed when the context
+// contains a dialer. The connections opened by reconnecting are closed when the function returns.
+func Run(ctx context.Context, c Context, state State) error {
+	reconnected := false
+	stop := closeOnDone(ctx, c.Conn)
+	defer func() {
+		stop()
+		if reconnected {
+			closeConn(c.Conn)
+		}
+	}()
 	for state != nil {
-		nextState, err := state(ctx)
+		nextState, err := state(ctx, c)
+		if ctx.Err() != nil {
+			return fmt.Errorf("client was stopped. %w", ctx.Err())
+		}
+		if errors.Is(err, ErrShutdown) {
+			log.Println("Server was shut down.")
+			return nil
+		}
+		if resumable(c, err) {
+			log.Printf("Connection to server was lost. %s", err)
+			resumedConn, resumedState, err := reconnect(ctx, c)
+			if err != nil {
+				return err
+			}
+			stop()
+			if reconnected {
+				closeConn(c.Conn)
+			}
+			c.Conn, reconnected = resumedConn, true
+			stop = closeOnDone(ctx, resumedConn)
+			state = resumedState
+			continue
+		}
 		if err != nil && !errors.Is(err, ErrEnd) {
 			return err
 		}
@@ -34,82 +84,983 @@ func Run(ctx Context, state State) error {
 	return nil
 }
 
-// Connected contains the logic when the client has been connected but not yet joined.
-func Connected(ctx Context) (State, error) {
-	log.Printf("Enter your name:")
-	name, err := waitInput(ctx.Input)
+// closeOnDone closes the connection when the ctx is done before the returned stop function is called.
+func closeOnDone(ctx context.Context, conn io.ReadWriter) func() {
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			closeConn(conn)
+		case <-stop:
+		}
+	}()
+	return func() { close(stop) }
+}
+
+func closeConn(conn io.ReadWriter) {
+	if closer, ok := conn.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// resumable checks whether the error was caused by a dropped connection which may be resumed.
+func resumable(c Context, err error) bool {
+	if c.Dial == nil || c.Match.ResumeToken == "" || err == nil {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) ||
+		errors.As(err, &netErr)
+}
+
+// reconnect tries to resume the game session over a new connection with an exponential backoff between the
+// attempts. Returns the new connection and the state which continues the resumed game session.
+func reconnect(ctx context.Context, c Context) (io.ReadWriter, State, error) {
+	delay := reconnectBackoff
+	for attempt := 1; attempt <= reconnectAttempts; attempt++ {
+		log.Printf("Reconnecting in %s (attempt %d/%d)...", delay, attempt, reconnectAttempts)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, fmt.Errorf("client was stopped. %w", ctx.Err())
+		}
+		conn, state, err := resume(ctx, c)
+		if err == nil {
+			return conn, state, nil
+		}
+		var serverErr *com.ErrorContent
+		if errors.As(err, &serverErr) {
+			return nil, nil, fmt.Errorf("failed to resume the game session. %w", err)
+		}
+		log.Printf("Failed to reconnect. %s", err)
+		if delay *= 2; delay > maxReconnectBackoff {
+			delay = maxReconnectBackoff
+		}
+	}
+	return nil, nil, fmt.Errorf("%w: gave up after %d attempts", ErrReconnectFailed, reconnectAttempts)
+}
+
+// resume opens a new connection, completes the handshake and presents the resume token to take back the
+// seat in the game session.
+func resume(ctx context.Context, c Context) (io.ReadWriter, State, error) {
+	conn, err := c.Dial(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read user input to as username. %w", err)
+		return nil, nil, fmt.Errorf("failed to dial server. %w", err)
+	}
+	c.Conn = conn
+	if _, err := Handshaking(ctx, c); err != nil {
+		closeConn(conn)
+		return nil, nil, err
+	}
+	state, err := Resuming(ctx, c)
+	if err != nil {
+		closeConn(conn)
+		return nil, nil, err
+	}
+	return conn, state, nil
+}
+
+// Handshaking contains the logic when the client has been connected but the protocol version is not yet agreed.
+func Handshaking(ctx context.Context, c Context) (State, error) {
+	hello := com.HelloContent{Version: com.ProtocolVersion, Capabilities: com.Capabilities()}
+	if err := com.WriteMessage(c.Conn, com.TypeHello, hello); err != nil {
+		return nil, fmt.Errorf("failed to write HELLO message. %w", err)
+	}
+	message, err := com.Read[com.Message](c.Conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WELCOME message. %w", err)
+	}
+	switch message.Type {
+	case com.TypeWelcome:
+		welcome, err := decode[com.WelcomeContent](message)
+		if err != nil {
+			return nil, err
+		}
+		if welcome.Version < com.MinProtocolVersion || welcome.Version > com.ProtocolVersion {
+			return nil, fmt.Errorf("%w: version %d", ErrUnsupportedVersion, welcome.Version)
+		}
+		if c.Spectate {
+			return Browsing, nil
+		}
+		if c.Account.Name == "" && (c.Account.Login || c.Account.Register || welcome.AccountRequired) {
+			return Authenticating, nil
+		}
+		return Connected, nil
+	case com.TypeReject:
+		reject, err := decode[com.RejectContent](message)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %s (server supports versions %d-%d)",
+			ErrRejected, reject.Reason, reject.MinVersion, reject.MaxVersion)
+	case com.TypeError:
+		content, err := decode[com.ErrorContent](message)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("server reported an error. %w", content)
+	case com.TypeShutdown:
+		return nil, ErrShutdown
+	case com.TypeHello, com.TypeJoin, com.TypeStart, com.TypeSelect, com.TypeResult, com.TypeMatchEnd,
+		com.TypeRematchOffer, com.TypeRematchAccept, com.TypeQueue, com.TypeResume, com.TypeResumed,
+		com.TypeCreateRoom, com.TypeRoomCreated, com.TypeJoinRoom, com.TypeSpectateList, com.TypeSpectateSessions,
+		com.TypeSpectate, com.TypeSpectateStart, com.TypeSpectateRound, com.TypeSpectateEnd, com.TypeTournamentJoin,
+		com.TypeBracket, com.TypeCommit, com.TypeCommitted, com.TypeReveal, com.TypeRegister, com.TypeLogin,
+		com.TypeAuthenticated:
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnexpectedMessage, message.Type)
+}
+
+// Resuming contains the logic when the client has reconnected and wants to take back its seat in the game
+// session. The session state replayed by the server determines the next state.
+func Resuming(ctx context.Context, c Context) (State, error) {
+	if err := com.WriteMessage(c.Conn, com.TypeResume, com.ResumeContent{Token: c.Match.ResumeToken}); err != nil {
+		return nil, fmt.Errorf("failed to write RESUME message. %w", err)
+	}
+	message, err := receive[com.ResumedContent](c.Conn, com.TypeResumed)
+	if err != nil {
+		return nil, err
+	}
+	selection, nonce := c.Match.Selection, c.Match.Nonce
+	if err := setMatch(c, &message.Start); err != nil {
+		return nil, err
+	}
+	c.Match.Round = message.Round
+	c.Match.Selection = message.Selection
+	if message.Commitment != "" && message.Commitment == com.Commit(selection, nonce) {
+		c.Match.Selection, c.Match.Nonce = selection, nonce
+	}
+	c.Match.Score = message.Score
+	c.Match.OpponentScores = message.OpponentScores
+	if len(message.OpponentScores) == 0 {
+		c.Match.OpponentScores = []int{message.OpponentScore}
+	}
+	log.Printf("Resumed the match against %s at round %d (score %s).",
+		describeNames(c.Match.Opponents), message.Round, describeScores(c.Match.Scores()))
+	switch {
+	case message.Ended && c.Match.Tournament:
+		return Bracket, nil
+	case message.Ended && message.Offered:
+		return Offered, nil
+	case message.Ended:
+		return Rematching, nil
+	case message.Eliminated:
+		return Eliminated, nil
+	case message.Selection != game.SelectionNone, message.Commitment != "":
+		return Waiting, nil
+	}
+	return Started, nil
+}
+
+// Authenticating contains the logic when the client logs in to a player account or registers a new account
+// before joining. The user is asked whether to log in or to register unless it has already been decided, and
+// the password is read with the input hidden when it is supported. A rejected attempt is retried.
+func Authenticating(ctx context.Context, c Context) (State, error) {
+	register := c.Account.Register
+	if !register && !c.Account.Login {
+		log.Printf("Enter 'l' to log in or 'r' to register a new account:")
+		input, err := c.Input.ReadLine(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read user input to as account action. %w", err)
+		}
+		switch strings.ToLower(strings.TrimSpace(input)) {
+		case "l":
+		case "r":
+			register = true
+		default:
+			log.Printf("Invalid input %q.", input)
+			return Authenticating, nil
+		}
+	}
+	name := c.Name
+	if name == "" {
+		log.Printf("Enter your name:")
+		input, err := c.Input.ReadLine(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read user input to as username. %w", err)
+		}
+		name = input
+	}
+	log.Printf("Enter your password:")
+	password, err := waitPassword(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user input to as password. %w", err)
+	}
+	if register {
+		err = com.WriteMessage(c.Conn, com.TypeRegister, com.RegisterContent{Name: name, Password: password})
+	} else {
+		err = com.WriteMessage(c.Conn, com.TypeLogin, com.LoginContent{Name: name, Password: password, Token: ""})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write account message. %w", err)
+	}
+	message, err := receive[com.AuthenticatedContent](c.Conn, com.TypeAuthenticated)
+	if authenticationFailed(err) {
+		return Authenticating, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.Account.Name = message.Name
+	c.Account.Token = message.Token
+	if register {
+		log.Printf("Registered the account %q.", message.Name)
+	} else {
+		log.Printf("Logged in as %q.", message.Name)
+	}
+	return Connected, nil
+}
+
+// authenticationFailed checks whether the error was caused by the server rejecting the account, in which
+// case the user may try again.
+func authenticationFailed(err error) bool {
+	var serverErr *com.ErrorContent
+	if !errors.As(err, &serverErr) {
+		return false
+	}
+	switch serverErr.Code {
+	case com.CodeAuthFailed, com.CodeNameTaken, com.CodeInvalidPassword, com.CodeInvalidName:
+		log.Printf("Account was not accepted (%s).", serverErr.Message)
+		return true
+	case com.CodeInvalidMessage, com.CodeUnsupportedMessage, com.CodeUnexpectedMessage, com.CodeInvalidSelection,
+		com.CodeSessionFailed, com.CodeRoundTimeout, com.CodeOpponentLeft, com.CodeResumeFailed,
+		com.CodeRoomNotFound, com.CodeSessionNotFound, com.CodeSessionClosed, com.CodeInvalidBot,
+		com.CodeAuthRequired:
+	}
+	return false
+}
+
+// Connected contains the logic when the client has been connected but not yet joined. A client which has
+// logged in to a player account joins with the name of the account.
+func Connected(ctx context.Context, c Context) (State, error) {
+	name := c.Name
+	if c.Account.Name != "" {
+		name = c.Account.Name
 	}
-	if len(name) > NameMaxLength {
-		return nil, ErrNameTooLong
+	if name == "" {
+		log.Printf("Enter your name:")
+		input, err := c.Input.ReadLine(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read user input to as username. %w", err)
+		}
+		name = input
 	}
-	if err := com.WriteMessage(ctx.Conn, com.TypeJoin, com.JoinContent{Name: name}); err != nil {
+	if err := game.ValidateName(name); err != nil {
+		return nil, fmt.Errorf("failed to validate username. %w", err)
+	}
+	switch {
+	case c.Tournament:
+		if err := com.WriteMessage(c.Conn, com.TypeTournamentJoin, com.TournamentJoinContent{Name: name}); err != nil {
+			return nil, fmt.Errorf("failed to write TOURNAMENT_JOIN message. %w", err)
+		}
+		log.Printf("Registered to the tournament as %q.", name)
+		return Bracket, nil
+	case c.CreateRoom:
+		if err := com.WriteMessage(c.Conn, com.TypeCreateRoom, com.CreateRoomContent{Name: name}); err != nil {
+			return nil, fmt.Errorf("failed to write CREATE_ROOM message. %w", err)
+		}
+		log.Printf("Joined the game as %q.", name)
+		return Hosting, nil
+	case c.RoomCode != "":
+		content := com.JoinRoomContent{Name: name, Code: c.RoomCode}
+		if err := com.WriteMessage(c.Conn, com.TypeJoinRoom, content); err != nil {
+			return nil, fmt.Errorf("failed to write JOIN_ROOM message. %w", err)
+		}
+		log.Printf("Joined the game as %q in room %q.", name, c.RoomCode)
+		return Joined, nil
+	}
+	if err := com.WriteMessage(c.Conn, com.TypeJoin, com.JoinContent{Name: name, Bot: c.Bot}); err != nil {
 		return nil, fmt.Errorf("failed to write JOIN message. %w", err)
 	}
+	if c.Bot != "" {
+		log.Printf("Joined the game as %q against %q bots.", name, c.Bot)
+		return Joined, nil
+	}
 	log.Printf("Joined the game as %q.", name)
 	return Joined, nil
 }
 
+// Hosting contains the logic when the client has asked the server to create a private room.
+func Hosting(ctx context.Context, c Context) (State, error) {
+	message, err := receive[com.RoomCreatedContent](c.Conn, com.TypeRoomCreated)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Created private room %q. Share the code with your opponent.", message.Code)
+	return Joined, nil
+}
+
 // Joined contains the logic when the client has been joined but game session round is not yet started.
-func Joined(ctx Context) (State, error) {
+func Joined(ctx context.Context, c Context) (State, error) {
 	log.Printf("Waiting for an opponent. Please wait...")
-	message, err := com.ReadMessage[com.StartContent](ctx.Conn)
+	message, err := receive[com.StartContent](c.Conn, com.TypeStart)
+	if sessionClosed(err) {
+		return Lobby, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to read START message. %w", err)
+		return nil, err
 	}
-	log.Printf("Opponent %q joined the game.", message.OpponentName)
-	return Started, nil
+	return start(c, message)
 }
 
-// Started contains the logic when the game session round has been started.
-func Started(ctx Context) (State, error) {
-	log.Println("Please type the selection ('r', 'p', 's') and press enter")
-	selection, err := waitSelection(ctx.Input)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read selection. %w", err)
+// Started contains the logic when the game session round has been started. An autoplaying client lets its
+// strategy make the selection instead of the user.
+func Started(ctx context.Context, c Context) (State, error) {
+	selection := game.SelectionNone
+	if c.Autoplay != nil {
+		selection = c.Autoplay.Strategy.Select(c.Match.Rules, c.Match.History)
+		log.Printf("Selected %q with the %s strategy.", selection, c.Autoplay.Strategy)
+	} else {
+		log.Printf("Please type the selection (%s) and press enter", describeOptions(c.Match.Rules))
+		stop := countdown(c.Match.RoundTimeout)
+		input, err := waitSelection(ctx, c.Input, c.Match.Rules)
+		stop()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read selection. %w", err)
+		}
+		selection = input
+	}
+	c.Match.Selection = selection
+	if c.Match.CommitReveal {
+		return commit(c)
 	}
-	if err := com.WriteMessage(ctx.Conn, com.TypeSelect, com.SelectContent{Selection: selection}); err != nil {
+	content := com.SelectContent{Round: c.Match.Round, Selection: selection}
+	if err := com.WriteMessage(c.Conn, com.TypeSelect, content); err != nil {
 		return nil, fmt.Errorf("failed to write SELECT message. %w", err)
 	}
 	return Waiting, nil
 }
 
+// commit sends a commitment of the selection with a new nonce, which is kept until the selection is revealed.
+func commit(c Context) (State, error) {
+	nonce, err := com.NewNonce()
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit to selection. %w", err)
+	}
+	c.Match.Nonce = nonce
+	content := com.CommitContent{Round: c.Match.Round, Commitment: com.Commit(c.Match.Selection, nonce)}
+	if err := com.WriteMessage(c.Conn, com.TypeCommit, content); err != nil {
+		return nil, fmt.Errorf("failed to write COMMIT message. %w", err)
+	}
+	return Waiting, nil
+}
+
+// reveal sends the selection and the nonce of the commitment once every player has committed.
+func reveal(c Context) (State, error) {
+	content := com.RevealContent{Round: c.Match.Round, Selection: c.Match.Selection, Nonce: c.Match.Nonce}
+	if err := com.WriteMessage(c.Conn, com.TypeReveal, content); err != nil {
+		return nil, fmt.Errorf("failed to write REVEAL message. %w", err)
+	}
+	log.Printf("Every player has committed. Revealed %q.", c.Match.Selection)
+	return Waiting, nil
+}
+
 // Waiting contains the logic when the client waits for the server to send round results.
-func Waiting(ctx Context) (State, error) {
+func Waiting(ctx context.Context, c Context) (State, error) {
 	log.Println("Waiting for game result. Please wait...")
-	message, err := com.ReadMessage[com.ResultContent](ctx.Conn)
+	return result(c)
+}
+
+// Eliminated contains the logic when the client has been eliminated from the ongoing game and waits for the
+// remaining players to decide the game.
+func Eliminated(ctx context.Context, c Context) (State, error) {
+	log.Println("Waiting for the remaining players to decide the game. Please wait...")
+	return result(c)
+}
+
+// result receives the next round results and determines whether the client plays the next round. The
+// committed selection is revealed when the server reports that every player has committed.
+func result(c Context) (State, error) {
+	received, err := receiveAny(c.Conn, []com.MessageType{com.TypeResult, com.TypeCommitted})
+	if sessionClosed(err) {
+		return lobby(c), nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to read RESULT message. %w", err)
+		return nil, err
 	}
+	if received.Type == com.TypeCommitted {
+		return reveal(c)
+	}
+	message, err := decode[com.ResultContent](received)
+	if err != nil {
+		return nil, err
+	}
+	if message.Result != "" {
+		c.Match.History = append(c.Match.History, Round{
+			Selection:         c.Match.Selection,
+			OpponentSelection: message.OpponentSelection,
+			Result:            message.Result,
+		})
+	}
+	c.Match.Round = message.Round + 1
+	c.Match.Selection = game.SelectionNone
+	c.Match.Nonce = ""
+	c.Match.Score = message.Score
+	c.Match.OpponentScores = []int{message.OpponentScore}
+	if len(message.Opponents) > 0 {
+		c.Match.OpponentScores = make([]int, len(message.Opponents))
+		for i, opponent := range message.Opponents {
+			c.Match.OpponentScores[i] = opponent.Score
+		}
+	}
+	if message.Forfeit {
+		log.Printf("Round %d was forfeited as a selection was not made in time or revealed correctly.", message.Round)
+	}
+	selections := describeSelections(message)
 	switch message.Result {
 	case game.ResultWin:
-		log.Printf("Opponent selected %q. You win the game!", message.OpponentSelection)
-		return nil, ErrEnd
+		log.Printf("%s You win the round!", selections)
 	case game.ResultLose:
-		log.Printf("Opponent selected %q. You lose the game!", message.OpponentSelection)
-		return nil, ErrEnd
+		log.Printf("%s You lose the round!", selections)
 	case game.ResultDraw:
-		log.Printf("Opponent selected %q. It's a draw! Let's have an another round...", message.OpponentSelection)
+		log.Printf("%s It's a draw!", selections)
+	default:
+		log.Println(selections)
 	}
+	log.Printf("Score is %s (%s).", describeScores(c.Match.Scores()), c.Match.Format)
+	if c.Match.Format.Decided(c.Match.Scores()...) {
+		return Ended, nil
+	}
+	if message.Eliminated {
+		log.Println("You have been eliminated from the game.")
+		return Eliminated, nil
+	}
+	log.Println("Let's have an another round...")
 	return Started, nil
 }
 
-func waitInput(reader io.Reader) (string, error) {
-	scanner := bufio.NewScanner(reader)
-	if !scanner.Scan() {
-		return "", fmt.Errorf("failed to scan user input. %w", scanner.Err())
+// describeSelections describes the selections of the opponents who played the round. A RESULT message with a
+// single opponent describes the opponent without a name like in a two player match.
+func describeSelections(message *com.ResultContent) string {
+	if len(message.Opponents) < 2 {
+		return fmt.Sprintf("Opponent selected %q.", message.OpponentSelection)
+	}
+	selections := make([]string, 0, len(message.Opponents))
+	for _, opponent := range message.Opponents {
+		switch {
+		case opponent.Result == "":
+		case opponent.Selection == game.SelectionNone:
+			selections = append(selections, fmt.Sprintf("%q did not select", opponent.Name))
+		default:
+			selections = append(selections, fmt.Sprintf("%q selected %q", opponent.Name, opponent.Selection))
+		}
+	}
+	return strings.Join(selections, ", ") + "."
+}
+
+// Ended contains the logic when the match has been decided and the client waits for the final match result.
+func Ended(ctx context.Context, c Context) (State, error) {
+	message, err := receive[com.MatchEndContent](c.Conn, com.TypeMatchEnd)
+	if err != nil {
+		return nil, err
 	}
-	return scanner.Text(), nil
+	switch message.Result {
+	case game.ResultWin:
+		log.Printf("You win the match %d-%d!", message.Score, message.OpponentScore)
+	case game.ResultLose:
+		log.Printf("You lose the match %d-%d!", message.Score, message.OpponentScore)
+	case game.ResultDraw:
+		log.Printf("The match is a draw %d-%d!", message.Score, message.OpponentScore)
+	}
+	if c.Autoplay != nil {
+		c.Autoplay.Played++
+	}
+	if c.Match.Tournament {
+		return Bracket, nil
+	}
+	return Rematching, nil
+}
+
+// Rematching contains the logic when the match has ended and the client decides whether to play a rematch,
+// play against a new opponent or quit.
+func Rematching(ctx context.Context, c Context) (State, error) {
+	if c.Autoplay != nil {
+		return autoplay(c)
+	}
+	log.Printf("Type 'r' for a rematch against %s, 'n' for a new opponent or 'q' to quit.",
+		describeNames(c.Match.Opponents))
+	answer, err := c.Input.ReadLine(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user input for rematch. %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "r":
+		if err := com.WriteMessage(c.Conn, com.TypeRematchOffer, com.RematchOfferContent{}); err != nil {
+			return nil, fmt.Errorf("failed to write REMATCH_OFFER message. %w", err)
+		}
+		return Offered, nil
+	case "n":
+		return queue(c)
+	case "q":
+		log.Println("Thanks for playing!")
+		return nil, ErrEnd
+	}
+	return Rematching, nil
+}
+
+// Offered contains the logic when the client has offered a rematch and waits for the opponent to accept it.
+func Offered(ctx context.Context, c Context) (State, error) {
+	log.Printf("Waiting for %s to accept the rematch. Please wait...", describeNames(c.Match.Opponents))
+	message, err := receive[com.StartContent](c.Conn, com.TypeStart, com.TypeRematchOffer)
+	if sessionClosed(err) {
+		return Lobby, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return start(c, message)
+}
+
+// Lobby contains the logic when the game session has been closed and the client decides whether to play
+// against a new opponent or quit.
+func Lobby(ctx context.Context, c Context) (State, error) {
+	c.Match.ResumeToken = ""
+	if c.Autoplay != nil {
+		return autoplay(c)
+	}
+	log.Println("Type 'n' to play against a new opponent or 'q' to quit.")
+	answer, err := c.Input.ReadLine(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user input in lobby. %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "n":
+		return queue(c)
+	case "q":
+		log.Println("Thanks for playing!")
+		return nil, ErrEnd
+	}
+	return Lobby, nil
+}
+
+// Bracket contains the logic when the client plays in a tournament and waits between its matches. The client
+// reports the bracket updates until its next match is started, it has been eliminated or the tournament has
+// been decided.
+func Bracket(ctx context.Context, c Context) (State, error) {
+	message, err := receiveAny(c.Conn, []com.MessageType{com.TypeBracket, com.TypeStart})
+	if sessionClosed(err) {
+		return Bracket, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if message.Type == com.TypeStart {
+		content, err := decode[com.StartContent](message)
+		if err != nil {
+			return nil, err
+		}
+		return start(c, content)
+	}
+	content, err := decode[com.BracketContent](message)
+	if err != nil {
+		return nil, err
+	}
+	if len(content.Rounds) == 0 {
+		log.Printf("Registered players are %s. Waiting for the tournament to start...", describeNames(content.Players))
+		return Bracket, nil
+	}
+	for i, round := range content.Rounds {
+		log.Printf("Tournament round %d: %s.", i+1, describePairings(round))
+	}
+	switch {
+	case content.Champion != "":
+		log.Printf("The tournament was won by %q!", content.Champion)
+		return Lobby, nil
+	case content.Eliminated:
+		log.Println("You have been eliminated from the tournament.")
+		return Lobby, nil
+	case content.Opponent != "":
+		log.Printf("Your next match is against %q. Please wait...", content.Opponent)
+	default:
+		log.Println("Waiting for the next tournament round. Please wait...")
+	}
+	return Bracket, nil
+}
+
+// describePairings describes the pairings of a bracket round e.g. "donald" vs "mickey" ("mickey" advanced).
+func describePairings(pairings []com.BracketPairing) string {
+	descriptions := make([]string, len(pairings))
+	for i, pairing := range pairings {
+		switch {
+		case len(pairing.Players) == 2 && pairing.Players[1] == "":
+			descriptions[i] = fmt.Sprintf("%q has a bye", pairing.Players[0])
+		case pairing.Winner != "":
+			descriptions[i] = fmt.Sprintf("%s (%q advanced)", describePlayers(pairing.Players), pairing.Winner)
+		default:
+			descriptions[i] = describePlayers(pairing.Players)
+		}
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// Browsing contains the logic when the client spectates and chooses the game session to watch.
+func Browsing(ctx context.Context, c Context) (State, error) {
+	if err := com.WriteMessage(c.Conn, com.TypeSpectateList, com.SpectateListContent{}); err != nil {
+		return nil, fmt.Errorf("failed to write SPECTATE_LIST message. %w", err)
+	}
+	message, err := receive[com.SpectateSessionsContent](c.Conn, com.TypeSpectateSessions)
+	if err != nil {
+		return nil, err
+	}
+	if len(message.Sessions) == 0 {
+		log.Println("There are no active game sessions.")
+	}
+	for _, session := range message.Sessions {
+		log.Printf("Session %d: %s (round %d, score %s)", session.ID, describePlayers(session.Players), session.Round,
+			describeScores(session.Scores))
+	}
+	log.Println("Type the number of a session to watch, 'r' to refresh the list or 'q' to quit.")
+	answer, err := c.Input.ReadLine(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user input for session. %w", err)
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	switch answer {
+	case "r":
+		return Browsing, nil
+	case "q":
+		log.Println("Thanks for watching!")
+		return nil, ErrEnd
+	}
+	id, err := strconv.Atoi(answer)
+	if err != nil {
+		log.Printf("Session number %q is not valid.", answer)
+		return Browsing, nil
+	}
+	if err := com.WriteMessage(c.Conn, com.TypeSpectate, com.SpectateContent{SessionID: id}); err != nil {
+		return nil, fmt.Errorf("failed to write SPECTATE message. %w", err)
+	}
+	return Watching, nil
+}
+
+// Watching contains the logic when the client spectates a game session and reports the events of its matches.
+// The client returns to browse the sessions when the watched session gets closed.
+func Watching(ctx context.Context, c Context) (State, error) {
+	message, err := receiveAny(c.Conn, []com.MessageType{com.TypeSpectateStart, com.TypeSpectateRound,
+		com.TypeSpectateEnd})
+	var serverErr *com.ErrorContent
+	if errors.As(err, &serverErr) &&
+		(serverErr.Code == com.CodeSessionClosed || serverErr.Code == com.CodeSessionNotFound) {
+		log.Printf("Stopped watching the game session (%s).", serverErr.Message)
+		return Browsing, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if message.Type == com.TypeSpectateStart {
+		content, err := decode[com.SpectateStartContent](message)
+		if err != nil {
+			return nil, err
+		}
+		*c.Spectated = Spectated{
+			SessionID: content.Session.ID,
+			Players:   content.Session.Players,
+			Format:    content.Format,
+		}
+		log.Printf("Watching session %d: %s played as %s with %s rules (round %d, score %s).",
+			content.Session.ID, describePlayers(content.Session.Players), content.Format, content.Rules,
+			content.Session.Round, describeScores(content.Session.Scores))
+		return Watching, nil
+	}
+	if message.Type == com.TypeSpectateRound {
+		content, err := decode[com.SpectateRoundContent](message)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Round %d: %s.", content.Round, describeSpectatedSelections(c, content.Selections))
+		if content.Forfeit {
+			log.Printf("Round %d was forfeited as a selection was not made in time.", content.Round)
+		}
+		log.Printf("%s Score is %s (%s).", describeSpectatedResult(c, content.Results, "round"),
+			describeScores(content.Scores), c.Spectated.Format)
+		return Watching, nil
+	}
+	content, err := decode[com.SpectateEndContent](message)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("%s Final score is %s.", describeSpectatedResult(c, content.Results, "match"),
+		describeScores(content.Scores))
+	return Watching, nil
+}
+
+// describeSpectatedSelections describes the selections of the players who played the round for spectators.
+func describeSpectatedSelections(c Context, selections []game.Selection) string {
+	descriptions := make([]string, 0, len(selections))
+	for i, selection := range selections {
+		if i < len(c.Spectated.Players) && selection != game.SelectionNone {
+			descriptions = append(descriptions, fmt.Sprintf("%q selected %q", c.Spectated.Players[i], selection))
+		}
+	}
+	return strings.Join(descriptions, " and ")
+}
+
+// describeSpectatedResult describes the results, which are in the player order, for spectators.
+func describeSpectatedResult(c Context, results []game.Result, of string) string {
+	winners := make([]string, 0, len(results))
+	for i, result := range results {
+		if i < len(c.Spectated.Players) && result == game.ResultWin {
+			winners = append(winners, c.Spectated.Players[i])
+		}
+	}
+	switch len(winners) {
+	case 0:
+		return fmt.Sprintf("The %s is a draw!", of)
+	case 1:
+		return fmt.Sprintf("%s wins the %s!", describeNames(winners), of)
+	}
+	return fmt.Sprintf("%s win the %s!", describeNames(winners), of)
+}
+
+// describeNames describes the names for the logs e.g. "donald", "mickey" and "goofy".
+func describeNames(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = strconv.Quote(name)
+	}
+	if len(quoted) < 2 {
+		return strings.Join(quoted, "")
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " and " + quoted[len(quoted)-1]
+}
+
+// describePlayers describes the players of a game session for the logs e.g. "donald" vs "mickey".
+func describePlayers(players []string) string {
+	quoted := make([]string, len(players))
+	for i, player := range players {
+		quoted[i] = strconv.Quote(player)
+	}
+	return strings.Join(quoted, " vs ")
+}
+
+// describeScores describes the scores for the logs e.g. "2-1-0".
+func describeScores(scores []int) string {
+	descriptions := make([]string, len(scores))
+	for i, score := range scores {
+		descriptions[i] = strconv.Itoa(score)
+	}
+	return strings.Join(descriptions, "-")
+}
+
+// lobby returns the state where the client continues after its game session has been closed.
+func lobby(c Context) State {
+	if c.Match.Tournament {
+		return Bracket
+	}
+	return Lobby
+}
+
+// autoplay plays against a new opponent unless the autoplaying client has played every match it should play.
+func autoplay(c Context) (State, error) {
+	if c.Autoplay.Done() {
+		log.Printf("Played %d matches. Thanks for playing!", c.Autoplay.Played)
+		return nil, ErrEnd
+	}
+	return queue(c)
+}
+
+func queue(c Context) (State, error) {
+	if err := com.WriteMessage(c.Conn, com.TypeQueue, com.QueueContent{}); err != nil {
+		return nil, fmt.Errorf("failed to write QUEUE message. %w", err)
+	}
+	return Joined, nil
+}
+
+// sessionClosed checks whether the error is a server reported error which closed the game session and
+// returned the client back to the lobby.
+func sessionClosed(err error) bool {
+	var serverErr *com.ErrorContent
+	if !errors.As(err, &serverErr) {
+		return false
+	}
+	switch serverErr.Code {
+	case com.CodeOpponentLeft, com.CodeRoundTimeout, com.CodeSessionFailed:
+		log.Printf("Game session was closed (%s).", serverErr.Message)
+		return true
+	case com.CodeInvalidMessage, com.CodeUnsupportedMessage, com.CodeUnexpectedMessage, com.CodeInvalidName,
+		com.CodeInvalidSelection, com.CodeResumeFailed, com.CodeRoomNotFound, com.CodeSessionNotFound,
+		com.CodeSessionClosed, com.CodeInvalidBot, com.CodeAuthRequired, com.CodeAuthFailed, com.CodeNameTaken,
+		com.CodeInvalidPassword:
+	}
+	return false
+}
+
+// start resets the match state with the settings from the START message and begins the first round.
+func start(c Context, message *com.StartContent) (State, error) {
+	if err := setMatch(c, message); err != nil {
+		return nil, err
+	}
+	if len(c.Match.Opponents) == 1 {
+		log.Printf("Opponent %s joined the game.", describeNames(c.Match.Opponents))
+	} else {
+		log.Printf("Opponents %s joined the game.", describeNames(c.Match.Opponents))
+	}
+	log.Printf("The match is played as %s with %s rules.", message.Format, c.Match.Rules)
+	return Started, nil
+}
+
+func setMatch(c Context, message *com.StartContent) error {
+	rules, err := game.NewRuleSet(message.Rules, message.Options)
+	if err != nil {
+		return fmt.Errorf("failed to build rule set from START message. %w", err)
+	}
+	opponents := message.Opponents
+	if len(opponents) == 0 {
+		opponents = []string{message.OpponentName}
+	}
+	*c.Match = Match{
+		Opponents:      opponents,
+		Format:         message.Format,
+		Rules:          rules,
+		RoundTimeout:   message.RoundTimeout,
+		Round:          1,
+		Score:          0,
+		OpponentScores: make([]int, len(opponents)),
+		ResumeToken:    message.ResumeToken,
+		Tournament:     message.Tournament,
+		Selection:      game.SelectionNone,
+		History:        nil,
+		CommitReveal:   message.CommitReveal,
+		Nonce:          "",
+	}
+	return nil
+}
+
+// receive reads the next message of the given type from the connection while skipping the given ignored
+// message types. See receiveAny for the handling of the ERROR and SHUTDOWN messages.
+func receive[T any](conn io.Reader, messageType com.MessageType, ignored ...com.MessageType) (*T, error) {
+	message, err := receiveAny(conn, []com.MessageType{messageType}, ignored...)
+	if err != nil {
+		return nil, err
+	}
+	return decode[T](message)
+}
+
+// receiveAny reads the next message of any of the given types from the connection while skipping the given
+// ignored message types. An ERROR message is returned as an error. A SHUTDOWN notice is only logged so that
+// the ongoing round can still be finished within the grace period, but a closed connection after the notice
+// is reported as ErrShutdown.
+func receiveAny(conn io.Reader, types []com.MessageType, ignored ...com.MessageType) (*com.Message, error) {
+	messageType := describeTypes(types)
+	shutdown := false
+	for {
+		message, err := com.Read[com.Message](conn)
+		if err != nil {
+			if shutdown {
+				return nil, fmt.Errorf("%w. %s", ErrShutdown, err)
+			}
+			return nil, fmt.Errorf("failed to read %s message. %w", messageType, err)
+		}
+		if message.Type == com.TypeShutdown {
+			content, err := decode[com.ShutdownContent](message)
+			if err != nil {
+				return nil, err
+			}
+			log.Printf("Server is shutting down (%s). The connection will be closed in %s.", content.Reason, content.Grace)
+			shutdown = true
+			continue
+		}
+		if message.Type == com.TypeError {
+			content, err := decode[com.ErrorContent](message)
+			if err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("server reported an error. %w", content)
+		}
+		if contains(ignored, message.Type) {
+			log.Printf("Skipped %s message while waiting for %s.", message.Type, messageType)
+			continue
+		}
+		if !contains(types, message.Type) {
+			return nil, fmt.Errorf("%w: %s", ErrUnexpectedMessage, message.Type)
+		}
+		return message, nil
+	}
+}
+
+func describeTypes(types []com.MessageType) string {
+	names := make([]string, len(types))
+	for i, messageType := range types {
+		names[i] = string(messageType)
+	}
+	return strings.Join(names, "/")
+}
+
+func contains(types []com.MessageType, messageType com.MessageType) bool {
+	for _, t := range types {
+		if t == messageType {
+			return true
+		}
+	}
+	return false
+}
+
+func decode[T any](message *com.Message) (*T, error) {
+	content := new(T)
+	if err := json.Unmarshal(message.Content, content); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s message content. %w", message.Type, err)
+	}
+	return content, nil
+}
+
+// waitPassword waits for the next line of user input with the input hidden while it is typed when the
+// client context supports it.
+func waitPassword(ctx context.Context, c Context) (string, error) {
+	if c.HideInput != nil {
+		restore, err := c.HideInput()
+		if err != nil {
+			log.Printf("Failed to hide the password input. %s", err)
+		} else {
+			defer restore()
+		}
+	}
+	return c.Input.ReadLine(ctx)
+}
+
+func describeOptions(rules game.RuleSet) string {
+	options := make([]string, len(rules.Options))
+	for i, option := range rules.Options {
+		options[i] = fmt.Sprintf("'%s'=%s", option.Selection, option.Name)
+	}
+	return strings.Join(options, ", ")
+}
+
+// countdown periodically reports the time left to make a selection until the returned stop function is called.
+func countdown(timeout time.Duration) func() {
+	if timeout <= 0 {
+		return func() {}
+	}
+	deadline := time.Now().Add(timeout)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(countdownInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				if left := deadline.Sub(now).Round(time.Second); left > 0 {
+					log.Printf("%s left to make a selection.", left)
+				} else {
+					log.Printf("Time is up! The round will be forfeited.")
+					return
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
 }
 
-func waitSelection(reader io.Reader) (game.Selection, error) {
-	input, err := waitInput(reader)
+func waitSelection(ctx context.Context, input *Input, rules game.RuleSet) (game.Selection, error) {
+	line, err := input.ReadLine(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to scan user input for selection. %w", err)
 	}
-	selection := game.Selection(input)
-	if err := game.ValidateSelection(selection); err != nil {
+	selection := game.Selection(line)
+	if err := rules.Validate(selection); err != nil {
 		return "", fmt.Errorf("failed to validate selection. %w", err)
 	}
 	return selection, nil
@@ -1,11 +1,19 @@
 package client_test
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/toivjon/go-rps/internal/client"
+	"github.com/toivjon/go-rps/internal/com"
 	"github.com/toivjon/go-rps/internal/game"
 )
 
@@ -13,61 +21,1209 @@ var errMock = errors.New("mock-error")
 
 func TestRun(t *testing.T) {
 	t.Parallel()
-	ctx := client.NewContext(new(readerMock), new(readWriterMock))
+	ctx := client.NewContext(new(readerMock), newWritableConnMock(nil))
 	t.Run("ReturnErrorWhenStateFails", func(t *testing.T) {
 		t.Parallel()
-		state := func(client.Context) (client.State, error) { return nil, errMock }
-		if err := client.Run(ctx, state); !errors.Is(err, errMock) {
+		state := func(context.Context, client.Context) (client.State, error) { return nil, errMock }
+		if err := client.Run(context.Background(), ctx, state); !errors.Is(err, errMock) {
 			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
 		}
 	})
 	t.Run("ReturnNilWhenSuccessAfterOneIteration", func(t *testing.T) {
 		t.Parallel()
-		state := func(client.Context) (client.State, error) { return nil, client.ErrEnd }
-		if err := client.Run(ctx, state); err != nil {
+		state := func(context.Context, client.Context) (client.State, error) { return nil, client.ErrEnd }
+		if err := client.Run(context.Background(), ctx, state); err != nil {
 			t.Fatalf("Expected nil error, but %q was returned!", err)
 		}
 	})
+	t.Run("ReturnErrorWhenContextIsDone", func(t *testing.T) {
+		t.Parallel()
+		conn, _ := net.Pipe()
+		cancelCtx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := client.Run(cancelCtx, client.NewContext(new(readerMock), conn), client.Handshaking)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", context.Canceled, err)
+		}
+	})
+	t.Run("ReturnNilWhenServerShutsDown", func(t *testing.T) {
+		t.Parallel()
+		state := func(context.Context, client.Context) (client.State, error) { return nil, client.ErrShutdown }
+		if err := client.Run(context.Background(), ctx, state); err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+	t.Run("ResumeSessionWhenConnectionIsLost", func(t *testing.T) {
+		t.Parallel()
+		resumeCtx := client.NewContext(succeedingReaderMock("q"), newWritableConnMock(nil))
+		resumeCtx.Match.ResumeToken = "token"
+		resumeCtx.Dial = func(context.Context) (io.ReadWriter, error) {
+			return newFramedConnMock(
+				`{"type":"WELCOME","content":{"version":1}}`,
+				`{"type":"RESUMED","content":{"start":{"opponentName":"donald","format":{"name":"best of 1",`+
+					`"winsNeeded":1},"rules":"classic","options":[{"selection":"r","name":"rock"},`+
+					`{"selection":"p","name":"paper"},{"selection":"s","name":"scissors"}],"resumeToken":"token"},`+
+					`"round":1,"selection":"r"}}`,
+				`{"type":"RESULT","content":{"round":1,"opponentSelection":"s","result":"WIN","score":1}}`,
+				`{"type":"MATCH_END","content":{"result":"WIN","score":1}}`,
+			), nil
+		}
+		state := func(context.Context, client.Context) (client.State, error) { return nil, io.EOF }
+		if err := client.Run(context.Background(), resumeCtx, state); err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+		if resumeCtx.Match.Score != 1 {
+			t.Fatalf("Expected resumed match to be played, but score was %d!", resumeCtx.Match.Score)
+		}
+	})
+	t.Run("ReturnErrorWhenResumeIsRejected", func(t *testing.T) {
+		t.Parallel()
+		resumeCtx := client.NewContext(new(readerMock), newWritableConnMock(nil))
+		resumeCtx.Match.ResumeToken = "token"
+		resumeCtx.Dial = func(context.Context) (io.ReadWriter, error) {
+			return newFramedConnMock(
+				`{"type":"WELCOME","content":{"version":1}}`,
+				`{"type":"ERROR","content":{"code":"RESUME_FAILED","message":"expired"}}`,
+			), nil
+		}
+		state := func(context.Context, client.Context) (client.State, error) { return nil, io.EOF }
+		err := client.Run(context.Background(), resumeCtx, state)
+		var serverErr *com.ErrorContent
+		if !errors.As(err, &serverErr) || serverErr.Code != com.CodeResumeFailed {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", com.CodeResumeFailed, err)
+		}
+	})
+	t.Run("ReturnErrorWhenContextIsDoneDuringReconnect", func(t *testing.T) {
+		t.Parallel()
+		resumeCtx := client.NewContext(new(readerMock), newWritableConnMock(nil))
+		resumeCtx.Match.ResumeToken = "token"
+		resumeCtx.Dial = func(context.Context) (io.ReadWriter, error) { return nil, errMock }
+		timeoutCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		state := func(context.Context, client.Context) (client.State, error) { return nil, io.EOF }
+		if err := client.Run(timeoutCtx, resumeCtx, state); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", context.DeadlineExceeded, err)
+		}
+	})
 	t.Run("ReturnNilWhenSuccessAfterTwoIterations", func(t *testing.T) {
 		t.Parallel()
-		state1 := func(client.Context) (client.State, error) { return nil, client.ErrEnd }
-		state2 := func(client.Context) (client.State, error) { return state1, nil }
-		if err := client.Run(ctx, state2); err != nil {
+		state1 := func(context.Context, client.Context) (client.State, error) { return nil, client.ErrEnd }
+		state2 := func(context.Context, client.Context) (client.State, error) { return state1, nil }
+		if err := client.Run(context.Background(), ctx, state2); err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+}
+
+//nolint:funlen
+func TestHandshaking(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnServerErrorWhenErrorIsReceived", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"ERROR","content":{"code":"OPPONENT_LEFT","message":"bye"}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Handshaking(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		var serverErr *com.ErrorContent
+		if !errors.As(err, &serverErr) {
+			t.Fatalf("Expected %T error in the chain %q, but did not exists!", serverErr, err)
+		}
+	})
+	t.Run("ReturnStateWhenSpectatorIsWelcomed", func(t *testing.T) {
+		t.Parallel()
+		data := fmt.Sprintf(`{"type":"WELCOME","content":{"version":%d}}`, com.ProtocolVersion)
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		ctx.Spectate = true
+		result, err := client.Handshaking(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+	t.Run("ReturnStateWhenAccountIsRequired", func(t *testing.T) {
+		t.Parallel()
+		data := fmt.Sprintf(`{"type":"WELCOME","content":{"version":%d,"accountRequired":true}}`, com.ProtocolVersion)
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Handshaking(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+	t.Run("ReturnErrorWhenWriteMessageFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(new(readerMock), newWritableConnMock(errMock))
+		result, err := client.Handshaking(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnErrorWhenReadFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(new(readerMock), newReadableConnMock("", errMock))
+		result, err := client.Handshaking(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnErrorWhenUnmarshalFails", func(t *testing.T) {
+		t.Parallel()
+		for _, data := range []string{
+			`{"type":"WELCOME","content":"non-json"}`,
+			`{"type":"REJECT","content":"non-json"}`,
+			`{"type":"ERROR","content":"non-json"}`,
+		} {
+			ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+			result, err := client.Handshaking(context.Background(), ctx)
+			if result != nil {
+				t.Fatalf("Expected nil result, but %v was returned!", result)
+			}
+			if err == nil {
+				t.Fatal("Expected non-nil error, but nil was returned!")
+			}
+		}
+	})
+	t.Run("ReturnErrorWhenRejected", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"REJECT","content":{"reason":"too old","minVersion":2,"maxVersion":3}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Handshaking(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, client.ErrRejected) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", client.ErrRejected, err)
+		}
+	})
+	t.Run("ReturnErrorWhenVersionIsUnsupported", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"WELCOME","content":{"version":0}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Handshaking(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, client.ErrUnsupportedVersion) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", client.ErrUnsupportedVersion, err)
+		}
+	})
+	t.Run("ReturnErrorWhenMessageIsUnexpected", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"START","content":{}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Handshaking(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, client.ErrUnexpectedMessage) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", client.ErrUnexpectedMessage, err)
+		}
+	})
+	t.Run("ReturnShutdownWhenServerShutsDown", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"SHUTDOWN","content":{"reason":"maintenance","grace":0}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Handshaking(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, client.ErrShutdown) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", client.ErrShutdown, err)
+		}
+	})
+	t.Run("ReturnStateWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"WELCOME","content":{"version":1,"capabilities":[]}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Handshaking(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+	})
+}
+
+func TestResuming(t *testing.T) {
+	t.Parallel()
+	start := `"start":{"opponentName":"donald","format":{"name":"best of 1","winsNeeded":1},"rules":"classic",` +
+		`"options":[{"selection":"r","name":"rock"},{"selection":"p","name":"paper"},` +
+		`{"selection":"s","name":"scissors"}],"resumeToken":"token"}`
+	t.Run("ReturnErrorWhenWriteMessageFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(new(readerMock), newWritableConnMock(errMock))
+		result, err := client.Resuming(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnErrorWhenReadFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(new(readerMock), newReadableConnMock("", errMock))
+		result, err := client.Resuming(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnErrorWhenRuleSetIsInvalid", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"RESUMED","content":{"start":{"rules":"classic","options":[]}}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Resuming(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, game.ErrInvalidRuleSet) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", game.ErrInvalidRuleSet, err)
+		}
+	})
+	t.Run("ReturnStateWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		for _, state := range []string{
+			`"round":2,"score":1`,
+			`"round":2,"selection":"r"`,
+			`"round":1,"score":1,"ended":true`,
+			`"round":1,"score":1,"ended":true,"offered":true`,
+			`"round":3,"eliminated":true,"opponentScore":1,"opponentScores":[1,0]`,
+		} {
+			data := `{"type":"RESUMED","content":{` + start + `,` + state + `}}`
+			ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+			result, err := client.Resuming(context.Background(), ctx)
+			if result == nil {
+				t.Fatal("Expected non-nil result, but nil was returned!")
+			}
+			if err != nil {
+				t.Fatalf("Expected nil error, but %q was returned!", err)
+			}
+			if ctx.Match.ResumeToken != "token" || len(ctx.Match.Opponents) != 1 || ctx.Match.Opponents[0] != "donald" {
+				t.Fatalf("Expected match to be restored, but was %+v!", ctx.Match)
+			}
+		}
+	})
+	t.Run("ReturnStateWhenCommitmentIsResumed", func(t *testing.T) {
+		t.Parallel()
+		commitment := com.Commit(game.SelectionRock, "nonce")
+		data := `{"type":"RESUMED","content":{` + start + `,"round":1,"commitment":"` + commitment + `"}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		ctx.Match.Selection = game.SelectionRock
+		ctx.Match.Nonce = "nonce"
+		result, err := client.Resuming(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+		if ctx.Match.Selection != game.SelectionRock || ctx.Match.Nonce != "nonce" {
+			t.Fatalf("Expected committed selection to be kept, but was %q with %q!", ctx.Match.Selection, ctx.Match.Nonce)
+		}
+	})
+}
+
+//nolint:funlen
+func TestAuthenticating(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenInputScanningFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(failingReaderMock(errMock), newWritableConnMock(nil))
+		result, err := client.Authenticating(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnStateWhenInputIsInvalid", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(succeedingReaderMock("x"), newWritableConnMock(errMock))
+		result, err := client.Authenticating(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+	t.Run("ReturnErrorWhenWriteMessageFails", func(t *testing.T) {
+		t.Parallel()
+		for _, input := range []string{"l", "r"} {
+			ctx := client.NewContext(succeedingReaderMock(input), newWritableConnMock(errMock))
+			result, err := client.Authenticating(context.Background(), ctx)
+			if result != nil {
+				t.Fatalf("Expected nil result, but %v was returned!", result)
+			}
+			if !errors.Is(err, errMock) {
+				t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+			}
+		}
+	})
+	t.Run("ReturnStateWhenAccountIsRejected", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"ERROR","content":{"code":"AUTH_FAILED","message":"invalid player name or password"}}`
+		ctx := client.NewContext(succeedingReaderMock("password"), newReadableConnMock(data, nil))
+		ctx.Name = "donald"
+		ctx.Account.Login = true
+		result, err := client.Authenticating(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+		if ctx.Account.Name != "" {
+			t.Fatalf("Expected client to not be logged in, but was logged in as %q!", ctx.Account.Name)
+		}
+	})
+	t.Run("ReturnServerErrorWhenErrorIsReceived", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"ERROR","content":{"code":"UNEXPECTED_MESSAGE","message":"client has already joined"}}`
+		ctx := client.NewContext(succeedingReaderMock("password"), newReadableConnMock(data, nil))
+		ctx.Name = "donald"
+		ctx.Account.Login = true
+		result, err := client.Authenticating(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		var serverErr *com.ErrorContent
+		if !errors.As(err, &serverErr) {
+			t.Fatalf("Expected %T error in the chain %q, but did not exists!", serverErr, err)
+		}
+	})
+	t.Run("ReturnStateWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		for _, register := range []bool{false, true} {
+			data := `{"type":"AUTHENTICATED","content":{"name":"donald","token":"token"}}`
+			ctx := client.NewContext(succeedingReaderMock("password"), newReadableConnMock(data, nil))
+			ctx.Name = "donald"
+			ctx.Account.Login = !register
+			ctx.Account.Register = register
+			hidden, restored := false, false
+			ctx.HideInput = func() (func(), error) {
+				hidden = true
+				return func() { restored = true }, nil
+			}
+			result, err := client.Authenticating(context.Background(), ctx)
+			if result == nil {
+				t.Fatal("Expected non-nil result, but nil was returned!")
+			}
+			if err != nil {
+				t.Fatalf("Expected nil error, but %q was returned!", err)
+			}
+			if ctx.Account.Name != "donald" || ctx.Account.Token != "token" {
+				t.Fatalf("Expected account to be stored, but was %+v!", ctx.Account)
+			}
+			if !hidden || !restored {
+				t.Fatal("Expected password input to be hidden and restored, but it was not!")
+			}
+		}
+	})
+	t.Run("ReadPasswordWhenInputCannotBeHidden", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"AUTHENTICATED","content":{"name":"donald","token":"token"}}`
+		ctx := client.NewContext(succeedingReaderMock("password"), newReadableConnMock(data, nil))
+		ctx.Name = "donald"
+		ctx.Account.Login = true
+		ctx.HideInput = func() (func(), error) { return nil, errMock }
+		result, err := client.Authenticating(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+}
+
+func TestConnected(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenInputScanningFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(failingReaderMock(errMock), newWritableConnMock(nil))
+		result, err := client.Connected(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %s in the chain, but did not exists!", errMock)
+		}
+	})
+	t.Run("ReturnErrorWhenInputIsCancelled", func(t *testing.T) {
+		t.Parallel()
+		input, _ := io.Pipe()
+		cancelCtx, cancel := context.WithCancel(context.Background())
+		cancel()
+		result, err := client.Connected(cancelCtx, client.NewContext(input, newWritableConnMock(nil)))
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", context.Canceled, err)
+		}
+	})
+	t.Run("ReturnErrorWhenInputValidationFails", func(t *testing.T) {
+		t.Parallel()
+		name := strings.Repeat("s", game.NameMaxLength+1)
+		ctx := client.NewContext(succeedingReaderMock(name), newWritableConnMock(nil))
+		result, err := client.Connected(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, game.ErrNameTooLong) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", game.ErrNameTooLong, err)
+		}
+	})
+	t.Run("ReturnErrorWhenWriteMessageFails", func(t *testing.T) {
+		t.Parallel()
+		name := strings.Repeat("s", game.NameMaxLength)
+		ctx := client.NewContext(succeedingReaderMock(name), newWritableConnMock(errMock))
+		result, err := client.Connected(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnStateWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		name := strings.Repeat("s", game.NameMaxLength)
+		ctx := client.NewContext(succeedingReaderMock(name), newWritableConnMock(nil))
+		result, err := client.Connected(context.Background(), ctx)
+		if result == nil {
+			t.Fatalf("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+	t.Run("ReturnErrorWhenRoomMessageWriteFails", func(t *testing.T) {
+		t.Parallel()
+		for _, room := range []struct {
+			code   string
+			create bool
+		}{{code: "", create: true}, {code: "ABC123", create: false}} {
+			ctx := client.NewContext(succeedingReaderMock("donald"), newWritableConnMock(errMock))
+			ctx.RoomCode = room.code
+			ctx.CreateRoom = room.create
+			result, err := client.Connected(context.Background(), ctx)
+			if result != nil {
+				t.Fatalf("Expected nil result, but %v was returned!", result)
+			}
+			if !errors.Is(err, errMock) {
+				t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+			}
+		}
+	})
+	t.Run("ReturnStateWhenRoomMessageIsSent", func(t *testing.T) {
+		t.Parallel()
+		for _, room := range []struct {
+			code   string
+			create bool
+		}{{code: "", create: true}, {code: "ABC123", create: false}} {
+			ctx := client.NewContext(succeedingReaderMock("donald"), newWritableConnMock(nil))
+			ctx.RoomCode = room.code
+			ctx.CreateRoom = room.create
+			result, err := client.Connected(context.Background(), ctx)
+			if result == nil {
+				t.Fatalf("Expected non-nil result, but nil was returned!")
+			}
+			if err != nil {
+				t.Fatalf("Expected nil error, but %q was returned!", err)
+			}
+		}
+	})
+	t.Run("ReturnErrorWhenTournamentJoinWriteFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(succeedingReaderMock("donald"), newWritableConnMock(errMock))
+		ctx.Tournament = true
+		result, err := client.Connected(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnStateWhenTournamentJoinIsSent", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(succeedingReaderMock("donald"), newWritableConnMock(nil))
+		ctx.Tournament = true
+		result, err := client.Connected(context.Background(), ctx)
+		if result == nil {
+			t.Fatalf("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+	t.Run("ReturnStateWhenJoinAgainstBotsIsSent", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(succeedingReaderMock("donald"), newWritableConnMock(nil))
+		ctx.Bot = "markov"
+		result, err := client.Connected(context.Background(), ctx)
+		if result == nil {
+			t.Fatalf("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+	t.Run("ReturnStateWhenLoggedIn", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(failingReaderMock(errMock), newWritableConnMock(nil))
+		ctx.Account.Name = "donald"
+		result, err := client.Connected(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+	t.Run("ReturnStateWhenNameIsGiven", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(failingReaderMock(errMock), newWritableConnMock(nil))
+		ctx.Name = "random bot"
+		result, err := client.Connected(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+}
+
+func TestHosting(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenReadFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(new(readerMock), newReadableConnMock("", errMock))
+		result, err := client.Hosting(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnServerErrorWhenErrorIsReceived", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"ERROR","content":{"code":"SESSION_FAILED","message":"nope"}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Hosting(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		var serverErr *com.ErrorContent
+		if !errors.As(err, &serverErr) {
+			t.Fatalf("Expected %T error in the chain %q, but did not exists!", serverErr, err)
+		}
+	})
+	t.Run("ReturnStateWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"ROOM_CREATED","content":{"code":"ABC123"}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Hosting(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+}
+
+func TestJoined(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnStateWhenSessionIsClosed", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"ERROR","content":{"code":"OPPONENT_LEFT","message":"bye"}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Joined(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+	t.Run("ReturnServerErrorWhenErrorIsReceived", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"ERROR","content":{"code":"UNEXPECTED_MESSAGE","message":"nope"}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Joined(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		var serverErr *com.ErrorContent
+		if !errors.As(err, &serverErr) {
+			t.Fatalf("Expected %T error in the chain %q, but did not exists!", serverErr, err)
+		}
+	})
+	t.Run("ReturnErrorWhenReadFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(new(readerMock), newReadableConnMock("", errMock))
+		result, err := client.Joined(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnErrorWhenUnmarshalFails", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"JOIN","content":"non-json"}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Joined(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if err == nil {
+			t.Fatal("Expected non-nil error, but nil was returned!")
+		}
+	})
+	t.Run("ReturnErrorWhenRuleSetIsInvalid", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"START","content":{"opponentName":"donald","rules":"classic","options":[]}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Joined(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, game.ErrInvalidRuleSet) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", game.ErrInvalidRuleSet, err)
+		}
+	})
+	t.Run("ReturnStateWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"START","content":{"opponentName":"donald","format":{"name":"best of 3","winsNeeded":2},` +
+			`"rules":"rpsls","options":[{"selection":"r","name":"rock"},{"selection":"s","name":"scissors"},` +
+			`{"selection":"l","name":"lizard"},{"selection":"p","name":"paper"},{"selection":"v","name":"Spock"}],` +
+			`"roundTimeout":30000000000}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Joined(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if len(ctx.Match.Opponents) != 1 || ctx.Match.Opponents[0] != "donald" {
+			t.Fatalf("Expected opponents to be [donald], but were %v!", ctx.Match.Opponents)
+		}
+		if ctx.Match.Format != game.BestOf(3) {
+			t.Fatalf("Expected format %v, but was %v!", game.BestOf(3), ctx.Match.Format)
+		}
+		if err := ctx.Match.Rules.Validate(game.SelectionSpock); err != nil {
+			t.Fatalf("Expected rules to contain %q, but validation failed: %s", game.SelectionSpock, err)
+		}
+		if ctx.Match.RoundTimeout != 30*time.Second {
+			t.Fatalf("Expected round timeout to be 30s, but was %s!", ctx.Match.RoundTimeout)
+		}
+		if ctx.Match.Round != 1 {
+			t.Fatalf("Expected round to be 1, but was %d!", ctx.Match.Round)
+		}
+	})
+	t.Run("ReturnStateWhenManyOpponentsJoin", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"START","content":{"opponentName":"donald","opponents":["donald","mickey"],` +
+			`"format":{"name":"best of 1","winsNeeded":1},"rules":"classic","options":[{"selection":"r",` +
+			`"name":"rock"},{"selection":"s","name":"scissors"},{"selection":"p","name":"paper"}]}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Joined(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if !reflect.DeepEqual(ctx.Match.Opponents, []string{"donald", "mickey"}) {
+			t.Fatalf("Expected opponents to be [donald mickey], but were %v!", ctx.Match.Opponents)
+		}
+		if !reflect.DeepEqual(ctx.Match.OpponentScores, []int{0, 0}) {
+			t.Fatalf("Expected opponent scores to be [0 0], but were %v!", ctx.Match.OpponentScores)
+		}
+	})
+}
+
+func TestStarted(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenInputScanningFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(failingReaderMock(errMock), newWritableConnMock(nil))
+		result, err := client.Started(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %s in the chain, but did not exists!", errMock)
+		}
+	})
+	t.Run("ReturnErrorWhenInputValidationFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(succeedingReaderMock("x"), newWritableConnMock(nil))
+		result, err := client.Started(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if err == nil {
+			t.Fatal("Expected non-nil error, but nil was returned!")
+		}
+	})
+	t.Run("ReturnErrorWhenWriteMessageFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(succeedingReaderMock(string(game.SelectionPaper)), newWritableConnMock(errMock))
+		result, err := client.Started(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnStateWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(succeedingReaderMock(string(game.SelectionPaper)), newWritableConnMock(nil))
+		ctx.Match.RoundTimeout = time.Minute
+		result, err := client.Started(context.Background(), ctx)
+		if result == nil {
+			t.Fatalf("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+	t.Run("ReturnStateWhenStrategySelects", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(failingReaderMock(errMock), newWritableConnMock(nil))
+		ctx.Autoplay = &client.Autoplay{Strategy: client.CycleStrategy{}, Matches: 1, Played: 0}
+		result, err := client.Started(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+		if ctx.Match.Selection != game.SelectionRock {
+			t.Fatalf("Expected selection to be %q, but was %q!", game.SelectionRock, ctx.Match.Selection)
+		}
+	})
+	t.Run("ReturnErrorWhenCommitWriteFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(succeedingReaderMock(string(game.SelectionPaper)), newWritableConnMock(errMock))
+		ctx.Match.CommitReveal = true
+		result, err := client.Started(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnStateWhenSelectionIsCommitted", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(succeedingReaderMock(string(game.SelectionPaper)), newWritableConnMock(nil))
+		ctx.Match.CommitReveal = true
+		result, err := client.Started(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+		if ctx.Match.Selection != game.SelectionPaper || ctx.Match.Nonce == "" {
+			t.Fatalf("Expected selection and nonce to be kept, but were %q and %q!", ctx.Match.Selection, ctx.Match.Nonce)
+		}
+	})
+}
+
+func TestWaiting(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnStateWhenSessionIsClosed", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"ERROR","content":{"code":"OPPONENT_LEFT","message":"bye"}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Waiting(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+	t.Run("ReturnServerErrorWhenErrorIsReceived", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"ERROR","content":{"code":"UNEXPECTED_MESSAGE","message":"nope"}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Waiting(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		var serverErr *com.ErrorContent
+		if !errors.As(err, &serverErr) {
+			t.Fatalf("Expected %T error in the chain %q, but did not exists!", serverErr, err)
+		}
+	})
+	t.Run("ReturnErrorWhenReadFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(new(readerMock), newReadableConnMock("", errMock))
+		result, err := client.Waiting(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnErrorWhenUnmarshalFails", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"RESULT","content":"non-json"}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Waiting(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if err == nil {
+			t.Fatal("Expected non-nil error, but nil was returned!")
+		}
+	})
+	t.Run("ReturnStateWhenSuccessWithDraw", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"RESULT","content":{"opponentSelection":"s","result":"DRAW"}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Waiting(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+	})
+	t.Run("ReturnStateWhenSuccessWithUndecidedMatch", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"RESULT","content":{"opponentSelection":"s","result":"WIN","score":1,"opponentScore":0}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		ctx.Match.Format = game.BestOf(3)
+		result, err := client.Waiting(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if ctx.Match.Score != 1 || len(ctx.Match.OpponentScores) != 1 || ctx.Match.OpponentScores[0] != 0 {
+			t.Fatalf("Expected score to be 1-0, but was %d-%v!", ctx.Match.Score, ctx.Match.OpponentScores)
+		}
+	})
+	t.Run("ReturnStateWhenSuccessWithForfeit", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"RESULT","content":{"round":2,"result":"LOSE","forfeit":true,"score":0,"opponentScore":1}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		ctx.Match.Format = game.BestOf(3)
+		result, err := client.Waiting(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if ctx.Match.Round != 3 {
+			t.Fatalf("Expected next round to be 3, but was %d!", ctx.Match.Round)
+		}
+	})
+	t.Run("ReturnStateWhenSuccessWithDecidedMatch", func(t *testing.T) {
+		t.Parallel()
+		payloads := []string{
+			`{"type":"RESULT","content":{"opponentSelection":"s","result":"WIN","score":1,"opponentScore":0}}`,
+			`{"type":"RESULT","content":{"opponentSelection":"s","result":"LOSE","score":0,"opponentScore":1}}`,
+		}
+		for _, payload := range payloads {
+			ctx := client.NewContext(new(readerMock), newReadableConnMock(payload, nil))
+			result, err := client.Waiting(context.Background(), ctx)
+			if result == nil {
+				t.Fatal("Expected non-nil result, but nil was returned!")
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, but %q was returned!", err)
+			}
+		}
+	})
+	t.Run("ReturnStateWhenSuccessWithManyOpponents", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"RESULT","content":{"round":1,"opponentSelection":"p","result":"LOSE","opponentScore":0,` +
+			`"opponents":[{"name":"mickey","selection":"p","result":"WIN","score":0},` +
+			`{"name":"goofy","selection":"","result":"LOSE","score":0}],"eliminated":true}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Waiting(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if len(ctx.Match.OpponentScores) != 2 {
+			t.Fatalf("Expected scores of two opponents, but were %v!", ctx.Match.OpponentScores)
+		}
+	})
+	t.Run("ReturnStateWhenRoundIsRecorded", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"RESULT","content":{"opponentSelection":"s","result":"WIN","score":1,"opponentScore":0}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		ctx.Match.Selection = game.SelectionRock
+		result, err := client.Waiting(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		expected := client.Round{
+			Selection:         game.SelectionRock,
+			OpponentSelection: game.SelectionScissors,
+			Result:            game.ResultWin,
+		}
+		if len(ctx.Match.History) != 1 || ctx.Match.History[0] != expected {
+			t.Fatalf("Expected history to be [%+v], but was %+v!", expected, ctx.Match.History)
+		}
+		if ctx.Match.Selection != game.SelectionNone {
+			t.Fatalf("Expected selection to be reset, but was %q!", ctx.Match.Selection)
+		}
+	})
+	t.Run("ReturnErrorWhenRevealWriteFails", func(t *testing.T) {
+		t.Parallel()
+		conn := newReadableConnMock(`{"type":"COMMITTED","content":{"round":1}}`, nil)
+		conn.writerMock.err = errMock
+		ctx := client.NewContext(new(readerMock), conn)
+		result, err := client.Waiting(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnStateWhenSelectionIsRevealed", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"COMMITTED","content":{"round":1}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		ctx.Match.Selection = game.SelectionRock
+		ctx.Match.Nonce = "nonce"
+		result, err := client.Waiting(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+	})
+}
+
+func TestWaitingOnShutdown(t *testing.T) {
+	t.Parallel()
+	shutdown := `{"type":"SHUTDOWN","content":{"reason":"maintenance","grace":1000000000}}`
+	t.Run("ReturnStateWhenResultFollowsShutdown", func(t *testing.T) {
+		t.Parallel()
+		result := `{"type":"RESULT","content":{"round":1,"opponentSelection":"s","result":"DRAW"}}`
+		ctx := client.NewContext(new(readerMock), newFramedConnMock(shutdown, result))
+		state, err := client.Waiting(context.Background(), ctx)
+		if state == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+	})
+	t.Run("ReturnShutdownWhenConnectionClosesAfterShutdown", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(new(readerMock), newFramedConnMock(shutdown))
+		state, err := client.Waiting(context.Background(), ctx)
+		if state != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", state)
+		}
+		if !errors.Is(err, client.ErrShutdown) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", client.ErrShutdown, err)
+		}
+	})
+	t.Run("ReturnErrorWhenShutdownUnmarshalFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(new(readerMock), newFramedConnMock(`{"type":"SHUTDOWN","content":"non-json"}`))
+		state, err := client.Waiting(context.Background(), ctx)
+		if state != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", state)
+		}
+		if err == nil {
+			t.Fatal("Expected non-nil error, but nil was returned!")
+		}
+	})
+	t.Run("ReturnErrorWhenMessageIsUnexpected", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(new(readerMock), newFramedConnMock(`{"type":"START","content":{}}`))
+		state, err := client.Waiting(context.Background(), ctx)
+		if state != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", state)
+		}
+		if !errors.Is(err, client.ErrUnexpectedMessage) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", client.ErrUnexpectedMessage, err)
+		}
+	})
+}
+
+func TestEliminated(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenReadFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(new(readerMock), newReadableConnMock("", errMock))
+		result, err := client.Eliminated(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnStateWhenGameIsDecided", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"RESULT","content":{"round":2,"opponentSelection":"r","opponentScore":1,` +
+			`"opponents":[{"name":"mickey","selection":"r","result":"WIN","score":1},` +
+			`{"name":"goofy","selection":"s","result":"LOSE","score":0}]}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		ctx.Match.Format = game.BestOf(3)
+		result, err := client.Eliminated(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if ctx.Match.Round != 3 || len(ctx.Match.OpponentScores) != 2 || ctx.Match.OpponentScores[0] != 1 {
+			t.Fatalf("Expected round 3 with opponent scores [1 0], but was %+v!", ctx.Match)
+		}
+	})
+}
+
+func TestEnded(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenReadFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(new(readerMock), newReadableConnMock("", errMock))
+		result, err := client.Ended(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnStateWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		payloads := []string{
+			`{"type":"MATCH_END","content":{"result":"WIN","score":2,"opponentScore":1}}`,
+			`{"type":"MATCH_END","content":{"result":"LOSE","score":1,"opponentScore":2}}`,
+			`{"type":"MATCH_END","content":{"result":"DRAW","score":1,"opponentScore":1}}`,
+		}
+		for _, payload := range payloads {
+			ctx := client.NewContext(new(readerMock), newReadableConnMock(payload, nil))
+			result, err := client.Ended(context.Background(), ctx)
+			if result == nil {
+				t.Fatal("Expected non-nil result, but nil was returned!")
+			}
+			if err != nil {
+				t.Fatalf("Expected nil error, but %q was returned!", err)
+			}
+		}
+	})
+	t.Run("ReturnStateWhenTournamentMatchEnds", func(t *testing.T) {
+		t.Parallel()
+		payload := `{"type":"MATCH_END","content":{"result":"LOSE","score":1,"opponentScore":2}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(payload, nil))
+		ctx.Match.Tournament = true
+		result, err := client.Ended(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+	t.Run("ReturnStateWhenAutoplayedMatchEnds", func(t *testing.T) {
+		t.Parallel()
+		payload := `{"type":"MATCH_END","content":{"result":"WIN","score":2,"opponentScore":1}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(payload, nil))
+		ctx.Autoplay = &client.Autoplay{Strategy: client.RandomStrategy{}, Matches: 2, Played: 0}
+		result, err := client.Ended(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
 			t.Fatalf("Expected nil error, but %q was returned!", err)
 		}
+		if ctx.Autoplay.Played != 1 {
+			t.Fatalf("Expected one played match, but was %d!", ctx.Autoplay.Played)
+		}
 	})
 }
 
-func TestConnected(t *testing.T) {
+func TestRematching(t *testing.T) {
 	t.Parallel()
 	t.Run("ReturnErrorWhenInputScanningFails", func(t *testing.T) {
 		t.Parallel()
-		ctx := client.NewContext(failingReaderMock(errMock), new(readWriterMock))
-		result, err := client.Connected(ctx)
+		ctx := client.NewContext(failingReaderMock(errMock), newWritableConnMock(nil))
+		result, err := client.Rematching(context.Background(), ctx)
 		if result != nil {
 			t.Fatalf("Expected nil result, but %v was returned!", result)
 		}
 		if !errors.Is(err, errMock) {
-			t.Fatalf("Expected %s in the chain, but did not exists!", errMock)
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
 		}
 	})
-	t.Run("ReturnErrorWhenInputValidationFails", func(t *testing.T) {
+	t.Run("ReturnEndWhenUserQuits", func(t *testing.T) {
 		t.Parallel()
-		name := strings.Repeat("s", client.NameMaxLength+1)
-		ctx := client.NewContext(succeedingReaderMock(name), new(readWriterMock))
-		result, err := client.Connected(ctx)
+		ctx := client.NewContext(succeedingReaderMock("q"), newWritableConnMock(errMock))
+		result, err := client.Rematching(context.Background(), ctx)
 		if result != nil {
 			t.Fatalf("Expected nil result, but %v was returned!", result)
 		}
-		if !errors.Is(err, client.ErrNameTooLong) {
-			t.Fatalf("Expected %q error in the chain %q, but did not exists!", client.ErrNameTooLong, err)
+		if !errors.Is(err, client.ErrEnd) {
+			t.Fatalf("Expected %q error, but %q was returned!", client.ErrEnd, err)
+		}
+	})
+	t.Run("ReturnStateWhenAnswerIsUnknown", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(succeedingReaderMock("x"), newWritableConnMock(errMock))
+		result, err := client.Rematching(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
 		}
 	})
 	t.Run("ReturnErrorWhenWriteMessageFails", func(t *testing.T) {
 		t.Parallel()
-		name := strings.Repeat("s", client.NameMaxLength)
-		ctx := client.NewContext(succeedingReaderMock(name), newWritableConnMock(errMock))
-		result, err := client.Connected(ctx)
+		ctx := client.NewContext(succeedingReaderMock("r"), newWritableConnMock(errMock))
+		result, err := client.Rematching(context.Background(), ctx)
 		if result != nil {
 			t.Fatalf("Expected nil result, but %v was returned!", result)
 		}
@@ -77,24 +1233,51 @@ func TestConnected(t *testing.T) {
 	})
 	t.Run("ReturnStateWhenSuccess", func(t *testing.T) {
 		t.Parallel()
-		name := strings.Repeat("s", client.NameMaxLength)
-		ctx := client.NewContext(succeedingReaderMock(name), newWritableConnMock(nil))
-		result, err := client.Connected(ctx)
-		if result == nil {
-			t.Fatalf("Expected non-nil result, but nil was returned!")
+		for _, answer := range []string{"R", "n"} {
+			ctx := client.NewContext(succeedingReaderMock(answer), newWritableConnMock(nil))
+			result, err := client.Rematching(context.Background(), ctx)
+			if result == nil {
+				t.Fatal("Expected non-nil result, but nil was returned!")
+			}
+			if err != nil {
+				t.Fatalf("Expected nil error, but %q was returned!", err)
+			}
 		}
-		if err != nil {
-			t.Fatalf("Expected nil error, but %q was returned!", err)
+	})
+	t.Run("ReturnEndWhenAutoplayIsDone", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(failingReaderMock(errMock), newWritableConnMock(nil))
+		ctx.Autoplay = &client.Autoplay{Strategy: client.RandomStrategy{}, Matches: 1, Played: 1}
+		result, err := client.Rematching(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, client.ErrEnd) {
+			t.Fatalf("Expected %q error, but %q was returned!", client.ErrEnd, err)
+		}
+	})
+	t.Run("ReturnStateWhenAutoplayIsNotDone", func(t *testing.T) {
+		t.Parallel()
+		for _, matches := range []int{0, 2} {
+			ctx := client.NewContext(failingReaderMock(errMock), newWritableConnMock(nil))
+			ctx.Autoplay = &client.Autoplay{Strategy: client.RandomStrategy{}, Matches: matches, Played: 1}
+			result, err := client.Rematching(context.Background(), ctx)
+			if result == nil {
+				t.Fatal("Expected non-nil result, but nil was returned!")
+			}
+			if err != nil {
+				t.Fatalf("Expected nil error, but %q was returned!", err)
+			}
 		}
 	})
 }
 
-func TestJoined(t *testing.T) {
+func TestBracket(t *testing.T) {
 	t.Parallel()
 	t.Run("ReturnErrorWhenReadFails", func(t *testing.T) {
 		t.Parallel()
 		ctx := client.NewContext(new(readerMock), newReadableConnMock("", errMock))
-		result, err := client.Joined(ctx)
+		result, err := client.Bracket(context.Background(), ctx)
 		if result != nil {
 			t.Fatalf("Expected nil result, but %v was returned!", result)
 		}
@@ -104,58 +1287,135 @@ func TestJoined(t *testing.T) {
 	})
 	t.Run("ReturnErrorWhenUnmarshalFails", func(t *testing.T) {
 		t.Parallel()
-		data := `{"type":"JOIN","content":"non-json"}`
+		for _, messageType := range []com.MessageType{com.TypeBracket, com.TypeStart} {
+			data := `{"type":"` + string(messageType) + `","content":"non-json"}`
+			ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+			result, err := client.Bracket(context.Background(), ctx)
+			if result != nil {
+				t.Fatalf("Expected nil result, but %v was returned!", result)
+			}
+			if err == nil {
+				t.Fatal("Expected non-nil error, but nil was returned!")
+			}
+		}
+	})
+	t.Run("ReturnStateWhenSessionIsClosed", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"ERROR","content":{"code":"OPPONENT_LEFT","message":"bye"}}`
 		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
-		result, err := client.Joined(ctx)
-		if result != nil {
-			t.Fatalf("Expected nil result, but %v was returned!", result)
+		result, err := client.Bracket(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
 		}
-		if err == nil {
-			t.Fatal("Expected non-nil error, but nil was returned!")
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
 		}
 	})
-	t.Run("ReturnStateWhenSuccess", func(t *testing.T) {
+	t.Run("ReturnStateWhenBracketIsReceived", func(t *testing.T) {
+		t.Parallel()
+		rounds := `"rounds":[[{"players":["donald",""],"winner":"donald"},` +
+			`{"players":["mickey","goofy"],"winner":"mickey"}],[{"players":["donald","mickey"],"winner":""}]]`
+		payloads := []string{
+			`{"type":"BRACKET","content":{"players":["donald","mickey","goofy"],"rounds":[]}}`,
+			`{"type":"BRACKET","content":{"players":["donald","mickey","goofy"],` + rounds + `,"opponent":"donald"}}`,
+			`{"type":"BRACKET","content":{"players":["donald","mickey","goofy"],` + rounds + `,"eliminated":true}}`,
+			`{"type":"BRACKET","content":{"players":["donald","mickey","goofy"],` + rounds + `}}`,
+			`{"type":"BRACKET","content":{"players":["donald","mickey","goofy"],` + rounds + `,"champion":"donald"}}`,
+		}
+		for _, payload := range payloads {
+			ctx := client.NewContext(new(readerMock), newReadableConnMock(payload, nil))
+			result, err := client.Bracket(context.Background(), ctx)
+			if result == nil {
+				t.Fatal("Expected non-nil result, but nil was returned!")
+			}
+			if err != nil {
+				t.Fatalf("Expected nil error, but %q was returned!", err)
+			}
+		}
+	})
+	t.Run("ReturnStateWhenMatchIsStarted", func(t *testing.T) {
 		t.Parallel()
-		data := `{"type":"JOIN","content":{"name":"donald"}}`
+		data := `{"type":"START","content":{"opponentName":"mickey","opponents":["mickey"],` +
+			`"format":{"name":"best of 1","winsNeeded":1},"rules":"classic","options":[{"selection":"r","name":"rock"},` +
+			`{"selection":"p","name":"paper"},{"selection":"s","name":"scissors"}],"tournament":true}}`
 		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
-		result, err := client.Joined(ctx)
+		result, err := client.Bracket(context.Background(), ctx)
 		if result == nil {
 			t.Fatal("Expected non-nil result, but nil was returned!")
 		}
 		if err != nil {
-			t.Fatalf("Expected no error, but %q was returned!", err)
+			t.Fatalf("Expected nil error, but %q was returned!", err)
 		}
 	})
 }
 
-func TestStarted(t *testing.T) {
+func TestBrowsing(t *testing.T) {
 	t.Parallel()
+	sessions := `{"type":"SPECTATE_SESSIONS","content":{"sessions":[{"id":1,"player1":"donald","player2":"mickey",` +
+		`"round":2,"score1":1,"score2":0}]}}`
+	t.Run("ReturnErrorWhenWriteMessageFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(succeedingReaderMock("1"), newWritableConnMock(errMock))
+		result, err := client.Browsing(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnErrorWhenReadFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(succeedingReaderMock("1"), newReadableConnMock("", errMock))
+		result, err := client.Browsing(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
 	t.Run("ReturnErrorWhenInputScanningFails", func(t *testing.T) {
 		t.Parallel()
-		ctx := client.NewContext(failingReaderMock(errMock), new(readWriterMock))
-		result, err := client.Started(ctx)
+		ctx := client.NewContext(failingReaderMock(errMock), newReadableConnMock(sessions, nil))
+		result, err := client.Browsing(context.Background(), ctx)
 		if result != nil {
 			t.Fatalf("Expected nil result, but %v was returned!", result)
 		}
 		if !errors.Is(err, errMock) {
-			t.Fatalf("Expected %s in the chain, but did not exists!", errMock)
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
 		}
 	})
-	t.Run("ReturnErrorWhenInputValidationFails", func(t *testing.T) {
+	t.Run("ReturnEndWhenUserQuits", func(t *testing.T) {
 		t.Parallel()
-		ctx := client.NewContext(succeedingReaderMock("x"), new(readWriterMock))
-		result, err := client.Started(ctx)
+		data := `{"type":"SPECTATE_SESSIONS","content":{"sessions":[]}}`
+		ctx := client.NewContext(succeedingReaderMock("q"), newReadableConnMock(data, nil))
+		result, err := client.Browsing(context.Background(), ctx)
 		if result != nil {
 			t.Fatalf("Expected nil result, but %v was returned!", result)
 		}
-		if err == nil {
-			t.Fatal("Expected non-nil error, but nil was returned!")
+		if !errors.Is(err, client.ErrEnd) {
+			t.Fatalf("Expected %q error, but %q was returned!", client.ErrEnd, err)
 		}
 	})
-	t.Run("ReturnErrorWhenWriteMessageFails", func(t *testing.T) {
+	t.Run("ReturnStateWhenAnswerIsNotSession", func(t *testing.T) {
 		t.Parallel()
-		ctx := client.NewContext(succeedingReaderMock(string(game.SelectionPaper)), newWritableConnMock(errMock))
-		result, err := client.Started(ctx)
+		for _, answer := range []string{"r", "x"} {
+			ctx := client.NewContext(succeedingReaderMock(answer), newReadableConnMock(sessions, nil))
+			result, err := client.Browsing(context.Background(), ctx)
+			if result == nil {
+				t.Fatal("Expected non-nil result, but nil was returned!")
+			}
+			if err != nil {
+				t.Fatalf("Expected nil error, but %q was returned!", err)
+			}
+		}
+	})
+	t.Run("ReturnErrorWhenSpectateWriteFails", func(t *testing.T) {
+		t.Parallel()
+		conn := newReadableConnMock(sessions, nil)
+		ctx := client.NewContext(succeedingReaderMock("1"), &failingSecondWriteMock{Reader: conn, writes: 0})
+		result, err := client.Browsing(context.Background(), ctx)
 		if result != nil {
 			t.Fatalf("Expected nil result, but %v was returned!", result)
 		}
@@ -163,12 +1423,12 @@ func TestStarted(t *testing.T) {
 			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
 		}
 	})
-	t.Run("ReturnStateWhenSuccess", func(t *testing.T) {
+	t.Run("ReturnStateWhenSessionIsSelected", func(t *testing.T) {
 		t.Parallel()
-		ctx := client.NewContext(succeedingReaderMock(string(game.SelectionPaper)), newWritableConnMock(nil))
-		result, err := client.Started(ctx)
+		ctx := client.NewContext(succeedingReaderMock("1"), newReadableConnMock(sessions, nil))
+		result, err := client.Browsing(context.Background(), ctx)
 		if result == nil {
-			t.Fatalf("Expected non-nil result, but nil was returned!")
+			t.Fatal("Expected non-nil result, but nil was returned!")
 		}
 		if err != nil {
 			t.Fatalf("Expected nil error, but %q was returned!", err)
@@ -176,12 +1436,24 @@ func TestStarted(t *testing.T) {
 	})
 }
 
-func TestWaiting(t *testing.T) {
+func TestWatching(t *testing.T) {
 	t.Parallel()
+	t.Run("ReturnStateWhenSessionIsClosed", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"ERROR","content":{"code":"SESSION_CLOSED","message":"bye"}}`
+		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+		result, err := client.Watching(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
 	t.Run("ReturnErrorWhenReadFails", func(t *testing.T) {
 		t.Parallel()
 		ctx := client.NewContext(new(readerMock), newReadableConnMock("", errMock))
-		result, err := client.Waiting(ctx)
+		result, err := client.Watching(context.Background(), ctx)
 		if result != nil {
 			t.Fatalf("Expected nil result, but %v was returned!", result)
 		}
@@ -191,68 +1463,231 @@ func TestWaiting(t *testing.T) {
 	})
 	t.Run("ReturnErrorWhenUnmarshalFails", func(t *testing.T) {
 		t.Parallel()
-		data := `{"type":"RESULT","content":"non-json"}`
+		for _, messageType := range []com.MessageType{
+			com.TypeSpectateStart,
+			com.TypeSpectateRound,
+			com.TypeSpectateEnd,
+		} {
+			data := `{"type":"` + string(messageType) + `","content":"non-json"}`
+			ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
+			result, err := client.Watching(context.Background(), ctx)
+			if result != nil {
+				t.Fatalf("Expected nil result, but %v was returned!", result)
+			}
+			if err == nil {
+				t.Fatal("Expected non-nil error, but nil was returned!")
+			}
+		}
+	})
+	t.Run("ReturnStateWhenEventsAreReceived", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(new(readerMock), newFramedConnMock(
+			`{"type":"SPECTATE_START","content":{"session":{"id":3,"players":["donald","mickey","goofy"],"round":1,`+
+				`"scores":[0,0,0]},"format":{"name":"best of 3","winsNeeded":2},"rules":"classic"}}`,
+			`{"type":"SPECTATE_ROUND","content":{"round":1,"selections":["r","p","s"],`+
+				`"results":["DRAW","DRAW","DRAW"],"scores":[0,0,0]}}`,
+			`{"type":"SPECTATE_ROUND","content":{"round":2,"selections":["r","r",""],"results":["WIN","WIN","LOSE"],`+
+				`"forfeit":true,"scores":[0,0,0]}}`,
+			`{"type":"SPECTATE_ROUND","content":{"round":3,"selections":["r","p",""],"results":["LOSE","WIN",""],`+
+				`"scores":[0,1,0]}}`,
+			`{"type":"SPECTATE_END","content":{"results":["LOSE","WIN","LOSE"],"scores":[0,2,0]}}`,
+		))
+		for i := 0; i < 5; i++ {
+			result, err := client.Watching(context.Background(), ctx)
+			if result == nil {
+				t.Fatal("Expected non-nil result, but nil was returned!")
+			}
+			if err != nil {
+				t.Fatalf("Expected nil error, but %q was returned!", err)
+			}
+		}
+		expected := client.Spectated{
+			SessionID: 3,
+			Players:   []string{"donald", "mickey", "goofy"},
+			Format:    game.BestOf(3),
+		}
+		if !reflect.DeepEqual(*ctx.Spectated, expected) {
+			t.Fatalf("Expected spectated session to be %+v, but was %+v!", expected, *ctx.Spectated)
+		}
+	})
+}
+
+func TestOffered(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnStateWhenOpponentLeaves", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"ERROR","content":{"code":"OPPONENT_LEFT","message":"bye"}}`
 		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
-		result, err := client.Waiting(ctx)
+		result, err := client.Offered(context.Background(), ctx)
+		if result == nil {
+			t.Fatal("Expected non-nil result, but nil was returned!")
+		}
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+	t.Run("ReturnErrorWhenReadFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(new(readerMock), newReadableConnMock("", errMock))
+		result, err := client.Offered(context.Background(), ctx)
 		if result != nil {
 			t.Fatalf("Expected nil result, but %v was returned!", result)
 		}
-		if err == nil {
-			t.Fatal("Expected non-nil error, but nil was returned!")
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
 		}
 	})
-	t.Run("ReturnStateWhenSuccessWithDraw", func(t *testing.T) {
+	t.Run("ReturnStateWhenRematchStarts", func(t *testing.T) {
 		t.Parallel()
-		data := `{"type":"RESULT","content":{"opponentSelection":"s","result":"DRAW"}}`
-		ctx := client.NewContext(new(readerMock), newReadableConnMock(data, nil))
-		result, err := client.Waiting(ctx)
+		conn := newFramedConnMock(
+			`{"type":"REMATCH_OFFER","content":{}}`,
+			`{"type":"START","content":{"opponentName":"donald","format":{"name":"best of 1","winsNeeded":1},`+
+				`"rules":"classic","options":[{"selection":"r","name":"rock"},{"selection":"p","name":"paper"},`+
+				`{"selection":"s","name":"scissors"}]}}`,
+		)
+		ctx := client.NewContext(new(readerMock), conn)
+		ctx.Match.Round = 3
+		ctx.Match.Score = 1
+		result, err := client.Offered(context.Background(), ctx)
 		if result == nil {
 			t.Fatal("Expected non-nil result, but nil was returned!")
 		}
 		if err != nil {
-			t.Fatalf("Expected no error, but %q was returned!", err)
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+		if ctx.Match.Round != 1 || ctx.Match.Score != 0 {
+			t.Fatalf("Expected match to be reset, but round was %d and score %d!", ctx.Match.Round, ctx.Match.Score)
 		}
 	})
-	t.Run("ReturnNilWhenSuccessWithNoDraw", func(t *testing.T) {
+}
+
+func TestLobby(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenInputScanningFails", func(t *testing.T) {
 		t.Parallel()
-		payloads := []string{
-			`{"type":"RESULT","content":{"opponentSelection":"s","result":"WIN"}}`,
-			`{"type":"RESULT","content":{"opponentSelection":"s","result":"LOSE"}}`,
+		ctx := client.NewContext(failingReaderMock(errMock), newWritableConnMock(nil))
+		result, err := client.Lobby(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
 		}
-		for _, payload := range payloads {
-			ctx := client.NewContext(new(readerMock), newReadableConnMock(payload, nil))
-			result, err := client.Waiting(ctx)
-			if result != nil {
-				t.Fatalf("Expected nil result, but %v was returned!", result)
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnEndWhenUserQuits", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(succeedingReaderMock("q"), newWritableConnMock(nil))
+		result, err := client.Lobby(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, client.ErrEnd) {
+			t.Fatalf("Expected %q error, but %q was returned!", client.ErrEnd, err)
+		}
+	})
+	t.Run("ReturnErrorWhenWriteMessageFails", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(succeedingReaderMock("n"), newWritableConnMock(errMock))
+		result, err := client.Lobby(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnStateWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		for _, answer := range []string{"N", "x"} {
+			ctx := client.NewContext(succeedingReaderMock(answer), newWritableConnMock(nil))
+			result, err := client.Lobby(context.Background(), ctx)
+			if result == nil {
+				t.Fatal("Expected non-nil result, but nil was returned!")
+			}
+			if err != nil {
+				t.Fatalf("Expected nil error, but %q was returned!", err)
+			}
+		}
+	})
+	t.Run("ReturnEndWhenAutoplayIsDone", func(t *testing.T) {
+		t.Parallel()
+		ctx := client.NewContext(failingReaderMock(errMock), newWritableConnMock(nil))
+		ctx.Autoplay = &client.Autoplay{Strategy: client.RandomStrategy{}, Matches: 1, Played: 1}
+		result, err := client.Lobby(context.Background(), ctx)
+		if result != nil {
+			t.Fatalf("Expected nil result, but %v was returned!", result)
+		}
+		if !errors.Is(err, client.ErrEnd) {
+			t.Fatalf("Expected %q error, but %q was returned!", client.ErrEnd, err)
+		}
+	})
+	t.Run("ReturnStateWhenAutoplayIsNotDone", func(t *testing.T) {
+		t.Parallel()
+		for _, matches := range []int{0, 2} {
+			ctx := client.NewContext(failingReaderMock(errMock), newWritableConnMock(nil))
+			ctx.Autoplay = &client.Autoplay{Strategy: client.RandomStrategy{}, Matches: matches, Played: 1}
+			result, err := client.Lobby(context.Background(), ctx)
+			if result == nil {
+				t.Fatal("Expected non-nil result, but nil was returned!")
 			}
-			if !errors.Is(err, client.ErrEnd) {
-				t.Fatalf("Expected %q error, but %q was returned!", client.ErrEnd, err)
+			if err != nil {
+				t.Fatalf("Expected nil error, but %q was returned!", err)
 			}
 		}
 	})
 }
 
 func newReadableConnMock(data string, err error) readWriterMock {
-	data += "\n"
+	var reader io.Reader = failingReaderMock(err)
+	if err == nil {
+		buffer := new(bytes.Buffer)
+		if err := com.WriteFrame(buffer, []byte(data), com.MaxFrameSize); err != nil {
+			panic(err)
+		}
+		reader = buffer
+	}
 	return readWriterMock{
-		readerMock: readerMock{
-			n:   len(data),
-			err: err,
-			val: []byte(data),
-		}, writerMock: writerMock{
+		Reader: reader,
+		writerMock: writerMock{
 			n:   0,
 			err: nil,
 		},
 	}
 }
 
-func newWritableConnMock(err error) readWriterMock {
+func newFramedConnMock(payloads ...string) readWriterMock {
+	buffer := new(bytes.Buffer)
+	for _, payload := range payloads {
+		if err := com.WriteFrame(buffer, []byte(payload), com.MaxFrameSize); err != nil {
+			panic(err)
+		}
+	}
 	return readWriterMock{
-		readerMock: readerMock{
+		Reader: buffer,
+		writerMock: writerMock{
 			n:   0,
 			err: nil,
-			val: nil,
-		}, writerMock: writerMock{
+		},
+	}
+}
+
+// failingSecondWriteMock is a connection which fails every write after the first one.
+type failingSecondWriteMock struct {
+	io.Reader
+	writes int
+}
+
+func (f *failingSecondWriteMock) Write(b []byte) (int, error) {
+	if f.writes++; f.writes > 1 {
+		return 0, errMock
+	}
+	return len(b), nil
+}
+
+func newWritableConnMock(err error) readWriterMock {
+	return readWriterMock{
+		Reader: failingReaderMock(io.EOF),
+		writerMock: writerMock{
 			n:   0,
 			err: err,
 		},
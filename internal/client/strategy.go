@@ -0,0 +1,96 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/toivjon/go-rps/internal/game"
+)
+
+// ErrUnknownStrategy is an error occurring when a strategy cannot be found with the given name.
+var ErrUnknownStrategy = errors.New("the provided value is not a known strategy")
+
+// Round describes a played round of the ongoing match from the perspective of the client.
+type Round struct {
+	Selection         game.Selection
+	OpponentSelection game.Selection
+	Result            game.Result
+}
+
+// Strategy decides the selections of an automated player instead of the user input. The history contains the
+// rounds of the ongoing match in the order they were played.
+type Strategy interface {
+	fmt.Stringer
+	Select(rules game.RuleSet, history []Round) game.Selection
+}
+
+// Strategies returns the built-in strategies.
+func Strategies() []Strategy {
+	return []Strategy{RandomStrategy{}, CycleStrategy{}, AdaptiveStrategy{}}
+}
+
+// LookupStrategy returns the built-in strategy with the given name.
+func LookupStrategy(name string) (Strategy, error) {
+	names := make([]string, 0, len(Strategies()))
+	for _, strategy := range Strategies() {
+		if strategy.String() == name {
+			return strategy, nil
+		}
+		names = append(names, strategy.String())
+	}
+	return nil, fmt.Errorf("%w: %q (supported %s)", ErrUnknownStrategy, name, strings.Join(names, ", "))
+}
+
+// RandomStrategy selects uniformly at random without looking at the history.
+type RandomStrategy struct{}
+
+// Select returns a random selection from the rule set.
+func (RandomStrategy) Select(rules game.RuleSet, history []Round) game.Selection {
+	return game.RandomSelection(rules.Selections())
+}
+
+// String returns a string representing the strategy.
+func (RandomStrategy) String() string {
+	return "random"
+}
+
+// CycleStrategy goes through the selections of the rule set in their order round after round.
+type CycleStrategy struct{}
+
+// Select returns the selection which follows the previous selection in the rule set.
+func (CycleStrategy) Select(rules game.RuleSet, history []Round) game.Selection {
+	selections := rules.Selections()
+	return selections[len(history)%len(selections)]
+}
+
+// String returns a string representing the strategy.
+func (CycleStrategy) String() string {
+	return "cycle"
+}
+
+// AdaptiveStrategy keeps the selection which won the previous round. Otherwise it expects the opponent to
+// repeat its most frequent selection and selects what beats it.
+type AdaptiveStrategy struct{}
+
+// Select returns the winning selection of the previous round or a selection which beats the most frequent
+// selection of the opponent.
+func (AdaptiveStrategy) Select(rules game.RuleSet, history []Round) game.Selection {
+	if len(history) == 0 {
+		return game.RandomSelection(rules.Selections())
+	}
+	if previous := history[len(history)-1]; previous.Result == game.ResultWin &&
+		rules.Validate(previous.Selection) == nil {
+		return previous.Selection
+	}
+	opponent := make([]game.Selection, len(history))
+	for i, round := range history {
+		opponent[i] = round.OpponentSelection
+	}
+	return rules.Counter(rules.MostFrequent(opponent))
+}
+
+// String returns a string representing the strategy.
+func (AdaptiveStrategy) String() string {
+	return "adaptive"
+}
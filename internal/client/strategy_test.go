@@ -0,0 +1,76 @@
+package client_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/toivjon/go-rps/internal/client"
+	"github.com/toivjon/go-rps/internal/game"
+)
+
+func TestLookupStrategy(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnStrategyWhenNameIsKnown", func(t *testing.T) {
+		t.Parallel()
+		for _, expected := range client.Strategies() {
+			strategy, err := client.LookupStrategy(expected.String())
+			if err != nil {
+				t.Fatalf("Expected nil error, but %q was returned!", err)
+			}
+			if strategy != expected {
+				t.Fatalf("Expected strategy %s, but was %s!", expected, strategy)
+			}
+		}
+	})
+	t.Run("ReturnErrorWhenNameIsUnknown", func(t *testing.T) {
+		t.Parallel()
+		if _, err := client.LookupStrategy("cheater"); !errors.Is(err, client.ErrUnknownStrategy) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", client.ErrUnknownStrategy, err)
+		}
+	})
+}
+
+func TestStrategySelect(t *testing.T) {
+	t.Parallel()
+	history := []client.Round{
+		{Selection: game.SelectionRock, OpponentSelection: game.SelectionScissors, Result: game.ResultWin},
+		{Selection: game.SelectionRock, OpponentSelection: game.SelectionScissors, Result: game.ResultWin},
+		{Selection: game.SelectionRock, OpponentSelection: game.SelectionPaper, Result: game.ResultLose},
+	}
+	for _, test := range []struct {
+		strategy client.Strategy
+		history  []client.Round
+		expected game.Selection
+	}{
+		// The cycle starts from the first selection of the rule set.
+		{strategy: client.CycleStrategy{}, history: nil, expected: game.SelectionRock},
+		// The cycle continues from the selection after the previous round.
+		{strategy: client.CycleStrategy{}, history: history[:2], expected: game.SelectionPaper},
+		// The cycle starts over after the last selection of the rule set.
+		{strategy: client.CycleStrategy{}, history: history, expected: game.SelectionRock},
+		// Rock won the previous round, so it is kept.
+		{strategy: client.AdaptiveStrategy{}, history: history[:2], expected: game.SelectionRock},
+		// Scissors is the most frequent selection of the opponent, so rock beats it.
+		{strategy: client.AdaptiveStrategy{}, history: history, expected: game.SelectionRock},
+	} {
+		if selection := test.strategy.Select(game.Classic(), test.history); selection != test.expected {
+			t.Fatalf("Expected %s strategy to select %q, but was %q!", test.strategy, test.expected, selection)
+		}
+	}
+}
+
+func TestStrategySelectWithoutHistory(t *testing.T) {
+	t.Parallel()
+	rules := game.RPSLS()
+	for _, strategy := range client.Strategies() {
+		for _, history := range [][]client.Round{
+			nil,
+			{{Selection: game.SelectionFire, OpponentSelection: game.SelectionFire, Result: game.ResultWin}},
+			{{Selection: game.SelectionRock, OpponentSelection: game.SelectionNone, Result: game.ResultLose}},
+		} {
+			if err := rules.Validate(strategy.Select(rules, history)); err != nil {
+				t.Fatalf("Expected %s strategy to select from the rule set, but validation failed: %s", strategy, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package com
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/toivjon/go-rps/internal/game"
+)
+
+// nonceSize specifies the number of random bytes in a commitment nonce.
+const nonceSize = 16
+
+// NewNonce generates a random nonce which keeps a committed selection from being guessed before it is revealed.
+func NewNonce() (string, error) {
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce. %w", err)
+	}
+	return hex.EncodeToString(nonce), nil
+}
+
+// Commit builds the commitment of the given selection and nonce as a hex encoded SHA-256 hash.
+func Commit(selection game.Selection, nonce string) string {
+	hash := sha256.Sum256([]byte(string(selection) + ":" + nonce))
+	return hex.EncodeToString(hash[:])
+}
+
+// ErrInvalidCommitment is an error occurring when a commitment is not a hex encoded SHA-256 hash.
+var ErrInvalidCommitment = errors.New("commitment is not a hex encoded SHA-256 hash")
+
+// ValidateCommitment returns an error if the given commitment could not have been built with Commit.
+func ValidateCommitment(commitment string) error {
+	hash, err := hex.DecodeString(commitment)
+	if err != nil || len(hash) != sha256.Size {
+		return fmt.Errorf("%w: %q", ErrInvalidCommitment, commitment)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package com_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/toivjon/go-rps/internal/com"
+	"github.com/toivjon/go-rps/internal/game"
+)
+
+func TestNewNonce(t *testing.T) {
+	t.Parallel()
+	nonce1, err := com.NewNonce()
+	if err != nil {
+		t.Fatalf("Expected no error, but error was returned: %s", err)
+	}
+	nonce2, err := com.NewNonce()
+	if err != nil {
+		t.Fatalf("Expected no error, but error was returned: %s", err)
+	}
+	if nonce1 == "" || nonce1 == nonce2 {
+		t.Fatalf("Expected unique non-empty nonces, but were %q and %q", nonce1, nonce2)
+	}
+}
+
+func TestCommit(t *testing.T) {
+	t.Parallel()
+	commitment := com.Commit(game.SelectionRock, "nonce")
+	if err := com.ValidateCommitment(commitment); err != nil {
+		t.Fatalf("Expected valid commitment, but validation failed: %s", err)
+	}
+	if commitment != com.Commit(game.SelectionRock, "nonce") {
+		t.Fatal("Expected the same selection and nonce to build the same commitment, but did not!")
+	}
+	if commitment == com.Commit(game.SelectionPaper, "nonce") || commitment == com.Commit(game.SelectionRock, "other") {
+		t.Fatal("Expected a different selection or nonce to build a different commitment, but did not!")
+	}
+}
+
+func TestValidateCommitment(t *testing.T) {
+	t.Parallel()
+	for _, commitment := range []string{"", "non-hex", "abcd"} {
+		if err := com.ValidateCommitment(commitment); !errors.Is(err, com.ErrInvalidCommitment) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", com.ErrInvalidCommitment, err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package com
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	ProtocolVersion    = 1 // The newest protocol version implemented by this build.
+	MinProtocolVersion = 1 // The oldest protocol version this build is still able to speak.
+)
+
+// ErrUnsupportedVersion is an error occurring when the peer protocol version cannot be spoken.
+var ErrUnsupportedVersion = errors.New("unsupported protocol version")
+
+// Capability specifies an optional protocol feature which both nodes must support before it can be used.
+type Capability string
+
+// CapabilityCommitReveal lets the player commit to a hash of its selection and reveal the selection only after
+// every player has committed, so that the server cannot see the selection before the others have been made.
+const CapabilityCommitReveal Capability = "COMMIT_REVEAL"
+
+// Capabilities returns the optional protocol features supported by this build.
+func Capabilities() []Capability {
+	return []Capability{CapabilityCommitReveal}
+}
+
+// Negotiate resolves the WELCOME response for the given HELLO content. The peer is downgraded to our
+// protocol version when it is newer, and the capabilities are narrowed to the ones both nodes support.
+func Negotiate(hello HelloContent) (WelcomeContent, error) {
+	if hello.Version < MinProtocolVersion {
+		return WelcomeContent{Version: 0, Capabilities: nil, AccountRequired: false},
+			fmt.Errorf("peer version %d is older than %d. %w", hello.Version, MinProtocolVersion, ErrUnsupportedVersion)
+	}
+	version := hello.Version
+	if version > ProtocolVersion {
+		version = ProtocolVersion
+	}
+	capabilities := []Capability{}
+	for _, capability := range hello.Capabilities {
+		if HasCapability(Capabilities(), capability) {
+			capabilities = append(capabilities, capability)
+		}
+	}
+	return WelcomeContent{Version: version, Capabilities: capabilities, AccountRequired: false}, nil
+}
+
+// HasCapability checks whether the given capability is contained in the capability list.
+func HasCapability(capabilities []Capability, capability Capability) bool {
+	for _, val := range capabilities {
+		if val == capability {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package com_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/toivjon/go-rps/internal/com"
+)
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnsErrorWhenPeerVersionIsTooOld", func(t *testing.T) {
+		t.Parallel()
+		hello := com.HelloContent{Version: com.MinProtocolVersion - 1, Capabilities: nil}
+		if _, err := com.Negotiate(hello); !errors.Is(err, com.ErrUnsupportedVersion) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", com.ErrUnsupportedVersion, err)
+		}
+	})
+	t.Run("DowngradesWhenPeerVersionIsNewer", func(t *testing.T) {
+		t.Parallel()
+		hello := com.HelloContent{Version: com.ProtocolVersion + 1, Capabilities: nil}
+		welcome, err := com.Negotiate(hello)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if welcome.Version != com.ProtocolVersion {
+			t.Fatalf("Expected version %d but was %d", com.ProtocolVersion, welcome.Version)
+		}
+	})
+	t.Run("DropsUnsupportedCapabilities", func(t *testing.T) {
+		t.Parallel()
+		hello := com.HelloContent{Version: com.ProtocolVersion, Capabilities: []com.Capability{"unknown"}}
+		welcome, err := com.Negotiate(hello)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if len(welcome.Capabilities) != 0 {
+			t.Fatalf("Expected no capabilities but had %v", welcome.Capabilities)
+		}
+	})
+	t.Run("KeepsSupportedCapabilities", func(t *testing.T) {
+		t.Parallel()
+		capabilities := []com.Capability{"unknown", com.CapabilityCommitReveal}
+		welcome, err := com.Negotiate(com.HelloContent{Version: com.ProtocolVersion, Capabilities: capabilities})
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if len(welcome.Capabilities) != 1 || welcome.Capabilities[0] != com.CapabilityCommitReveal {
+			t.Fatalf("Expected only commit-reveal capability but had %v", welcome.Capabilities)
+		}
+	})
+}
+
+func TestHasCapability(t *testing.T) {
+	t.Parallel()
+	capabilities := []com.Capability{"foo", "bar"}
+	if !com.HasCapability(capabilities, "bar") {
+		t.Fatal("Expected to contain capability \"bar\", but did not!")
+	}
+	if com.HasCapability(capabilities, "baz") {
+		t.Fatal("Expected to not contain capability \"baz\", but did!")
+	}
+}
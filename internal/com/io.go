@@ -1,12 +1,21 @@
 package com
 
 import (
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 )
 
-const bufferSize = 128
+// MaxFrameSize specifies the default maximum size of a single frame payload in bytes.
+const MaxFrameSize = 64 * 1024
+
+// headerSize specifies the size of the frame header containing the payload length.
+const headerSize = 4
+
+// ErrFrameTooLarge is an error occurring when a frame payload exceeds the maximum frame size.
+var ErrFrameTooLarge = errors.New("frame payload exceeds the maximum frame size")
 
 // WriteMessage marshals the given message into a JSON and writes it with the given writer.
 func WriteMessage[T any](writer io.Writer, messageType MessageType, content T) error {
@@ -20,24 +29,46 @@ func WriteMessage[T any](writer io.Writer, messageType MessageType, content T) e
 	return nil
 }
 
-// Write marshals the provided data into a JSON and writes it with the given writer.
+// Write marshals the provided data into a JSON and writes it as a single frame with the given writer.
 func Write[T any](writer io.Writer, data T) error {
 	bytes, err := json.Marshal(data)
 	if err != nil {
 		return fmt.Errorf("failed to marshal data into JSON. %w", err)
 	}
-	if _, err = writer.Write(bytes); err != nil {
+	if err := WriteFrame(writer, bytes, MaxFrameSize); err != nil {
 		return fmt.Errorf("failed to write data into connection. %w", err)
 	}
 	return nil
 }
 
-// ReadMessage reads message from the reader and unmarshals it as a JSON data.
+// WriteFrame writes the payload prefixed with its big-endian length in a single write call.
+func WriteFrame(writer io.Writer, payload []byte, maxSize int) error {
+	if len(payload) > maxSize {
+		return fmt.Errorf("failed to write %d byte frame (max: %d). %w", len(payload), maxSize, ErrFrameTooLarge)
+	}
+	frame := make([]byte, headerSize+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[headerSize:], payload)
+	if _, err := writer.Write(frame); err != nil {
+		return fmt.Errorf("failed to write frame. %w", err)
+	}
+	return nil
+}
+
+// ReadMessage reads message from the reader and unmarshals it as a JSON data. An ERROR message is
+// returned as an *ErrorContent error.
 func ReadMessage[T any](reader io.Reader) (*T, error) {
 	message, err := Read[Message](reader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read %v message. %w", message, err)
 	}
+	if message.Type == TypeError {
+		content := new(ErrorContent)
+		if err := json.Unmarshal(message.Content, content); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal ERROR message content from JSON. %w", err)
+		}
+		return nil, content
+	}
 	content := new(T)
 	if err := json.Unmarshal(message.Content, &content); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal message content from JSON. %w", err)
@@ -45,16 +76,33 @@ func ReadMessage[T any](reader io.Reader) (*T, error) {
 	return content, nil
 }
 
-// Read reads data from the reader and unmarshals it as a JSON data.
+// Read reads a single frame from the reader and unmarshals it as a JSON data.
 func Read[T any](reader io.Reader) (*T, error) {
-	buffer := make([]byte, bufferSize)
-	byteCount, err := reader.Read(buffer)
+	payload, err := ReadFrame(reader, MaxFrameSize)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read from connection. %w", err)
 	}
 	out := new(T)
-	if err := json.Unmarshal(buffer[:byteCount], out); err != nil {
+	if err := json.Unmarshal(payload, out); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal data from JSON. %w", err)
 	}
 	return out, nil
 }
+
+// ReadFrame reads a single length-prefixed frame and returns its payload. The reader is read only up
+// to the end of the frame, so partial reads and several frames arriving together are handled properly.
+func ReadFrame(reader io.Reader, maxSize int) ([]byte, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("failed to read frame header. %w", err)
+	}
+	size := binary.BigEndian.Uint32(header)
+	if uint64(size) > uint64(maxSize) {
+		return nil, fmt.Errorf("failed to read %d byte frame (max: %d). %w", size, maxSize, ErrFrameTooLarge)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, fmt.Errorf("failed to read frame payload. %w", err)
+	}
+	return payload, nil
+}
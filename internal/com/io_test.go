@@ -1,8 +1,12 @@
 package com_test
 
 import (
+	"bytes"
 	"errors"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/toivjon/go-rps/internal/com"
 )
@@ -29,14 +33,14 @@ func TestWriteMessage(t *testing.T) {
 	t.Run("ReturnsErrorWhenWriterWriteFails", func(t *testing.T) {
 		t.Parallel()
 		writer := &writerMock{n: 0, err: errMock}
-		if err := com.WriteMessage(writer, com.TypeJoin, com.JoinContent{Name: ""}); err == nil {
+		if err := com.WriteMessage(writer, com.TypeJoin, com.JoinContent{Name: "", Bot: ""}); err == nil {
 			t.Fatalf("Expected an error, but nil was returned!")
 		}
 	})
 	t.Run("ReturnsNilWhenSuccess", func(t *testing.T) {
 		t.Parallel()
 		writer := &writerMock{n: 0, err: nil}
-		if err := com.WriteMessage(writer, com.TypeJoin, com.JoinContent{Name: ""}); err != nil {
+		if err := com.WriteMessage(writer, com.TypeJoin, com.JoinContent{Name: "", Bot: ""}); err != nil {
 			t.Fatalf("Expected no error, but error was returned: %s", err)
 		}
 	})
@@ -66,39 +70,85 @@ func TestWrite(t *testing.T) {
 	})
 }
 
-type readerMock struct {
-	n   int
-	err error
-	val []byte
+func framed(data string) []byte {
+	buffer := new(bytes.Buffer)
+	if err := com.WriteFrame(buffer, []byte(data), com.MaxFrameSize); err != nil {
+		panic(err)
+	}
+	return buffer.Bytes()
 }
 
-func (r *readerMock) Read(b []byte) (int, error) {
-	copy(b, r.val)
-	return r.n, r.err
+func TestWriteFrame(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnsErrorWhenPayloadIsTooLarge", func(t *testing.T) {
+		t.Parallel()
+		writer := &writerMock{n: 0, err: nil}
+		if err := com.WriteFrame(writer, []byte("abc"), 2); !errors.Is(err, com.ErrFrameTooLarge) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", com.ErrFrameTooLarge, err)
+		}
+	})
+	t.Run("ReturnsErrorWhenWriterWriteFails", func(t *testing.T) {
+		t.Parallel()
+		writer := &writerMock{n: 0, err: errMock}
+		if err := com.WriteFrame(writer, []byte("abc"), com.MaxFrameSize); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("WritesLengthPrefixedPayload", func(t *testing.T) {
+		t.Parallel()
+		buffer := new(bytes.Buffer)
+		if err := com.WriteFrame(buffer, []byte("abc"), com.MaxFrameSize); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		expected := []byte{0, 0, 0, 3, 'a', 'b', 'c'}
+		if !bytes.Equal(buffer.Bytes(), expected) {
+			t.Fatalf("Expected %v to be written but was %v", expected, buffer.Bytes())
+		}
+	})
 }
 
 func TestReadMessage(t *testing.T) {
 	t.Parallel()
 	t.Run("ReturnsErrorWhenReaderReadFails", func(t *testing.T) {
 		t.Parallel()
-		reader := &readerMock{n: 0, err: errMock, val: nil}
+		reader := iotest.ErrReader(errMock)
 		if _, err := com.ReadMessage[com.JoinContent](reader); err == nil {
 			t.Fatal("Expected an error, but nil was returned!")
 		}
 	})
 	t.Run("ReturnsErrorWhenUnmarshallingFails", func(t *testing.T) {
 		t.Parallel()
-		data := `{"type":"JOIN","content":{"name":313}}`
-		reader := &readerMock{n: len(data), err: nil, val: []byte(data)}
+		reader := bytes.NewReader(framed(`{"type":"JOIN","content":{"name":313}}`))
+		if _, err := com.ReadMessage[com.JoinContent](reader); err == nil {
+			t.Fatal("Expected an error, but nil was returned!")
+		}
+	})
+	t.Run("ReturnsErrorWhenErrorUnmarshallingFails", func(t *testing.T) {
+		t.Parallel()
+		reader := bytes.NewReader(framed(`{"type":"ERROR","content":"non-json"}`))
 		if _, err := com.ReadMessage[com.JoinContent](reader); err == nil {
 			t.Fatal("Expected an error, but nil was returned!")
 		}
 	})
+	t.Run("ReturnsErrorContentWhenErrorIsReceived", func(t *testing.T) {
+		t.Parallel()
+		reader := bytes.NewReader(framed(`{"type":"ERROR","content":{"code":"OPPONENT_LEFT","message":"bye"}}`))
+		_, err := com.ReadMessage[com.StartContent](reader)
+		var content *com.ErrorContent
+		if !errors.As(err, &content) {
+			t.Fatalf("Expected %T error in the chain %q, but did not exists!", content, err)
+		}
+		if content.Code != com.CodeOpponentLeft {
+			t.Fatalf("Expected code %q but was %q", com.CodeOpponentLeft, content.Code)
+		}
+		if content.Error() != "OPPONENT_LEFT: bye" {
+			t.Fatalf("Expected error text %q but was %q", "OPPONENT_LEFT: bye", content.Error())
+		}
+	})
 	t.Run("ReturnsResultWhenSuccess", func(t *testing.T) {
 		t.Parallel()
 		expectedResult := struct{}{}
-		data := `{"type":"JOIN","content":{"name":"donald"}}`
-		reader := &readerMock{n: len(data), err: nil, val: []byte(data)}
+		reader := bytes.NewReader(framed(`{"type":"JOIN","content":{"name":"donald"}}`))
 		val, err := com.ReadMessage[struct{}](reader)
 		if err != nil {
 			t.Fatalf("Expected no error, but error was returned: %s", err)
@@ -113,14 +163,14 @@ func TestRead(t *testing.T) {
 	t.Parallel()
 	t.Run("ReturnsErrorWhenReaderReadFails", func(t *testing.T) {
 		t.Parallel()
-		reader := &readerMock{n: 0, err: errMock, val: nil}
-		if _, err := com.Read[string](reader); err == nil {
-			t.Fatal("Expected an error, but nil was returned!")
+		reader := iotest.ErrReader(errMock)
+		if _, err := com.Read[string](reader); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
 		}
 	})
 	t.Run("ReturnsErrorWhenUnmarshallingFails", func(t *testing.T) {
 		t.Parallel()
-		reader := &readerMock{n: 0, err: nil, val: nil}
+		reader := bytes.NewReader(framed(""))
 		if _, err := com.Read[chan int](reader); err == nil {
 			t.Fatal("Expected an error, but nil was returned!")
 		}
@@ -128,7 +178,7 @@ func TestRead(t *testing.T) {
 	t.Run("ReturnsResultWhenSuccess", func(t *testing.T) {
 		t.Parallel()
 		expectedResult := struct{}{}
-		reader := &readerMock{n: 2, err: nil, val: []byte("{}")}
+		reader := bytes.NewReader(framed("{}"))
 		val, err := com.Read[struct{}](reader)
 		if err != nil {
 			t.Fatalf("Expected no error, but error was returned: %s", err)
@@ -138,3 +188,53 @@ func TestRead(t *testing.T) {
 		}
 	})
 }
+
+func TestReadFrame(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnsErrorWhenHeaderIsTruncated", func(t *testing.T) {
+		t.Parallel()
+		reader := bytes.NewReader([]byte{0, 0})
+		if _, err := com.ReadFrame(reader, com.MaxFrameSize); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", io.ErrUnexpectedEOF, err)
+		}
+	})
+	t.Run("ReturnsErrorWhenPayloadIsTruncated", func(t *testing.T) {
+		t.Parallel()
+		reader := bytes.NewReader([]byte{0, 0, 0, 3, 'a'})
+		if _, err := com.ReadFrame(reader, com.MaxFrameSize); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", io.ErrUnexpectedEOF, err)
+		}
+	})
+	t.Run("ReturnsErrorWhenPayloadIsTooLarge", func(t *testing.T) {
+		t.Parallel()
+		reader := bytes.NewReader(framed("abc"))
+		if _, err := com.ReadFrame(reader, 2); !errors.Is(err, com.ErrFrameTooLarge) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", com.ErrFrameTooLarge, err)
+		}
+	})
+	t.Run("ReturnsPayloadWhenReadPartially", func(t *testing.T) {
+		t.Parallel()
+		payload := strings.Repeat("x", 1024)
+		reader := iotest.OneByteReader(bytes.NewReader(framed(payload)))
+		val, err := com.ReadFrame(reader, com.MaxFrameSize)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if string(val) != payload {
+			t.Fatalf("Expected %d byte payload but received %d bytes", len(payload), len(val))
+		}
+	})
+	t.Run("ReturnsPayloadsWhenFramesAreCoalesced", func(t *testing.T) {
+		t.Parallel()
+		reader := bytes.NewReader(append(framed("first"), framed("second")...))
+		for _, expected := range []string{"first", "second"} {
+			val, err := com.ReadFrame(reader, com.MaxFrameSize)
+			if err != nil {
+				t.Fatalf("Expected no error, but error was returned: %s", err)
+			}
+			if string(val) != expected {
+				t.Fatalf("Expected %q but received %q", expected, val)
+			}
+		}
+	})
+}
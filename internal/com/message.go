@@ -2,6 +2,8 @@ package com
 
 import (
 	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/toivjon/go-rps/internal/game"
 )
@@ -10,10 +12,66 @@ import (
 type MessageType string
 
 const (
-	TypeJoin   MessageType = "JOIN"   // Client wants to join server.
-	TypeStart  MessageType = "START"  // Server starts a game session.
-	TypeSelect MessageType = "SELECT" // Client decides an in-game decision.
-	TypeResult MessageType = "RESULT" // Server resolves game session round result.
+	TypeHello    MessageType = "HELLO"     // Client introduces its protocol version and capabilities.
+	TypeWelcome  MessageType = "WELCOME"   // Server accepts the client with a negotiated protocol version.
+	TypeReject   MessageType = "REJECT"    // Server rejects the client due an incompatible protocol version.
+	TypeJoin     MessageType = "JOIN"      // Client wants to join server.
+	TypeStart    MessageType = "START"     // Server starts a game session.
+	TypeSelect   MessageType = "SELECT"    // Client decides an in-game decision.
+	TypeResult   MessageType = "RESULT"    // Server resolves game session round result.
+	TypeMatchEnd MessageType = "MATCH_END" // Server resolves the whole game session match result.
+	TypeError    MessageType = "ERROR"     // Server reports a failure or rejects a client message.
+	TypeShutdown MessageType = "SHUTDOWN"  // Server is shutting down and will soon close the connection.
+
+	TypeRematchOffer  MessageType = "REMATCH_OFFER"  // Player offers a rematch for the opponent of a decided match.
+	TypeRematchAccept MessageType = "REMATCH_ACCEPT" // Player accepts the rematch offered by the opponent.
+	TypeQueue         MessageType = "QUEUE"          // Player leaves the decided match to play against a new opponent.
+	TypeResume        MessageType = "RESUME"         // Client reconnects to take back its seat in a game session.
+	TypeResumed       MessageType = "RESUMED"        // Server restores the game session state for a resumed client.
+	TypeCreateRoom    MessageType = "CREATE_ROOM"    // Client wants to join server by hosting a private room.
+	TypeRoomCreated   MessageType = "ROOM_CREATED"   // Server reports the code of the created private room.
+	TypeJoinRoom      MessageType = "JOIN_ROOM"      // Client wants to join server by entering a private room.
+
+	TypeSpectateList     MessageType = "SPECTATE_LIST"     // Spectator asks for the active game sessions.
+	TypeSpectateSessions MessageType = "SPECTATE_SESSIONS" // Server lists the active game sessions.
+	TypeSpectate         MessageType = "SPECTATE"          // Spectator subscribes to the events of a game session.
+	TypeSpectateStart    MessageType = "SPECTATE_START"    // Server reports the state of a spectated match.
+	TypeSpectateRound    MessageType = "SPECTATE_ROUND"    // Server reports the resolved round of a spectated match.
+	TypeSpectateEnd      MessageType = "SPECTATE_END"      // Server reports the result of a spectated match.
+
+	TypeTournamentJoin MessageType = "TOURNAMENT_JOIN" // Client wants to join server by registering to a tournament.
+	TypeBracket        MessageType = "BRACKET"         // Server reports the state of the tournament bracket.
+
+	TypeCommit    MessageType = "COMMIT"    // Client commits to a selection without revealing it.
+	TypeCommitted MessageType = "COMMITTED" // Server reports that every player has committed and asks for reveals.
+	TypeReveal    MessageType = "REVEAL"    // Client reveals the selection and the nonce of its commitment.
+
+	TypeRegister      MessageType = "REGISTER"      // Client registers a new player account.
+	TypeLogin         MessageType = "LOGIN"         // Client logs in to a player account.
+	TypeAuthenticated MessageType = "AUTHENTICATED" // Server reports the account and the session token of a client.
+)
+
+// ErrorCode specifies a machine-readable reason of an ERROR message.
+type ErrorCode string
+
+const (
+	CodeInvalidMessage     ErrorCode = "INVALID_MESSAGE"     // Message content could not be parsed.
+	CodeUnsupportedMessage ErrorCode = "UNSUPPORTED_MESSAGE" // Message type is never accepted from a client.
+	CodeUnexpectedMessage  ErrorCode = "UNEXPECTED_MESSAGE"  // Message type is not accepted in the current state.
+	CodeInvalidName        ErrorCode = "INVALID_NAME"        // Player name in JOIN did not pass validation.
+	CodeInvalidSelection   ErrorCode = "INVALID_SELECTION"   // Selection in SELECT did not pass validation.
+	CodeSessionFailed      ErrorCode = "SESSION_FAILED"      // Game session could not be started or continued.
+	CodeRoundTimeout       ErrorCode = "ROUND_TIMEOUT"       // None of the players made a selection in time.
+	CodeOpponentLeft       ErrorCode = "OPPONENT_LEFT"       // Opponent left the game session.
+	CodeResumeFailed       ErrorCode = "RESUME_FAILED"       // Resume token is unknown or its seat has expired.
+	CodeRoomNotFound       ErrorCode = "ROOM_NOT_FOUND"      // Room code is unknown or the room is already full.
+	CodeSessionNotFound    ErrorCode = "SESSION_NOT_FOUND"   // Spectated game session is unknown or already closed.
+	CodeSessionClosed      ErrorCode = "SESSION_CLOSED"      // Spectated game session was closed.
+	CodeInvalidBot         ErrorCode = "INVALID_BOT"         // Bot strategy in JOIN is not known.
+	CodeAuthRequired       ErrorCode = "AUTH_REQUIRED"       // Server requires an account to join.
+	CodeAuthFailed         ErrorCode = "AUTH_FAILED"         // Account credentials or session token are not valid.
+	CodeNameTaken          ErrorCode = "NAME_TAKEN"          // Player name belongs to a registered account.
+	CodeInvalidPassword    ErrorCode = "INVALID_PASSWORD"    // Password in REGISTER did not pass validation.
 )
 
 // Message is base structure for each message being sent between the nodes.
@@ -22,23 +80,259 @@ type Message struct {
 	Content json.RawMessage `json:"content"`
 }
 
-// JoinContent contains the content of a JOIN message.
+// HelloContent contains the content of a HELLO message.
+type HelloContent struct {
+	Version      int
+	Capabilities []Capability
+}
+
+// WelcomeContent contains the content of a WELCOME message. The account required is set when the server accepts
+// only players which have logged in to a player account.
+type WelcomeContent struct {
+	Version         int
+	Capabilities    []Capability
+	AccountRequired bool
+}
+
+// RejectContent contains the content of a REJECT message.
+type RejectContent struct {
+	Reason     string
+	MinVersion int
+	MaxVersion int
+}
+
+// JoinContent contains the content of a JOIN message. The bot names the strategy of the server-side bots which
+// the player wants to play against instead of waiting for other players. It is empty for a regular join.
 type JoinContent struct {
 	Name string
+	Bot  string
 }
 
-// StartContent contains the content of a START message.
+// StartContent contains the content of a START message. The opponents are listed in the seat order and the
+// opponent name is the name of the first opponent. The tournament is set when the match is a pairing of a
+// tournament bracket, in which case the match cannot be rematched. The commit reveal is set when the player
+// should commit to its selections with COMMIT and REVEAL messages instead of sending SELECT messages.
 type StartContent struct {
 	OpponentName string
+	Opponents    []string
+	Format       game.Format
+	Rules        string
+	Options      []game.Option
+	RoundTimeout time.Duration
+	ResumeToken  string
+	Tournament   bool
+	CommitReveal bool
 }
 
 // SelectContent contains the content of a SELECT message.
 type SelectContent struct {
+	Round     int
 	Selection game.Selection
 }
 
-// ResultContent contains the content of a RESULT message.
+// CommitContent contains the content of a COMMIT message. The commitment is built from the selection and a
+// nonce which the player keeps secret until the selection is revealed.
+type CommitContent struct {
+	Round      int
+	Commitment string
+}
+
+// CommittedContent contains the content of a COMMITTED message.
+type CommittedContent struct {
+	Round int
+}
+
+// RevealContent contains the content of a REVEAL message. The selection and the nonce must match the commitment
+// made for the round.
+type RevealContent struct {
+	Round     int
+	Selection game.Selection
+	Nonce     string
+}
+
+// ErrorContent contains the content of an ERROR message. It also acts as an error on the receiving side.
+type ErrorContent struct {
+	Code    ErrorCode
+	Message string
+}
+
+// Error returns a string representing the reported error.
+func (e *ErrorContent) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// ResultContent contains the content of a RESULT message. The opponents are listed in the seat order while
+// the opponent selection is the one of the first opponent and the opponent score is the highest opponent
+// score. The result is empty for a player who sat out the round. An eliminated player sits out the following
+// rounds until one of the remaining players wins the ongoing game.
 type ResultContent struct {
+	Round             int
 	OpponentSelection game.Selection
 	Result            game.Result
+	Forfeit           bool
+	Score             int
+	OpponentScore     int
+	Opponents         []OpponentResult
+	Eliminated        bool
+}
+
+// OpponentResult describes the round of a single opponent in a RESULT message.
+type OpponentResult struct {
+	Name      string
+	Selection game.Selection
+	Result    game.Result
+	Score     int
+}
+
+// MatchEndContent contains the content of a MATCH_END message. The opponent score is the highest opponent score.
+type MatchEndContent struct {
+	Result        game.Result
+	Score         int
+	OpponentScore int
+}
+
+// ShutdownContent contains the content of a SHUTDOWN message. The grace specifies how long the ongoing
+// rounds may still be played before the server closes the connection.
+type ShutdownContent struct {
+	Reason string
+	Grace  time.Duration
+}
+
+// RematchOfferContent contains the content of a REMATCH_OFFER message.
+type RematchOfferContent struct{}
+
+// RematchAcceptContent contains the content of a REMATCH_ACCEPT message.
+type RematchAcceptContent struct{}
+
+// QueueContent contains the content of a QUEUE message.
+type QueueContent struct{}
+
+// ResumeContent contains the content of a RESUME message. The token is the one received in a START message.
+type ResumeContent struct {
+	Token string
+}
+
+// ResumedContent contains the content of a RESUMED message. It replays the game session state so that the
+// client can continue from the ongoing round. The opponent scores are in the seat order of the opponents and
+// the opponent score is the highest of them. The commitment is the one made for the ongoing round when the
+// selection has not been revealed yet.
+type ResumedContent struct {
+	Start          StartContent
+	Round          int
+	Selection      game.Selection
+	Commitment     string
+	Score          int
+	OpponentScore  int
+	OpponentScores []int
+	Eliminated     bool
+	Ended          bool
+	Offered        bool
+}
+
+// CreateRoomContent contains the content of a CREATE_ROOM message.
+type CreateRoomContent struct {
+	Name string
+}
+
+// RoomCreatedContent contains the content of a ROOM_CREATED message. The code is shared with the player who
+// should be paired with the room host.
+type RoomCreatedContent struct {
+	Code string
+}
+
+// JoinRoomContent contains the content of a JOIN_ROOM message.
+type JoinRoomContent struct {
+	Name string
+	Code string
+}
+
+// SessionInfo describes an active game session for the spectators. The scores are in the player order.
+type SessionInfo struct {
+	ID      int
+	Players []string
+	Round   int
+	Scores  []int
+}
+
+// SpectateListContent contains the content of a SPECTATE_LIST message.
+type SpectateListContent struct{}
+
+// SpectateSessionsContent contains the content of a SPECTATE_SESSIONS message.
+type SpectateSessionsContent struct {
+	Sessions []SessionInfo
+}
+
+// SpectateContent contains the content of a SPECTATE message.
+type SpectateContent struct {
+	SessionID int
+}
+
+// SpectateStartContent contains the content of a SPECTATE_START message. It is sent when the spectator
+// subscribes to a game session and each time a new match is started in the session.
+type SpectateStartContent struct {
+	Session SessionInfo
+	Format  game.Format
+	Rules   string
+}
+
+// SpectateRoundContent contains the content of a SPECTATE_ROUND message. The selections, results and scores
+// are in the player order. The selection and result are empty for the players who sat out the round.
+type SpectateRoundContent struct {
+	Round      int
+	Selections []game.Selection
+	Results    []game.Result
+	Forfeit    bool
+	Scores     []int
+}
+
+// SpectateEndContent contains the content of a SPECTATE_END message. The results and scores are in the
+// player order.
+type SpectateEndContent struct {
+	Results []game.Result
+	Scores  []int
+}
+
+// TournamentJoinContent contains the content of a TOURNAMENT_JOIN message.
+type TournamentJoinContent struct {
+	Name string
+}
+
+// BracketContent contains the content of a BRACKET message. The players are listed in the seed order and the
+// rounds contain the pairings of each bracket round played so far. The opponent is the opponent of the
+// recipient in its next match, and it is empty when the recipient has no match to play in the ongoing round.
+// The champion is set once the tournament has been decided.
+type BracketContent struct {
+	Players    []string
+	Rounds     [][]BracketPairing
+	Opponent   string
+	Eliminated bool
+	Champion   string
+}
+
+// BracketPairing describes a single match of a tournament bracket round. An empty player marks a bye and an
+// empty winner marks a match which has not been decided.
+type BracketPairing struct {
+	Players []string
+	Winner  string
+}
+
+// RegisterContent contains the content of a REGISTER message.
+type RegisterContent struct {
+	Name     string
+	Password string
+}
+
+// LoginContent contains the content of a LOGIN message. The player logs in either with the name and the password
+// of its account or with a session token received in an earlier AUTHENTICATED message.
+type LoginContent struct {
+	Name     string
+	Password string
+	Token    string
+}
+
+// AuthenticatedContent contains the content of an AUTHENTICATED message. The session token can be used to log
+// in again without the password until it expires.
+type AuthenticatedContent struct {
+	Name  string
+	Token string
 }
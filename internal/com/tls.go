@@ -0,0 +1,107 @@
+package com
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+)
+
+// ErrNoCertificates is an error occurring when a certificate authority file does not contain any certificates.
+var ErrNoCertificates = errors.New("no certificates found")
+
+// ServerTLSConfig builds a TLS configuration for a server with the given certificate and key files. The
+// clients must present a certificate signed by the client certificate authority when the client CA file is
+// given, which makes the connections mutually authenticated.
+func ServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate. %w", err)
+	}
+	config := new(tls.Config)
+	config.MinVersion = tls.VersionTLS12
+	config.Certificates = []tls.Certificate{cert}
+	if clientCAFile != "" {
+		pool, err := loadCertPool(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate authority. %w", err)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// ClientTLSConfig builds a TLS configuration for a client. The server certificate is verified against the
+// certificate authority file when it is given and against the system certificate pool otherwise. The
+// verification is skipped altogether when insecure is set. The client presents the certificate from the
+// given certificate and key files when they are given.
+func ClientTLSConfig(caFile, certFile, keyFile string, insecure bool) (*tls.Config, error) {
+	config := new(tls.Config)
+	config.MinVersion = tls.VersionTLS12
+	config.InsecureSkipVerify = insecure //nolint:gosec
+	if caFile != "" {
+		pool, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load server certificate authority. %w", err)
+		}
+		config.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate. %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// CertificateName returns the common name of the first certificate in the given TLS configuration or an
+// empty string when there is no such certificate.
+func CertificateName(config *tls.Config) string {
+	if config == nil || len(config.Certificates) == 0 || len(config.Certificates[0].Certificate) == 0 {
+		return ""
+	}
+	leaf, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if err != nil {
+		return ""
+	}
+	return leaf.Subject.CommonName
+}
+
+// PeerName returns the common name of the verified peer certificate of a TLS connection. Connections which
+// wrap another connection are unwrapped with their NetConn method. An empty string is returned when the
+// connection is not a TLS connection or when the peer did not present a verified certificate.
+func PeerName(conn io.ReadWriter) string {
+	for {
+		switch wrapper := conn.(type) {
+		case *tls.Conn:
+			chains := wrapper.ConnectionState().VerifiedChains
+			if len(chains) == 0 || len(chains[0]) == 0 {
+				return ""
+			}
+			return chains[0][0].Subject.CommonName
+		case interface{ NetConn() net.Conn }:
+			conn = wrapper.NetConn()
+		default:
+			return ""
+		}
+	}
+}
+
+// loadCertPool builds a certificate pool from the PEM encoded certificates in the given file.
+func loadCertPool(file string) (*x509.CertPool, error) {
+	bytes, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate file %q. %w", file, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bytes) {
+		return nil, fmt.Errorf("failed to parse certificate file %q. %w", file, ErrNoCertificates)
+	}
+	return pool, nil
+}
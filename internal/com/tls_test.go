@@ -0,0 +1,227 @@
+package com_test
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/toivjon/go-rps/internal/com"
+	"github.com/toivjon/go-rps/internal/testcert"
+)
+
+// newCertFiles generates a certificate authority together with a server and a client certificate signed by it.
+func newCertFiles(t *testing.T) (testcert.Files, testcert.Files, testcert.Files) {
+	t.Helper()
+	ca, err := testcert.NewAuthority(t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected no error, but error was returned: %s", err)
+	}
+	serverFiles, err := ca.IssueServer("localhost")
+	if err != nil {
+		t.Fatalf("Expected no error, but error was returned: %s", err)
+	}
+	clientFiles, err := ca.IssueClient("donald")
+	if err != nil {
+		t.Fatalf("Expected no error, but error was returned: %s", err)
+	}
+	return ca.Files, serverFiles, clientFiles
+}
+
+// wrappedConn is a connection which wraps another connection like a WebSocket connection.
+type wrappedConn struct {
+	net.Conn
+}
+
+func (w wrappedConn) NetConn() net.Conn {
+	return w.Conn
+}
+
+// handshake connects a TLS client and a TLS server with the given configurations and returns the server side
+// connection after the handshake has been completed.
+func handshake(t *testing.T, serverConfig, clientConfig *tls.Config) (*tls.Conn, error) {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	t.Cleanup(func() {
+		serverConn.Close()
+		clientConn.Close()
+	})
+	server := tls.Server(serverConn, serverConfig)
+	clientConfig.ServerName = "localhost"
+	go func() {
+		if err := tls.Client(clientConn, clientConfig).Handshake(); err != nil {
+			clientConn.Close()
+		}
+	}()
+	if err := server.Handshake(); err != nil {
+		return nil, fmt.Errorf("failed to complete TLS handshake. %w", err)
+	}
+	return server, nil
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	t.Parallel()
+	caFiles, serverFiles, _ := newCertFiles(t)
+	t.Run("ReturnErrorWhenCertificateIsMissing", func(t *testing.T) {
+		t.Parallel()
+		missing := filepath.Join(t.TempDir(), "missing.crt")
+		if _, err := com.ServerTLSConfig(missing, serverFiles.Key, ""); err == nil {
+			t.Fatal("Expected an error, but nil was returned!")
+		}
+	})
+	t.Run("ReturnErrorWhenClientCAHasNoCertificates", func(t *testing.T) {
+		t.Parallel()
+		_, err := com.ServerTLSConfig(serverFiles.Cert, serverFiles.Key, serverFiles.Key)
+		if !errors.Is(err, com.ErrNoCertificates) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", com.ErrNoCertificates, err)
+		}
+	})
+	t.Run("ReturnErrorWhenClientCAIsMissing", func(t *testing.T) {
+		t.Parallel()
+		missing := filepath.Join(t.TempDir(), "missing.crt")
+		if _, err := com.ServerTLSConfig(serverFiles.Cert, serverFiles.Key, missing); err == nil {
+			t.Fatal("Expected an error, but nil was returned!")
+		}
+	})
+	t.Run("ReturnConfigWithoutClientAuthentication", func(t *testing.T) {
+		t.Parallel()
+		config, err := com.ServerTLSConfig(serverFiles.Cert, serverFiles.Key, "")
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if len(config.Certificates) != 1 || config.ClientAuth != tls.NoClientCert {
+			t.Fatalf("Expected one certificate without client authentication, but config was %+v", config)
+		}
+	})
+	t.Run("ReturnConfigWithClientAuthentication", func(t *testing.T) {
+		t.Parallel()
+		config, err := com.ServerTLSConfig(serverFiles.Cert, serverFiles.Key, caFiles.Cert)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if config.ClientCAs == nil || config.ClientAuth != tls.RequireAndVerifyClientCert {
+			t.Fatalf("Expected verified client authentication, but config was %+v", config)
+		}
+	})
+}
+
+func TestClientTLSConfig(t *testing.T) {
+	t.Parallel()
+	caFiles, _, clientFiles := newCertFiles(t)
+	t.Run("ReturnErrorWhenCAIsMissing", func(t *testing.T) {
+		t.Parallel()
+		missing := filepath.Join(t.TempDir(), "missing.crt")
+		if _, err := com.ClientTLSConfig(missing, "", "", false); err == nil {
+			t.Fatal("Expected an error, but nil was returned!")
+		}
+	})
+	t.Run("ReturnErrorWhenKeyIsMissing", func(t *testing.T) {
+		t.Parallel()
+		if _, err := com.ClientTLSConfig(caFiles.Cert, clientFiles.Cert, "", false); err == nil {
+			t.Fatal("Expected an error, but nil was returned!")
+		}
+	})
+	t.Run("ReturnConfigWithSystemCertificates", func(t *testing.T) {
+		t.Parallel()
+		config, err := com.ClientTLSConfig("", "", "", true)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if config.RootCAs != nil || len(config.Certificates) != 0 || !config.InsecureSkipVerify {
+			t.Fatalf("Expected insecure config without certificates, but config was %+v", config)
+		}
+	})
+	t.Run("ReturnConfigWithClientCertificate", func(t *testing.T) {
+		t.Parallel()
+		config, err := com.ClientTLSConfig(caFiles.Cert, clientFiles.Cert, clientFiles.Key, false)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if config.RootCAs == nil || len(config.Certificates) != 1 || config.InsecureSkipVerify {
+			t.Fatalf("Expected verifying config with a certificate, but config was %+v", config)
+		}
+	})
+}
+
+func TestCertificateName(t *testing.T) {
+	t.Parallel()
+	_, _, clientFiles := newCertFiles(t)
+	t.Run("ReturnEmptyWhenConfigIsNil", func(t *testing.T) {
+		t.Parallel()
+		if name := com.CertificateName(nil); name != "" {
+			t.Fatalf("Expected an empty name, but was %q", name)
+		}
+	})
+	t.Run("ReturnEmptyWhenCertificateIsInvalid", func(t *testing.T) {
+		t.Parallel()
+		config := new(tls.Config)
+		config.Certificates = []tls.Certificate{{Certificate: [][]byte{[]byte("invalid")}}}
+		if name := com.CertificateName(config); name != "" {
+			t.Fatalf("Expected an empty name, but was %q", name)
+		}
+	})
+	t.Run("ReturnCommonName", func(t *testing.T) {
+		t.Parallel()
+		config, err := com.ClientTLSConfig("", clientFiles.Cert, clientFiles.Key, false)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if name := com.CertificateName(config); name != "donald" {
+			t.Fatalf("Expected name %q, but was %q", "donald", name)
+		}
+	})
+}
+
+func TestPeerName(t *testing.T) {
+	t.Parallel()
+	caFiles, serverFiles, clientFiles := newCertFiles(t)
+	t.Run("ReturnEmptyWhenConnectionIsNotTLS", func(t *testing.T) {
+		t.Parallel()
+		conn, _ := net.Pipe()
+		defer conn.Close()
+		if name := com.PeerName(wrappedConn{Conn: conn}); name != "" {
+			t.Fatalf("Expected an empty name, but was %q", name)
+		}
+	})
+	t.Run("ReturnEmptyWhenPeerIsNotAuthenticated", func(t *testing.T) {
+		t.Parallel()
+		serverConfig, err := com.ServerTLSConfig(serverFiles.Cert, serverFiles.Key, "")
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		clientConfig, err := com.ClientTLSConfig(caFiles.Cert, "", "", false)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		conn, err := handshake(t, serverConfig, clientConfig)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if name := com.PeerName(conn); name != "" {
+			t.Fatalf("Expected an empty name, but was %q", name)
+		}
+	})
+	t.Run("ReturnCommonNameWhenPeerIsAuthenticated", func(t *testing.T) {
+		t.Parallel()
+		serverConfig, err := com.ServerTLSConfig(serverFiles.Cert, serverFiles.Key, caFiles.Cert)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		clientConfig, err := com.ClientTLSConfig(caFiles.Cert, clientFiles.Cert, clientFiles.Key, false)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		conn, err := handshake(t, serverConfig, clientConfig)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if name := com.PeerName(conn); name != "donald" {
+			t.Fatalf("Expected name %q, but was %q", "donald", name)
+		}
+		if name := com.PeerName(wrappedConn{Conn: conn}); name != "donald" {
+			t.Fatalf("Expected name %q of wrapped connection, but was %q", "donald", name)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidFormat is an error occurring when a match format cannot be parsed.
+var ErrInvalidFormat = errors.New("the provided value is not a valid match format")
+
+// Format specifies how many round wins a player needs to win the whole match.
+type Format struct {
+	Name       string
+	WinsNeeded int
+}
+
+// BestOf builds a format where the match is won by the player who wins the majority of the given rounds.
+func BestOf(rounds int) Format {
+	return Format{Name: fmt.Sprintf("best of %d", rounds), WinsNeeded: rounds/2 + 1}
+}
+
+// FirstTo builds a format where the match is won by the player who first reaches the given wins.
+func FirstTo(wins int) Format {
+	return Format{Name: fmt.Sprintf("first to %d", wins), WinsNeeded: wins}
+}
+
+// ParseFormat parses a format from a value like "bo3" (best of three) or "ft5" (first to five wins).
+func ParseFormat(val string) (Format, error) {
+	prefix, count := "", 0
+	if len(val) > 2 {
+		prefix = strings.ToLower(val[:2])
+		// The count must contain only digits, as the conversion would also accept a sign.
+		digits := val[2:]
+		if strings.Trim(digits, "0123456789") != "" {
+			return Format{Name: "", WinsNeeded: 0}, fmt.Errorf("%w: %q", ErrInvalidFormat, val)
+		}
+		number, err := strconv.Atoi(digits)
+		if err != nil {
+			return Format{Name: "", WinsNeeded: 0}, fmt.Errorf("%w: %q", ErrInvalidFormat, val)
+		}
+		count = number
+	}
+	switch {
+	case prefix == "bo" && count > 0 && count%2 == 1:
+		return BestOf(count), nil
+	case prefix == "ft" && count > 0:
+		return FirstTo(count), nil
+	}
+	return Format{Name: "", WinsNeeded: 0}, fmt.Errorf("%w: %q", ErrInvalidFormat, val)
+}
+
+// Decided checks whether any of the given scores has reached the wins needed to win the match.
+func (f Format) Decided(scores ...int) bool {
+	for _, score := range scores {
+		if score >= f.WinsNeeded {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns a string representing the format.
+func (f Format) String() string {
+	return f.Name
+}
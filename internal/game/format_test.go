@@ -0,0 +1,57 @@
+package game_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/toivjon/go-rps/internal/game"
+)
+
+func TestParseFormat(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnsErrorWithInvalidInputs", func(t *testing.T) {
+		t.Parallel()
+		for _, input := range []string{"", "bo", "bo2", "bo0", "ft0", "ftx", "xx3", "bo-1", "bo+3", "ft+2", "ft 2", "bo3x"} {
+			if _, err := game.ParseFormat(input); !errors.Is(err, game.ErrInvalidFormat) {
+				t.Fatalf("Expected %q to return invalid format error, but %v was returned!", input, err)
+			}
+		}
+	})
+	t.Run("ReturnsFormatWithValidInputs", func(t *testing.T) {
+		t.Parallel()
+		inputs := map[string]game.Format{
+			"bo1": game.BestOf(1),
+			"BO5": game.BestOf(5),
+			"ft3": game.FirstTo(3),
+		}
+		for input, expected := range inputs {
+			format, err := game.ParseFormat(input)
+			if err != nil {
+				t.Fatalf("Expected %q to return no error, but error was returned: %s", input, err)
+			}
+			if format != expected {
+				t.Fatalf("Expected %q to return %v, but %v was returned!", input, expected, format)
+			}
+		}
+	})
+}
+
+func TestFormatDecided(t *testing.T) {
+	t.Parallel()
+	format := game.BestOf(3)
+	if format.WinsNeeded != 2 {
+		t.Fatalf("Expected best of 3 to need 2 wins, but needed %d!", format.WinsNeeded)
+	}
+	if format.Decided(1, 1) {
+		t.Fatal("Expected 1-1 to not be decided in best of 3, but it was!")
+	}
+	if !format.Decided(2, 1) || !format.Decided(0, 2) {
+		t.Fatal("Expected 2 wins to decide best of 3, but it did not!")
+	}
+	if format.Decided(1, 1, 1) || !format.Decided(1, 0, 2) {
+		t.Fatal("Expected only 2 wins to decide best of 3 between three players, but it did not!")
+	}
+	if format.String() != "best of 3" {
+		t.Fatalf("Expected string \"best of 3\", but was %q!", format.String())
+	}
+}
@@ -0,0 +1,36 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// NameMaxLength specifies the maximum length of the player's name in characters.
+const NameMaxLength = 64
+
+var (
+	ErrNameEmpty            = errors.New("player name must not be empty")
+	ErrNameTooLong          = fmt.Errorf("player name must not contain more than %d characters", NameMaxLength)
+	ErrNameInvalidUTF8      = errors.New("player name must be valid UTF-8")
+	ErrNameControlCharacter = errors.New("player name must not contain control characters")
+)
+
+// ValidateName returns an error if the given player name is not valid.
+func ValidateName(name string) error {
+	switch {
+	case name == "":
+		return ErrNameEmpty
+	case !utf8.ValidString(name):
+		return ErrNameInvalidUTF8
+	case utf8.RuneCountInString(name) > NameMaxLength:
+		return ErrNameTooLong
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return ErrNameControlCharacter
+		}
+	}
+	return nil
+}
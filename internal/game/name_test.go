@@ -0,0 +1,37 @@
+package game_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/toivjon/go-rps/internal/game"
+)
+
+func TestValidateName(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnsErrorWithInvalidInputs", func(t *testing.T) {
+		t.Parallel()
+		inputs := map[string]error{
+			"":     game.ErrNameEmpty,
+			"\xff": game.ErrNameInvalidUTF8,
+			strings.Repeat("a", game.NameMaxLength+1): game.ErrNameTooLong,
+			"don\nald":   game.ErrNameControlCharacter,
+			"don\x1bald": game.ErrNameControlCharacter,
+		}
+		for input, expected := range inputs {
+			if err := game.ValidateName(input); !errors.Is(err, expected) {
+				t.Fatalf("Expected %q to return %q error, but %v was returned!", input, expected, err)
+			}
+		}
+	})
+	t.Run("ReturnsNilWithValidInputs", func(t *testing.T) {
+		t.Parallel()
+		inputs := []string{"donald", "Äkäslompolo", strings.Repeat("ä", game.NameMaxLength)}
+		for _, input := range inputs {
+			if err := game.ValidateName(input); err != nil {
+				t.Fatalf("Expected %q to return no error, but error was returned: %s", input, err)
+			}
+		}
+	})
+}
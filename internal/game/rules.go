@@ -0,0 +1,155 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidRuleSet = errors.New("rule set must contain an odd number (3 or more) of unique selections")
+	ErrUnknownRuleSet = errors.New("the provided value is not a known rule set")
+)
+
+// Option describes a single selection available in a rule set.
+type Option struct {
+	Selection Selection
+	Name      string
+}
+
+// RuleSet describes the available selections and the beats relation between them. The options form a
+// cycle where each option beats the half of the other options which directly follow it in the cycle.
+// This keeps every odd-sized rule set balanced so that each option beats and loses to equally many others.
+type RuleSet struct {
+	Name    string
+	Options []Option
+}
+
+// NewRuleSet builds a new rule set with the given name and options in their beats cycle order.
+func NewRuleSet(name string, options []Option) (RuleSet, error) {
+	if len(options) < 3 || len(options)%2 == 0 {
+		return RuleSet{Name: "", Options: nil}, fmt.Errorf("%w: %d selections", ErrInvalidRuleSet, len(options))
+	}
+	seen := make(map[Selection]bool, len(options))
+	for _, option := range options {
+		if option.Selection == SelectionNone || seen[option.Selection] {
+			return RuleSet{Name: "", Options: nil}, fmt.Errorf("%w: %q", ErrInvalidRuleSet, option.Selection)
+		}
+		seen[option.Selection] = true
+	}
+	return RuleSet{Name: name, Options: options}, nil
+}
+
+// Classic builds the classic rock-paper-scissors rule set.
+func Classic() RuleSet {
+	return RuleSet{Name: "classic", Options: []Option{
+		{Selection: SelectionRock, Name: "rock"},
+		{Selection: SelectionScissors, Name: "scissors"},
+		{Selection: SelectionPaper, Name: "paper"},
+	}}
+}
+
+// RPSLS builds the rock-paper-scissors-lizard-Spock rule set.
+func RPSLS() RuleSet {
+	return RuleSet{Name: "rpsls", Options: []Option{
+		{Selection: SelectionRock, Name: "rock"},
+		{Selection: SelectionScissors, Name: "scissors"},
+		{Selection: SelectionLizard, Name: "lizard"},
+		{Selection: SelectionPaper, Name: "paper"},
+		{Selection: SelectionSpock, Name: "Spock"},
+	}}
+}
+
+// RPS7 builds the seven selection rock-paper-scissors rule set.
+func RPS7() RuleSet {
+	return RuleSet{Name: "rps7", Options: []Option{
+		{Selection: SelectionRock, Name: "rock"},
+		{Selection: SelectionFire, Name: "fire"},
+		{Selection: SelectionScissors, Name: "scissors"},
+		{Selection: SelectionSponge, Name: "sponge"},
+		{Selection: SelectionPaper, Name: "paper"},
+		{Selection: SelectionAir, Name: "air"},
+		{Selection: SelectionWater, Name: "water"},
+	}}
+}
+
+// LookupRuleSet returns the built-in rule set with the given name.
+func LookupRuleSet(name string) (RuleSet, error) {
+	for _, rules := range []RuleSet{Classic(), RPSLS(), RPS7()} {
+		if rules.Name == name {
+			return rules, nil
+		}
+	}
+	return RuleSet{Name: "", Options: nil}, fmt.Errorf("%w: %q", ErrUnknownRuleSet, name)
+}
+
+// Validate returns an error if the given selection is not available in the rule set.
+func (r RuleSet) Validate(val Selection) error {
+	if r.index(val) < 0 {
+		return ErrInvalidSelection
+	}
+	return nil
+}
+
+// Beats checks whether the selection beats the other selection. Panics if either selection is not available.
+func (r RuleSet) Beats(selection, other Selection) bool {
+	index, otherIndex := r.index(selection), r.index(other)
+	if index < 0 || otherIndex < 0 {
+		panic("Unable to check beat with a selection outside the rule set!")
+	}
+	distance := (otherIndex - index + len(r.Options)) % len(r.Options)
+	return distance > 0 && distance <= len(r.Options)/2
+}
+
+// Selections returns the selections available in the rule set.
+func (r RuleSet) Selections() []Selection {
+	selections := make([]Selection, len(r.Options))
+	for i, option := range r.Options {
+		selections[i] = option.Selection
+	}
+	return selections
+}
+
+// MostFrequent returns the selection of the rule set which occurs the most in the given selections. The ties
+// are broken by the order of the rule set and none is returned when none of the selections are in the rule set.
+func (r RuleSet) MostFrequent(selections []Selection) Selection {
+	counts := make(map[Selection]int, len(r.Options))
+	for _, selection := range selections {
+		counts[selection]++
+	}
+	frequent, most := SelectionNone, 0
+	for _, selection := range r.Selections() {
+		if counts[selection] > most {
+			frequent, most = selection, counts[selection]
+		}
+	}
+	return frequent
+}
+
+// Counter returns a random selection which beats the expected selection. A random selection is returned when
+// the expected selection is not in the rule set.
+func (r RuleSet) Counter(expected Selection) Selection {
+	if r.Validate(expected) != nil {
+		return RandomSelection(r.Selections())
+	}
+	counters := make([]Selection, 0, len(r.Options))
+	for _, selection := range r.Selections() {
+		if r.Beats(selection, expected) {
+			counters = append(counters, selection)
+		}
+	}
+	return RandomSelection(counters)
+}
+
+// String returns a string representing the rule set.
+func (r RuleSet) String() string {
+	return r.Name
+}
+
+func (r RuleSet) index(val Selection) int {
+	for i, option := range r.Options {
+		if option.Selection == val && val != SelectionNone {
+			return i
+		}
+	}
+	return -1
+}
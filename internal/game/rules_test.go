@@ -0,0 +1,243 @@
+package game_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/toivjon/go-rps/internal/game"
+)
+
+type match struct {
+	s1 game.Selection
+	s2 game.Selection
+}
+
+func TestNewRuleSet(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnsErrorWithInvalidOptions", func(t *testing.T) {
+		t.Parallel()
+		inputs := [][]game.Option{
+			nil,
+			{{Selection: "a", Name: "a"}, {Selection: "b", Name: "b"}},
+			{{Selection: "a", Name: "a"}, {Selection: "b", Name: "b"}, {Selection: "c", Name: "c"}, {Selection: "d", Name: "d"}},
+			{{Selection: "a", Name: "a"}, {Selection: "a", Name: "b"}, {Selection: "c", Name: "c"}},
+			{{Selection: "a", Name: "a"}, {Selection: game.SelectionNone, Name: "b"}, {Selection: "c", Name: "c"}},
+		}
+		for _, input := range inputs {
+			if _, err := game.NewRuleSet("test", input); !errors.Is(err, game.ErrInvalidRuleSet) {
+				t.Fatalf("Expected %v to return invalid rule set error, but %v was returned!", input, err)
+			}
+		}
+	})
+	t.Run("ReturnsBalancedRuleSetWithValidOptions", func(t *testing.T) {
+		t.Parallel()
+		options := []game.Option{
+			{Selection: "a", Name: "a"},
+			{Selection: "b", Name: "b"},
+			{Selection: "c", Name: "c"},
+			{Selection: "d", Name: "d"},
+			{Selection: "e", Name: "e"},
+			{Selection: "f", Name: "f"},
+			{Selection: "g", Name: "g"},
+			{Selection: "h", Name: "h"},
+			{Selection: "i", Name: "i"},
+		}
+		rules, err := game.NewRuleSet("test", options)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		assertBalanced(t, rules)
+	})
+}
+
+func TestLookupRuleSet(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnsErrorWithUnknownName", func(t *testing.T) {
+		t.Parallel()
+		if _, err := game.LookupRuleSet("unknown"); !errors.Is(err, game.ErrUnknownRuleSet) {
+			t.Fatalf("Expected unknown rule set error, but %v was returned!", err)
+		}
+	})
+	t.Run("ReturnsBuiltInRuleSets", func(t *testing.T) {
+		t.Parallel()
+		for _, name := range []string{"classic", "rpsls", "rps7"} {
+			rules, err := game.LookupRuleSet(name)
+			if err != nil {
+				t.Fatalf("Expected %q to return no error, but error was returned: %s", name, err)
+			}
+			if rules.String() != name {
+				t.Fatalf("Expected rule set %q, but %q was returned!", name, rules)
+			}
+			assertBalanced(t, rules)
+		}
+	})
+}
+
+//nolint:funlen
+func TestRuleSetBeats(t *testing.T) {
+	t.Parallel()
+	rules := game.Classic()
+	t.Run("PanicsWhenLhsSelectionIsNone", func(t *testing.T) {
+		t.Parallel()
+		defer func() {
+			if err := recover(); err == nil {
+				t.Fatalf("Recover did not have a non-nil error!")
+			}
+		}()
+		rules.Beats(game.SelectionNone, game.SelectionRock)
+		t.Fatalf("Expected to panic, but did not!")
+	})
+	t.Run("PanicsWhenRhsSelectionIsNone", func(t *testing.T) {
+		t.Parallel()
+		defer func() {
+			if err := recover(); err == nil {
+				t.Fatalf("Recover did not have a non-nil error!")
+			}
+		}()
+		rules.Beats(game.SelectionRock, game.SelectionNone)
+		t.Fatalf("Expected to panic, but did not!")
+	})
+	t.Run("ReturnsTrueWhenWinning", func(t *testing.T) {
+		t.Parallel()
+		matches := []match{
+			{s1: game.SelectionRock, s2: game.SelectionScissors},
+			{s1: game.SelectionPaper, s2: game.SelectionRock},
+			{s1: game.SelectionScissors, s2: game.SelectionPaper},
+		}
+		for _, match := range matches {
+			if !rules.Beats(match.s1, match.s2) {
+				t.Fatalf("Expected %q to beat %q, but it did not!", match.s1, match.s2)
+			}
+		}
+	})
+	t.Run("ReturnsFalseWhenOtherWins", func(t *testing.T) {
+		t.Parallel()
+		matches := []match{
+			{s1: game.SelectionRock, s2: game.SelectionPaper},
+			{s1: game.SelectionPaper, s2: game.SelectionScissors},
+			{s1: game.SelectionScissors, s2: game.SelectionRock},
+		}
+		for _, match := range matches {
+			if rules.Beats(match.s1, match.s2) {
+				t.Fatalf("Expected %q to not beat %q, but it did!", match.s1, match.s2)
+			}
+		}
+	})
+	t.Run("ReturnsFalseWhenDraw", func(t *testing.T) {
+		t.Parallel()
+		for _, selection := range rules.Selections() {
+			if rules.Beats(selection, selection) {
+				t.Fatalf("Expected %q to not beat %q, but it did!", selection, selection)
+			}
+		}
+	})
+	t.Run("ReturnsTrueWhenWinningWithRPSLS", func(t *testing.T) {
+		t.Parallel()
+		matches := []match{
+			{s1: game.SelectionScissors, s2: game.SelectionPaper},
+			{s1: game.SelectionPaper, s2: game.SelectionRock},
+			{s1: game.SelectionRock, s2: game.SelectionLizard},
+			{s1: game.SelectionLizard, s2: game.SelectionSpock},
+			{s1: game.SelectionSpock, s2: game.SelectionScissors},
+			{s1: game.SelectionScissors, s2: game.SelectionLizard},
+			{s1: game.SelectionLizard, s2: game.SelectionPaper},
+			{s1: game.SelectionPaper, s2: game.SelectionSpock},
+			{s1: game.SelectionSpock, s2: game.SelectionRock},
+			{s1: game.SelectionRock, s2: game.SelectionScissors},
+		}
+		for _, match := range matches {
+			if !game.RPSLS().Beats(match.s1, match.s2) {
+				t.Fatalf("Expected %q to beat %q, but it did not!", match.s1, match.s2)
+			}
+		}
+	})
+}
+
+func TestRuleSetValidate(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnsErrorWithEmptyInput", func(t *testing.T) {
+		t.Parallel()
+		if err := game.Classic().Validate(game.Selection("")); !errors.Is(err, game.ErrInvalidSelection) {
+			t.Fatalf("Expected invalid selection error, but %v was returned!", err)
+		}
+	})
+	t.Run("ReturnsErrorWithInvalidNonEmptyInput", func(t *testing.T) {
+		t.Parallel()
+		if err := game.Classic().Validate(game.SelectionLizard); !errors.Is(err, game.ErrInvalidSelection) {
+			t.Fatalf("Expected invalid selection error, but %v was returned!", err)
+		}
+	})
+	t.Run("ReturnsNilWithValidInputs", func(t *testing.T) {
+		t.Parallel()
+		inputs := []game.Selection{game.SelectionRock, game.SelectionPaper, game.SelectionScissors}
+		for _, input := range inputs {
+			if err := game.Classic().Validate(input); err != nil {
+				t.Fatalf("Expected %s to return no error, but error was returned: %s", input, err)
+			}
+		}
+	})
+}
+
+func TestRuleSetMostFrequent(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnMostFrequentSelection", func(t *testing.T) {
+		t.Parallel()
+		selections := []game.Selection{game.SelectionPaper, game.SelectionRock, game.SelectionPaper}
+		if frequent := game.Classic().MostFrequent(selections); frequent != game.SelectionPaper {
+			t.Fatalf("Expected %q to be the most frequent, but was %q!", game.SelectionPaper, frequent)
+		}
+	})
+	t.Run("BreakTiesByRuleSetOrder", func(t *testing.T) {
+		t.Parallel()
+		selections := []game.Selection{game.SelectionPaper, game.SelectionScissors}
+		if frequent := game.Classic().MostFrequent(selections); frequent != game.SelectionScissors {
+			t.Fatalf("Expected %q to be the most frequent, but was %q!", game.SelectionScissors, frequent)
+		}
+	})
+	t.Run("ReturnNoneWithoutRuleSetSelections", func(t *testing.T) {
+		t.Parallel()
+		selections := []game.Selection{game.SelectionNone, game.SelectionLizard}
+		if frequent := game.Classic().MostFrequent(selections); frequent != game.SelectionNone {
+			t.Fatalf("Expected no selection to be the most frequent, but was %q!", frequent)
+		}
+	})
+}
+
+func TestRuleSetCounter(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnSelectionWhichBeatsExpected", func(t *testing.T) {
+		t.Parallel()
+		rules := game.RPSLS()
+		for i := 0; i < 20; i++ {
+			if counter := rules.Counter(game.SelectionRock); !rules.Beats(counter, game.SelectionRock) {
+				t.Fatalf("Expected %q to beat %q, but it did not!", counter, game.SelectionRock)
+			}
+		}
+	})
+	t.Run("ReturnRandomSelectionWhenExpectedIsNotInRuleSet", func(t *testing.T) {
+		t.Parallel()
+		if counter := game.Classic().Counter(game.SelectionNone); game.Classic().Validate(counter) != nil {
+			t.Fatalf("Expected a selection of the rule set, but was %q!", counter)
+		}
+	})
+}
+
+// assertBalanced checks that each selection of the rule set beats exactly the half of the other selections.
+func assertBalanced(t *testing.T, rules game.RuleSet) {
+	t.Helper()
+	selections := rules.Selections()
+	for _, selection := range selections {
+		wins := 0
+		for _, other := range selections {
+			if rules.Beats(selection, other) {
+				wins++
+				if rules.Beats(other, selection) {
+					t.Fatalf("Expected %q and %q to not beat each other, but they did!", selection, other)
+				}
+			}
+		}
+		if wins != len(selections)/2 {
+			t.Fatalf("Expected %q to beat %d selections, but it beat %d!", selection, len(selections)/2, wins)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package game
 
 import (
+	"crypto/rand"
 	"errors"
+	"math/big"
 )
 
 // Selection represents a player selection in a game round.
@@ -12,31 +14,22 @@ const (
 	SelectionRock     Selection = "r"
 	SelectionPaper    Selection = "p"
 	SelectionScissors Selection = "s"
+	SelectionLizard   Selection = "l"
+	SelectionSpock    Selection = "v"
+	SelectionFire     Selection = "f"
+	SelectionSponge   Selection = "g"
+	SelectionAir      Selection = "a"
+	SelectionWater    Selection = "w"
 )
 
-// Beats checks whether the selection beats the other selection. Panics if either selection is none.
-func (s Selection) Beats(other Selection) bool {
-	if s == SelectionNone || other == SelectionNone {
-		panic("Unable to check beat with no selection!")
-	}
-	switch {
-	case s == SelectionRock && other == SelectionScissors:
-		return true
-	case s == SelectionPaper && other == SelectionRock:
-		return true
-	case s == SelectionScissors && other == SelectionPaper:
-		return true
-	}
-	return false
-}
-
 // ErrInvalidSelection is an error occurring when selection validation fails.
 var ErrInvalidSelection = errors.New("the provided value contains an invalid selection")
 
-// ValidateSelection returns an error if the given selection is not valid..
-func ValidateSelection(val Selection) error {
-	if val != SelectionRock && val != SelectionPaper && val != SelectionScissors {
-		return ErrInvalidSelection
+// RandomSelection returns a uniformly chosen selection from the given selections.
+func RandomSelection(selections []Selection) Selection {
+	index, err := rand.Int(rand.Reader, big.NewInt(int64(len(selections))))
+	if err != nil {
+		return selections[0]
 	}
-	return nil
+	return selections[index.Int64()]
 }
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// MinPasswordLength and MaxPasswordLength specify the supported range of characters in a password.
+	MinPasswordLength = 8
+	MaxPasswordLength = 128
+	// hashIterations specifies the number of PBKDF2 iterations used to hash the new passwords.
+	hashIterations = 100000
+	// saltSize specifies the number of random bytes in a password salt.
+	saltSize = 16
+	// sessionTokenSize specifies the number of random bytes in a session token.
+	sessionTokenSize = 32
+	// sessionTokenTTL specifies how long a session token can be used to log in without the password.
+	sessionTokenTTL = 24 * time.Hour
+)
+
+var (
+	ErrPasswordTooShort   = fmt.Errorf("password must contain at least %d characters", MinPasswordLength)
+	ErrPasswordTooLong    = fmt.Errorf("password must not contain more than %d characters", MaxPasswordLength)
+	ErrNameTaken          = errors.New("player name is already registered")
+	ErrInvalidCredentials = errors.New("invalid player name or password")
+	ErrInvalidToken       = errors.New("session token is unknown or has expired")
+)
+
+// ValidatePassword returns an error if the given password is not valid.
+func ValidatePassword(password string) error {
+	switch length := utf8.RuneCountInString(password); {
+	case length < MinPasswordLength:
+		return ErrPasswordTooShort
+	case length > MaxPasswordLength:
+		return ErrPasswordTooLong
+	}
+	return nil
+}
+
+// Account contains the stored credentials of a registered player. The password itself is never stored, only
+// the hash derived from the password and the salt with the given number of PBKDF2-HMAC-SHA256 iterations.
+type Account struct {
+	Name       string
+	Salt       []byte
+	Hash       []byte
+	Iterations int
+}
+
+// Authentication represents the outcome of a REGISTER or a LOGIN message. The token is the session token
+// issued for the authenticated account, and the error is set when the authentication failed.
+type Authentication struct {
+	Name  string
+	Token string
+	Err   error
+}
+
+// Accounts contains the registered player accounts and the issued session tokens. The accounts are written to
+// the file after each registration unless the file is empty, while the session tokens are only kept in memory.
+// Accounts is safe for concurrent use as the passwords are hashed outside the server main loop. The number of
+// passwords hashed at the same time is limited to half of the CPUs, so that the other work is not starved by
+// a flood of authentication attempts.
+type Accounts struct {
+	mutex    sync.Mutex
+	file     string
+	accounts map[string]Account
+	tokens   map[string]sessionToken
+	hashing  chan struct{}
+}
+
+// sessionToken describes the account and the expiration time of an issued session token.
+type sessionToken struct {
+	name    string
+	expires time.Time
+}
+
+// NewAccounts builds a new container without any accounts which is kept only in memory.
+func NewAccounts() *Accounts {
+	return &Accounts{
+		mutex:    sync.Mutex{},
+		file:     "",
+		accounts: make(map[string]Account),
+		tokens:   make(map[string]sessionToken),
+		hashing:  make(chan struct{}, hashingLimit()),
+	}
+}
+
+// hashingLimit returns how many passwords may be hashed at the same time.
+func hashingLimit() int {
+	if limit := runtime.NumCPU() / 2; limit > 1 {
+		return limit
+	}
+	return 1
+}
+
+// LoadAccounts builds a new container with the accounts stored in the given file. The file is created with
+// the first registration if it does not exist yet.
+func LoadAccounts(file string) (*Accounts, error) {
+	accounts := NewAccounts()
+	accounts.file = file
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return accounts, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read accounts file %q. %w", file, err)
+	}
+	stored := []Account{}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse accounts file %q. %w", file, err)
+	}
+	for _, account := range stored {
+		accounts.accounts[account.Name] = account
+	}
+	return accounts, nil
+}
+
+// Registered checks whether an account has been registered with the given name.
+func (a *Accounts) Registered(name string) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	_, ok := a.accounts[name]
+	return ok
+}
+
+// Register creates a new account with the given name and password and returns a session token for it.
+func (a *Accounts) Register(name, password string) (string, error) {
+	if err := ValidatePassword(password); err != nil {
+		return "", err
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to read random bytes for salt. %w", err)
+	}
+	hash := a.hash(password, salt, hashIterations)
+	account := Account{Name: name, Salt: salt, Hash: hash, Iterations: hashIterations}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if _, ok := a.accounts[name]; ok {
+		return "", ErrNameTaken
+	}
+	a.accounts[name] = account
+	if err := a.save(); err != nil {
+		delete(a.accounts, name)
+		return "", err
+	}
+	return a.issue(name)
+}
+
+// Login checks the password of the account with the given name and returns a new session token for it.
+func (a *Accounts) Login(name, password string) (string, error) {
+	a.mutex.Lock()
+	account, ok := a.accounts[name]
+	a.mutex.Unlock()
+	if !ok {
+		// The password is hashed anyway so that the unknown names cannot be told apart by the response time.
+		a.hash(password, make([]byte, saltSize), hashIterations)
+		return "", ErrInvalidCredentials
+	}
+	hash := a.hash(password, account.Salt, account.Iterations)
+	if subtle.ConstantTimeCompare(hash, account.Hash) != 1 {
+		return "", ErrInvalidCredentials
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.issue(name)
+}
+
+// Authenticate returns the name of the account the given session token was issued for.
+func (a *Accounts) Authenticate(token string) (string, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	session, ok := a.tokens[token]
+	if !ok || time.Now().After(session.expires) {
+		delete(a.tokens, token)
+		return "", ErrInvalidToken
+	}
+	return session.name, nil
+}
+
+// issue generates a new session token for the account with the given name. The expired tokens are removed
+// at the same time. The mutex must be held by the caller.
+func (a *Accounts) issue(name string) (string, error) {
+	bytes := make([]byte, sessionTokenSize)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to read random bytes for session token. %w", err)
+	}
+	now := time.Now()
+	for token, session := range a.tokens {
+		if now.After(session.expires) {
+			delete(a.tokens, token)
+		}
+	}
+	token := hex.EncodeToString(bytes)
+	a.tokens[token] = sessionToken{name: name, expires: now.Add(sessionTokenTTL)}
+	return token, nil
+}
+
+// save writes the accounts to the file unless the file is empty. The accounts are first written to a temporary
+// file which then replaces the file, so that a failed write never leaves a partially written file behind. The
+// mutex must be held by the caller.
+func (a *Accounts) save() error {
+	if a.file == "" {
+		return nil
+	}
+	stored := make([]Account, 0, len(a.accounts))
+	for _, account := range a.accounts {
+		stored = append(stored, account)
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].Name < stored[j].Name })
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal accounts. %w", err)
+	}
+	temp := a.file + ".tmp"
+	if err := os.WriteFile(temp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write accounts file %q. %w", temp, err)
+	}
+	if err := os.Rename(temp, a.file); err != nil {
+		return fmt.Errorf("failed to replace accounts file %q. %w", a.file, err)
+	}
+	return nil
+}
+
+// hash hashes the password with the salt once a hashing slot is free.
+func (a *Accounts) hash(password string, salt []byte, iterations int) []byte {
+	a.hashing <- struct{}{}
+	defer func() { <-a.hashing }()
+	return hashPassword(password, salt, iterations)
+}
+
+// hashPassword derives a key from the password and the salt with PBKDF2 using HMAC-SHA256 as the
+// pseudorandom function. The derived key is as long as a single SHA-256 digest.
+func hashPassword(password string, salt []byte, iterations int) []byte {
+	mac := hmac.New(sha256.New, []byte(password))
+	block := make([]byte, len(salt)+4)
+	copy(block, salt)
+	binary.BigEndian.PutUint32(block[len(salt):], 1)
+	mac.Write(block)
+	sum := mac.Sum(nil)
+	key := make([]byte, len(sum))
+	copy(key, sum)
+	for i := 1; i < iterations; i++ {
+		mac.Reset()
+		mac.Write(sum)
+		sum = mac.Sum(sum[:0])
+		for j := range key {
+			key[j] ^= sum[j]
+		}
+	}
+	return key
+}
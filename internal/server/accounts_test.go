@@ -0,0 +1,132 @@
+package server_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/toivjon/go-rps/internal/server"
+)
+
+func TestValidatePassword(t *testing.T) {
+	t.Parallel()
+	inputs := map[string]error{
+		strings.Repeat("a", server.MinPasswordLength-1): server.ErrPasswordTooShort,
+		strings.Repeat("a", server.MaxPasswordLength+1): server.ErrPasswordTooLong,
+		strings.Repeat("a", server.MinPasswordLength):   nil,
+		strings.Repeat("ä", server.MaxPasswordLength):   nil,
+	}
+	for input, expected := range inputs {
+		if err := server.ValidatePassword(input); !errors.Is(err, expected) {
+			t.Fatalf("Expected %q to return %v error, but %v was returned!", input, expected, err)
+		}
+	}
+}
+
+//nolint:funlen
+func TestAccounts(t *testing.T) {
+	t.Parallel()
+	t.Run("LoginWithRegisteredPassword", func(t *testing.T) {
+		t.Parallel()
+		accounts := server.NewAccounts()
+		if _, err := accounts.Register("donald", "password"); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if !accounts.Registered("donald") {
+			t.Fatal("Expected account to be registered, but it was not!")
+		}
+		token, err := accounts.Login("donald", "password")
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		name, err := accounts.Authenticate(token)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if name != "donald" {
+			t.Fatalf("Expected token to authenticate \"donald\", but authenticated %q!", name)
+		}
+	})
+	t.Run("ReturnErrorWhenCredentialsAreInvalid", func(t *testing.T) {
+		t.Parallel()
+		accounts := server.NewAccounts()
+		if _, err := accounts.Register("donald", "password"); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if _, err := accounts.Login("donald", "Password"); !errors.Is(err, server.ErrInvalidCredentials) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", server.ErrInvalidCredentials, err)
+		}
+		if _, err := accounts.Login("mickey", "password"); !errors.Is(err, server.ErrInvalidCredentials) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", server.ErrInvalidCredentials, err)
+		}
+		if _, err := accounts.Authenticate("token"); !errors.Is(err, server.ErrInvalidToken) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", server.ErrInvalidToken, err)
+		}
+	})
+	t.Run("ReturnErrorWhenRegisterIsInvalid", func(t *testing.T) {
+		t.Parallel()
+		accounts := server.NewAccounts()
+		if _, err := accounts.Register("donald", "short"); !errors.Is(err, server.ErrPasswordTooShort) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", server.ErrPasswordTooShort, err)
+		}
+		if _, err := accounts.Register("donald", "password"); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if _, err := accounts.Register("donald", "password"); !errors.Is(err, server.ErrNameTaken) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", server.ErrNameTaken, err)
+		}
+	})
+	t.Run("LoadRegisteredAccountsFromFile", func(t *testing.T) {
+		t.Parallel()
+		file := filepath.Join(t.TempDir(), "accounts.json")
+		accounts, err := server.LoadAccounts(file)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if _, err := accounts.Register("donald", "password"); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if strings.Contains(string(data), "password") {
+			t.Fatal("Expected the password to not be stored, but it was!")
+		}
+		if accounts, err = server.LoadAccounts(file); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if _, err := accounts.Login("donald", "password"); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+	})
+	t.Run("ReturnErrorWhenFileIsInvalid", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		file := filepath.Join(dir, "accounts.json")
+		if err := os.WriteFile(file, []byte("invalid"), 0o600); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if _, err := server.LoadAccounts(file); err == nil {
+			t.Fatal("Expected an error, but nil was returned!")
+		}
+		if _, err := server.LoadAccounts(dir); err == nil {
+			t.Fatal("Expected an error, but nil was returned!")
+		}
+	})
+	t.Run("ReturnErrorWhenFileCannotBeWritten", func(t *testing.T) {
+		t.Parallel()
+		accounts, err := server.LoadAccounts(filepath.Join(t.TempDir(), "missing", "accounts.json"))
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if _, err := accounts.Register("donald", "password"); err == nil {
+			t.Fatal("Expected an error, but nil was returned!")
+		}
+		if accounts.Registered("donald") {
+			t.Fatal("Expected account to not be registered, but it was!")
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/toivjon/go-rps/internal/com"
+	"github.com/toivjon/go-rps/internal/game"
+)
+
+// ErrBotClosed is an error occurring when a message is written to a bot which has already left the server.
+var ErrBotClosed = errors.New("bot has been closed")
+
+// Bot represents a server-side player which plays with a strategy instead of a remote connection. The bot acts
+// as the connection of its client, so the server writes the messages to the bot like to any other connection
+// and the client routine reads the replies of the bot like the messages of a remote client. The bot accepts
+// every rematch and leaves the server once its game session has been closed.
+type Bot struct {
+	Strategy Strategy
+	rules    game.RuleSet
+	format   game.Format
+	history  []game.Selection
+	mutex    sync.Mutex
+	replies  bytes.Buffer
+	ready    chan struct{}
+	closed   chan struct{}
+	once     sync.Once
+}
+
+// NewBot builds a new bot which plays with the given strategy.
+func NewBot(strategy Strategy) *Bot {
+	return &Bot{
+		Strategy: strategy,
+		rules:    game.Classic(),
+		format:   game.BestOf(1),
+		history:  nil,
+		mutex:    sync.Mutex{},
+		replies:  bytes.Buffer{},
+		ready:    make(chan struct{}, 1),
+		closed:   make(chan struct{}),
+		once:     sync.Once{},
+	}
+}
+
+// Read reads the replies of the bot. Blocks until the bot has replied or returns io.EOF once the bot is closed.
+func (b *Bot) Read(p []byte) (int, error) {
+	for {
+		b.mutex.Lock()
+		if b.replies.Len() > 0 {
+			n, _ := b.replies.Read(p)
+			b.mutex.Unlock()
+			return n, nil
+		}
+		b.mutex.Unlock()
+		select {
+		case <-b.ready:
+		case <-b.closed:
+			return 0, io.EOF
+		}
+	}
+}
+
+// Write handles a single framed message from the server and queues the reply of the bot if any.
+func (b *Bot) Write(p []byte) (int, error) {
+	select {
+	case <-b.closed:
+		return 0, ErrBotClosed
+	default:
+	}
+	message, err := com.Read[com.Message](bytes.NewReader(p))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read message. %w", err)
+	}
+	if err := b.handle(message); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// handle updates the state of the bot with the given message and replies to it when needed.
+func (b *Bot) handle(message *com.Message) error {
+	switch message.Type {
+	case com.TypeStart:
+		content := new(com.StartContent)
+		if err := json.Unmarshal(message.Content, content); err != nil {
+			return fmt.Errorf("failed to unmarshal START message content. %w", err)
+		}
+		rules, err := game.NewRuleSet(content.Rules, content.Options)
+		if err != nil {
+			return fmt.Errorf("failed to build rule set from START message. %w", err)
+		}
+		b.rules = rules
+		b.format = content.Format
+		return b.play(1)
+	case com.TypeResult:
+		content := new(com.ResultContent)
+		if err := json.Unmarshal(message.Content, content); err != nil {
+			return fmt.Errorf("failed to unmarshal RESULT message content. %w", err)
+		}
+		if content.OpponentSelection != game.SelectionNone {
+			b.history = append(b.history, content.OpponentSelection)
+		}
+		scores := []int{content.Score}
+		for _, opponent := range content.Opponents {
+			scores = append(scores, opponent.Score)
+		}
+		if content.Eliminated || b.format.Decided(scores...) {
+			return nil
+		}
+		return b.play(content.Round + 1)
+	case com.TypeRematchOffer:
+		return b.reply(com.TypeRematchAccept, com.RematchAcceptContent{})
+	case com.TypeError:
+		content := new(com.ErrorContent)
+		if err := json.Unmarshal(message.Content, content); err != nil {
+			return fmt.Errorf("failed to unmarshal ERROR message content. %w", err)
+		}
+		switch content.Code {
+		case com.CodeOpponentLeft, com.CodeRoundTimeout, com.CodeSessionFailed:
+			return b.Close()
+		case com.CodeInvalidMessage, com.CodeUnsupportedMessage, com.CodeUnexpectedMessage, com.CodeInvalidName,
+			com.CodeInvalidSelection, com.CodeResumeFailed, com.CodeRoomNotFound, com.CodeSessionNotFound,
+			com.CodeSessionClosed, com.CodeInvalidBot, com.CodeAuthRequired, com.CodeAuthFailed, com.CodeNameTaken,
+			com.CodeInvalidPassword:
+		}
+	case com.TypeHello, com.TypeWelcome, com.TypeReject, com.TypeJoin, com.TypeSelect, com.TypeMatchEnd,
+		com.TypeShutdown, com.TypeRematchAccept, com.TypeQueue, com.TypeResume, com.TypeResumed,
+		com.TypeCreateRoom, com.TypeRoomCreated, com.TypeJoinRoom, com.TypeSpectateList, com.TypeSpectateSessions,
+		com.TypeSpectate, com.TypeSpectateStart, com.TypeSpectateRound, com.TypeSpectateEnd,
+		com.TypeTournamentJoin, com.TypeBracket, com.TypeCommit, com.TypeCommitted, com.TypeReveal, com.TypeRegister,
+		com.TypeLogin, com.TypeAuthenticated:
+	}
+	return nil
+}
+
+// play replies with the selection of the strategy for the given round.
+func (b *Bot) play(round int) error {
+	selection := b.Strategy.Select(b.rules, b.history)
+	return b.reply(com.TypeSelect, com.SelectContent{Round: round, Selection: selection})
+}
+
+// reply queues the given message to be read by the client routine of the bot.
+func (b *Bot) reply(messageType com.MessageType, content any) error {
+	b.mutex.Lock()
+	err := com.WriteMessage(&b.replies, messageType, content)
+	b.mutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to reply to %s message. %w", messageType, err)
+	}
+	select {
+	case b.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Close makes the bot leave the server by ending the reads of its client routine.
+func (b *Bot) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	return nil
+}
@@ -0,0 +1,138 @@
+package server_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/toivjon/go-rps/internal/com"
+	"github.com/toivjon/go-rps/internal/game"
+	"github.com/toivjon/go-rps/internal/server"
+)
+
+// startBot builds a bot with the given strategy and starts a classic match of the given format for it.
+func startBot(t *testing.T, strategy server.Strategy, format game.Format) *server.Bot {
+	t.Helper()
+	bot := server.NewBot(strategy)
+	content := com.StartContent{
+		OpponentName: "donald",
+		Opponents:    []string{"donald"},
+		Format:       format,
+		Rules:        game.Classic().Name,
+		Options:      game.Classic().Options,
+		RoundTimeout: 0,
+		ResumeToken:  "",
+		Tournament:   false,
+		CommitReveal: false,
+	}
+	if err := com.WriteMessage(bot, com.TypeStart, content); err != nil {
+		t.Fatalf("Expected nil error, but %q was returned!", err)
+	}
+	return bot
+}
+
+// readSelect reads the SELECT reply of the bot.
+func readSelect(t *testing.T, bot *server.Bot) *com.SelectContent {
+	t.Helper()
+	content, err := com.ReadMessage[com.SelectContent](bot)
+	if err != nil {
+		t.Fatalf("Expected nil error, but %q was returned!", err)
+	}
+	return content
+}
+
+// writeResult writes a RESULT of the given round to the bot.
+func writeResult(t *testing.T, bot *server.Bot, round int, selection game.Selection, score int) {
+	t.Helper()
+	content := com.ResultContent{
+		Round:             round,
+		OpponentSelection: selection,
+		Result:            game.ResultWin,
+		Forfeit:           false,
+		Score:             score,
+		OpponentScore:     0,
+		Opponents:         []com.OpponentResult{{Name: "donald", Selection: selection, Result: game.ResultLose, Score: 0}},
+		Eliminated:        false,
+	}
+	if err := com.WriteMessage(bot, com.TypeResult, content); err != nil {
+		t.Fatalf("Expected nil error, but %q was returned!", err)
+	}
+}
+
+func TestBot(t *testing.T) {
+	t.Parallel()
+	t.Run("ReplySelectionWhenMatchIsStarted", func(t *testing.T) {
+		t.Parallel()
+		bot := startBot(t, server.RandomStrategy{}, game.BestOf(1))
+		content := readSelect(t, bot)
+		if content.Round != 1 || game.Classic().Validate(content.Selection) != nil {
+			t.Fatalf("Expected a valid selection for the first round, but was %+v!", content)
+		}
+	})
+	t.Run("ReplySelectionWhenMatchIsNotDecided", func(t *testing.T) {
+		t.Parallel()
+		bot := startBot(t, server.BeatLastStrategy{}, game.BestOf(3))
+		readSelect(t, bot)
+		writeResult(t, bot, 1, game.SelectionScissors, 1)
+		expected := com.SelectContent{Round: 2, Selection: game.SelectionRock}
+		if content := readSelect(t, bot); *content != expected {
+			t.Fatalf("Expected selection to be %+v, but was %+v!", expected, *content)
+		}
+	})
+	t.Run("AcceptRematchWhenMatchIsDecided", func(t *testing.T) {
+		t.Parallel()
+		bot := startBot(t, server.RandomStrategy{}, game.BestOf(1))
+		readSelect(t, bot)
+		writeResult(t, bot, 1, game.SelectionScissors, 1)
+		if err := com.WriteMessage(bot, com.TypeRematchOffer, com.RematchOfferContent{}); err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+		message, err := com.Read[com.Message](bot)
+		if err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+		if message.Type != com.TypeRematchAccept {
+			t.Fatalf("Expected the bot to accept the rematch, but %s was replied!", message.Type)
+		}
+	})
+	t.Run("CloseWhenSessionIsClosed", func(t *testing.T) {
+		t.Parallel()
+		bot := startBot(t, server.RandomStrategy{}, game.BestOf(1))
+		content := com.ErrorContent{Code: com.CodeOpponentLeft, Message: "bye"}
+		if err := com.WriteMessage(bot, com.TypeError, content); err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+		readSelect(t, bot)
+		if _, err := bot.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", io.EOF, err)
+		}
+		if _, err := bot.Write(nil); !errors.Is(err, server.ErrBotClosed) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", server.ErrBotClosed, err)
+		}
+	})
+	t.Run("ReturnErrorWhenMessageIsInvalid", func(t *testing.T) {
+		t.Parallel()
+		bot := server.NewBot(server.RandomStrategy{})
+		if _, err := bot.Write([]byte("non-frame")); err == nil {
+			t.Fatal("Expected non-nil error, but nil was returned!")
+		}
+		for _, messageType := range []com.MessageType{com.TypeStart, com.TypeResult, com.TypeError} {
+			if err := com.WriteMessage(bot, messageType, "non-json"); err == nil {
+				t.Fatalf("Expected non-nil error for %s, but nil was returned!", messageType)
+			}
+		}
+		if err := com.WriteMessage(bot, com.TypeStart, com.StartContent{
+			OpponentName: "donald",
+			Opponents:    []string{"donald"},
+			Format:       game.BestOf(1),
+			Rules:        "classic",
+			Options:      nil,
+			RoundTimeout: 0,
+			ResumeToken:  "",
+			Tournament:   false,
+			CommitReveal: false,
+		}); err == nil {
+			t.Fatal("Expected non-nil error for a START without options, but nil was returned!")
+		}
+	})
+}
@@ -1,86 +1,382 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"time"
 
 	"github.com/toivjon/go-rps/internal/com"
 	"github.com/toivjon/go-rps/internal/game"
 )
 
-// Client represents a single client connected to the server.
+// ErrUnsupportedMessage is an error occurring when a client sends a message type which only the server sends
+// or which is not known at all.
+var ErrUnsupportedMessage = errors.New("unsupported message type")
+
+// ClientState specifies the matchmaking state of a client.
+type ClientState string
+
+const (
+	StateConnected  ClientState = "CONNECTED"  // Client has connected but has not yet joined.
+	StateQueued     ClientState = "QUEUED"     // Client has joined and waits for an opponent.
+	StatePlaying    ClientState = "PLAYING"    // Client plays in a game session.
+	StateLobby      ClientState = "LOBBY"      // Client has left a game session and may queue again.
+	StateSpectating ClientState = "SPECTATING" // Client watches a game session without playing.
+	StateTournament ClientState = "TOURNAMENT" // Client has registered to a tournament and waits for its next match.
+)
+
+// Client represents a single client connected to the server. A client which has lost its connection while
+// playing keeps its seat in the session until the resume deadline passes. A spectator client refers to the
+// watched session with the spectating instead of the session. A tournament participant refers to the
+// tournament until it has been decided or the client withdraws from it. The account is the name of the player
+// account the client has logged in to, and it is empty for a guest.
 type Client struct {
-	Conn    io.ReadWriteCloser
-	Name    string
-	Session *Session
+	Conn           io.ReadWriteCloser
+	Name           string
+	Account        string
+	Authenticating bool
+	State          ClientState
+	Session        *Session
+	Spectating     *Session
+	Tournament     *Tournament
+	Version        int
+	Capabilities   []com.Capability
+	ResumeToken    string
+	ResumeDeadline time.Time
 }
 
 // NewClient builds a new client with the provided connection.
 func NewClient(conn io.ReadWriteCloser) *Client {
 	return &Client{
-		Conn:    conn,
-		Name:    "",
-		Session: nil,
+		Conn:           conn,
+		Name:           "",
+		Account:        "",
+		Authenticating: false,
+		State:          StateConnected,
+		Session:        nil,
+		Spectating:     nil,
+		Tournament:     nil,
+		Version:        0,
+		Capabilities:   nil,
+		ResumeToken:    "",
+		ResumeDeadline: time.Time{},
+	}
+}
+
+// Handshaked checks whether the client has completed the protocol version handshake.
+func (c *Client) Handshaked() bool {
+	return c.Version != 0
+}
+
+// Identity returns the name the client joins with. The common name of a verified client certificate of a
+// mutually authenticated TLS connection overrides the name declared by the client, and so does the name of the
+// player account the client has logged in to.
+func (c *Client) Identity(declared string) string {
+	if name := com.PeerName(c.Conn); name != "" {
+		return name
+	}
+	if c.Account != "" {
+		return c.Account
+	}
+	return declared
+}
+
+// Authenticated checks whether the client has logged in to a player account or presented a verified client
+// certificate.
+func (c *Client) Authenticated() bool {
+	return c.Account != "" || com.PeerName(c.Conn) != ""
+}
+
+// Detached checks whether the client has lost its connection and waits to be resumed.
+func (c *Client) Detached() bool {
+	return !c.ResumeDeadline.IsZero()
+}
+
+// WriteWelcome sends a WELCOME message to the client.
+func (c *Client) WriteWelcome(content com.WelcomeContent) error {
+	if err := c.write(com.TypeWelcome, content); err != nil {
+		return fmt.Errorf("failed to write WELCOME message. %w", err)
+	}
+	return nil
+}
+
+// WriteAuthenticated sends an AUTHENTICATED message to the client.
+func (c *Client) WriteAuthenticated(name, token string) error {
+	if err := c.write(com.TypeAuthenticated, com.AuthenticatedContent{Name: name, Token: token}); err != nil {
+		return fmt.Errorf("failed to write AUTHENTICATED message. %w", err)
+	}
+	return nil
+}
+
+// WriteReject sends a REJECT message to the client.
+func (c *Client) WriteReject(reason string) error {
+	content := com.RejectContent{
+		Reason:     reason,
+		MinVersion: com.MinProtocolVersion,
+		MaxVersion: com.ProtocolVersion,
+	}
+	if err := c.write(com.TypeReject, content); err != nil {
+		return fmt.Errorf("failed to write REJECT message. %w", err)
 	}
+	return nil
 }
 
 // WriteStart sends a START message to the client.
-func (c *Client) WriteStart(opponentName string) error {
-	content := com.StartContent{OpponentName: opponentName}
-	if err := com.WriteMessage(c.Conn, com.TypeStart, content); err != nil {
+func (c *Client) WriteStart(content com.StartContent) error {
+	if err := c.write(com.TypeStart, content); err != nil {
 		return fmt.Errorf("failed to write START message. %w", err)
 	}
 	return nil
 }
 
 // WriteResult sends a RESULT message to the client.
-func (c *Client) WriteResult(opponentSelection game.Selection, result game.Result) error {
-	messageContent := com.ResultContent{OpponentSelection: opponentSelection, Result: result}
-	if err := com.WriteMessage(c.Conn, com.TypeResult, messageContent); err != nil {
+func (c *Client) WriteResult(content com.ResultContent) error {
+	if err := c.write(com.TypeResult, content); err != nil {
 		return fmt.Errorf("failed to write RESULT message. %w", err)
 	}
 	return nil
 }
 
-// Run starts the processing of the client.
-func (c *Client) Run(
-	leaveCh chan<- io.ReadWriteCloser,
-	joinCh chan<- Message[com.JoinContent],
-	selectCh chan<- Message[com.SelectContent],
-) {
+// WriteCommitted sends a COMMITTED message to the client.
+func (c *Client) WriteCommitted(round int) error {
+	if err := c.write(com.TypeCommitted, com.CommittedContent{Round: round}); err != nil {
+		return fmt.Errorf("failed to write COMMITTED message. %w", err)
+	}
+	return nil
+}
+
+// WriteMatchEnd sends a MATCH_END message to the client.
+func (c *Client) WriteMatchEnd(result game.Result, score, opponentScore int) error {
+	content := com.MatchEndContent{Result: result, Score: score, OpponentScore: opponentScore}
+	if err := c.write(com.TypeMatchEnd, content); err != nil {
+		return fmt.Errorf("failed to write MATCH_END message. %w", err)
+	}
+	return nil
+}
+
+// WriteRematchOffer sends a REMATCH_OFFER message to the client.
+func (c *Client) WriteRematchOffer() error {
+	if err := c.write(com.TypeRematchOffer, com.RematchOfferContent{}); err != nil {
+		return fmt.Errorf("failed to write REMATCH_OFFER message. %w", err)
+	}
+	return nil
+}
+
+// WriteRoomCreated sends a ROOM_CREATED message to the client.
+func (c *Client) WriteRoomCreated(code string) error {
+	if err := c.write(com.TypeRoomCreated, com.RoomCreatedContent{Code: code}); err != nil {
+		return fmt.Errorf("failed to write ROOM_CREATED message. %w", err)
+	}
+	return nil
+}
+
+// WriteResumed sends a RESUMED message to the client.
+func (c *Client) WriteResumed(content com.ResumedContent) error {
+	if err := c.write(com.TypeResumed, content); err != nil {
+		return fmt.Errorf("failed to write RESUMED message. %w", err)
+	}
+	return nil
+}
+
+// WriteSpectateSessions sends a SPECTATE_SESSIONS message to the client.
+func (c *Client) WriteSpectateSessions(sessions []com.SessionInfo) error {
+	if err := c.write(com.TypeSpectateSessions, com.SpectateSessionsContent{Sessions: sessions}); err != nil {
+		return fmt.Errorf("failed to write SPECTATE_SESSIONS message. %w", err)
+	}
+	return nil
+}
+
+// WriteSpectateStart sends a SPECTATE_START message to the client.
+func (c *Client) WriteSpectateStart(content com.SpectateStartContent) error {
+	if err := c.write(com.TypeSpectateStart, content); err != nil {
+		return fmt.Errorf("failed to write SPECTATE_START message. %w", err)
+	}
+	return nil
+}
+
+// WriteSpectateRound sends a SPECTATE_ROUND message to the client.
+func (c *Client) WriteSpectateRound(content com.SpectateRoundContent) error {
+	if err := c.write(com.TypeSpectateRound, content); err != nil {
+		return fmt.Errorf("failed to write SPECTATE_ROUND message. %w", err)
+	}
+	return nil
+}
+
+// WriteSpectateEnd sends a SPECTATE_END message to the client.
+func (c *Client) WriteSpectateEnd(content com.SpectateEndContent) error {
+	if err := c.write(com.TypeSpectateEnd, content); err != nil {
+		return fmt.Errorf("failed to write SPECTATE_END message. %w", err)
+	}
+	return nil
+}
+
+// WriteBracket sends a BRACKET message to the client.
+func (c *Client) WriteBracket(content com.BracketContent) error {
+	if err := c.write(com.TypeBracket, content); err != nil {
+		return fmt.Errorf("failed to write BRACKET message. %w", err)
+	}
+	return nil
+}
+
+// WriteShutdown sends a SHUTDOWN message to the client.
+func (c *Client) WriteShutdown(reason string, grace time.Duration) error {
+	content := com.ShutdownContent{Reason: reason, Grace: grace}
+	if err := c.write(com.TypeShutdown, content); err != nil {
+		return fmt.Errorf("failed to write SHUTDOWN message. %w", err)
+	}
+	return nil
+}
+
+// WriteError sends an ERROR message to the client.
+func (c *Client) WriteError(code com.ErrorCode, message string) error {
+	content := com.ErrorContent{Code: code, Message: message}
+	if err := c.write(com.TypeError, content); err != nil {
+		return fmt.Errorf("failed to write ERROR message. %w", err)
+	}
+	return nil
+}
+
+// write sends a message to the client unless the client is detached, in which case the message is dropped as
+// the session state gets replayed when the client is resumed.
+func (c *Client) write(messageType com.MessageType, content any) error {
+	if c.Detached() {
+		return nil
+	}
+	if err := com.WriteMessage(c.Conn, messageType, content); err != nil {
+		return fmt.Errorf("failed to write to connection. %w", err)
+	}
+	return nil
+}
+
+// Inbox contains the channels where a client forwards the received messages for the server to handle.
+type Inbox struct {
+	Leave          chan<- io.ReadWriteCloser
+	Hello          chan<- Message[com.HelloContent]
+	Join           chan<- Message[com.JoinContent]
+	Select         chan<- Message[com.SelectContent]
+	Offer          chan<- Message[com.RematchOfferContent]
+	Accept         chan<- Message[com.RematchAcceptContent]
+	Queue          chan<- Message[com.QueueContent]
+	Resume         chan<- Message[com.ResumeContent]
+	CreateRoom     chan<- Message[com.CreateRoomContent]
+	JoinRoom       chan<- Message[com.JoinRoomContent]
+	SpectateList   chan<- Message[com.SpectateListContent]
+	Spectate       chan<- Message[com.SpectateContent]
+	TournamentJoin chan<- Message[com.TournamentJoinContent]
+	Commit         chan<- Message[com.CommitContent]
+	Reveal         chan<- Message[com.RevealContent]
+	Register       chan<- Message[com.RegisterContent]
+	Login          chan<- Message[com.LoginContent]
+}
+
+// Run starts the processing of the client. The processing stops when the connection is closed, the client
+// sends an invalid message or the given context is done. Returns an error describing why it was stopped.
+// The connection is captured when the processing starts, as the server main loop hands the client over to a
+// new connection when the player resumes its seat after the connection has been left.
+func (c *Client) Run(ctx context.Context, inbox Inbox) error {
+	conn := c.Conn
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
 	defer func() {
-		leaveCh <- c.Conn
-		c.Conn.Close()
+		select {
+		case inbox.Leave <- conn:
+		case <-ctx.Done():
+		}
+		conn.Close()
 	}()
 	for {
-		message, err := com.Read[com.Message](c.Conn)
+		message, err := com.Read[com.Message](conn)
+		if ctx.Err() != nil {
+			return fmt.Errorf("client was stopped. %w", ctx.Err())
+		}
 		if err != nil {
-			return
+			return fmt.Errorf("failed to read message. %w", err)
 		}
 		switch message.Type {
+		case com.TypeHello:
+			err = forward(ctx, c, conn, message, inbox.Hello)
 		case com.TypeJoin:
-			content := new(com.JoinContent)
-			if err := json.Unmarshal(message.Content, &content); err != nil {
-				log.Printf("Failed to unmarshal %T message content. %s", content, err)
-				return
-			}
-			joinCh <- Message[com.JoinContent]{Conn: c.Conn, Content: *content}
+			err = forward(ctx, c, conn, message, inbox.Join)
 		case com.TypeSelect:
-			content := new(com.SelectContent)
-			if err := json.Unmarshal(message.Content, &content); err != nil {
-				log.Printf("Failed to unmarshal %T message content. %s", content, err)
-				return
-			}
-			selectCh <- Message[com.SelectContent]{Conn: c.Conn, Content: *content}
-		case com.TypeResult, com.TypeStart:
-			log.Printf("Connection %#p received unsupported message type %s!", c.Conn, message.Type)
-			return
+			err = forward(ctx, c, conn, message, inbox.Select)
+		case com.TypeRematchOffer:
+			err = forward(ctx, c, conn, message, inbox.Offer)
+		case com.TypeRematchAccept:
+			err = forward(ctx, c, conn, message, inbox.Accept)
+		case com.TypeQueue:
+			err = forward(ctx, c, conn, message, inbox.Queue)
+		case com.TypeResume:
+			err = forward(ctx, c, conn, message, inbox.Resume)
+		case com.TypeCreateRoom:
+			err = forward(ctx, c, conn, message, inbox.CreateRoom)
+		case com.TypeJoinRoom:
+			err = forward(ctx, c, conn, message, inbox.JoinRoom)
+		case com.TypeSpectateList:
+			err = forward(ctx, c, conn, message, inbox.SpectateList)
+		case com.TypeSpectate:
+			err = forward(ctx, c, conn, message, inbox.Spectate)
+		case com.TypeTournamentJoin:
+			err = forward(ctx, c, conn, message, inbox.TournamentJoin)
+		case com.TypeCommit:
+			err = forward(ctx, c, conn, message, inbox.Commit)
+		case com.TypeReveal:
+			err = forward(ctx, c, conn, message, inbox.Reveal)
+		case com.TypeRegister:
+			err = forward(ctx, c, conn, message, inbox.Register)
+		case com.TypeLogin:
+			err = forward(ctx, c, conn, message, inbox.Login)
+		case com.TypeWelcome, com.TypeReject, com.TypeResult, com.TypeStart, com.TypeMatchEnd, com.TypeError,
+			com.TypeShutdown, com.TypeResumed, com.TypeRoomCreated, com.TypeSpectateSessions, com.TypeSpectateStart,
+			com.TypeSpectateRound, com.TypeSpectateEnd, com.TypeBracket, com.TypeCommitted, com.TypeAuthenticated:
+			c.fail(com.CodeUnsupportedMessage, fmt.Sprintf("message type %s is not supported", message.Type))
+			return fmt.Errorf("%w: %s", ErrUnsupportedMessage, message.Type)
+		default:
+			c.fail(com.CodeUnsupportedMessage, fmt.Sprintf("message type %q is not known", message.Type))
+			return fmt.Errorf("%w: %q", ErrUnsupportedMessage, message.Type)
+		}
+		if err != nil {
+			return err
 		}
 	}
 }
 
+// forward unmarshals the message content and passes it to the given channel as a message from the connection
+// unless the context is done.
+func forward[T any](ctx context.Context, c *Client, conn io.ReadWriteCloser, message *com.Message,
+	ch chan<- Message[T],
+) error {
+	content := new(T)
+	if err := json.Unmarshal(message.Content, content); err != nil {
+		c.fail(com.CodeInvalidMessage, fmt.Sprintf("failed to unmarshal %s message content", message.Type))
+		return fmt.Errorf("failed to unmarshal %s message content. %w", message.Type, err)
+	}
+	select {
+	case ch <- Message[T]{Conn: conn, Content: *content}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("client was stopped. %w", ctx.Err())
+	}
+}
+
+// fail logs the failure and reports it to the client before the connection gets closed.
+func (c *Client) fail(code com.ErrorCode, message string) {
+	log.Printf("Connection %#p failed (code: %s). %s", c.Conn, code, message)
+	if err := c.WriteError(code, message); err != nil {
+		log.Printf("Failed to report failure to connection %#p. %s", c.Conn, err)
+	}
+}
+
 // String returns a string representing the client.
 func (c *Client) String() string {
 	return fmt.Sprintf("client(%#p:%s)", c.Conn, c.Name)
@@ -1,10 +1,13 @@
 package server_test
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/toivjon/go-rps/internal/com"
 	"github.com/toivjon/go-rps/internal/game"
@@ -23,10 +26,22 @@ type readerMock struct {
 }
 
 func (r *readerMock) Read(p []byte) (int, error) {
-	result := r.results[0]
-	copy(p, result.data)
+	result := &r.results[0]
+	n := copy(p, result.data)
+	result.data = result.data[n:]
+	if len(result.data) > 0 {
+		return n, nil
+	}
 	r.results = r.results[1:]
-	return len(result.data), result.err
+	return n, result.err
+}
+
+func framed(data string) []byte {
+	buffer := new(bytes.Buffer)
+	if err := com.WriteFrame(buffer, []byte(data), com.MaxFrameSize); err != nil {
+		panic(err)
+	}
+	return buffer.Bytes()
 }
 
 type writerMock struct {
@@ -67,14 +82,116 @@ func TestNewClient(t *testing.T) {
 	}
 }
 
+func TestClientHandshaked(t *testing.T) {
+	t.Parallel()
+	cli := server.NewClient(new(connMock))
+	if cli.Handshaked() {
+		t.Fatal("Expected new client to not be handshaked, but it was!")
+	}
+	cli.Version = com.ProtocolVersion
+	if !cli.Handshaked() {
+		t.Fatal("Expected client with a version to be handshaked, but it was not!")
+	}
+}
+
+func TestClientIdentity(t *testing.T) {
+	t.Parallel()
+	cli := server.NewClient(new(connMock))
+	if name := cli.Identity("donald"); name != "donald" {
+		t.Fatalf("Expected the declared name %q to be used, but was %q", "donald", name)
+	}
+	if cli.Authenticated() {
+		t.Fatal("Expected client to not be authenticated, but it was!")
+	}
+	cli.Account = "mickey"
+	if name := cli.Identity("donald"); name != "mickey" {
+		t.Fatalf("Expected the account name %q to be used, but was %q", "mickey", name)
+	}
+	if !cli.Authenticated() {
+		t.Fatal("Expected client to be authenticated, but it was not!")
+	}
+}
+
+func TestClientWriteAuthenticated(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenWriteFails", func(t *testing.T) {
+		t.Parallel()
+		conn := new(connMock)
+		conn.writerMock.err = errMock
+		cli := server.NewClient(conn)
+		if err := cli.WriteAuthenticated("donald", "token"); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnNilWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		cli := server.NewClient(new(connMock))
+		if err := cli.WriteAuthenticated("donald", "token"); err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+}
+
+func TestClientWriteWelcome(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenWriteFails", func(t *testing.T) {
+		t.Parallel()
+		conn := new(connMock)
+		conn.writerMock.err = errMock
+		cli := server.NewClient(conn)
+		welcome := com.WelcomeContent{Version: com.ProtocolVersion, Capabilities: nil}
+		if err := cli.WriteWelcome(welcome); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnNilWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		cli := server.NewClient(new(connMock))
+		if err := cli.WriteWelcome(com.WelcomeContent{Version: com.ProtocolVersion, Capabilities: nil}); err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+}
+
+func TestClientWriteReject(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenWriteFails", func(t *testing.T) {
+		t.Parallel()
+		conn := new(connMock)
+		conn.writerMock.err = errMock
+		cli := server.NewClient(conn)
+		if err := cli.WriteReject(""); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnNilWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		cli := server.NewClient(new(connMock))
+		if err := cli.WriteReject(""); err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+}
+
 func TestClientWriteStart(t *testing.T) {
 	t.Parallel()
+	start := com.StartContent{
+		OpponentName: "",
+		Opponents:    nil,
+		Format:       game.BestOf(1),
+		Rules:        game.Classic().Name,
+		Options:      game.Classic().Options,
+		RoundTimeout: time.Minute,
+		ResumeToken:  "",
+		Tournament:   false,
+		CommitReveal: false,
+	}
 	t.Run("ReturnErrorWhenWriteFails", func(t *testing.T) {
 		t.Parallel()
 		conn := new(connMock)
 		conn.writerMock.err = errMock
 		cli := server.NewClient(conn)
-		if err := cli.WriteStart(""); !errors.Is(err, errMock) {
+		if err := cli.WriteStart(start); !errors.Is(err, errMock) {
 			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
 		}
 	})
@@ -82,28 +199,254 @@ func TestClientWriteStart(t *testing.T) {
 		t.Parallel()
 		conn := new(connMock)
 		cli := server.NewClient(conn)
-		if err := cli.WriteStart(""); err != nil {
+		if err := cli.WriteStart(start); err != nil {
 			t.Fatalf("Expected nil error, but %q was returned!", err)
 		}
 	})
 }
 
 func TestClientWriteResult(t *testing.T) {
+	t.Parallel()
+	result := com.ResultContent{
+		Round:             1,
+		OpponentSelection: game.SelectionRock,
+		Result:            game.ResultWin,
+		Forfeit:           false,
+		Score:             1,
+		OpponentScore:     0,
+		Opponents:         nil,
+		Eliminated:        false,
+	}
+	t.Run("ReturnErrorWhenWriteFails", func(t *testing.T) {
+		t.Parallel()
+		conn := new(connMock)
+		conn.writerMock.err = errMock
+		cli := server.NewClient(conn)
+		if err := cli.WriteResult(result); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnNilWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		conn := new(connMock)
+		cli := server.NewClient(conn)
+		if err := cli.WriteResult(result); err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+}
+
+func TestClientWriteResumed(t *testing.T) {
+	t.Parallel()
+	resumed := com.ResumedContent{
+		Start: com.StartContent{
+			OpponentName: "",
+			Opponents:    nil,
+			Format:       game.BestOf(1),
+			Rules:        game.Classic().Name,
+			Options:      game.Classic().Options,
+			RoundTimeout: time.Minute,
+			ResumeToken:  "",
+			Tournament:   false,
+			CommitReveal: false,
+		},
+		Round:          1,
+		Selection:      game.SelectionNone,
+		Score:          0,
+		OpponentScore:  0,
+		OpponentScores: nil,
+		Eliminated:     false,
+		Ended:          false,
+		Offered:        false,
+	}
+	t.Run("ReturnErrorWhenWriteFails", func(t *testing.T) {
+		t.Parallel()
+		conn := new(connMock)
+		conn.writerMock.err = errMock
+		cli := server.NewClient(conn)
+		if err := cli.WriteResumed(resumed); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnNilWhenClientIsDetached", func(t *testing.T) {
+		t.Parallel()
+		conn := new(connMock)
+		conn.writerMock.err = errMock
+		cli := server.NewClient(conn)
+		cli.ResumeDeadline = time.Now()
+		if err := cli.WriteResumed(resumed); err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+}
+
+func TestClientWriteShutdown(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenWriteFails", func(t *testing.T) {
+		t.Parallel()
+		conn := new(connMock)
+		conn.writerMock.err = errMock
+		cli := server.NewClient(conn)
+		if err := cli.WriteShutdown("", time.Second); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnNilWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		conn := new(connMock)
+		cli := server.NewClient(conn)
+		if err := cli.WriteShutdown("", time.Second); err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+}
+
+func TestClientWriteRematchOffer(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenWriteFails", func(t *testing.T) {
+		t.Parallel()
+		conn := new(connMock)
+		conn.writerMock.err = errMock
+		cli := server.NewClient(conn)
+		if err := cli.WriteRematchOffer(); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnNilWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		cli := server.NewClient(new(connMock))
+		if err := cli.WriteRematchOffer(); err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+}
+
+func TestClientWriteRoomCreated(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenWriteFails", func(t *testing.T) {
+		t.Parallel()
+		conn := new(connMock)
+		conn.writerMock.err = errMock
+		cli := server.NewClient(conn)
+		if err := cli.WriteRoomCreated("ABC123"); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnNilWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		cli := server.NewClient(new(connMock))
+		if err := cli.WriteRoomCreated("ABC123"); err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+}
+
+func TestClientWriteBracket(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenWriteFails", func(t *testing.T) {
+		t.Parallel()
+		conn := new(connMock)
+		conn.writerMock.err = errMock
+		cli := server.NewClient(conn)
+		if err := cli.WriteBracket(server.NewTournament(2).Bracket(cli)); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnNilWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		cli := server.NewClient(new(connMock))
+		if err := cli.WriteBracket(server.NewTournament(2).Bracket(cli)); err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+}
+
+func TestClientWriteSpectateEvents(t *testing.T) {
+	t.Parallel()
+	writes := map[string]func(cli *server.Client) error{
+		"SPECTATE_SESSIONS": func(cli *server.Client) error {
+			return cli.WriteSpectateSessions([]com.SessionInfo{
+				{ID: 1, Players: []string{"donald", "mickey"}, Round: 1, Scores: []int{0, 0}},
+			})
+		},
+		"SPECTATE_START": func(cli *server.Client) error {
+			return cli.WriteSpectateStart(com.SpectateStartContent{
+				Session: com.SessionInfo{ID: 1, Players: []string{"donald", "mickey"}, Round: 1, Scores: []int{0, 0}},
+				Format:  game.BestOf(1),
+				Rules:   game.Classic().Name,
+			})
+		},
+		"SPECTATE_ROUND": func(cli *server.Client) error {
+			return cli.WriteSpectateRound(com.SpectateRoundContent{
+				Round:      1,
+				Selections: []game.Selection{game.SelectionRock, game.SelectionPaper},
+				Results:    []game.Result{game.ResultLose, game.ResultWin},
+				Forfeit:    false,
+				Scores:     []int{0, 1},
+			})
+		},
+		"SPECTATE_END": func(cli *server.Client) error {
+			return cli.WriteSpectateEnd(com.SpectateEndContent{
+				Results: []game.Result{game.ResultLose, game.ResultWin},
+				Scores:  []int{0, 1},
+			})
+		},
+	}
+	t.Run("ReturnErrorWhenWriteFails", func(t *testing.T) {
+		t.Parallel()
+		for messageType, write := range writes {
+			conn := new(connMock)
+			conn.writerMock.err = errMock
+			if err := write(server.NewClient(conn)); !errors.Is(err, errMock) {
+				t.Fatalf("Expected %q error in the chain %q of %s, but did not exists!", errMock, err, messageType)
+			}
+		}
+	})
+	t.Run("ReturnNilWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		for messageType, write := range writes {
+			if err := write(server.NewClient(new(connMock))); err != nil {
+				t.Fatalf("Expected nil error for %s, but %q was returned!", messageType, err)
+			}
+		}
+	})
+}
+
+func TestClientWriteError(t *testing.T) {
 	t.Parallel()
 	t.Run("ReturnErrorWhenWriteFails", func(t *testing.T) {
 		t.Parallel()
 		conn := new(connMock)
 		conn.writerMock.err = errMock
 		cli := server.NewClient(conn)
-		if err := cli.WriteResult(game.SelectionRock, game.ResultWin); !errors.Is(err, errMock) {
+		if err := cli.WriteError(com.CodeSessionFailed, ""); !errors.Is(err, errMock) {
 			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
 		}
 	})
 	t.Run("ReturnNilWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		cli := server.NewClient(new(connMock))
+		if err := cli.WriteError(com.CodeSessionFailed, ""); err != nil {
+			t.Fatalf("Expected nil error, but %q was returned!", err)
+		}
+	})
+}
+
+func TestClientWriteMatchEnd(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenWriteFails", func(t *testing.T) {
 		t.Parallel()
 		conn := new(connMock)
+		conn.writerMock.err = errMock
 		cli := server.NewClient(conn)
-		if err := cli.WriteResult(game.SelectionRock, game.ResultWin); err != nil {
+		if err := cli.WriteMatchEnd(game.ResultWin, 1, 0); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReturnNilWhenSuccess", func(t *testing.T) {
+		t.Parallel()
+		cli := server.NewClient(new(connMock))
+		if err := cli.WriteMatchEnd(game.ResultWin, 1, 0); err != nil {
 			t.Fatalf("Expected nil error, but %q was returned!", err)
 		}
 	})
@@ -118,7 +461,43 @@ func TestClientRun(t *testing.T) {
 		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: nil, err: errMock})
 		cli := server.NewClient(conn)
 		leaveCh := make(chan io.ReadWriteCloser, 1)
-		cli.Run(leaveCh, nil, nil)
+		if err := cli.Run(context.Background(), newInbox(leaveCh)); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+		if leaveConn := <-leaveCh; leaveConn != conn {
+			t.Fatalf("Expected leave to be called with connection %#p but was %#p!", conn, leaveConn)
+		}
+	})
+	t.Run("CloseOwnConnWhenSeatIsHandedOver", func(t *testing.T) {
+		t.Parallel()
+		conn := newFullConnMock()
+		cli := server.NewClient(conn)
+		leaveCh := make(chan io.ReadWriteCloser)
+		done := make(chan error)
+		go func() { done <- cli.Run(context.Background(), newInbox(leaveCh)) }()
+		conn.Close()
+		if leaveConn := <-leaveCh; leaveConn != conn {
+			t.Fatalf("Expected leave to be called with connection %#p but was %#p!", conn, leaveConn)
+		}
+		resumed := newFullConnMock()
+		cli.Conn = resumed
+		<-done
+		select {
+		case <-resumed.closed:
+			t.Fatal("Expected the resumed connection to stay open, but it was closed!")
+		default:
+		}
+	})
+	t.Run("ReturnErrorWhenHelloUnmarshalFails", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"HELLO","content":"non-json"}`
+		conn := new(connMock)
+		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: framed(data), err: nil})
+		cli := server.NewClient(conn)
+		leaveCh := make(chan io.ReadWriteCloser, 1)
+		if err := cli.Run(context.Background(), newInbox(leaveCh)); err == nil {
+			t.Fatal("Expected non-nil error, but nil was returned!")
+		}
 		if leaveConn := <-leaveCh; leaveConn != conn {
 			t.Fatalf("Expected leave to be called with connection %#p but was %#p!", conn, leaveConn)
 		}
@@ -127,10 +506,12 @@ func TestClientRun(t *testing.T) {
 		t.Parallel()
 		data := `{"type":"JOIN","content":"non-json"}`
 		conn := new(connMock)
-		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: []byte(data), err: nil})
+		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: framed(data), err: nil})
 		cli := server.NewClient(conn)
 		leaveCh := make(chan io.ReadWriteCloser, 1)
-		cli.Run(leaveCh, nil, nil)
+		if err := cli.Run(context.Background(), newInbox(leaveCh)); err == nil {
+			t.Fatal("Expected non-nil error, but nil was returned!")
+		}
 		if leaveConn := <-leaveCh; leaveConn != conn {
 			t.Fatalf("Expected leave to be called with connection %#p but was %#p!", conn, leaveConn)
 		}
@@ -139,38 +520,103 @@ func TestClientRun(t *testing.T) {
 		t.Parallel()
 		data := `{"type":"SELECT","content":"non-json"}`
 		conn := new(connMock)
-		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: []byte(data), err: nil})
+		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: framed(data), err: nil})
 		cli := server.NewClient(conn)
 		leaveCh := make(chan io.ReadWriteCloser, 1)
-		cli.Run(leaveCh, nil, nil)
+		if err := cli.Run(context.Background(), newInbox(leaveCh)); err == nil {
+			t.Fatal("Expected non-nil error, but nil was returned!")
+		}
 		if leaveConn := <-leaveCh; leaveConn != conn {
 			t.Fatalf("Expected leave to be called with connection %#p but was %#p!", conn, leaveConn)
 		}
 	})
 	t.Run("ReturnErrorWhenUnsupportedTypeIsReceived", func(t *testing.T) {
 		t.Parallel()
-		for _, messageType := range []com.MessageType{com.TypeResult, com.TypeStart} {
+		for _, messageType := range []com.MessageType{
+			com.TypeWelcome,
+			com.TypeReject,
+			com.TypeResult,
+			com.TypeStart,
+			com.TypeMatchEnd,
+			com.TypeError,
+			com.TypeShutdown,
+			com.TypeResumed,
+			com.TypeRoomCreated,
+			com.TypeSpectateSessions,
+			com.TypeSpectateStart,
+			com.TypeSpectateRound,
+			com.TypeSpectateEnd,
+		} {
 			data := fmt.Sprintf(`{"type":"%s","content":{}}`, messageType)
 			conn := new(connMock)
-			conn.readerMock.results = append(conn.readerMock.results, readerResult{data: []byte(data), err: nil})
+			conn.readerMock.results = append(conn.readerMock.results, readerResult{data: framed(data), err: nil})
 			cli := server.NewClient(conn)
 			leaveCh := make(chan io.ReadWriteCloser, 1)
-			cli.Run(leaveCh, nil, nil)
+			err := cli.Run(context.Background(), newInbox(leaveCh))
+			if !errors.Is(err, server.ErrUnsupportedMessage) {
+				t.Fatalf("Expected %q error in the chain %q, but did not exists!", server.ErrUnsupportedMessage, err)
+			}
 			if leaveConn := <-leaveCh; leaveConn != conn {
 				t.Fatalf("Expected leave to be called with connection %#p but was %#p!", conn, leaveConn)
 			}
 		}
 	})
+	t.Run("ReturnErrorWhenUnknownTypeIsReceived", func(t *testing.T) {
+		t.Parallel()
+		for _, messageType := range []com.MessageType{"", "FOO"} {
+			data := fmt.Sprintf(`{"type":"%s","content":{}}`, messageType)
+			conn := new(connMock)
+			conn.readerMock.results = append(conn.readerMock.results, readerResult{data: framed(data), err: nil})
+			cli := server.NewClient(conn)
+			leaveCh := make(chan io.ReadWriteCloser, 1)
+			err := cli.Run(context.Background(), newInbox(leaveCh))
+			if !errors.Is(err, server.ErrUnsupportedMessage) {
+				t.Fatalf("Expected %q error in the chain %q, but did not exists!", server.ErrUnsupportedMessage, err)
+			}
+			if leaveConn := <-leaveCh; leaveConn != conn {
+				t.Fatalf("Expected leave to be called with connection %#p but was %#p!", conn, leaveConn)
+			}
+		}
+	})
+	t.Run("CallHelloChannelWhenHelloMessageIsReceived", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"HELLO","content":{"version":1,"capabilities":["foo"]}}`
+		conn := new(connMock)
+		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: framed(data), err: nil})
+		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: nil, err: errMock})
+		cli := server.NewClient(conn)
+		leaveCh := make(chan io.ReadWriteCloser, 1)
+		helloCh := make(chan server.Message[com.HelloContent], 1)
+		inbox := newInbox(leaveCh)
+		inbox.Hello = helloCh
+		if err := cli.Run(context.Background(), inbox); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+		helloCall := <-helloCh
+		if helloCall.Conn != conn {
+			t.Fatalf("Expected hello call to contain connection %#p but had %#p!", conn, helloCall.Conn)
+		}
+		if helloCall.Content.Version != 1 {
+			t.Fatalf("Expected hello call to contain version 1 but had %d!", helloCall.Content.Version)
+		}
+		if leaveConn := <-leaveCh; leaveConn != conn {
+			t.Fatalf("Expected leave to be called with connection %#p but was %#p!", conn, leaveConn)
+		}
+	})
 	t.Run("CallJoinChannelWhenJoinMessageIsReceived", func(t *testing.T) {
 		t.Parallel()
 		data := `{"type":"JOIN","content":{"name":"donald"}}`
 		conn := new(connMock)
-		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: []byte(data), err: nil})
+		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: framed(data), err: nil})
 		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: nil, err: errMock})
 		cli := server.NewClient(conn)
 		leaveCh := make(chan io.ReadWriteCloser, 1)
 		joinCh := make(chan server.Message[com.JoinContent], 1)
-		cli.Run(leaveCh, joinCh, nil)
+		inbox := newInbox(leaveCh)
+		inbox.Join = joinCh
+		if err := cli.Run(context.Background(), inbox); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
 		joinCall := <-joinCh
 		if joinCall.Conn != conn {
 			t.Fatalf("Expected join call to contain connection %#p but had %#p!", conn, joinCall.Conn)
@@ -186,12 +632,16 @@ func TestClientRun(t *testing.T) {
 		t.Parallel()
 		data := `{"type":"SELECT","content":{"selection":"r"}}`
 		conn := new(connMock)
-		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: []byte(data), err: nil})
+		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: framed(data), err: nil})
 		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: nil, err: errMock})
 		cli := server.NewClient(conn)
 		leaveCh := make(chan io.ReadWriteCloser, 1)
 		selectCh := make(chan server.Message[com.SelectContent], 1)
-		cli.Run(leaveCh, nil, selectCh)
+		inbox := newInbox(leaveCh)
+		inbox.Select = selectCh
+		if err := cli.Run(context.Background(), inbox); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
 		selectCall := <-selectCh
 		if selectCall.Conn != conn {
 			t.Fatalf("Expected join call to contain connection %#p but had %#p!", conn, selectCall.Conn)
@@ -203,12 +653,196 @@ func TestClientRun(t *testing.T) {
 			t.Fatalf("Expected leave to be called with connection %#p but was %#p!", conn, leaveConn)
 		}
 	})
+	t.Run("CallRematchChannelsWhenRematchMessagesAreReceived", func(t *testing.T) {
+		t.Parallel()
+		conn := new(connMock)
+		for _, data := range []string{`{"type":"REMATCH_OFFER","content":{}}`, `{"type":"REMATCH_ACCEPT","content":{}}`} {
+			conn.readerMock.results = append(conn.readerMock.results, readerResult{data: framed(data), err: nil})
+		}
+		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: nil, err: errMock})
+		cli := server.NewClient(conn)
+		leaveCh := make(chan io.ReadWriteCloser, 1)
+		offerCh := make(chan server.Message[com.RematchOfferContent], 1)
+		acceptCh := make(chan server.Message[com.RematchAcceptContent], 1)
+		inbox := newInbox(leaveCh)
+		inbox.Offer = offerCh
+		inbox.Accept = acceptCh
+		if err := cli.Run(context.Background(), inbox); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+		if offerCall := <-offerCh; offerCall.Conn != conn {
+			t.Fatalf("Expected offer call to contain connection %#p but had %#p!", conn, offerCall.Conn)
+		}
+		if acceptCall := <-acceptCh; acceptCall.Conn != conn {
+			t.Fatalf("Expected accept call to contain connection %#p but had %#p!", conn, acceptCall.Conn)
+		}
+		if leaveConn := <-leaveCh; leaveConn != conn {
+			t.Fatalf("Expected leave to be called with connection %#p but was %#p!", conn, leaveConn)
+		}
+	})
+	t.Run("CallQueueChannelWhenQueueMessageIsReceived", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"QUEUE","content":{}}`
+		conn := new(connMock)
+		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: framed(data), err: nil})
+		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: nil, err: errMock})
+		cli := server.NewClient(conn)
+		leaveCh := make(chan io.ReadWriteCloser, 1)
+		queueCh := make(chan server.Message[com.QueueContent], 1)
+		inbox := newInbox(leaveCh)
+		inbox.Queue = queueCh
+		if err := cli.Run(context.Background(), inbox); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+		if queueCall := <-queueCh; queueCall.Conn != conn {
+			t.Fatalf("Expected queue call to contain connection %#p but had %#p!", conn, queueCall.Conn)
+		}
+		if leaveConn := <-leaveCh; leaveConn != conn {
+			t.Fatalf("Expected leave to be called with connection %#p but was %#p!", conn, leaveConn)
+		}
+	})
+	t.Run("CallRoomChannelsWhenRoomMessagesAreReceived", func(t *testing.T) {
+		t.Parallel()
+		conn := new(connMock)
+		for _, data := range []string{
+			`{"type":"CREATE_ROOM","content":{"name":"donald"}}`,
+			`{"type":"JOIN_ROOM","content":{"name":"daisy","code":"ABC123"}}`,
+		} {
+			conn.readerMock.results = append(conn.readerMock.results, readerResult{data: framed(data), err: nil})
+		}
+		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: nil, err: errMock})
+		cli := server.NewClient(conn)
+		leaveCh := make(chan io.ReadWriteCloser, 1)
+		createRoomCh := make(chan server.Message[com.CreateRoomContent], 1)
+		joinRoomCh := make(chan server.Message[com.JoinRoomContent], 1)
+		inbox := newInbox(leaveCh)
+		inbox.CreateRoom = createRoomCh
+		inbox.JoinRoom = joinRoomCh
+		if err := cli.Run(context.Background(), inbox); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+		if createCall := <-createRoomCh; createCall.Content.Name != "donald" {
+			t.Fatalf("Expected create room call to contain name \"donald\" but had %q!", createCall.Content.Name)
+		}
+		if joinCall := <-joinRoomCh; joinCall.Content.Code != "ABC123" {
+			t.Fatalf("Expected join room call to contain code \"ABC123\" but had %q!", joinCall.Content.Code)
+		}
+		if leaveConn := <-leaveCh; leaveConn != conn {
+			t.Fatalf("Expected leave to be called with connection %#p but was %#p!", conn, leaveConn)
+		}
+	})
+	t.Run("CallSpectateChannelsWhenSpectateMessagesAreReceived", func(t *testing.T) {
+		t.Parallel()
+		conn := new(connMock)
+		for _, data := range []string{
+			`{"type":"SPECTATE_LIST","content":{}}`,
+			`{"type":"SPECTATE","content":{"sessionId":3}}`,
+		} {
+			conn.readerMock.results = append(conn.readerMock.results, readerResult{data: framed(data), err: nil})
+		}
+		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: nil, err: errMock})
+		cli := server.NewClient(conn)
+		leaveCh := make(chan io.ReadWriteCloser, 1)
+		spectateListCh := make(chan server.Message[com.SpectateListContent], 1)
+		spectateCh := make(chan server.Message[com.SpectateContent], 1)
+		inbox := newInbox(leaveCh)
+		inbox.SpectateList = spectateListCh
+		inbox.Spectate = spectateCh
+		if err := cli.Run(context.Background(), inbox); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+		if listCall := <-spectateListCh; listCall.Conn != conn {
+			t.Fatalf("Expected spectate list call to contain connection %#p but had %#p!", conn, listCall.Conn)
+		}
+		if spectateCall := <-spectateCh; spectateCall.Content.SessionID != 3 {
+			t.Fatalf("Expected spectate call to contain session 3 but had %d!", spectateCall.Content.SessionID)
+		}
+		if leaveConn := <-leaveCh; leaveConn != conn {
+			t.Fatalf("Expected leave to be called with connection %#p but was %#p!", conn, leaveConn)
+		}
+	})
+	t.Run("CallTournamentJoinChannelWhenTournamentJoinIsReceived", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"TOURNAMENT_JOIN","content":{"name":"donald"}}`
+		conn := new(connMock)
+		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: framed(data), err: nil})
+		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: nil, err: errMock})
+		cli := server.NewClient(conn)
+		leaveCh := make(chan io.ReadWriteCloser, 1)
+		tournamentJoinCh := make(chan server.Message[com.TournamentJoinContent], 1)
+		inbox := newInbox(leaveCh)
+		inbox.TournamentJoin = tournamentJoinCh
+		if err := cli.Run(context.Background(), inbox); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errMock, err)
+		}
+		if joinCall := <-tournamentJoinCh; joinCall.Content.Name != "donald" {
+			t.Fatalf("Expected tournament join call to contain name \"donald\" but had %q!", joinCall.Content.Name)
+		}
+		if leaveConn := <-leaveCh; leaveConn != conn {
+			t.Fatalf("Expected leave to be called with connection %#p but was %#p!", conn, leaveConn)
+		}
+	})
+	t.Run("ReturnErrorWhenContextIsDone", func(t *testing.T) {
+		t.Parallel()
+		conn := newFullConnMock()
+		cli := server.NewClient(conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := cli.Run(ctx, newInbox(make(chan io.ReadWriteCloser)))
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", context.Canceled, err)
+		}
+	})
+	t.Run("ReturnErrorWhenContextIsDoneDuringForward", func(t *testing.T) {
+		t.Parallel()
+		data := `{"type":"HELLO","content":{"version":1,"capabilities":[]}}`
+		conn := new(connMock)
+		conn.readerMock.results = append(conn.readerMock.results, readerResult{data: framed(data), err: nil})
+		cli := server.NewClient(conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			cancel()
+		}()
+		helloCh := make(chan server.Message[com.HelloContent])
+		inbox := newInbox(make(chan io.ReadWriteCloser))
+		inbox.Hello = helloCh
+		err := cli.Run(ctx, inbox)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", context.Canceled, err)
+		}
+	})
+}
+
+func newInbox(leaveCh chan<- io.ReadWriteCloser) server.Inbox {
+	return server.Inbox{
+		Leave:          leaveCh,
+		Hello:          nil,
+		Join:           nil,
+		Select:         nil,
+		Offer:          nil,
+		Accept:         nil,
+		Queue:          nil,
+		Resume:         nil,
+		CreateRoom:     nil,
+		JoinRoom:       nil,
+		SpectateList:   nil,
+		Spectate:       nil,
+		TournamentJoin: nil,
+	}
 }
 
 func TestString(t *testing.T) {
 	t.Parallel()
 	conn := new(connMock)
-	cli := server.Client{Conn: conn, Name: "foo", Session: nil}
+	cli := server.Client{
+		Conn:         conn,
+		Name:         "foo",
+		State:        server.StateConnected,
+		Session:      nil,
+		Version:      0,
+		Capabilities: nil,
+	}
 	expected := fmt.Sprintf("client(%#p:%s)", conn, "foo")
 	if val := cli.String(); val != expected {
 		t.Fatalf("Expected to return %s but returned %q!", expected, val)
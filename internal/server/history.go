@@ -0,0 +1,215 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/toivjon/go-rps/internal/com"
+	"github.com/toivjon/go-rps/internal/game"
+)
+
+// ErrHistoryClosed is an error occurring when a match is recorded to a closed history.
+var ErrHistoryClosed = errors.New("match history has been closed")
+
+// History records the played matches so that they outlive the server. Implementations must be safe for
+// concurrent use and must not block the caller on slow storage, as the matches are recorded from the server
+// main loop.
+type History interface {
+	// Record stores the given match.
+	Record(match MatchRecord) error
+	// Matches returns the stored matches in the order they were recorded.
+	Matches() ([]MatchRecord, error)
+	// Close stores the pending matches and releases the storage.
+	Close() error
+}
+
+// MatchRecord describes a match of a game session. The players, the results and the scores are in the seat
+// order and the rounds in the order they were played. The aborted contains the error code of the failure which
+// closed the session before the match was decided, in which case the match has no results. The aborted is
+// empty for a decided match.
+type MatchRecord struct {
+	Players []string
+	Format  game.Format
+	Rules   string
+	Rounds  []RoundRecord
+	Results []game.Result
+	Scores  []int
+	Aborted com.ErrorCode
+	Started time.Time
+	Ended   time.Time
+}
+
+// RoundRecord describes a resolved round of a match. The selections and the results are in the seat order and
+// the seats sitting out the round have neither a selection nor a result. The forfeit is set when the round was
+// resolved without every selection due to the round timeout or mismatched reveals.
+type RoundRecord struct {
+	Number     int
+	Selections []game.Selection
+	Results    []game.Result
+	Forfeit    bool
+	Resolved   time.Time
+}
+
+// MemoryHistory is a history which keeps the recorded matches only in memory.
+type MemoryHistory struct {
+	mutex   sync.Mutex
+	matches []MatchRecord
+}
+
+// NewMemoryHistory builds a new empty history which is kept only in memory.
+func NewMemoryHistory() *MemoryHistory {
+	return &MemoryHistory{mutex: sync.Mutex{}, matches: nil}
+}
+
+// Record stores the given match in memory.
+func (h *MemoryHistory) Record(match MatchRecord) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.matches = append(h.matches, match)
+	return nil
+}
+
+// Matches returns the matches recorded in memory.
+func (h *MemoryHistory) Matches() ([]MatchRecord, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return append([]MatchRecord(nil), h.matches...), nil
+}
+
+// Close does nothing as the matches are only kept in memory.
+func (h *MemoryHistory) Close() error {
+	return nil
+}
+
+// FileHistory is a history which appends the recorded matches to a file as JSON lines, one match per line.
+// The file is kept open and the recorded matches are queued for a background routine which writes them to
+// the file, so that recording a match never waits for the disk.
+type FileHistory struct {
+	name    string
+	mutex   sync.Mutex
+	pending []MatchRecord
+	closed  bool
+	writing sync.Mutex
+	writer  *bufio.Writer
+	file    *os.File
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+// OpenFileHistory opens the history stored in the given file and starts the routine which writes the recorded
+// matches to it. The file is created if it does not exist yet. The history must be closed after use.
+func OpenFileHistory(name string) (*FileHistory, error) {
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file %q. %w", name, err)
+	}
+	history := &FileHistory{
+		name:    name,
+		mutex:   sync.Mutex{},
+		pending: nil,
+		closed:  false,
+		writing: sync.Mutex{},
+		writer:  bufio.NewWriter(file),
+		file:    file,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go history.run()
+	return history, nil
+}
+
+// run writes the queued matches to the file whenever new matches are recorded until the history is closed.
+func (h *FileHistory) run() {
+	defer close(h.done)
+	for range h.wake {
+		if err := h.flush(); err != nil {
+			log.Printf("Failed to write match history. %s", err)
+		}
+	}
+}
+
+// Record queues the given match to be appended to the end of the file.
+func (h *FileHistory) Record(match MatchRecord) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.closed {
+		return ErrHistoryClosed
+	}
+	h.pending = append(h.pending, match)
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// flush writes the queued matches to the file.
+func (h *FileHistory) flush() error {
+	h.writing.Lock()
+	defer h.writing.Unlock()
+	h.mutex.Lock()
+	pending := h.pending
+	h.pending = nil
+	h.mutex.Unlock()
+	for _, match := range pending {
+		data, err := json.Marshal(match)
+		if err != nil {
+			return fmt.Errorf("failed to marshal match record. %w", err)
+		}
+		if _, err := h.writer.Write(append(data, '\n')); err != nil {
+			return fmt.Errorf("failed to write history file %q. %w", h.name, err)
+		}
+	}
+	if err := h.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write history file %q. %w", h.name, err)
+	}
+	return nil
+}
+
+// Matches reads the matches recorded in the file after writing the queued matches to it.
+func (h *FileHistory) Matches() ([]MatchRecord, error) {
+	if err := h.flush(); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(h.name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file %q. %w", h.name, err)
+	}
+	defer file.Close()
+	var matches []MatchRecord
+	decoder := json.NewDecoder(file)
+	for {
+		var match MatchRecord
+		if err := decoder.Decode(&match); errors.Is(err, io.EOF) {
+			return matches, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse history file %q. %w", h.name, err)
+		}
+		matches = append(matches, match)
+	}
+}
+
+// Close stops the background routine, writes the queued matches to the file and closes the file.
+func (h *FileHistory) Close() error {
+	h.mutex.Lock()
+	if h.closed {
+		h.mutex.Unlock()
+		return nil
+	}
+	h.closed = true
+	close(h.wake)
+	h.mutex.Unlock()
+	<-h.done
+	err := h.flush()
+	if closeErr := h.file.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("failed to close history file %q. %w", h.name, closeErr)
+	}
+	return err
+}
@@ -0,0 +1,147 @@
+package server_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/toivjon/go-rps/internal/game"
+	"github.com/toivjon/go-rps/internal/server"
+)
+
+func matchRecord(players ...string) server.MatchRecord {
+	started := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	return server.MatchRecord{
+		Players: players,
+		Format:  game.BestOf(1),
+		Rules:   game.Classic().Name,
+		Rounds: []server.RoundRecord{{
+			Number:     1,
+			Selections: []game.Selection{game.SelectionRock, game.SelectionScissors},
+			Results:    []game.Result{game.ResultWin, game.ResultLose},
+			Forfeit:    false,
+			Resolved:   started.Add(time.Second),
+		}},
+		Results: []game.Result{game.ResultWin, game.ResultLose},
+		Scores:  []int{1, 0},
+		Aborted: "",
+		Started: started,
+		Ended:   started.Add(time.Second),
+	}
+}
+
+func TestMemoryHistory(t *testing.T) {
+	t.Parallel()
+	history := server.NewMemoryHistory()
+	records := []server.MatchRecord{matchRecord("donald", "mickey"), matchRecord("mickey", "goofy")}
+	for _, record := range records {
+		if err := history.Record(record); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+	}
+	matches, err := history.Matches()
+	if err != nil {
+		t.Fatalf("Expected no error, but error was returned: %s", err)
+	}
+	if !reflect.DeepEqual(matches, records) {
+		t.Fatalf("Expected matches to be %v, but were %v!", records, matches)
+	}
+	if err := history.Close(); err != nil {
+		t.Fatalf("Expected no error, but error was returned: %s", err)
+	}
+}
+
+//nolint:funlen
+func TestFileHistory(t *testing.T) {
+	t.Parallel()
+	t.Run("ReadRecordedMatchesWithNewHistory", func(t *testing.T) {
+		t.Parallel()
+		file := filepath.Join(t.TempDir(), "history.jsonl")
+		records := []server.MatchRecord{matchRecord("donald", "mickey"), matchRecord("mickey", "goofy")}
+		for _, record := range records {
+			history, err := server.OpenFileHistory(file)
+			if err != nil {
+				t.Fatalf("Expected no error, but error was returned: %s", err)
+			}
+			if err := history.Record(record); err != nil {
+				t.Fatalf("Expected no error, but error was returned: %s", err)
+			}
+			if err := history.Close(); err != nil {
+				t.Fatalf("Expected no error, but error was returned: %s", err)
+			}
+		}
+		history, err := server.OpenFileHistory(file)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		defer history.Close()
+		matches, err := history.Matches()
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if !reflect.DeepEqual(matches, records) {
+			t.Fatalf("Expected matches to be %v, but were %v!", records, matches)
+		}
+	})
+	t.Run("ReadQueuedMatchesBeforeClose", func(t *testing.T) {
+		t.Parallel()
+		history, err := server.OpenFileHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		defer history.Close()
+		records := []server.MatchRecord{matchRecord("donald", "mickey"), matchRecord("mickey", "goofy")}
+		for _, record := range records {
+			if err := history.Record(record); err != nil {
+				t.Fatalf("Expected no error, but error was returned: %s", err)
+			}
+		}
+		matches, err := history.Matches()
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if !reflect.DeepEqual(matches, records) {
+			t.Fatalf("Expected matches to be %v, but were %v!", records, matches)
+		}
+	})
+	t.Run("ReturnErrorWhenRecordedAfterClose", func(t *testing.T) {
+		t.Parallel()
+		history, err := server.OpenFileHistory(filepath.Join(t.TempDir(), "history.jsonl"))
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if err := history.Close(); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if err := history.Close(); err != nil {
+			t.Fatalf("Expected no error when closed again, but error was returned: %s", err)
+		}
+		if err := history.Record(matchRecord("donald", "mickey")); !errors.Is(err, server.ErrHistoryClosed) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", server.ErrHistoryClosed, err)
+		}
+	})
+	t.Run("ReturnErrorWhenFileIsInvalid", func(t *testing.T) {
+		t.Parallel()
+		file := filepath.Join(t.TempDir(), "history.jsonl")
+		if err := os.WriteFile(file, []byte("{\"Players\":[]}\n{"), 0o600); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		history, err := server.OpenFileHistory(file)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		defer history.Close()
+		if _, err := history.Matches(); err == nil {
+			t.Fatal("Expected error, but nil was returned!")
+		}
+	})
+	t.Run("ReturnErrorWhenFileCannotBeOpened", func(t *testing.T) {
+		t.Parallel()
+		if _, err := server.OpenFileHistory(t.TempDir()); err == nil {
+			t.Fatal("Expected error, but nil was returned!")
+		}
+	})
+}
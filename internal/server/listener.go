@@ -0,0 +1,85 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// accepted contains the result of a single accept call of a listener.
+type accepted struct {
+	conn net.Conn
+	err  error
+}
+
+// MultiListener merges several listeners into a single listener which accepts the connections of all of them.
+// It lets the server accept the TCP and the WebSocket connections and match their players with each other.
+type MultiListener struct {
+	listeners []net.Listener
+	accepted  chan accepted
+	closed    chan struct{}
+	closeOnce *sync.Once
+}
+
+// NewMultiListener builds a new listener which starts accepting the connections of the given listeners.
+func NewMultiListener(listeners ...net.Listener) *MultiListener {
+	multi := &MultiListener{
+		listeners: listeners,
+		accepted:  make(chan accepted),
+		closed:    make(chan struct{}),
+		closeOnce: new(sync.Once),
+	}
+	for _, listener := range listeners {
+		go multi.accept(listener)
+	}
+	return multi
+}
+
+// accept forwards the connections of the listener until the listener is closed.
+func (m *MultiListener) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		select {
+		case m.accepted <- accepted{conn: conn, err: err}:
+		case <-m.closed:
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		}
+	}
+}
+
+// Accept waits for and returns the next connection of any of the listeners. Returns net.ErrClosed when the
+// listener is closed.
+func (m *MultiListener) Accept() (net.Conn, error) {
+	select {
+	case result := <-m.accepted:
+		return result.conn, result.err
+	case <-m.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes every merged listener. Returns the first error which occurred while closing the listeners.
+func (m *MultiListener) Close() error {
+	var err error
+	m.closeOnce.Do(func() {
+		close(m.closed)
+		for _, listener := range m.listeners {
+			if closeErr := listener.Close(); closeErr != nil && err == nil {
+				err = fmt.Errorf("failed to close listener %s. %w", listener.Addr(), closeErr)
+			}
+		}
+	})
+	return err
+}
+
+// Addr returns the network address of the first merged listener.
+func (m *MultiListener) Addr() net.Addr {
+	return m.listeners[0].Addr()
+}
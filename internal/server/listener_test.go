@@ -0,0 +1,80 @@
+package server_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/toivjon/go-rps/internal/server"
+)
+
+var errAccept = errors.New("accept error")
+
+func TestMultiListener(t *testing.T) {
+	t.Parallel()
+	t.Run("AcceptConnectionsOfEveryListener", func(t *testing.T) {
+		t.Parallel()
+		listener1 := newListenerMock()
+		listener2 := newListenerMock()
+		multi := server.NewMultiListener(listener1, listener2)
+		defer multi.Close()
+		for _, listener := range []*listenerMock{listener1, listener2} {
+			conn := newFullConnMock()
+			go func(listener *listenerMock) { listener.acceptCh <- conn }(listener)
+			accepted, err := multi.Accept()
+			if err != nil {
+				t.Fatalf("Expected no error, but error was returned: %s", err)
+			}
+			if accepted != conn {
+				t.Fatalf("Expected connection %#p, but was %#p", conn, accepted)
+			}
+		}
+	})
+	t.Run("ReturnErrorWhenListenerFails", func(t *testing.T) {
+		t.Parallel()
+		listener := newListenerMock()
+		listener.acceptErr = errAccept
+		multi := server.NewMultiListener(listener)
+		defer multi.Close()
+		if _, err := multi.Accept(); !errors.Is(err, errAccept) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", errAccept, err)
+		}
+	})
+	t.Run("ReturnErrorWhenClosed", func(t *testing.T) {
+		t.Parallel()
+		listener := newListenerMock()
+		multi := server.NewMultiListener(listener)
+		if err := multi.Close(); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if _, err := multi.Accept(); !errors.Is(err, net.ErrClosed) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", net.ErrClosed, err)
+		}
+		if _, err := listener.Accept(); !errors.Is(err, net.ErrClosed) {
+			t.Fatal("Expected the merged listener to be closed, but it was not!")
+		}
+	})
+	t.Run("ReturnErrorWhenListenerCannotBeClosed", func(t *testing.T) {
+		t.Parallel()
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		listener.Close()
+		if err := server.NewMultiListener(listener).Close(); err == nil {
+			t.Fatal("Expected an error, but nil was returned!")
+		}
+	})
+	t.Run("ReturnAddressOfFirstListener", func(t *testing.T) {
+		t.Parallel()
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		multi := server.NewMultiListener(listener, newListenerMock())
+		defer multi.Close()
+		if multi.Addr() != listener.Addr() {
+			t.Fatalf("Expected address %s, but was %s", listener.Addr(), multi.Addr())
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package server
+
+import "time"
+
+// Queue represents a first-in-first-out matchmaking queue of the clients waiting for opponents.
+type Queue struct {
+	clients []*Client
+	since   map[*Client]time.Time
+}
+
+// NewQueue builds a new empty matchmaking queue.
+func NewQueue() *Queue {
+	return &Queue{clients: nil, since: make(map[*Client]time.Time)}
+}
+
+// Push adds the client to the end of the queue and marks the client as queued.
+func (q *Queue) Push(client *Client) {
+	client.State = StateQueued
+	q.clients = append(q.clients, client)
+	q.since[client] = time.Now()
+}
+
+// Take removes and returns the given number of clients which have waited the longest. Returns false if the
+// queue does not yet contain enough clients to form a group.
+func (q *Queue) Take(count int) ([]*Client, bool) {
+	if len(q.clients) < count {
+		return nil, false
+	}
+	clients := append([]*Client(nil), q.clients[:count]...)
+	q.clients = q.clients[count:]
+	for _, client := range clients {
+		delete(q.since, client)
+	}
+	return clients, true
+}
+
+// Remove removes the client from the queue. Returns false if the client was not in the queue.
+func (q *Queue) Remove(client *Client) bool {
+	for i, queued := range q.clients {
+		if queued == client {
+			q.clients = append(q.clients[:i], q.clients[i+1:]...)
+			delete(q.since, client)
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of clients waiting in the queue.
+func (q *Queue) Len() int {
+	return len(q.clients)
+}
+
+// Waited returns how long the client which has waited the longest has been in the queue at the given time.
+// Returns zero when the queue is empty.
+func (q *Queue) Waited(now time.Time) time.Duration {
+	if len(q.clients) == 0 {
+		return 0
+	}
+	return now.Sub(q.since[q.clients[0]])
+}
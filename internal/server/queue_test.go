@@ -0,0 +1,100 @@
+package server_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/toivjon/go-rps/internal/server"
+)
+
+func TestNewQueue(t *testing.T) {
+	t.Parallel()
+	queue := server.NewQueue()
+	if queue.Len() != 0 {
+		t.Fatalf("Expected queue to be empty, but had %d items!", queue.Len())
+	}
+}
+
+func TestQueuePush(t *testing.T) {
+	t.Parallel()
+	queue := server.NewQueue()
+	client := server.NewClient(new(connMock))
+	queue.Push(client)
+	if queue.Len() != 1 {
+		t.Fatalf("Expected queue to have one item, but had %d!", queue.Len())
+	}
+	if client.State != server.StateQueued {
+		t.Fatalf("Expected client state to be %q, but was %q!", server.StateQueued, client.State)
+	}
+}
+
+func TestQueueTake(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnFalseWhenNotEnoughClients", func(t *testing.T) {
+		t.Parallel()
+		queue := server.NewQueue()
+		queue.Push(server.NewClient(new(connMock)))
+		queue.Push(server.NewClient(new(connMock)))
+		if _, ok := queue.Take(3); ok {
+			t.Fatal("Expected no group of three from two clients, but a group was returned!")
+		}
+		if queue.Len() != 2 {
+			t.Fatalf("Expected queue to still have two items, but had %d!", queue.Len())
+		}
+	})
+	t.Run("ReturnClientsInJoinOrder", func(t *testing.T) {
+		t.Parallel()
+		queue := server.NewQueue()
+		clients := []*server.Client{
+			server.NewClient(new(connMock)),
+			server.NewClient(new(connMock)),
+			server.NewClient(new(connMock)),
+		}
+		for _, client := range clients {
+			queue.Push(client)
+		}
+		group, ok := queue.Take(2)
+		if !ok {
+			t.Fatal("Expected a group to be returned, but it was not!")
+		}
+		if len(group) != 2 || group[0] != clients[0] || group[1] != clients[1] {
+			t.Fatalf("Expected group to be %s & %s, but was %s!", clients[0], clients[1], group)
+		}
+		if queue.Len() != 1 {
+			t.Fatalf("Expected queue to have one item, but had %d!", queue.Len())
+		}
+	})
+}
+
+func TestQueueRemove(t *testing.T) {
+	t.Parallel()
+	queue := server.NewQueue()
+	client1 := server.NewClient(new(connMock))
+	client2 := server.NewClient(new(connMock))
+	client3 := server.NewClient(new(connMock))
+	queue.Push(client1)
+	queue.Push(client2)
+	queue.Push(client3)
+	if !queue.Remove(client2) {
+		t.Fatal("Expected queued client to be removed, but it was not!")
+	}
+	if queue.Remove(client2) {
+		t.Fatal("Expected removing a non-queued client to return false, but it returned true!")
+	}
+	group, _ := queue.Take(2)
+	if group[0] != client1 || group[1] != client3 {
+		t.Fatalf("Expected group to be %s & %s, but was %s!", client1, client3, group)
+	}
+}
+
+func TestQueueWaited(t *testing.T) {
+	t.Parallel()
+	queue := server.NewQueue()
+	if waited := queue.Waited(time.Now()); waited != 0 {
+		t.Fatalf("Expected empty queue to have no wait, but was %s!", waited)
+	}
+	queue.Push(server.NewClient(new(connMock)))
+	if waited := queue.Waited(time.Now().Add(time.Minute)); waited < time.Minute {
+		t.Fatalf("Expected the first client to have waited at least a minute, but was %s!", waited)
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+)
+
+const (
+	// roomCodeAlphabet contains the room code characters without the easily confused ones like 0/O and 1/I.
+	roomCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	// roomCodeSize specifies the number of characters in a room code.
+	roomCodeSize = 6
+)
+
+// Rooms represents the private rooms where the players wait until the room is full. The host opens a room
+// and the other players enter it by presenting the room code.
+type Rooms struct {
+	size    int
+	players map[string][]*Client
+}
+
+// NewRooms builds a new container without any rooms where each room is full with the given number of players.
+func NewRooms(size int) *Rooms {
+	return &Rooms{size: size, players: make(map[string][]*Client)}
+}
+
+// Create opens a new room for the host, marks the host as queued and returns the code of the room.
+func (r *Rooms) Create(host *Client) (string, error) {
+	for {
+		code, err := newRoomCode()
+		if err != nil {
+			return "", err
+		}
+		if _, ok := r.players[code]; !ok {
+			host.State = StateQueued
+			r.players[code] = []*Client{host}
+			return code, nil
+		}
+	}
+}
+
+// Join seats the client in the room with the given code and marks the client as queued. The code is
+// case-insensitive. Once the room is full, it is removed and its players are returned in the order they
+// entered the room. Returns false if there is no room with the code.
+func (r *Rooms) Join(code string, client *Client) ([]*Client, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	players, ok := r.players[code]
+	if !ok {
+		return nil, false
+	}
+	client.State = StateQueued
+	players = append(players, client)
+	if len(players) < r.size {
+		r.players[code] = players
+		return nil, true
+	}
+	delete(r.players, code)
+	return players, true
+}
+
+// Remove removes the client from the room it waits in. The room is removed when its last player leaves.
+// Returns false if the client was not waiting in a room.
+func (r *Rooms) Remove(client *Client) bool {
+	for code, players := range r.players {
+		for i, player := range players {
+			if player != client {
+				continue
+			}
+			if len(players) == 1 {
+				delete(r.players, code)
+			} else {
+				r.players[code] = append(players[:i], players[i+1:]...)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of rooms waiting for players.
+func (r *Rooms) Len() int {
+	return len(r.players)
+}
+
+// newRoomCode generates a random room code which is short enough to be shared by word of mouth.
+func newRoomCode() (string, error) {
+	bytes := make([]byte, roomCodeSize)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to read random bytes for room code. %w", err)
+	}
+	for i, b := range bytes {
+		bytes[i] = roomCodeAlphabet[int(b)%len(roomCodeAlphabet)]
+	}
+	return string(bytes), nil
+}
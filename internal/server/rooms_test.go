@@ -0,0 +1,126 @@
+package server_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/toivjon/go-rps/internal/server"
+)
+
+func TestNewRooms(t *testing.T) {
+	t.Parallel()
+	rooms := server.NewRooms(2)
+	if rooms.Len() != 0 {
+		t.Fatalf("Expected rooms to be empty, but had %d items!", rooms.Len())
+	}
+}
+
+func TestRoomsCreate(t *testing.T) {
+	t.Parallel()
+	rooms := server.NewRooms(2)
+	client := server.NewClient(new(connMock))
+	code, err := rooms.Create(client)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if len(code) != 6 || strings.ToUpper(code) != code {
+		t.Fatalf("Expected code to have six upper case characters, but was %q!", code)
+	}
+	if rooms.Len() != 1 {
+		t.Fatalf("Expected rooms to have one item, but had %d!", rooms.Len())
+	}
+	if client.State != server.StateQueued {
+		t.Fatalf("Expected client state to be %q, but was %q!", server.StateQueued, client.State)
+	}
+}
+
+func TestRoomsJoin(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnFalseWhenCodeIsUnknown", func(t *testing.T) {
+		t.Parallel()
+		rooms := server.NewRooms(2)
+		if _, err := rooms.Create(server.NewClient(new(connMock))); err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		if _, ok := rooms.Join("unknown", server.NewClient(new(connMock))); ok {
+			t.Fatal("Expected unknown code to not be joined, but it was!")
+		}
+		if rooms.Len() != 1 {
+			t.Fatalf("Expected rooms to still have one item, but had %d!", rooms.Len())
+		}
+	})
+	t.Run("ReturnPlayersWhenCodeMatchesIgnoringCase", func(t *testing.T) {
+		t.Parallel()
+		rooms := server.NewRooms(2)
+		host := server.NewClient(new(connMock))
+		client := server.NewClient(new(connMock))
+		code, err := rooms.Create(host)
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		players, ok := rooms.Join(" "+strings.ToLower(code)+" ", client)
+		if !ok || len(players) != 2 || players[0] != host || players[1] != client {
+			t.Fatalf("Expected players to be %s & %s, but were %s!", host, client, players)
+		}
+		if _, ok := rooms.Join(code, server.NewClient(new(connMock))); ok {
+			t.Fatal("Expected full room to be removed, but it was joined!")
+		}
+	})
+	t.Run("WaitUntilRoomIsFull", func(t *testing.T) {
+		t.Parallel()
+		rooms := server.NewRooms(3)
+		code, err := rooms.Create(server.NewClient(new(connMock)))
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		client := server.NewClient(new(connMock))
+		if players, ok := rooms.Join(code, client); !ok || players != nil {
+			t.Fatalf("Expected room to be joined without players, but was %t with %s!", ok, players)
+		}
+		if client.State != server.StateQueued {
+			t.Fatalf("Expected client state to be %q, but was %q!", server.StateQueued, client.State)
+		}
+		if players, ok := rooms.Join(code, server.NewClient(new(connMock))); !ok || len(players) != 3 {
+			t.Fatalf("Expected three players from a full room, but was %t with %s!", ok, players)
+		}
+		if rooms.Len() != 0 {
+			t.Fatalf("Expected full room to be removed, but had %d rooms!", rooms.Len())
+		}
+	})
+}
+
+func TestRoomsRemove(t *testing.T) {
+	t.Parallel()
+	rooms := server.NewRooms(3)
+	client1 := server.NewClient(new(connMock))
+	client2 := server.NewClient(new(connMock))
+	client3 := server.NewClient(new(connMock))
+	if _, err := rooms.Create(client1); err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	code, err := rooms.Create(client2)
+	if err != nil {
+		t.Fatalf("Expected no error, but got: %v", err)
+	}
+	if !rooms.Remove(client1) {
+		t.Fatal("Expected room host to be removed, but it was not!")
+	}
+	if rooms.Remove(client1) {
+		t.Fatal("Expected removing a non-waiting client to return false, but it returned true!")
+	}
+	if rooms.Len() != 1 {
+		t.Fatalf("Expected empty room to be removed, but had %d rooms!", rooms.Len())
+	}
+	if _, ok := rooms.Join(code, client3); !ok {
+		t.Fatal("Expected room to be joined, but it was not!")
+	}
+	if !rooms.Remove(client2) {
+		t.Fatal("Expected room host to be removed, but it was not!")
+	}
+	if players, ok := rooms.Join(code, client1); !ok || players != nil {
+		t.Fatalf("Expected room to still wait for players, but was %t with %s!", ok, players)
+	}
+	if players, ok := rooms.Join(code, client2); !ok || len(players) != 3 || players[0] != client3 {
+		t.Fatalf("Expected players to be led by %s, but were %s!", client3, players)
+	}
+}
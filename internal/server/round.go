@@ -1,36 +1,132 @@
 package server
 
 import (
+	"math"
+	"time"
+
 	"github.com/toivjon/go-rps/internal/game"
 )
 
-// Round represents a single game round in a RPS game.
+// Round represents a single game round in a RPS game where the players make their selections simultaneously.
+// The selections are in the seat order of the session players. The players who have been eliminated from the
+// ongoing game are not playing and sit out the round. A player using the commit-reveal protocol first commits
+// to its selection, and the selection is set once the player has revealed it. The mismatches mark the seats
+// whose reveal did not match their commitment.
 type Round struct {
-	Selection1 game.Selection
-	Selection2 game.Selection
+	Number      int
+	Playing     []bool
+	Selections  []game.Selection
+	Commitments []string
+	Mismatches  []bool
+	Deadline    time.Time
 }
 
-// NewRound builds a new round with empty selections.
-func NewRound() *Round {
+// NewRound builds a new round with empty selections for the given seats. A zero deadline means that the round
+// never expires.
+func NewRound(number int, playing []bool, deadline time.Time) *Round {
 	return &Round{
-		Selection1: game.SelectionNone,
-		Selection2: game.SelectionNone,
+		Number:      number,
+		Playing:     playing,
+		Selections:  make([]game.Selection, len(playing)),
+		Commitments: make([]string, len(playing)),
+		Mismatches:  make([]bool, len(playing)),
+		Deadline:    deadline,
 	}
 }
 
-// Ended checks whether the round has been ended i.e. both selections have been made.
+// Ended checks whether the round has been ended i.e. every playing seat has made a selection or failed to
+// reveal its committed selection.
 func (r *Round) Ended() bool {
-	return r.Selection1 != game.SelectionNone && r.Selection2 != game.SelectionNone
+	for i, selection := range r.Selections {
+		if r.Playing[i] && selection == game.SelectionNone && !r.Mismatches[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Sealed checks whether every playing seat has either made or committed to a selection, after which the
+// committed selections may be revealed.
+func (r *Round) Sealed() bool {
+	for i, selection := range r.Selections {
+		if r.Playing[i] && selection == game.SelectionNone && r.Commitments[i] == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Mismatched checks whether any of the seats revealed a selection which did not match its commitment.
+func (r *Round) Mismatched() bool {
+	for _, mismatch := range r.Mismatches {
+		if mismatch {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired checks whether the round selection deadline has passed before every selection was made.
+func (r *Round) Expired(now time.Time) bool {
+	return !r.Deadline.IsZero() && !r.Ended() && now.After(r.Deadline)
+}
+
+// Result returns the game results in the seat order based on the current selections and the given rule set.
+// Each playing seat scores a point for every other selection it beats and loses a point for every other
+// selection which beats it. The seats with the lowest score lose the round and the others win it, unless
+// every seat scored the same, which makes the round a draw. The seats sitting out the round get no result.
+func (r *Round) Result(rules game.RuleSet) []game.Result {
+	points := make([]int, len(r.Selections))
+	for i, selection := range r.Selections {
+		for j, other := range r.Selections {
+			switch {
+			case !r.Playing[i] || !r.Playing[j] || i == j:
+			case rules.Beats(selection, other):
+				points[i]++
+			case rules.Beats(other, selection):
+				points[i]--
+			}
+		}
+	}
+	lowest, highest := math.MaxInt, math.MinInt
+	for i, point := range points {
+		if r.Playing[i] && point < lowest {
+			lowest = point
+		}
+		if r.Playing[i] && point > highest {
+			highest = point
+		}
+	}
+	results := make([]game.Result, len(r.Selections))
+	for i, point := range points {
+		switch {
+		case !r.Playing[i]:
+		case lowest == highest:
+			results[i] = game.ResultDraw
+		case point == lowest:
+			results[i] = game.ResultLose
+		default:
+			results[i] = game.ResultWin
+		}
+	}
+	return results
 }
 
-// Result returns game result based on the current selections.
-func (r *Round) Result() (game.Result, game.Result) {
-	switch {
-	case r.Selection1 == r.Selection2:
-		return game.ResultDraw, game.ResultDraw
-	case r.Selection1.Beats(r.Selection2):
-		return game.ResultWin, game.ResultLose
-	default:
-		return game.ResultLose, game.ResultWin
+// Forfeit returns the game results in the seat order for a round whose deadline has passed or whose reveals
+// did not match. The playing seats which made a selection win and the others lose the round. Returns false
+// when none of the seats selected.
+func (r *Round) Forfeit() ([]game.Result, bool) {
+	selected := false
+	results := make([]game.Result, len(r.Selections))
+	for i, selection := range r.Selections {
+		switch {
+		case !r.Playing[i]:
+		case selection == game.SelectionNone:
+			results[i] = game.ResultLose
+		default:
+			results[i] = game.ResultWin
+			selected = true
+		}
 	}
+	return results, selected
 }
@@ -1,7 +1,9 @@
 package server_test
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/toivjon/go-rps/internal/game"
 	"github.com/toivjon/go-rps/internal/server"
@@ -9,12 +11,17 @@ import (
 
 func TestNewRound(t *testing.T) {
 	t.Parallel()
-	round := server.NewRound()
-	if round.Selection1 != game.SelectionNone {
-		t.Fatalf("Expected selection one to be %q, but was %q!", game.SelectionNone, round.Selection1)
+	deadline := time.Now()
+	round := server.NewRound(2, []bool{true, false, true}, deadline)
+	if round.Number != 2 {
+		t.Fatalf("Expected round number to be 2, but was %d!", round.Number)
 	}
-	if round.Selection2 != game.SelectionNone {
-		t.Fatalf("Expected selection two to be %q, but was %q!", game.SelectionNone, round.Selection2)
+	if !round.Deadline.Equal(deadline) {
+		t.Fatalf("Expected deadline to be %v, but was %v!", deadline, round.Deadline)
+	}
+	expected := []game.Selection{game.SelectionNone, game.SelectionNone, game.SelectionNone}
+	if !reflect.DeepEqual(round.Selections, expected) {
+		t.Fatalf("Expected selections to be %q, but were %q!", expected, round.Selections)
 	}
 }
 
@@ -22,25 +29,127 @@ func TestRoundEnded(t *testing.T) {
 	t.Parallel()
 	t.Run("ReturnFalseWhenSelection1IsNone", func(t *testing.T) {
 		t.Parallel()
-		round := server.Round{Selection1: game.SelectionNone, Selection2: game.SelectionRock}
+		round := server.Round{
+			Number:      1,
+			Playing:     []bool{true, true},
+			Selections:  []game.Selection{game.SelectionNone, game.SelectionRock},
+			Commitments: make([]string, 2),
+			Mismatches:  make([]bool, 2),
+			Deadline:    time.Time{},
+		}
 		if round.Ended() {
 			t.Fatal("Expected to return false when Selection1 is none, but returned true!")
 		}
 	})
 	t.Run("ReturnFalseWhenSelection2IsNone", func(t *testing.T) {
 		t.Parallel()
-		round := server.Round{Selection1: game.SelectionRock, Selection2: game.SelectionNone}
+		round := server.Round{
+			Number:      1,
+			Playing:     []bool{true, true},
+			Selections:  []game.Selection{game.SelectionRock, game.SelectionNone},
+			Commitments: make([]string, 2),
+			Mismatches:  make([]bool, 2),
+			Deadline:    time.Time{},
+		}
 		if round.Ended() {
 			t.Fatal("Expected to return false when Selection2 is none, but returned true!")
 		}
 	})
 	t.Run("ReturnTrueWhenBothSelectionsAreNotNone", func(t *testing.T) {
 		t.Parallel()
-		round := server.Round{Selection1: game.SelectionRock, Selection2: game.SelectionRock}
+		round := server.Round{
+			Number:      1,
+			Playing:     []bool{true, true},
+			Selections:  []game.Selection{game.SelectionRock, game.SelectionRock},
+			Commitments: make([]string, 2),
+			Mismatches:  make([]bool, 2),
+			Deadline:    time.Time{},
+		}
 		if !round.Ended() {
 			t.Fatal("Expected to return true both selections are not none, but returned false!")
 		}
 	})
+	t.Run("ReturnTrueWhenOnlyEliminatedSelectionIsNone", func(t *testing.T) {
+		t.Parallel()
+		round := server.Round{
+			Number:      1,
+			Playing:     []bool{true, false, true},
+			Selections:  []game.Selection{game.SelectionRock, game.SelectionNone, game.SelectionPaper},
+			Commitments: make([]string, 3),
+			Mismatches:  make([]bool, 3),
+			Deadline:    time.Time{},
+		}
+		if !round.Ended() {
+			t.Fatal("Expected to return true when the playing seats have selected, but returned false!")
+		}
+	})
+}
+
+func TestRoundSealed(t *testing.T) {
+	t.Parallel()
+	round := server.NewRound(1, []bool{true, false, true}, time.Time{})
+	round.Commitments[0] = "commitment"
+	if round.Sealed() {
+		t.Fatal("Expected to return false when a playing seat has not selected, but returned true!")
+	}
+	round.Selections[2] = game.SelectionRock
+	if !round.Sealed() {
+		t.Fatal("Expected to return true when the playing seats have selected or committed, but returned false!")
+	}
+	if round.Ended() {
+		t.Fatal("Expected a round with an unrevealed commitment to not be ended, but it was!")
+	}
+}
+
+func TestRoundMismatched(t *testing.T) {
+	t.Parallel()
+	round := server.NewRound(1, []bool{true, true}, time.Time{})
+	round.Selections[1] = game.SelectionRock
+	if round.Mismatched() {
+		t.Fatal("Expected to return false without mismatches, but returned true!")
+	}
+	round.Mismatches[0] = true
+	if !round.Mismatched() {
+		t.Fatal("Expected to return true with a mismatch, but returned false!")
+	}
+	if !round.Ended() {
+		t.Fatal("Expected a round with a mismatched reveal to be ended, but it was not!")
+	}
+}
+
+func TestRoundExpired(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	t.Run("ReturnFalseWhenDeadlineIsZero", func(t *testing.T) {
+		t.Parallel()
+		round := server.NewRound(1, []bool{true, true}, time.Time{})
+		if round.Expired(now) {
+			t.Fatal("Expected round without deadline to never expire, but it did!")
+		}
+	})
+	t.Run("ReturnFalseWhenDeadlineHasNotPassed", func(t *testing.T) {
+		t.Parallel()
+		round := server.NewRound(1, []bool{true, true}, now.Add(time.Second))
+		if round.Expired(now) {
+			t.Fatal("Expected round to not be expired before the deadline, but it was!")
+		}
+	})
+	t.Run("ReturnFalseWhenRoundHasEnded", func(t *testing.T) {
+		t.Parallel()
+		round := server.NewRound(1, []bool{true, true}, now.Add(-time.Second))
+		round.Selections[0] = game.SelectionRock
+		round.Selections[1] = game.SelectionPaper
+		if round.Expired(now) {
+			t.Fatal("Expected ended round to not be expired, but it was!")
+		}
+	})
+	t.Run("ReturnTrueWhenDeadlineHasPassed", func(t *testing.T) {
+		t.Parallel()
+		round := server.NewRound(1, []bool{true, true}, now.Add(-time.Second))
+		if !round.Expired(now) {
+			t.Fatal("Expected round to be expired after the deadline, but it was not!")
+		}
+	})
 }
 
 func TestRoundResult(t *testing.T) {
@@ -48,13 +157,12 @@ func TestRoundResult(t *testing.T) {
 	t.Run("ReturnDrawsWhenSelectionsAreSame", func(t *testing.T) {
 		t.Parallel()
 		for _, selection := range []game.Selection{game.SelectionPaper, game.SelectionRock, game.SelectionScissors} {
-			round := server.Round{Selection1: selection, Selection2: selection}
-			result1, result2 := round.Result()
-			if result1 != game.ResultDraw {
-				t.Fatalf("Expected result1 to be %q, but was %q!", game.ResultDraw, result1)
-			}
-			if result2 != game.ResultDraw {
-				t.Fatalf("Expected result2 to be %q, but was %q!", game.ResultDraw, result2)
+			round := server.NewRound(1, []bool{true, true}, time.Time{})
+			round.Selections = []game.Selection{selection, selection}
+			results := round.Result(game.Classic())
+			expected := []game.Result{game.ResultDraw, game.ResultDraw}
+			if !reflect.DeepEqual(results, expected) {
+				t.Fatalf("Expected results to be %q, but were %q!", expected, results)
 			}
 		}
 	})
@@ -66,13 +174,12 @@ func TestRoundResult(t *testing.T) {
 			{game.SelectionScissors, game.SelectionPaper},
 		}
 		for _, selections := range roundSelections {
-			round := server.Round{Selection1: selections[0], Selection2: selections[1]}
-			result1, result2 := round.Result()
-			if result1 != game.ResultWin {
-				t.Fatalf("Expected result1 to be %q, but was %q!", game.ResultWin, result1)
-			}
-			if result2 != game.ResultLose {
-				t.Fatalf("Expected result2 to be %q, but was %q!", game.ResultLose, result2)
+			round := server.NewRound(1, []bool{true, true}, time.Time{})
+			round.Selections = selections
+			results := round.Result(game.Classic())
+			expected := []game.Result{game.ResultWin, game.ResultLose}
+			if !reflect.DeepEqual(results, expected) {
+				t.Fatalf("Expected results to be %q, but were %q!", expected, results)
 			}
 		}
 	})
@@ -84,14 +191,92 @@ func TestRoundResult(t *testing.T) {
 			{game.SelectionScissors, game.SelectionRock},
 		}
 		for _, selections := range roundSelections {
-			round := server.Round{Selection1: selections[0], Selection2: selections[1]}
-			result1, result2 := round.Result()
-			if result1 != game.ResultLose {
-				t.Fatalf("Expected result1 to be %q, but was %q!", game.ResultLose, result1)
+			round := server.NewRound(1, []bool{true, true}, time.Time{})
+			round.Selections = selections
+			results := round.Result(game.Classic())
+			expected := []game.Result{game.ResultLose, game.ResultWin}
+			if !reflect.DeepEqual(results, expected) {
+				t.Fatalf("Expected results to be %q, but were %q!", expected, results)
 			}
-			if result2 != game.ResultWin {
-				t.Fatalf("Expected result2 to be %q, but was %q!", game.ResultWin, result2)
+		}
+	})
+}
+
+func TestRoundResultWithManyPlayers(t *testing.T) {
+	t.Parallel()
+	tests := map[string]struct {
+		rules      game.RuleSet
+		playing    []bool
+		selections []game.Selection
+		expected   []game.Result
+	}{
+		"ReturnDrawsWhenEverySelectionIsPresent": {
+			rules:      game.Classic(),
+			playing:    []bool{true, true, true},
+			selections: []game.Selection{game.SelectionRock, game.SelectionPaper, game.SelectionScissors},
+			expected:   []game.Result{game.ResultDraw, game.ResultDraw, game.ResultDraw},
+		},
+		"ReturnLosesForBeatenSelections": {
+			rules:      game.Classic(),
+			playing:    []bool{true, true, true, true},
+			selections: []game.Selection{game.SelectionRock, game.SelectionScissors, game.SelectionRock, game.SelectionScissors},
+			expected:   []game.Result{game.ResultWin, game.ResultLose, game.ResultWin, game.ResultLose},
+		},
+		"ReturnLoseForLowestScoreWithRPSLS": {
+			rules:      game.RPSLS(),
+			playing:    []bool{true, true, true},
+			selections: []game.Selection{game.SelectionRock, game.SelectionPaper, game.SelectionSpock},
+			expected:   []game.Result{game.ResultLose, game.ResultWin, game.ResultWin},
+		},
+		"ReturnNoResultWhenSeatIsNotPlaying": {
+			rules:      game.Classic(),
+			playing:    []bool{true, false, true},
+			selections: []game.Selection{game.SelectionRock, game.SelectionNone, game.SelectionPaper},
+			expected:   []game.Result{game.ResultLose, "", game.ResultWin},
+		},
+	}
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			round := server.NewRound(1, test.playing, time.Time{})
+			round.Selections = test.selections
+			if results := round.Result(test.rules); !reflect.DeepEqual(results, test.expected) {
+				t.Fatalf("Expected results to be %q, but were %q!", test.expected, results)
 			}
+		})
+	}
+}
+
+func TestRoundResultWithRPSLS(t *testing.T) {
+	t.Parallel()
+	round := server.NewRound(1, []bool{true, true}, time.Time{})
+	round.Selections = []game.Selection{game.SelectionSpock, game.SelectionRock}
+	results := round.Result(game.RPSLS())
+	expected := []game.Result{game.ResultWin, game.ResultLose}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("Expected results to be %q, but were %q!", expected, results)
+	}
+}
+
+func TestRoundForfeit(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnFalseWhenNoneSelected", func(t *testing.T) {
+		t.Parallel()
+		round := server.NewRound(1, []bool{true, true, true}, time.Time{})
+		if _, ok := round.Forfeit(); ok {
+			t.Fatal("Expected no forfeit without selections, but there was!")
+		}
+	})
+	t.Run("ReturnWinsForSelectedSeats", func(t *testing.T) {
+		t.Parallel()
+		round := server.NewRound(1, []bool{true, false, true, true}, time.Time{})
+		round.Selections[0] = game.SelectionRock
+		round.Selections[3] = game.SelectionPaper
+		results, ok := round.Forfeit()
+		expected := []game.Result{game.ResultWin, "", game.ResultLose, game.ResultWin}
+		if !ok || !reflect.DeepEqual(results, expected) {
+			t.Fatalf("Expected results to be %q, but were %q (ok: %t)!", expected, results, ok)
 		}
 	})
 }
@@ -1,22 +1,137 @@
 package server
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net"
-	"os"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/toivjon/go-rps/internal/com"
+	"github.com/toivjon/go-rps/internal/game"
 )
 
+// Config contains the adjustable settings of the server. The players specifies how many players play in
+// each game session and the tournament size how many players play in each tournament. The queued players are
+// paired with bots playing with the bot strategy once they have waited for the bot wait. The guests specifies
+// whether the players may join without logging in to a player account.
+type Config struct {
+	Players        int
+	TournamentSize int
+	Format         game.Format
+	Rules          game.RuleSet
+	RoundTimeout   time.Duration
+	ShutdownGrace  time.Duration
+	ResumeGrace    time.Duration
+	BotWait        time.Duration
+	BotStrategy    Strategy
+	Guests         bool
+}
+
+// DefaultConfig builds a configuration with the default settings where two players play against each other
+// and a single won round of the classic rock-paper-scissors wins the match and each round selection must be
+// made within a minute. Connections are closed immediately on shutdown without waiting for the ongoing rounds.
+// A player who loses the connection during a session may resume within half a minute. Tournaments are played
+// by four players. The queued players are never paired with bots unless they ask for them. The players may
+// join as guests.
+func DefaultConfig() Config {
+	return Config{
+		Players:        MinPlayers,
+		TournamentSize: defaultTournamentSize,
+		Format:         game.BestOf(1),
+		Rules:          game.Classic(),
+		RoundTimeout:   time.Minute,
+		ShutdownGrace:  0,
+		ResumeGrace:    30 * time.Second,
+		BotWait:        0,
+		BotStrategy:    RandomStrategy{},
+		Guests:         true,
+	}
+}
+
+const (
+	// MinPlayers and MaxPlayers specify the supported range of players in a game session.
+	MinPlayers = 2
+	MaxPlayers = 8
+	// MinTournamentSize and MaxTournamentSize specify the supported range of players in a tournament.
+	MinTournamentSize = 2
+	MaxTournamentSize = 64
+	// defaultTournamentSize specifies the number of players in a tournament by default.
+	defaultTournamentSize = 4
+	// tickInterval specifies how often the server main loop checks the time based events like round deadlines.
+	tickInterval = 100 * time.Millisecond
+	// resumeTokenSize specifies the number of random bytes in a resume token.
+	resumeTokenSize = 16
+)
+
+// ErrInvalidPlayers is an error occurring when a game session cannot be played with the number of players.
+var ErrInvalidPlayers = errors.New("the provided value is not a supported number of players")
+
+// ValidatePlayers returns an error if a game session cannot be played with the given number of players.
+func ValidatePlayers(players int) error {
+	if players < MinPlayers || players > MaxPlayers {
+		return fmt.Errorf("%w: %d (supported %d-%d)", ErrInvalidPlayers, players, MinPlayers, MaxPlayers)
+	}
+	return nil
+}
+
+// ErrInvalidTournamentSize is an error occurring when a tournament cannot be played with the number of players.
+var ErrInvalidTournamentSize = errors.New("the provided value is not a supported tournament size")
+
+// ValidateTournamentSize returns an error if a tournament cannot be played with the given number of players.
+func ValidateTournamentSize(size int) error {
+	if size < MinTournamentSize || size > MaxTournamentSize {
+		return fmt.Errorf("%w: %d (supported %d-%d)", ErrInvalidTournamentSize, size,
+			MinTournamentSize, MaxTournamentSize)
+	}
+	return nil
+}
+
 // Server represents a RPS server handling the connection communication, matchmaking and game logics.
+// The functions sent to the InspectCh are run in the main loop, so the state of a running server can be
+// inspected without racing the loop.
 type Server struct {
-	Listener net.Listener
-	Conns    map[io.ReadWriteCloser]*Client
-	JoinCh   chan Message[com.JoinContent]
-	SelectCh chan Message[com.SelectContent]
-	LeaveCh  chan io.ReadWriteCloser
-	Shutdown <-chan os.Signal
+	Config           Config
+	Listener         net.Listener
+	Conns            map[io.ReadWriteCloser]*Client
+	HelloCh          chan Message[com.HelloContent]
+	JoinCh           chan Message[com.JoinContent]
+	SelectCh         chan Message[com.SelectContent]
+	OfferCh          chan Message[com.RematchOfferContent]
+	AcceptCh         chan Message[com.RematchAcceptContent]
+	QueueCh          chan Message[com.QueueContent]
+	ResumeCh         chan Message[com.ResumeContent]
+	CreateRoomCh     chan Message[com.CreateRoomContent]
+	JoinRoomCh       chan Message[com.JoinRoomContent]
+	SpectateListCh   chan Message[com.SpectateListContent]
+	SpectateCh       chan Message[com.SpectateContent]
+	TournamentJoinCh chan Message[com.TournamentJoinContent]
+	CommitCh         chan Message[com.CommitContent]
+	RevealCh         chan Message[com.RevealContent]
+	RegisterCh       chan Message[com.RegisterContent]
+	LoginCh          chan Message[com.LoginContent]
+	AuthCh           chan Message[Authentication]
+	LeaveCh          chan io.ReadWriteCloser
+	InspectCh        chan func()
+	Routines         *sync.WaitGroup
+	Accounts         *Accounts
+	Throttle         *Throttle
+	History          History
+	Queue            *Queue
+	Rooms            *Rooms
+	Seats            map[string]*Client
+	Sessions         map[int]*Session
+	Registration     *Tournament
+	Tournaments      []*Tournament
+	lastSessionID    int
+	lastBotID        int
+	shuttingDown     bool
 }
 
 // Message represents an incoming message from a client connection.
@@ -25,87 +140,983 @@ type Message[T any] struct {
 	Content T
 }
 
-// NewServer builds a new server with the given network listener and shutdown channel.
-func NewServer(listener net.Listener, shutdown <-chan os.Signal) Server {
+// NewServer builds a new server with the given network listener and configuration. The player accounts and
+// the match history are kept only in memory unless they are replaced before the server is run.
+func NewServer(listener net.Listener, config Config) Server {
 	return Server{
-		Listener: listener,
-		Conns:    make(map[io.ReadWriteCloser]*Client),
-		JoinCh:   make(chan Message[com.JoinContent]),
-		SelectCh: make(chan Message[com.SelectContent]),
-		LeaveCh:  make(chan io.ReadWriteCloser),
-		Shutdown: shutdown,
+		Config:           config,
+		Listener:         listener,
+		Conns:            make(map[io.ReadWriteCloser]*Client),
+		HelloCh:          make(chan Message[com.HelloContent]),
+		JoinCh:           make(chan Message[com.JoinContent]),
+		SelectCh:         make(chan Message[com.SelectContent]),
+		OfferCh:          make(chan Message[com.RematchOfferContent]),
+		AcceptCh:         make(chan Message[com.RematchAcceptContent]),
+		QueueCh:          make(chan Message[com.QueueContent]),
+		ResumeCh:         make(chan Message[com.ResumeContent]),
+		CreateRoomCh:     make(chan Message[com.CreateRoomContent]),
+		JoinRoomCh:       make(chan Message[com.JoinRoomContent]),
+		SpectateListCh:   make(chan Message[com.SpectateListContent]),
+		SpectateCh:       make(chan Message[com.SpectateContent]),
+		TournamentJoinCh: make(chan Message[com.TournamentJoinContent]),
+		CommitCh:         make(chan Message[com.CommitContent]),
+		RevealCh:         make(chan Message[com.RevealContent]),
+		RegisterCh:       make(chan Message[com.RegisterContent]),
+		LoginCh:          make(chan Message[com.LoginContent]),
+		AuthCh:           make(chan Message[Authentication]),
+		LeaveCh:          make(chan io.ReadWriteCloser),
+		InspectCh:        make(chan func()),
+		Routines:         new(sync.WaitGroup),
+		Accounts:         NewAccounts(),
+		Throttle:         NewThrottle(),
+		History:          NewMemoryHistory(),
+		Queue:            NewQueue(),
+		Rooms:            NewRooms(config.Players),
+		Seats:            make(map[string]*Client),
+		Sessions:         make(map[int]*Session),
+		Registration:     NewTournament(config.TournamentSize),
+		Tournaments:      nil,
+		lastSessionID:    0,
+		lastBotID:        0,
+		shuttingDown:     false,
 	}
 }
 
 // Run starts running the server main loop which accepts new connections and handles incoming messages.
-func (s *Server) Run() {
-	accept := newAccept(s.Listener)
+// The loop ends with a graceful shutdown when the given context is done. Returns an error describing why
+// the server was stopped.
+func (s *Server) Run(ctx context.Context) error {
+	accept := newAccept(ctx, s.Listener)
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	connCtx, closeConns := context.WithCancel(context.Background())
+	defer closeConns()
 	for {
 		select {
+		case now := <-ticker.C:
+			s.handleTick(now)
+			s.advanceTournaments()
+			s.matchBots(connCtx, now)
 		case conn := <-accept:
-			s.handleAccept(conn)
+			s.handleAccept(connCtx, conn)
+		case message := <-s.HelloCh:
+			s.handleHello(message.Conn, message.Content)
 		case message := <-s.JoinCh:
-			s.handleJoin(message.Conn, message.Content)
+			s.handleJoin(connCtx, message.Conn, message.Content)
 		case message := <-s.SelectCh:
 			s.handleSelect(message.Conn, message.Content)
+		case message := <-s.CommitCh:
+			s.handleCommit(message.Conn, message.Content)
+		case message := <-s.RevealCh:
+			s.handleReveal(message.Conn, message.Content)
+		case message := <-s.OfferCh:
+			s.handleRematchOffer(message.Conn)
+		case message := <-s.AcceptCh:
+			s.handleRematchAccept(message.Conn)
+		case message := <-s.QueueCh:
+			s.handleQueue(message.Conn)
+		case message := <-s.ResumeCh:
+			s.handleResume(message.Conn, message.Content)
+		case message := <-s.CreateRoomCh:
+			s.handleCreateRoom(message.Conn, message.Content)
+		case message := <-s.JoinRoomCh:
+			s.handleJoinRoom(message.Conn, message.Content)
+		case message := <-s.SpectateListCh:
+			s.handleSpectateList(message.Conn)
+		case message := <-s.SpectateCh:
+			s.handleSpectate(message.Conn, message.Content)
+		case message := <-s.TournamentJoinCh:
+			s.handleTournamentJoin(message.Conn, message.Content)
+		case message := <-s.RegisterCh:
+			s.handleRegister(message.Conn, message.Content)
+		case message := <-s.LoginCh:
+			s.handleLogin(message.Conn, message.Content)
+		case message := <-s.AuthCh:
+			s.handleAuthentication(message.Conn, message.Content)
 		case conn := <-s.LeaveCh:
 			s.handleLeave(conn)
-		case <-s.Shutdown:
-			log.Printf("Shutting down server...")
-			// ...
-			return
+		case inspect := <-s.InspectCh:
+			inspect()
+		case <-ctx.Done():
+			s.shutdown(ticker.C, closeConns)
+			return fmt.Errorf("server was stopped. %w", ctx.Err())
 		}
 	}
 }
 
-func newAccept(listener net.Listener) <-chan net.Conn {
+func newAccept(ctx context.Context, listener net.Listener) <-chan net.Conn {
 	accept := make(chan net.Conn)
 	go func() {
 		for {
 			conn, err := listener.Accept()
-			if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			} else if err != nil {
 				log.Printf("Error accepting incoming connection: %s", err)
-			} else {
-				accept <- conn
+				continue
+			}
+			select {
+			case accept <- conn:
+			case <-ctx.Done():
+				conn.Close()
+				return
 			}
 		}
 	}()
 	return accept
 }
 
-func (s *Server) handleAccept(conn io.ReadWriteCloser) {
+// shutdown stops accepting new connections and notifies the connected clients about the shutdown. Sessions
+// may still finish their ongoing rounds within the grace period before all connections get closed. Returns
+// after every client routine has exited.
+func (s *Server) shutdown(tick <-chan time.Time, closeConns context.CancelFunc) {
+	log.Printf("Shutting down server (conns: %d, grace: %s)...", len(s.Conns), s.Config.ShutdownGrace)
+	if err := s.Listener.Close(); err != nil {
+		log.Printf("Failed to close listener. %s", err)
+	}
+	// Seats are no longer held as the connections could not be resumed after the shutdown.
+	s.shuttingDown = true
+	for token, client := range s.Seats {
+		delete(s.Seats, token)
+		if client.Session != nil {
+			client.Session.Leave(client)
+		}
+	}
+	for _, client := range s.Conns {
+		if err := client.WriteShutdown("server is shutting down", s.Config.ShutdownGrace); err != nil {
+			log.Printf("Failed to write SHUTDOWN message for %s. %s", client, err)
+		}
+	}
+	s.drainRounds(tick)
+	closeConns()
+	for _, client := range s.Conns {
+		client.Close()
+	}
+	s.drainRoutines()
+	for conn := range s.Conns {
+		delete(s.Conns, conn)
+	}
+	log.Printf("Server was shut down.")
+}
+
+// drainRounds keeps handling the messages of the ongoing game sessions until their rounds at the time of the
+// shutdown have been resolved or the grace period has passed.
+func (s *Server) drainRounds(tick <-chan time.Time) {
+	rounds := make(map[*Session]int)
+	for _, client := range s.Conns {
+		if client.Session != nil && !client.Session.Ended() {
+			rounds[client.Session] = client.Session.Round.Number
+		}
+	}
+	grace := time.NewTimer(s.Config.ShutdownGrace)
+	defer grace.Stop()
+	for s.pendingRounds(rounds) {
+		select {
+		case now := <-tick:
+			s.handleTick(now)
+		case message := <-s.HelloCh:
+			s.handleHello(message.Conn, message.Content)
+		case message := <-s.JoinCh:
+			s.rejectShutdown(message.Conn)
+		case message := <-s.SelectCh:
+			s.handleSelect(message.Conn, message.Content)
+		case message := <-s.CommitCh:
+			s.handleCommit(message.Conn, message.Content)
+		case message := <-s.RevealCh:
+			s.handleReveal(message.Conn, message.Content)
+		case message := <-s.OfferCh:
+			s.rejectShutdown(message.Conn)
+		case message := <-s.AcceptCh:
+			s.rejectShutdown(message.Conn)
+		case message := <-s.QueueCh:
+			s.rejectShutdown(message.Conn)
+		case message := <-s.ResumeCh:
+			s.rejectShutdown(message.Conn)
+		case message := <-s.CreateRoomCh:
+			s.rejectShutdown(message.Conn)
+		case message := <-s.JoinRoomCh:
+			s.rejectShutdown(message.Conn)
+		case message := <-s.SpectateListCh:
+			s.rejectShutdown(message.Conn)
+		case message := <-s.SpectateCh:
+			s.rejectShutdown(message.Conn)
+		case message := <-s.TournamentJoinCh:
+			s.rejectShutdown(message.Conn)
+		case message := <-s.RegisterCh:
+			s.rejectShutdown(message.Conn)
+		case message := <-s.LoginCh:
+			s.rejectShutdown(message.Conn)
+		case message := <-s.AuthCh:
+			s.handleAuthentication(message.Conn, message.Content)
+		case conn := <-s.LeaveCh:
+			s.handleLeave(conn)
+		case inspect := <-s.InspectCh:
+			inspect()
+		case <-grace.C:
+			log.Printf("Shutdown grace period passed with unfinished rounds.")
+			return
+		}
+	}
+}
+
+// pendingRounds checks whether any of the given sessions is still playing the round it was playing when
+// the shutdown began.
+func (s *Server) pendingRounds(rounds map[*Session]int) bool {
+	for session, round := range rounds {
+		if !session.Closed() && !session.Ended() && session.Round.Number == round {
+			return true
+		}
+	}
+	return false
+}
+
+// rejectShutdown rejects a message which would start a new match or subscribe to a session while the server
+// is shutting down.
+func (s *Server) rejectShutdown(conn io.ReadWriteCloser) {
+	if client, ok := s.Conns[conn]; ok {
+		s.reject(client, com.CodeUnexpectedMessage, "server is shutting down")
+	}
+}
+
+// drainRoutines discards the incoming messages until every client routine has exited.
+func (s *Server) drainRoutines() {
+	done := make(chan struct{})
+	go func() {
+		s.Routines.Wait()
+		close(done)
+	}()
+	for {
+		select {
+		case <-s.HelloCh:
+		case <-s.JoinCh:
+		case <-s.SelectCh:
+		case <-s.CommitCh:
+		case <-s.RevealCh:
+		case <-s.OfferCh:
+		case <-s.AcceptCh:
+		case <-s.QueueCh:
+		case <-s.ResumeCh:
+		case <-s.CreateRoomCh:
+		case <-s.JoinRoomCh:
+		case <-s.SpectateListCh:
+		case <-s.SpectateCh:
+		case <-s.TournamentJoinCh:
+		case <-s.RegisterCh:
+		case <-s.LoginCh:
+		case <-s.AuthCh:
+		case <-s.LeaveCh:
+		case <-done:
+			return
+		}
+	}
+}
+
+func (s *Server) handleAccept(ctx context.Context, conn io.ReadWriteCloser) {
 	client := NewClient(conn)
 	s.Conns[conn] = client
-	go client.Run(s.LeaveCh, s.JoinCh, s.SelectCh)
+	s.Routines.Add(1)
+	go func() {
+		defer s.Routines.Done()
+		err := client.Run(ctx, s.inbox())
+		log.Printf("Connection %#p stopped. %s", conn, err)
+	}()
 	log.Printf("Connection %#p added (conns: %d).", conn, len(s.Conns))
 }
 
-func (s *Server) handleJoin(conn io.ReadWriteCloser, content com.JoinContent) {
+// inbox builds the inbox where the client routines forward the messages for the server main loop.
+func (s *Server) inbox() Inbox {
+	return Inbox{
+		Leave:          s.LeaveCh,
+		Hello:          s.HelloCh,
+		Join:           s.JoinCh,
+		Select:         s.SelectCh,
+		Offer:          s.OfferCh,
+		Accept:         s.AcceptCh,
+		Queue:          s.QueueCh,
+		Resume:         s.ResumeCh,
+		CreateRoom:     s.CreateRoomCh,
+		JoinRoom:       s.JoinRoomCh,
+		SpectateList:   s.SpectateListCh,
+		Spectate:       s.SpectateCh,
+		TournamentJoin: s.TournamentJoinCh,
+		Commit:         s.CommitCh,
+		Reveal:         s.RevealCh,
+		Register:       s.RegisterCh,
+		Login:          s.LoginCh,
+	}
+}
+
+func (s *Server) handleHello(conn io.ReadWriteCloser, content com.HelloContent) {
 	if client, ok := s.Conns[conn]; ok {
-		client.Name = content.Name
-		s.Conns[conn] = client
-		log.Printf("Connection %#p joined (name: %s)", conn, content.Name)
+		if client.Handshaked() {
+			log.Printf("Connection %#p sent HELLO twice.", conn)
+			s.fail(client, com.CodeUnexpectedMessage, "handshake has already been completed")
+			return
+		}
+		welcome, err := com.Negotiate(content)
+		if err != nil {
+			log.Printf("Connection %#p rejected (version: %d). %s", conn, content.Version, err)
+			if err := client.WriteReject(err.Error()); err != nil {
+				log.Printf("Failed to reject connection %#p. %s", conn, err)
+			}
+			client.Close()
+			return
+		}
+		welcome.AccountRequired = !s.Config.Guests
+		if err := client.WriteWelcome(welcome); err != nil {
+			log.Printf("Failed to welcome connection %#p. %s", conn, err)
+			client.Close()
+			return
+		}
+		client.Version = welcome.Version
+		client.Capabilities = welcome.Capabilities
+		log.Printf("Connection %#p handshaked (version: %d)", conn, welcome.Version)
+	}
+}
 
-		for _, otherClient := range s.Conns {
-			// ... improve the way how to detect that otherClient actually has joined! Now we use name here!
-			if otherClient.Session == nil && otherClient != client && otherClient.Name != "" {
-				session := NewSession(client, otherClient)
-				if err := session.Start(); err != nil {
-					log.Printf("Failed to start session for connection %s and %s", client, otherClient)
-					otherClient.Session = nil
-					client.Session = nil
-				}
+func (s *Server) handleJoin(ctx context.Context, conn io.ReadWriteCloser, content com.JoinContent) {
+	if client, ok := s.Conns[conn]; ok {
+		content.Name = client.Identity(content.Name)
+		if !s.canJoin(client, com.TypeJoin, content.Name) {
+			return
+		}
+		if content.Bot != "" {
+			strategy, err := LookupStrategy(content.Bot)
+			if err != nil {
+				s.reject(client, com.CodeInvalidBot, err.Error())
+				return
+			}
+			s.register(client, content.Name)
+			log.Printf("Connection %#p joined against bots (name: %s, strategy: %s)", conn, content.Name, strategy)
+			s.startSession(append([]*Client{client}, s.addBots(ctx, strategy, s.Config.Players-1)...))
+			return
+		}
+		s.register(client, content.Name)
+		s.Queue.Push(client)
+		log.Printf("Connection %#p joined (name: %s, queued: %d)", conn, content.Name, s.Queue.Len())
+		s.matchmake()
+	}
+}
+
+func (s *Server) handleCreateRoom(conn io.ReadWriteCloser, content com.CreateRoomContent) {
+	if client, ok := s.Conns[conn]; ok {
+		content.Name = client.Identity(content.Name)
+		if !s.canJoin(client, com.TypeCreateRoom, content.Name) {
+			return
+		}
+		s.register(client, content.Name)
+		code, err := s.Rooms.Create(client)
+		if err != nil {
+			log.Printf("Failed to create room for connection %#p. %s", conn, err)
+			s.fail(client, com.CodeSessionFailed, "failed to create a room")
+			return
+		}
+		log.Printf("Connection %#p created room %s (name: %s, rooms: %d)", conn, code, content.Name, s.Rooms.Len())
+		if err := client.WriteRoomCreated(code); err != nil {
+			log.Printf("Failed to write ROOM_CREATED message for %s. %s", client, err)
+		}
+	}
+}
+
+func (s *Server) handleJoinRoom(conn io.ReadWriteCloser, content com.JoinRoomContent) {
+	if client, ok := s.Conns[conn]; ok {
+		content.Name = client.Identity(content.Name)
+		if !s.canJoin(client, com.TypeJoinRoom, content.Name) {
+			return
+		}
+		players, ok := s.Rooms.Join(content.Code, client)
+		if !ok {
+			s.reject(client, com.CodeRoomNotFound, fmt.Sprintf("room %q does not exist", content.Code))
+			return
+		}
+		s.register(client, content.Name)
+		log.Printf("Connection %#p joined room %s (name: %s, rooms: %d)", conn, content.Code, content.Name, s.Rooms.Len())
+		if players != nil {
+			s.startSession(players)
+		}
+	}
+}
+
+func (s *Server) handleTournamentJoin(conn io.ReadWriteCloser, content com.TournamentJoinContent) {
+	if client, ok := s.Conns[conn]; ok {
+		content.Name = client.Identity(content.Name)
+		if !s.canJoin(client, com.TypeTournamentJoin, content.Name) {
+			return
+		}
+		s.register(client, content.Name)
+		tournament := s.Registration
+		tournament.Register(client)
+		log.Printf("Connection %#p registered to tournament %#p (name: %s, registered: %d/%d)", conn, tournament,
+			content.Name, len(tournament.Players), tournament.Size)
+		if tournament.Full() {
+			s.Registration = NewTournament(s.Config.TournamentSize)
+			s.Tournaments = append(s.Tournaments, tournament)
+			tournament.Start()
+			s.advanceTournament(tournament)
+		} else {
+			s.writeBracket(tournament)
+		}
+	}
+}
+
+// canJoin checks whether the client may join the server with the given name and rejects the message if not.
+func (s *Server) canJoin(client *Client, messageType com.MessageType, name string) bool {
+	if !client.Handshaked() {
+		log.Printf("Connection %#p sent %s before HELLO.", client.Conn, messageType)
+		s.fail(client, com.CodeUnexpectedMessage, fmt.Sprintf("handshake must be completed before %s", messageType))
+		return false
+	}
+	if client.State != StateConnected {
+		s.reject(client, com.CodeUnexpectedMessage, "client has already joined")
+		return false
+	}
+	if err := game.ValidateName(name); err != nil {
+		s.reject(client, com.CodeInvalidName, err.Error())
+		return false
+	}
+	if !client.Authenticated() {
+		if !s.Config.Guests {
+			s.reject(client, com.CodeAuthRequired, fmt.Sprintf("login is required before %s", messageType))
+			return false
+		}
+		if s.Accounts.Registered(name) {
+			s.reject(client, com.CodeNameTaken, fmt.Sprintf("player name %q belongs to a registered account", name))
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) handleRegister(conn io.ReadWriteCloser, content com.RegisterContent) {
+	if client, ok := s.Conns[conn]; ok {
+		if !s.canAuthenticate(client, com.TypeRegister) {
+			return
+		}
+		if err := game.ValidateName(content.Name); err != nil {
+			s.reject(client, com.CodeInvalidName, err.Error())
+			return
+		}
+		if err := ValidatePassword(content.Password); err != nil {
+			s.reject(client, com.CodeInvalidPassword, err.Error())
+			return
+		}
+		if s.Accounts.Registered(content.Name) {
+			s.reject(client, com.CodeNameTaken, ErrNameTaken.Error())
+			return
+		}
+		log.Printf("Connection %#p registers account (name: %s)", conn, content.Name)
+		s.authenticate(client, func() Authentication {
+			token, err := s.Accounts.Register(content.Name, content.Password)
+			return Authentication{Name: content.Name, Token: token, Err: err}
+		})
+	}
+}
+
+func (s *Server) handleLogin(conn io.ReadWriteCloser, content com.LoginContent) {
+	if client, ok := s.Conns[conn]; ok {
+		if !s.canAuthenticate(client, com.TypeLogin) {
+			return
+		}
+		if wait := s.Throttle.Wait(time.Now(), throttleKeys(conn, content.Name)...); wait > 0 {
+			log.Printf("Connection %#p login throttled (name: %s)", conn, content.Name)
+			s.reject(client, com.CodeAuthFailed, fmt.Sprintf("too many failed attempts, try again in %s",
+				wait.Round(time.Second)))
+			return
+		}
+		if content.Token != "" {
+			s.authenticate(client, func() Authentication {
+				name, err := s.Accounts.Authenticate(content.Token)
+				return Authentication{Name: name, Token: content.Token, Err: err}
+			})
+			return
+		}
+		s.authenticate(client, func() Authentication {
+			token, err := s.Accounts.Login(content.Name, content.Password)
+			return Authentication{Name: content.Name, Token: token, Err: err}
+		})
+	}
+}
+
+// canAuthenticate checks whether the client may log in to a player account and rejects the message if not.
+func (s *Server) canAuthenticate(client *Client, messageType com.MessageType) bool {
+	if !client.Handshaked() {
+		log.Printf("Connection %#p sent %s before HELLO.", client.Conn, messageType)
+		s.fail(client, com.CodeUnexpectedMessage, fmt.Sprintf("handshake must be completed before %s", messageType))
+		return false
+	}
+	switch {
+	case client.State != StateConnected:
+		s.reject(client, com.CodeUnexpectedMessage, "client has already joined")
+	case client.Account != "":
+		s.reject(client, com.CodeUnexpectedMessage, "client has already logged in")
+	case client.Authenticating:
+		s.reject(client, com.CodeUnexpectedMessage, "client is already logging in")
+	default:
+		return true
+	}
+	return false
+}
+
+// authenticate runs the given authentication in its own routine as hashing a password takes too long to be
+// done in the server main loop. The outcome is passed back to the main loop through the authentication channel.
+func (s *Server) authenticate(client *Client, authenticate func() Authentication) {
+	client.Authenticating = true
+	s.Routines.Add(1)
+	go func() {
+		defer s.Routines.Done()
+		s.AuthCh <- Message[Authentication]{Conn: client.Conn, Content: authenticate()}
+	}()
+}
+
+func (s *Server) handleAuthentication(conn io.ReadWriteCloser, content Authentication) {
+	if client, ok := s.Conns[conn]; ok {
+		client.Authenticating = false
+		switch {
+		case errors.Is(content.Err, ErrNameTaken):
+			s.reject(client, com.CodeNameTaken, content.Err.Error())
+		case errors.Is(content.Err, ErrInvalidCredentials), errors.Is(content.Err, ErrInvalidToken):
+			s.Throttle.Fail(time.Now(), throttleKeys(conn, content.Name)...)
+			s.reject(client, com.CodeAuthFailed, content.Err.Error())
+		case content.Err != nil:
+			log.Printf("Failed to authenticate connection %#p. %s", conn, content.Err)
+			s.reject(client, com.CodeAuthFailed, "failed to authenticate")
+		case s.loggedIn(content.Name):
+			log.Printf("Connection %#p rejected as %s is already logged in.", conn, content.Name)
+			s.reject(client, com.CodeAuthFailed, "account is already logged in on another connection")
+		default:
+			s.Throttle.Reset(throttleKeys(conn, content.Name)...)
+			client.Account = content.Name
+			log.Printf("Connection %#p logged in (name: %s)", conn, content.Name)
+			if err := client.WriteAuthenticated(content.Name, content.Token); err != nil {
+				log.Printf("Failed to write AUTHENTICATED message for %s. %s", client, err)
 			}
 		}
 	}
 }
 
+// loggedIn checks whether a connection has already logged in to the account with the given name.
+func (s *Server) loggedIn(name string) bool {
+	for _, client := range s.Conns {
+		if client.Account == name {
+			return true
+		}
+	}
+	return false
+}
+
+// throttleKeys returns the keys which the failed login attempts of the connection are throttled by.
+func throttleKeys(conn io.ReadWriter, name string) []string {
+	keys := []string{}
+	if name != "" {
+		keys = append(keys, "name:"+name)
+	}
+	if host := remoteHost(conn); host != "" {
+		keys = append(keys, "address:"+host)
+	}
+	return keys
+}
+
+// register assigns the name and a resume token for the joined client.
+func (s *Server) register(client *Client, name string) {
+	client.Name = name
+	if token, err := newResumeToken(); err != nil {
+		log.Printf("Failed to generate resume token for connection %#p. %s", client.Conn, err)
+	} else {
+		client.ResumeToken = token
+	}
+}
+
+// newResumeToken generates a random token which a client may use to take back its seat after reconnecting.
+func newResumeToken() (string, error) {
+	bytes := make([]byte, resumeTokenSize)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to read random bytes for resume token. %w", err)
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// matchmake starts a session for the clients which have waited the longest in the matchmaking queue.
+func (s *Server) matchmake() {
+	players, ok := s.Queue.Take(s.Config.Players)
+	if ok {
+		s.startSession(players)
+	}
+}
+
+// matchBots pairs the queued clients with bots once the client which has waited the longest has waited for
+// the bot wait. The bots play with the configured bot strategy and fill the seats which are left empty.
+func (s *Server) matchBots(ctx context.Context, now time.Time) {
+	if s.Config.BotWait <= 0 || s.Queue.Len() == 0 || s.Queue.Waited(now) < s.Config.BotWait {
+		return
+	}
+	players, _ := s.Queue.Take(s.Queue.Len())
+	log.Printf("Queued players waited for %s, pairing them with bots (strategy: %s)", s.Config.BotWait,
+		s.Config.BotStrategy)
+	s.startSession(append(players, s.addBots(ctx, s.Config.BotStrategy, s.Config.Players-len(players))...))
+}
+
+// addBots connects the given number of bots playing with the strategy to the server. The bots are added like
+// the accepted connections, so they leave the server through their client routine like the remote clients.
+func (s *Server) addBots(ctx context.Context, strategy Strategy, count int) []*Client {
+	bots := make([]*Client, count)
+	for i := range bots {
+		bot := NewBot(strategy)
+		s.handleAccept(ctx, bot)
+		s.lastBotID++
+		bots[i] = s.Conns[bot]
+		bots[i].Name = fmt.Sprintf("bot %d (%s)", s.lastBotID, strategy)
+		bots[i].Version = com.ProtocolVersion
+	}
+	return bots
+}
+
+// startSession starts a new game session between the given clients and registers it for the spectators. The
+// decided matches of the session are written to the match history.
+func (s *Server) startSession(players []*Client) *Session {
+	session := NewSession(players, s.Config)
+	session.History = s.History
+	s.lastSessionID++
+	session.ID = s.lastSessionID
+	s.Sessions[session.ID] = session
+	if err := session.Start(); err != nil {
+		log.Printf("Failed to start session for %s. %s", session, err)
+		session.Abort(com.CodeSessionFailed, "failed to start the game session")
+	}
+	return session
+}
+
+// advanceTournaments advances the brackets of the ongoing tournaments and releases the decided tournaments.
+func (s *Server) advanceTournaments() {
+	ongoing := s.Tournaments[:0]
+	for _, tournament := range s.Tournaments {
+		s.advanceTournament(tournament)
+		if tournament.Ended() {
+			tournament.Finish()
+		} else {
+			ongoing = append(ongoing, tournament)
+		}
+	}
+	s.Tournaments = ongoing
+}
+
+// advanceTournament decides the pairings of the tournament which can be decided, reports the changed bracket
+// to the participants and starts the sessions of the pairings which are ready to be played.
+func (s *Server) advanceTournament(tournament *Tournament) {
+	if !tournament.Advance() && len(tournament.Ready()) == 0 {
+		return
+	}
+	s.writeBracket(tournament)
+	for _, pairing := range tournament.Ready() {
+		pairing.Session = s.startSession(pairing.Players)
+	}
+}
+
+// writeBracket reports the bracket of the tournament to its participants and to the players who have just been
+// knocked out of it.
+func (s *Server) writeBracket(tournament *Tournament) {
+	for _, player := range append(tournament.Participants(), tournament.TakeEliminated()...) {
+		if err := player.WriteBracket(tournament.Bracket(player)); err != nil {
+			log.Printf("Failed to write BRACKET message for %s. %s", player, err)
+		}
+	}
+}
+
 func (s *Server) handleSelect(conn io.ReadWriteCloser, content com.SelectContent) {
 	if client, ok := s.Conns[conn]; ok {
 		log.Printf("Connection %#p selection received (selection: %s)", conn, content.Selection)
+		if !s.canSelect(client, content.Round) {
+			return
+		}
+		if err := client.Session.Rules.Validate(content.Selection); err != nil {
+			s.reject(client, com.CodeInvalidSelection, err.Error())
+			return
+		}
 		if err := client.Session.Select(client, content.Selection); err != nil {
 			log.Printf("Failed to process SELECT in session %#p. %s", client.Session, err)
-			client.Session.Close()
+			client.Session.Abort(com.CodeSessionFailed, "failed to resolve the game round")
+		}
+	}
+}
+
+func (s *Server) handleCommit(conn io.ReadWriteCloser, content com.CommitContent) {
+	if client, ok := s.Conns[conn]; ok {
+		log.Printf("Connection %#p commitment received (round: %d)", conn, content.Round)
+		if !com.HasCapability(client.Capabilities, com.CapabilityCommitReveal) {
+			s.reject(client, com.CodeUnexpectedMessage, "commit-reveal has not been negotiated")
+			return
+		}
+		if !s.canSelect(client, content.Round) {
+			return
+		}
+		if err := com.ValidateCommitment(content.Commitment); err != nil {
+			s.reject(client, com.CodeInvalidSelection, err.Error())
+			return
+		}
+		if err := client.Session.Commit(client, content.Commitment); err != nil {
+			log.Printf("Failed to process COMMIT in session %#p. %s", client.Session, err)
+			client.Session.Abort(com.CodeSessionFailed, "failed to seal the game round")
+		}
+	}
+}
+
+// canSelect checks whether the client may make or commit to a selection for the given round and rejects the
+// message if not. A selection for a round which has already ended is ignored.
+func (s *Server) canSelect(client *Client, round int) bool {
+	switch {
+	case client.Session == nil && client.State == StateLobby:
+		log.Printf("Connection %#p selection ignored as the game session has been closed.", client.Conn)
+		return false
+	case client.Session == nil:
+		s.reject(client, com.CodeUnexpectedMessage, "client is not in a game session")
+		return false
+	case client.Session.Ended():
+		s.reject(client, com.CodeUnexpectedMessage, "game session has already ended")
+		return false
+	case round < client.Session.Round.Number:
+		log.Printf("Connection %#p selection ignored as round %d has already ended.", client.Conn, round)
+		return false
+	case round > client.Session.Round.Number:
+		s.reject(client, com.CodeUnexpectedMessage, fmt.Sprintf("round %d has not started", round))
+		return false
+	case !client.Session.Playing(client):
+		s.reject(client, com.CodeUnexpectedMessage, "player has been eliminated from the ongoing game")
+		return false
+	case client.Session.HasSelected(client):
+		s.reject(client, com.CodeUnexpectedMessage, "selection has already been made for the round")
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleReveal(conn io.ReadWriteCloser, content com.RevealContent) {
+	if client, ok := s.Conns[conn]; ok {
+		log.Printf("Connection %#p reveal received (selection: %s)", conn, content.Selection)
+		switch {
+		case client.Session == nil && client.State == StateLobby:
+			log.Printf("Connection %#p reveal ignored as the game session has been closed.", conn)
+			return
+		case client.Session == nil:
+			s.reject(client, com.CodeUnexpectedMessage, "client is not in a game session")
+			return
+		case client.Session.Ended():
+			s.reject(client, com.CodeUnexpectedMessage, "game session has already ended")
+			return
+		case content.Round < client.Session.Round.Number:
+			log.Printf("Connection %#p reveal ignored as round %d has already ended.", conn, content.Round)
+			return
+		case content.Round > client.Session.Round.Number:
+			s.reject(client, com.CodeUnexpectedMessage, fmt.Sprintf("round %d has not started", content.Round))
+			return
+		case !client.Session.Unrevealed(client):
+			s.reject(client, com.CodeUnexpectedMessage, "no unrevealed commitment has been made for the round")
+			return
+		case !client.Session.Round.Sealed():
+			s.reject(client, com.CodeUnexpectedMessage, "every player has not committed yet")
+			return
+		}
+		err := client.Session.Reveal(client, content.Selection, content.Nonce)
+		switch {
+		case errors.Is(err, ErrRevealMismatch):
+			log.Printf("Session %#p round could not be resolved. %s", client.Session, err)
+			client.Session.Abort(com.CodeSessionFailed, "none of the reveals matched the commitments")
+		case err != nil:
+			log.Printf("Failed to process REVEAL in session %#p. %s", client.Session, err)
+			client.Session.Abort(com.CodeSessionFailed, "failed to resolve the game round")
+		}
+	}
+}
+
+func (s *Server) handleRematchOffer(conn io.ReadWriteCloser) {
+	if client, ok := s.Conns[conn]; ok {
+		if !s.canRematch(client) {
+			return
+		}
+		if err := client.Session.Offer(client); err != nil {
+			log.Printf("Failed to process REMATCH_OFFER in session %#p. %s", client.Session, err)
+			client.Session.Abort(com.CodeSessionFailed, "failed to offer a rematch")
+		}
+	}
+}
+
+func (s *Server) handleRematchAccept(conn io.ReadWriteCloser) {
+	if client, ok := s.Conns[conn]; ok {
+		if !s.canRematch(client) {
+			return
+		}
+		if !client.Session.Proposed(client) {
+			s.reject(client, com.CodeUnexpectedMessage, "no opponent has offered a rematch")
+			return
+		}
+		if err := client.Session.Accept(client); err != nil {
+			log.Printf("Failed to process REMATCH_ACCEPT in session %#p. %s", client.Session, err)
+			client.Session.Abort(com.CodeSessionFailed, "failed to start the rematch")
+		}
+	}
+}
+
+func (s *Server) handleQueue(conn io.ReadWriteCloser) {
+	if client, ok := s.Conns[conn]; ok {
+		switch {
+		case client.State == StateConnected:
+			s.reject(client, com.CodeUnexpectedMessage, "client must join before queueing")
+			return
+		case client.State == StateQueued:
+			s.reject(client, com.CodeUnexpectedMessage, "client has already been queued")
+			return
+		case client.Tournament != nil:
+			s.reject(client, com.CodeUnexpectedMessage, "client plays in a tournament")
+			return
+		case client.Session != nil && !client.Session.Ended():
+			s.reject(client, com.CodeUnexpectedMessage, "match has not been decided")
+			return
+		}
+		if client.Session != nil {
+			client.Session.Leave(client)
+		}
+		s.Queue.Push(client)
+		log.Printf("Connection %#p queued again (name: %s, queued: %d)", conn, client.Name, s.Queue.Len())
+		s.matchmake()
+	}
+}
+
+// canRematch checks whether the client may offer or accept a rematch and rejects the message if not.
+func (s *Server) canRematch(client *Client) bool {
+	switch {
+	case client.Session == nil && client.State == StateLobby:
+		log.Printf("Connection %#p rematch ignored as the game session has been closed.", client.Conn)
+		return false
+	case client.Session == nil:
+		s.reject(client, com.CodeUnexpectedMessage, "client is not in a game session")
+		return false
+	case !client.Session.Ended():
+		s.reject(client, com.CodeUnexpectedMessage, "match has not been decided")
+		return false
+	case client.Tournament != nil:
+		s.reject(client, com.CodeUnexpectedMessage, "tournament matches cannot be rematched")
+		return false
+	case client.Session.Offered(client):
+		s.reject(client, com.CodeUnexpectedMessage, "rematch has already been offered")
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleResume(conn io.ReadWriteCloser, content com.ResumeContent) {
+	if client, ok := s.Conns[conn]; ok {
+		if !client.Handshaked() {
+			log.Printf("Connection %#p sent RESUME before HELLO.", conn)
+			s.fail(client, com.CodeUnexpectedMessage, "handshake must be completed before RESUME")
+			return
+		}
+		if client.State != StateConnected {
+			s.reject(client, com.CodeUnexpectedMessage, "client has already joined")
+			return
+		}
+		seat, ok := s.Seats[content.Token]
+		if !ok || seat.Session == nil {
+			s.reject(client, com.CodeResumeFailed, "resume token is unknown or the seat has expired")
+			return
+		}
+		delete(s.Seats, content.Token)
+		seat.Conn = conn
+		seat.Version = client.Version
+		seat.Capabilities = client.Capabilities
+		seat.ResumeDeadline = time.Time{}
+		s.Conns[conn] = seat
+		log.Printf("Connection %#p resumed the seat of %s", conn, seat)
+		if err := seat.Session.Resume(seat); err != nil {
+			log.Printf("Failed to resume session %#p. %s", seat.Session, err)
+			seat.Session.Abort(com.CodeSessionFailed, "failed to resume the game session")
+		}
+	}
+}
+
+func (s *Server) handleSpectateList(conn io.ReadWriteCloser) {
+	if client, ok := s.Conns[conn]; ok {
+		if !s.canSpectate(client, com.TypeSpectateList) {
+			return
+		}
+		s.pruneSessions()
+		sessions := make([]com.SessionInfo, 0, len(s.Sessions))
+		for _, session := range s.Sessions {
+			sessions = append(sessions, session.Info())
+		}
+		sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+		if err := client.WriteSpectateSessions(sessions); err != nil {
+			log.Printf("Failed to write SPECTATE_SESSIONS message for %s. %s", client, err)
+		}
+	}
+}
+
+func (s *Server) handleSpectate(conn io.ReadWriteCloser, content com.SpectateContent) {
+	if client, ok := s.Conns[conn]; ok {
+		if !s.canSpectate(client, com.TypeSpectate) {
+			return
+		}
+		session, ok := s.Sessions[content.SessionID]
+		if !ok || session.Closed() {
+			s.reject(client, com.CodeSessionNotFound, fmt.Sprintf("session %d does not exist", content.SessionID))
+			return
+		}
+		if client.Spectating != nil {
+			client.Spectating.Unwatch(client)
+		}
+		session.Watch(client)
+	}
+}
+
+// canSpectate checks whether the client may watch the game sessions and rejects the message if not. Only the
+// clients which have not joined as players may be spectators.
+func (s *Server) canSpectate(client *Client, messageType com.MessageType) bool {
+	if !client.Handshaked() {
+		log.Printf("Connection %#p sent %s before HELLO.", client.Conn, messageType)
+		s.fail(client, com.CodeUnexpectedMessage, fmt.Sprintf("handshake must be completed before %s", messageType))
+		return false
+	}
+	if client.State != StateConnected && client.State != StateSpectating {
+		s.reject(client, com.CodeUnexpectedMessage, "players cannot spectate")
+		return false
+	}
+	return true
+}
+
+// pruneSessions removes the closed sessions from the session registry.
+func (s *Server) pruneSessions() {
+	for id, session := range s.Sessions {
+		if session.Closed() {
+			delete(s.Sessions, id)
+		}
+	}
+}
+
+func (s *Server) handleTick(now time.Time) {
+	s.expireSeats(now)
+	s.pruneSessions()
+	s.Throttle.Prune(now)
+	// The sessions are expired even when all of their players have lost their connections.
+	for _, session := range s.Sessions {
+		if err := session.Expire(now); errors.Is(err, ErrRoundTimeout) {
+			log.Printf("Session %#p round %d expired without selections.", session, session.Round.Number)
+			session.Abort(com.CodeRoundTimeout, "none of the players made a selection in time")
+		} else if err != nil {
+			log.Printf("Failed to expire round in session %#p. %s", session, err)
+			session.Abort(com.CodeSessionFailed, "failed to resolve the game round")
+		}
+	}
+}
+
+// expireSeats releases the seats which were not resumed in time or whose session has already been closed.
+func (s *Server) expireSeats(now time.Time) {
+	for token, client := range s.Seats {
+		if client.Session == nil || now.After(client.ResumeDeadline) {
+			delete(s.Seats, token)
+			log.Printf("Seat of %s expired.", client)
+			if client.Session != nil {
+				client.Session.Leave(client)
+			}
+			if client.Tournament != nil {
+				client.Tournament.Withdraw(client)
+			}
 		}
 	}
 }
@@ -113,9 +1124,50 @@ func (s *Server) handleSelect(conn io.ReadWriteCloser, content com.SelectContent
 func (s *Server) handleLeave(conn io.ReadWriteCloser) {
 	if client, ok := s.Conns[conn]; ok {
 		delete(s.Conns, conn)
+		s.Queue.Remove(client)
+		s.Rooms.Remove(client)
+		if client.Spectating != nil {
+			client.Spectating.Unwatch(client)
+		}
 		if client.Session != nil {
-			client.Session.Close()
+			if !s.shuttingDown && s.Config.ResumeGrace > 0 && client.ResumeToken != "" {
+				client.ResumeDeadline = time.Now().Add(s.Config.ResumeGrace)
+				s.Seats[client.ResumeToken] = client
+				log.Printf("Connection %#p lost, seat held for %s.", conn, s.Config.ResumeGrace)
+			} else {
+				client.Session.Leave(client)
+			}
+		}
+		if client.Tournament != nil && !client.Detached() {
+			s.withdraw(client)
 		}
 		log.Printf("Connection %#p removed (conns: %d).", conn, len(s.Conns))
 	}
 }
+
+// withdraw removes the client from its tournament. The remaining registrations are reported to the other
+// players when the tournament has not started yet.
+func (s *Server) withdraw(client *Client) {
+	tournament := client.Tournament
+	tournament.Withdraw(client)
+	log.Printf("Connection %#p withdrew from tournament %#p", client.Conn, tournament)
+	if !tournament.Started() {
+		s.writeBracket(tournament)
+	}
+}
+
+// reject reports the given rejection to the client while keeping the connection open.
+func (s *Server) reject(client *Client, code com.ErrorCode, message string) {
+	log.Printf("Rejected message from %s (code: %s). %s", client, code, message)
+	if err := client.WriteError(code, message); err != nil {
+		log.Printf("Failed to write ERROR message for %s. %s", client, err)
+	}
+}
+
+// fail reports the given failure to the client and closes the connection.
+func (s *Server) fail(client *Client, code com.ErrorCode, message string) {
+	if err := client.WriteError(code, message); err != nil {
+		log.Printf("Failed to write ERROR message for %s. %s", client, err)
+	}
+	client.Close()
+}
@@ -1,8 +1,15 @@
 package server_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net"
-	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,6 +21,17 @@ import (
 type listenerMock struct {
 	acceptErr error
 	acceptCh  chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newListenerMock() *listenerMock {
+	return &listenerMock{
+		acceptErr: nil,
+		acceptCh:  make(chan net.Conn),
+		closed:    make(chan struct{}),
+		closeOnce: sync.Once{},
+	}
 }
 
 func (l *listenerMock) Accept() (net.Conn, error) {
@@ -22,10 +40,16 @@ func (l *listenerMock) Accept() (net.Conn, error) {
 		l.acceptErr = nil
 		return nil, err
 	}
-	return <-l.acceptCh, nil
+	select {
+	case conn := <-l.acceptCh:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
 }
 
 func (l *listenerMock) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
 	return nil
 }
 
@@ -34,13 +58,18 @@ func (l *listenerMock) Addr() net.Addr {
 }
 
 type fullConnMock struct {
-	readCh   chan any
-	writeErr error
+	closed    chan struct{}
+	closeOnce sync.Once
+	writeErr  error
+}
+
+func newFullConnMock() *fullConnMock {
+	return &fullConnMock{closed: make(chan struct{}), closeOnce: sync.Once{}, writeErr: nil}
 }
 
 func (f *fullConnMock) Read(b []byte) (int, error) {
-	<-f.readCh
-	return 0, nil
+	<-f.closed
+	return 0, net.ErrClosed
 }
 
 func (f *fullConnMock) Write(b []byte) (int, error) {
@@ -48,6 +77,7 @@ func (f *fullConnMock) Write(b []byte) (int, error) {
 }
 
 func (f *fullConnMock) Close() error {
+	f.closeOnce.Do(func() { close(f.closed) })
 	return nil
 }
 
@@ -71,16 +101,69 @@ func (f *fullConnMock) SetWriteDeadline(t time.Time) error {
 	return nil
 }
 
+// inspect runs the given function in the main loop of the running server and returns its result, so that the
+// tests can read the server state without racing the loop.
+func inspect[T any](srv *server.Server, fn func() T) T {
+	result := make(chan T, 1)
+	srv.InspectCh <- func() { result <- fn() }
+	return <-result
+}
+
+// update runs the given function in the main loop of the running server, so that the tests can modify the server
+// state without racing the loop.
+func update(srv *server.Server, fn func()) {
+	inspect(srv, func() struct{} {
+		fn()
+		return struct{}{}
+	})
+}
+
+// clientOf returns a copy of the client of the given connection in the running server or a zero client when the
+// connection is not known.
+func clientOf(srv *server.Server, conn io.ReadWriteCloser) server.Client {
+	return inspect(srv, func() server.Client {
+		if client, ok := srv.Conns[conn]; ok {
+			return *client
+		}
+		return server.Client{}
+	})
+}
+
+// selectionOf returns the selection of the player with the given index in the ongoing round of the session.
+func selectionOf(srv *server.Server, session *server.Session, player int) game.Selection {
+	return inspect(srv, func() game.Selection { return session.Round.Selections[player] })
+}
+
+// scoresOf returns a copy of the scores of the session.
+func scoresOf(srv *server.Server, session *server.Session) []int {
+	return inspect(srv, func() []int { return append([]int(nil), session.Scores...) })
+}
+
+// connCount returns the number of connections in the running server.
+func connCount(srv *server.Server) int {
+	return inspect(srv, func() int { return len(srv.Conns) })
+}
+
 func TestNewServer(t *testing.T) {
 	t.Parallel()
-	listenerMock := new(listenerMock)
-	shutdown := make(chan os.Signal)
-	server := server.NewServer(listenerMock, shutdown)
+	listenerMock := newListenerMock()
+	server := server.NewServer(listenerMock, server.DefaultConfig())
 	if server.Listener != listenerMock {
 		t.Fatalf("Expected listener member to be %#p but was %#p!", listenerMock, server.Listener)
 	}
-	if server.Shutdown != shutdown {
-		t.Fatalf("Expected shutdown channel to be %#p but it was %#p!", &shutdown, server.Shutdown)
+}
+
+func TestValidatePlayers(t *testing.T) {
+	t.Parallel()
+	for _, players := range []int{server.MinPlayers, 3, server.MaxPlayers} {
+		if err := server.ValidatePlayers(players); err != nil {
+			t.Fatalf("Expected no error for %d players, but %q was returned!", players, err)
+		}
+	}
+	for _, players := range []int{-1, 0, 1, server.MaxPlayers + 1} {
+		if err := server.ValidatePlayers(players); !errors.Is(err, server.ErrInvalidPlayers) {
+			t.Fatalf("Expected %q in the chain %q for %d players, but did not exists!", server.ErrInvalidPlayers, err, players)
+		}
 	}
 }
 
@@ -89,210 +172,1918 @@ func TestServerRun(t *testing.T) {
 	t.Parallel()
 	t.Run("SkipFailedAccept", func(t *testing.T) {
 		t.Parallel()
-		listenerMock := new(listenerMock)
+		listenerMock := newListenerMock()
 		listenerMock.acceptErr = errMock
 		listenerMock.acceptCh = make(chan net.Conn)
-		shutdown := make(chan os.Signal)
-		server := server.NewServer(listenerMock, shutdown)
-		go server.Run()
-		shutdown <- os.Kill
-		if len(server.Conns) != 0 {
-			t.Fatalf("Expected connections to be empty, but was %v!", server.Conns)
+		ctx, cancel := context.WithCancel(context.Background())
+		server := server.NewServer(listenerMock, server.DefaultConfig())
+		go server.Run(ctx)
+		conns := connCount(&server)
+		cancel()
+		if conns != 0 {
+			t.Fatalf("Expected connections to be empty, but had %d!", conns)
 		}
 	})
 	t.Run("StartNewClientOnAccept", func(t *testing.T) {
 		t.Parallel()
-		listenerMock := new(listenerMock)
+		listenerMock := newListenerMock()
 		listenerMock.acceptCh = make(chan net.Conn)
-		shutdown := make(chan os.Signal)
-		server := server.NewServer(listenerMock, shutdown)
-		go server.Run()
-		conn := new(fullConnMock)
+		ctx, cancel := context.WithCancel(context.Background())
+		server := server.NewServer(listenerMock, server.DefaultConfig())
+		go server.Run(ctx)
+		conn := newFullConnMock()
 		listenerMock.acceptCh <- conn
 		time.Sleep(time.Second)
-		if len(server.Conns) != 1 {
-			t.Fatalf("Expected connections to contain one item, but had %d!", len(server.Conns))
+		if conns := connCount(&server); conns != 1 {
+			t.Fatalf("Expected connections to contain one item, but had %d!", conns)
 		}
-		if server.Conns[conn].Conn != conn {
+		if clientOf(&server, conn).Conn != conn {
 			t.Fatal("Expected client to wrap connection, but it did not!")
 		}
-		shutdown <- os.Kill
+		cancel()
+	})
+	t.Run("HandshakeClientOnHello", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		listenerMock.acceptCh = make(chan net.Conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn := newFullConnMock()
+		srv.Conns[conn] = server.NewClient(conn)
+		go srv.Run(ctx)
+		srv.HelloCh <- server.Message[com.HelloContent]{
+			Conn:    conn,
+			Content: com.HelloContent{Version: com.ProtocolVersion + 1, Capabilities: nil},
+		}
+		time.Sleep(time.Second)
+		if version := clientOf(&srv, conn).Version; version != com.ProtocolVersion {
+			t.Fatalf("Expected client version to be %d, but was %d!", com.ProtocolVersion, version)
+		}
+		cancel()
+	})
+	t.Run("RejectClientOnUnsupportedVersion", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		listenerMock.acceptCh = make(chan net.Conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn := newFullConnMock()
+		srv.Conns[conn] = server.NewClient(conn)
+		go srv.Run(ctx)
+		srv.HelloCh <- server.Message[com.HelloContent]{
+			Conn:    conn,
+			Content: com.HelloContent{Version: com.MinProtocolVersion - 1, Capabilities: nil},
+		}
+		time.Sleep(time.Second)
+		if cli := clientOf(&srv, conn); cli.Handshaked() {
+			t.Fatal("Expected client to not be handshaked, but it was!")
+		}
+		cancel()
+	})
+	t.Run("IgnoreJoinBeforeHello", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		listenerMock.acceptCh = make(chan net.Conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn := newFullConnMock()
+		srv.Conns[conn] = server.NewClient(conn)
+		go srv.Run(ctx)
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn, Content: com.JoinContent{Name: "donald", Bot: ""}}
+		time.Sleep(time.Second)
+		if cli := clientOf(&srv, conn); cli.Name != "" {
+			t.Fatalf("Expected client to have no name, but had %q!", cli.Name)
+		}
+		cancel()
 	})
 	t.Run("UpdateClientNameOnJoin", func(t *testing.T) {
 		t.Parallel()
-		listenerMock := new(listenerMock)
+		listenerMock := newListenerMock()
+		listenerMock.acceptCh = make(chan net.Conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn := newFullConnMock()
+		srv.Conns[conn] = server.NewClient(conn)
+		srv.Conns[conn].Version = com.ProtocolVersion
+		go srv.Run(ctx)
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn, Content: com.JoinContent{Name: "donald", Bot: ""}}
+		time.Sleep(time.Second)
+		cli := clientOf(&srv, conn)
+		if cli.Name != "donald" {
+			t.Fatalf("Expected client to have name \"donald\", but had %q!", cli.Name)
+		}
+		if cli.ResumeToken == "" {
+			t.Fatal("Expected client to have a resume token, but it had none!")
+		}
+		cancel()
+	})
+	t.Run("RejectJoinWithInvalidName", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
 		listenerMock.acceptCh = make(chan net.Conn)
-		shutdown := make(chan os.Signal)
-		srv := server.NewServer(listenerMock, shutdown)
-		go srv.Run()
-		conn := new(fullConnMock)
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn := newFullConnMock()
 		srv.Conns[conn] = server.NewClient(conn)
-		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn, Content: com.JoinContent{Name: "donald"}}
+		srv.Conns[conn].Version = com.ProtocolVersion
+		go srv.Run(ctx)
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn, Content: com.JoinContent{Name: "don\nald", Bot: ""}}
 		time.Sleep(time.Second)
-		if srv.Conns[conn].Name != "donald" {
-			t.Fatalf("Expected client to have name \"donald\", but had %q!", srv.Conns[conn].Name)
+		if cli := clientOf(&srv, conn); cli.Name != "" {
+			t.Fatalf("Expected client to have no name, but had %q!", cli.Name)
 		}
-		shutdown <- os.Kill
+		cancel()
+	})
+	t.Run("RejectDuplicateJoin", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		listenerMock.acceptCh = make(chan net.Conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn := newFullConnMock()
+		srv.Conns[conn] = server.NewClient(conn)
+		srv.Conns[conn].Version = com.ProtocolVersion
+		go srv.Run(ctx)
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn, Content: com.JoinContent{Name: "donald", Bot: ""}}
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn, Content: com.JoinContent{Name: "mickey", Bot: ""}}
+		time.Sleep(time.Second)
+		if cli := clientOf(&srv, conn); cli.Name != "donald" {
+			t.Fatalf("Expected client to have name \"donald\", but had %q!", cli.Name)
+		}
+		cancel()
 	})
 	t.Run("StartSessionOnMatchmakeDuringJoin", func(t *testing.T) {
 		t.Parallel()
-		listenerMock := new(listenerMock)
+		listenerMock := newListenerMock()
 		listenerMock.acceptCh = make(chan net.Conn)
-		shutdown := make(chan os.Signal)
-		srv := server.NewServer(listenerMock, shutdown)
-		go srv.Run()
-
-		conn1 := new(fullConnMock)
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
 		srv.Conns[conn1] = server.NewClient(conn1)
-		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn1, Content: com.JoinContent{Name: "donald"}}
+		srv.Conns[conn1].Version = com.ProtocolVersion
+		conn2 := newFullConnMock()
+		srv.Conns[conn2] = server.NewClient(conn2)
+		srv.Conns[conn2].Version = com.ProtocolVersion
+		go srv.Run(ctx)
+
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn1, Content: com.JoinContent{Name: "donald", Bot: ""}}
 		time.Sleep(time.Second)
 
-		conn2 := new(fullConnMock)
-		srv.Conns[conn2] = server.NewClient(conn2)
-		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn2, Content: com.JoinContent{Name: "mickey"}}
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn2, Content: com.JoinContent{Name: "mickey", Bot: ""}}
 		time.Sleep(time.Second)
 
-		session := srv.Conns[conn1].Session
+		session := clientOf(&srv, conn1).Session
 		if session == nil {
 			t.Fatal("Expected client session to be non-nil, but was nil!")
 		}
-		if session != srv.Conns[conn2].Session {
+		if session != clientOf(&srv, conn2).Session {
 			t.Fatal("Expected clients to contain same session, but did not!")
 		}
-		shutdown <- os.Kill
+		cancel()
 	})
-	t.Run("SkipFailedSessionStart", func(t *testing.T) {
+	t.Run("PairClientsInJoinOrder", func(t *testing.T) {
 		t.Parallel()
-		listenerMock := new(listenerMock)
-		listenerMock.acceptCh = make(chan net.Conn)
-		shutdown := make(chan os.Signal)
-		srv := server.NewServer(listenerMock, shutdown)
-		go srv.Run()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conns := []*fullConnMock{newFullConnMock(), newFullConnMock(), newFullConnMock()}
+		for _, conn := range conns {
+			srv.Conns[conn] = server.NewClient(conn)
+			srv.Conns[conn].Version = com.ProtocolVersion
+		}
+		go srv.Run(ctx)
+
+		for i, conn := range conns {
+			srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn, Content: com.JoinContent{Name: fmt.Sprint(i), Bot: ""}}
+		}
+		time.Sleep(time.Second)
+
+		paired := inspect(&srv, func() bool {
+			session := srv.Conns[conns[0]].Session
+			return session != nil && session.Players[0] == srv.Conns[conns[0]] && session.Players[1] == srv.Conns[conns[1]]
+		})
+		if !paired {
+			t.Fatal("Expected the first two clients to be paired, but they were not!")
+		}
+		if state := clientOf(&srv, conns[2]).State; state != server.StateQueued {
+			t.Fatalf("Expected the third client to be %q, but was %q!", server.StateQueued, state)
+		}
+		cancel()
+	})
+	t.Run("PlayAgainstBotsOnJoinWithBot", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		config := server.DefaultConfig()
+		config.Players = 3
+		srv := server.NewServer(listenerMock, config)
+		conn := newFullConnMock()
+		srv.Conns[conn] = server.NewClient(conn)
+		srv.Conns[conn].Version = com.ProtocolVersion
+		go srv.Run(ctx)
+
+		content := com.JoinContent{Name: "donald", Bot: server.BeatLastStrategy{}.String()}
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn, Content: content}
+		time.Sleep(time.Second)
+		session := clientOf(&srv, conn).Session
+		if session == nil || inspect(&srv, func() int { return len(session.Players) }) != 3 {
+			t.Fatal("Expected client to play against two bots, but it did not!")
+		}
+		unselected := inspect(&srv, func() string {
+			for _, bot := range session.Opponents(srv.Conns[conn]) {
+				if !strings.HasPrefix(bot.Name, "bot") || !session.HasSelected(bot) {
+					return bot.String()
+				}
+			}
+			return ""
+		})
+		if unselected != "" {
+			t.Fatalf("Expected the bot to have selected, but %s did not!", unselected)
+		}
+		selection := com.SelectContent{Round: 1, Selection: game.SelectionRock}
+		srv.SelectCh <- server.Message[com.SelectContent]{Conn: conn, Content: selection}
+		time.Sleep(time.Second)
+		if inspect(&srv, func() bool { return session.Round.Number == 1 && !session.Ended() }) {
+			t.Fatal("Expected the round to be resolved, but it was not!")
+		}
+		cancel()
+	})
+	t.Run("RejectJoinWithUnknownBot", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn := newFullConnMock()
+		srv.Conns[conn] = server.NewClient(conn)
+		srv.Conns[conn].Version = com.ProtocolVersion
+		go srv.Run(ctx)
+
+		content := com.JoinContent{Name: "donald", Bot: "cheater"}
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn, Content: content}
+		time.Sleep(time.Second)
+		if cli := clientOf(&srv, conn); cli.Name != "" || cli.State != server.StateConnected {
+			t.Fatalf("Expected client to not join, but was %q!", cli.State)
+		}
+		cancel()
+	})
+	t.Run("PairWithBotsWhenBotWaitPasses", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		config := server.DefaultConfig()
+		config.BotWait = time.Millisecond
+		config.ResumeGrace = 0
+		srv := server.NewServer(listenerMock, config)
+		conn := newFullConnMock()
+		srv.Conns[conn] = server.NewClient(conn)
+		srv.Conns[conn].Version = com.ProtocolVersion
+		go srv.Run(ctx)
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn, Content: com.JoinContent{Name: "donald", Bot: ""}}
+		time.Sleep(time.Second)
+		if clientOf(&srv, conn).Session == nil || connCount(&srv) != 2 {
+			t.Fatal("Expected client to be paired with a bot, but it was not!")
+		}
+		srv.LeaveCh <- conn
+		time.Sleep(time.Second)
+		if conns := connCount(&srv); conns != 0 {
+			t.Fatalf("Expected the bot to leave with the client, but had %d connections!", conns)
+		}
+		cancel()
+	})
+	t.Run("RemoveQueuedClientOnLeave", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn := newFullConnMock()
+		srv.Conns[conn] = server.NewClient(conn)
+		srv.Conns[conn].Version = com.ProtocolVersion
+		go srv.Run(ctx)
+
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn, Content: com.JoinContent{Name: "donald", Bot: ""}}
+		srv.LeaveCh <- conn
+		time.Sleep(time.Second)
+
+		if queued := inspect(&srv, srv.Queue.Len); queued != 0 {
+			t.Fatalf("Expected queue to be empty, but had %d items!", queued)
+		}
+		cancel()
+	})
+	t.Run("CreateRoomOnCreateRoom", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn := newFullConnMock()
+		srv.Conns[conn] = server.NewClient(conn)
+		srv.Conns[conn].Version = com.ProtocolVersion
+		go srv.Run(ctx)
+
+		content := com.CreateRoomContent{Name: "donald"}
+		srv.CreateRoomCh <- server.Message[com.CreateRoomContent]{Conn: conn, Content: content}
+		time.Sleep(time.Second)
+
+		rooms, queued := inspect(&srv, srv.Rooms.Len), inspect(&srv, srv.Queue.Len)
+		if rooms != 1 || queued != 0 {
+			t.Fatalf("Expected one room and empty queue, but had %d rooms and %d queued!", rooms, queued)
+		}
+		if cli := clientOf(&srv, conn); cli.Name != "donald" || cli.State != server.StateQueued {
+			t.Fatalf("Expected client to be queued as \"donald\", but was %q as %q!", cli.State, cli.Name)
+		}
+		cancel()
+	})
+	t.Run("PairClientsInSameRoom", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conns := []*fullConnMock{newFullConnMock(), newFullConnMock(), newFullConnMock()}
+		for _, conn := range conns {
+			srv.Conns[conn] = server.NewClient(conn)
+			srv.Conns[conn].Version = com.ProtocolVersion
+		}
+		code, err := srv.Rooms.Create(srv.Conns[conns[0]])
+		if err != nil {
+			t.Fatalf("Expected no error, but got: %v", err)
+		}
+		go srv.Run(ctx)
+
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conns[1], Content: com.JoinContent{Name: "mickey", Bot: ""}}
+		content := com.JoinRoomContent{Name: "daisy", Code: code}
+		srv.JoinRoomCh <- server.Message[com.JoinRoomContent]{Conn: conns[2], Content: content}
+		time.Sleep(time.Second)
+
+		paired := inspect(&srv, func() bool {
+			session := srv.Conns[conns[0]].Session
+			return session != nil && session.Players[0] == srv.Conns[conns[0]] && session.Players[1] == srv.Conns[conns[2]]
+		})
+		if !paired {
+			t.Fatal("Expected the room host and the room guest to be paired, but they were not!")
+		}
+		if state := clientOf(&srv, conns[1]).State; state != server.StateQueued {
+			t.Fatalf("Expected the public client to be %q, but was %q!", server.StateQueued, state)
+		}
+		if rooms := inspect(&srv, srv.Rooms.Len); rooms != 0 {
+			t.Fatalf("Expected rooms to be empty, but had %d items!", rooms)
+		}
+		cancel()
+	})
+	t.Run("RejectJoinRoomWithUnknownCode", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn := newFullConnMock()
+		srv.Conns[conn] = server.NewClient(conn)
+		srv.Conns[conn].Version = com.ProtocolVersion
+		go srv.Run(ctx)
+
+		content := com.JoinRoomContent{Name: "daisy", Code: "ABC123"}
+		srv.JoinRoomCh <- server.Message[com.JoinRoomContent]{Conn: conn, Content: content}
+		time.Sleep(time.Second)
+
+		if cli := clientOf(&srv, conn); cli.Name != "" || cli.State != server.StateConnected {
+			t.Fatalf("Expected client to stay %q without a name, but was %q as %q!", server.StateConnected, cli.State,
+				cli.Name)
+		}
+		cancel()
+	})
+	t.Run("RejectRoomMessagesBeforeHello", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		go srv.Run(ctx)
+
+		create := com.CreateRoomContent{Name: "donald"}
+		srv.CreateRoomCh <- server.Message[com.CreateRoomContent]{Conn: conn1, Content: create}
+		join := com.JoinRoomContent{Name: "daisy", Code: "ABC123"}
+		srv.JoinRoomCh <- server.Message[com.JoinRoomContent]{Conn: conn2, Content: join}
+		time.Sleep(time.Second)
+
+		if rooms := inspect(&srv, srv.Rooms.Len); rooms != 0 {
+			t.Fatalf("Expected rooms to be empty, but had %d items!", rooms)
+		}
+		<-conn1.closed
+		<-conn2.closed
+		cancel()
+	})
+	t.Run("RemoveRoomOnLeave", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn := newFullConnMock()
+		srv.Conns[conn] = server.NewClient(conn)
+		srv.Conns[conn].Version = com.ProtocolVersion
+		go srv.Run(ctx)
+
+		content := com.CreateRoomContent{Name: "donald"}
+		srv.CreateRoomCh <- server.Message[com.CreateRoomContent]{Conn: conn, Content: content}
+		srv.LeaveCh <- conn
+		time.Sleep(time.Second)
+
+		if rooms := inspect(&srv, srv.Rooms.Len); rooms != 0 {
+			t.Fatalf("Expected rooms to be empty, but had %d items!", rooms)
+		}
+		cancel()
+	})
+	t.Run("StartTournamentWhenRegistrationIsFull", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		config := server.DefaultConfig()
+		config.TournamentSize = 3
+		srv := server.NewServer(listenerMock, config)
+		conns := []*fullConnMock{newFullConnMock(), newFullConnMock(), newFullConnMock()}
+		for _, conn := range conns {
+			srv.Conns[conn] = server.NewClient(conn)
+			srv.Conns[conn].Version = com.ProtocolVersion
+		}
+		go srv.Run(ctx)
+
+		for i, name := range []string{"donald", "mickey", "goofy"} {
+			content := com.TournamentJoinContent{Name: name}
+			srv.TournamentJoinCh <- server.Message[com.TournamentJoinContent]{Conn: conns[i], Content: content}
+		}
+		time.Sleep(time.Second)
+
+		tournaments, registered := inspect(&srv, func() int { return len(srv.Tournaments) }),
+			inspect(&srv, func() int { return len(srv.Registration.Players) })
+		if tournaments != 1 || registered != 0 {
+			t.Fatalf("Expected a started tournament and an empty registration, but had %d tournaments!", tournaments)
+		}
+		if cli := clientOf(&srv, conns[0]); cli.Session != nil || cli.State != server.StateTournament {
+			t.Fatalf("Expected the first seed to wait with a bye, but was %q!", cli.State)
+		}
+		paired := inspect(&srv, func() bool {
+			session := srv.Conns[conns[1]].Session
+			return session != nil && session.Players[1] == srv.Conns[conns[2]]
+		})
+		if !paired {
+			t.Fatal("Expected the other seeds to be paired, but they were not!")
+		}
+		cancel()
+	})
+	t.Run("QueueKnockedOutPlayerDuringTournament", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		config := server.DefaultConfig()
+		config.TournamentSize = 3
+		srv := server.NewServer(newListenerMock(), config)
+		conns := []*fullConnMock{newFullConnMock(), newFullConnMock(), newFullConnMock()}
+		for _, conn := range conns {
+			srv.Conns[conn] = server.NewClient(conn)
+			srv.Conns[conn].Version = com.ProtocolVersion
+		}
+		go srv.Run(ctx)
 
-		conn1 := new(fullConnMock)
+		for i, name := range []string{"donald", "mickey", "goofy"} {
+			content := com.TournamentJoinContent{Name: name}
+			srv.TournamentJoinCh <- server.Message[com.TournamentJoinContent]{Conn: conns[i], Content: content}
+		}
+		for i, selection := range []game.Selection{game.SelectionRock, game.SelectionScissors} {
+			content := com.SelectContent{Round: 1, Selection: selection}
+			srv.SelectCh <- server.Message[com.SelectContent]{Conn: conns[i+1], Content: content}
+		}
+		time.Sleep(time.Second)
+		srv.QueueCh <- server.Message[com.QueueContent]{Conn: conns[2], Content: com.QueueContent{}}
+		time.Sleep(time.Second)
+
+		if tournaments := inspect(&srv, func() int { return len(srv.Tournaments) }); tournaments != 1 {
+			t.Fatalf("Expected the tournament to go on, but had %d tournaments!", tournaments)
+		}
+		if cli := clientOf(&srv, conns[2]); cli.Tournament != nil || cli.State != server.StateQueued {
+			t.Fatalf("Expected the knocked out player to be queued, but was %q!", cli.State)
+		}
+	})
+	t.Run("FinishTournamentWhenFinalIsDecided", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		config := server.DefaultConfig()
+		config.TournamentSize = 2
+		srv := server.NewServer(listenerMock, config)
+		conns := []*fullConnMock{newFullConnMock(), newFullConnMock()}
+		for _, conn := range conns {
+			srv.Conns[conn] = server.NewClient(conn)
+			srv.Conns[conn].Version = com.ProtocolVersion
+		}
+		go srv.Run(ctx)
+
+		for i, name := range []string{"donald", "mickey"} {
+			content := com.TournamentJoinContent{Name: name}
+			srv.TournamentJoinCh <- server.Message[com.TournamentJoinContent]{Conn: conns[i], Content: content}
+		}
+		for i, selection := range []game.Selection{game.SelectionRock, game.SelectionScissors} {
+			content := com.SelectContent{Round: 1, Selection: selection}
+			srv.SelectCh <- server.Message[com.SelectContent]{Conn: conns[i], Content: content}
+		}
+		time.Sleep(time.Second)
+
+		if tournaments := inspect(&srv, func() int { return len(srv.Tournaments) }); tournaments != 0 {
+			t.Fatalf("Expected the tournament to be finished, but had %d tournaments!", tournaments)
+		}
+		for _, conn := range conns {
+			if cli := clientOf(&srv, conn); cli.Session != nil || cli.Tournament != nil || cli.State != server.StateLobby {
+				t.Fatalf("Expected the players to return to the lobby, but was %q!", cli.State)
+			}
+		}
+		cancel()
+	})
+	t.Run("RejectQueueInTournament", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn := newFullConnMock()
+		srv.Conns[conn] = server.NewClient(conn)
+		srv.Conns[conn].Version = com.ProtocolVersion
+		go srv.Run(ctx)
+
+		content := com.TournamentJoinContent{Name: "donald"}
+		srv.TournamentJoinCh <- server.Message[com.TournamentJoinContent]{Conn: conn, Content: content}
+		srv.QueueCh <- server.Message[com.QueueContent]{Conn: conn, Content: com.QueueContent{}}
+		time.Sleep(time.Second)
+
+		if cli := clientOf(&srv, conn); inspect(&srv, srv.Queue.Len) != 0 || cli.State != server.StateTournament {
+			t.Fatalf("Expected client to stay in the tournament, but was %q!", cli.State)
+		}
+		cancel()
+	})
+	t.Run("WithdrawFromTournamentOnLeave", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
 		srv.Conns[conn1] = server.NewClient(conn1)
-		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn1, Content: com.JoinContent{Name: "donald"}}
+		srv.Conns[conn2] = server.NewClient(conn2)
+		srv.Conns[conn1].Version = com.ProtocolVersion
+		srv.Conns[conn2].Version = com.ProtocolVersion
+		go srv.Run(ctx)
+
+		content := com.TournamentJoinContent{Name: "donald"}
+		srv.TournamentJoinCh <- server.Message[com.TournamentJoinContent]{Conn: conn1, Content: content}
+		srv.TournamentJoinCh <- server.Message[com.TournamentJoinContent]{Conn: conn2, Content: content}
+		srv.LeaveCh <- conn1
+		time.Sleep(time.Second)
+
+		registered := inspect(&srv, func() bool {
+			players := srv.Registration.Players
+			return len(players) == 1 && players[0].Conn == conn2
+		})
+		if !registered {
+			t.Fatal("Expected only the remaining client to be registered, but it was not!")
+		}
+		cancel()
+	})
+	t.Run("WatchSessionOnSpectate", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conns := []*fullConnMock{newFullConnMock(), newFullConnMock(), newFullConnMock()}
+		for _, conn := range conns {
+			srv.Conns[conn] = server.NewClient(conn)
+			srv.Conns[conn].Version = com.ProtocolVersion
+		}
+		go srv.Run(ctx)
+
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conns[0], Content: com.JoinContent{Name: "donald", Bot: ""}}
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conns[1], Content: com.JoinContent{Name: "mickey", Bot: ""}}
+		srv.SpectateListCh <- server.Message[com.SpectateListContent]{Conn: conns[2], Content: com.SpectateListContent{}}
+		srv.SpectateCh <- server.Message[com.SpectateContent]{Conn: conns[2], Content: com.SpectateContent{SessionID: 1}}
+		time.Sleep(time.Second)
+
+		session := inspect(&srv, func() *server.Session { return srv.Sessions[1] })
+		spectator := clientOf(&srv, conns[2])
+		if session == nil || spectator.Spectating != session || spectator.State != server.StateSpectating {
+			t.Fatalf("Expected spectator to watch session 1, but was %q with %p!", spectator.State, spectator.Spectating)
+		}
+		cancel()
+	})
+	t.Run("RejectInvalidSpectate", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conns := []*fullConnMock{newFullConnMock(), newFullConnMock(), newFullConnMock(), newFullConnMock()}
+		for _, conn := range conns {
+			srv.Conns[conn] = server.NewClient(conn)
+			srv.Conns[conn].Version = com.ProtocolVersion
+		}
+		srv.Conns[conns[3]].Version = 0
+		go srv.Run(ctx)
+
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conns[0], Content: com.JoinContent{Name: "donald", Bot: ""}}
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conns[1], Content: com.JoinContent{Name: "mickey", Bot: ""}}
+		srv.SpectateCh <- server.Message[com.SpectateContent]{Conn: conns[0], Content: com.SpectateContent{SessionID: 1}}
+		srv.SpectateCh <- server.Message[com.SpectateContent]{Conn: conns[2], Content: com.SpectateContent{SessionID: 2}}
+		srv.SpectateCh <- server.Message[com.SpectateContent]{Conn: conns[3], Content: com.SpectateContent{SessionID: 1}}
+		time.Sleep(time.Second)
+
+		for _, conn := range conns[:3] {
+			if client := clientOf(&srv, conn); client.Spectating != nil {
+				t.Fatalf("Expected %s not to spectate, but it watched %p!", &client, client.Spectating)
+			}
+		}
+		<-conns[3].closed
+		cancel()
+	})
+	t.Run("ReleaseSpectatorsOnLeaveAndSessionClose", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conns := []*fullConnMock{newFullConnMock(), newFullConnMock(), newFullConnMock(), newFullConnMock()}
+		for _, conn := range conns {
+			srv.Conns[conn] = server.NewClient(conn)
+			srv.Conns[conn].Version = com.ProtocolVersion
+		}
+		go srv.Run(ctx)
+
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conns[0], Content: com.JoinContent{Name: "donald", Bot: ""}}
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conns[1], Content: com.JoinContent{Name: "mickey", Bot: ""}}
+		srv.SpectateCh <- server.Message[com.SpectateContent]{Conn: conns[2], Content: com.SpectateContent{SessionID: 1}}
+		srv.SpectateCh <- server.Message[com.SpectateContent]{Conn: conns[3], Content: com.SpectateContent{SessionID: 1}}
+		srv.LeaveCh <- conns[3]
 		time.Sleep(time.Second)
+		if spectators := inspect(&srv, func() int { return len(srv.Sessions[1].Spectators) }); spectators != 1 {
+			t.Fatalf("Expected one spectator to remain, but had %d!", spectators)
+		}
 
-		conn2 := new(fullConnMock)
+		update(&srv, func() { srv.Sessions[1].Players[0].ResumeToken = "" })
+		srv.LeaveCh <- conns[0]
+		time.Sleep(time.Second)
+		if spectator := clientOf(&srv, conns[2]); spectator.Spectating != nil || spectator.State != server.StateConnected {
+			t.Fatalf("Expected spectator to be released, but was %q with %p!", spectator.State, spectator.Spectating)
+		}
+		if sessions := inspect(&srv, func() int { return len(srv.Sessions) }); sessions != 0 {
+			t.Fatalf("Expected closed session to be pruned, but had %d sessions!", sessions)
+		}
+		cancel()
+	})
+	t.Run("SkipFailedSessionStart", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		listenerMock.acceptCh = make(chan net.Conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn1].Version = com.ProtocolVersion
+		conn2 := newFullConnMock()
 		conn2.writeErr = errMock
 		srv.Conns[conn2] = server.NewClient(conn2)
-		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn2, Content: com.JoinContent{Name: "mickey"}}
+		srv.Conns[conn2].Version = com.ProtocolVersion
+		go srv.Run(ctx)
+
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn1, Content: com.JoinContent{Name: "donald", Bot: ""}}
 		time.Sleep(time.Second)
 
-		if srv.Conns[conn1].Session != nil {
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn2, Content: com.JoinContent{Name: "mickey", Bot: ""}}
+		time.Sleep(time.Second)
+
+		if clientOf(&srv, conn1).Session != nil {
 			t.Fatal("Expected client1 session to nil!")
 		}
-		if srv.Conns[conn2].Session != nil {
+		if clientOf(&srv, conn2).Session != nil {
 			t.Fatal("Expected client2 session to nil!")
 		}
-		shutdown <- os.Kill
+		cancel()
 	})
 	t.Run("PerformSelectOnSelect", func(t *testing.T) {
 		t.Parallel()
-		listenerMock := new(listenerMock)
+		listenerMock := newListenerMock()
 		listenerMock.acceptCh = make(chan net.Conn)
-		shutdown := make(chan os.Signal)
-		srv := server.NewServer(listenerMock, shutdown)
-		go srv.Run()
-
-		conn := new(fullConnMock)
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn := newFullConnMock()
 		srv.Conns[conn] = server.NewClient(conn)
 		srv.Conns[conn].Session = &server.Session{
-			Cli1:  srv.Conns[conn],
-			Cli2:  srv.Conns[conn],
-			Round: server.NewRound(),
+			ID:           0,
+			Players:      []*server.Client{srv.Conns[conn], srv.Conns[conn]},
+			Round:        server.NewRound(1, []bool{true, true}, time.Time{}),
+			Format:       game.BestOf(1),
+			Rules:        game.Classic(),
+			RoundTimeout: 0,
+			Scores:       []int{0, 0},
+			Rematches:    []bool{false, false},
+			Spectators:   nil,
+			Record:       new(server.MatchRecord),
+			History:      nil,
 		}
+		go srv.Run(ctx)
+
 		srv.SelectCh <- server.Message[com.SelectContent]{
 			Conn:    conn,
-			Content: com.SelectContent{Selection: game.SelectionRock},
+			Content: com.SelectContent{Round: 1, Selection: game.SelectionRock},
 		}
 		time.Sleep(time.Second)
 
-		round := srv.Conns[conn].Session.Round
-		if round.Selection1 != game.SelectionRock {
+		round := clientOf(&srv, conn).Session.Round
+		if round.Selections[0] != game.SelectionRock {
 			t.Fatal("Expcted selection1 to be rock!")
 		}
-		if round.Selection2 != game.SelectionNone {
+		if round.Selections[1] != game.SelectionNone {
 			t.Fatal("Expcted selection1 to be none!")
 		}
-		shutdown <- os.Kill
+		cancel()
 	})
-	t.Run("CloseSessionOnFailedSelect", func(t *testing.T) {
+	t.Run("RejectSelectWithoutSession", func(t *testing.T) {
 		t.Parallel()
-		listenerMock := new(listenerMock)
+		listenerMock := newListenerMock()
 		listenerMock.acceptCh = make(chan net.Conn)
-		shutdown := make(chan os.Signal)
-		srv := server.NewServer(listenerMock, shutdown)
-		go srv.Run()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn := newFullConnMock()
+		srv.Conns[conn] = server.NewClient(conn)
+		go srv.Run(ctx)
 
-		conn1 := new(fullConnMock)
-		conn1.writeErr = errMock
-		conn2 := new(fullConnMock)
-		srv.Conns[conn1] = server.NewClient(conn1)
-		srv.Conns[conn2] = server.NewClient(conn2)
-		srv.Conns[conn1].Session = &server.Session{
-			Cli1: srv.Conns[conn1],
-			Cli2: srv.Conns[conn2],
-			Round: &server.Round{
-				Selection1: game.SelectionNone,
-				Selection2: game.SelectionRock,
-			},
-		}
 		srv.SelectCh <- server.Message[com.SelectContent]{
-			Conn:    conn1,
-			Content: com.SelectContent{Selection: game.SelectionRock},
+			Conn:    conn,
+			Content: com.SelectContent{Round: 1, Selection: game.SelectionRock},
 		}
 		time.Sleep(time.Second)
 
-		if srv.Conns[conn1].Session != nil {
-			t.Fatal("Expected conn1 session to be closed and nil!")
+		if conns := connCount(&srv); conns != 1 {
+			t.Fatalf("Expected connections to contain one item, but had %d!", conns)
 		}
-		if srv.Conns[conn2].Session != nil {
-			t.Fatal("Expected conn2 session to be closed and nil!")
-		}
-		shutdown <- os.Kill
+		cancel()
 	})
-	t.Run("RemoveConnectionOnLeave", func(t *testing.T) {
+	t.Run("RejectInvalidSelection", func(t *testing.T) {
 		t.Parallel()
-		listenerMock := new(listenerMock)
+		listenerMock := newListenerMock()
 		listenerMock.acceptCh = make(chan net.Conn)
-		shutdown := make(chan os.Signal)
-		srv := server.NewServer(listenerMock, shutdown)
-		go srv.Run()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		session := server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, server.DefaultConfig())
+		session.Round.Selections[1] = game.SelectionRock
+		go srv.Run(ctx)
 
-		conn := new(fullConnMock)
-		srv.Conns[conn] = server.NewClient(conn)
-		srv.LeaveCh <- conn
+		for _, selection := range []game.Selection{game.SelectionNone, "x"} {
+			srv.SelectCh <- server.Message[com.SelectContent]{
+				Conn:    conn1,
+				Content: com.SelectContent{Round: 1, Selection: selection},
+			}
+		}
 		time.Sleep(time.Second)
 
-		if len(srv.Conns) != 0 {
-			t.Fatalf("Expected connections list to be empty, but was %v!", srv.Conns)
+		if selection := selectionOf(&srv, session, 0); selection != game.SelectionNone {
+			t.Fatalf("Expected selection1 to be none, but was %q!", selection)
 		}
-		shutdown <- os.Kill
+		cancel()
 	})
-	t.Run("CloseSessionOnLeave", func(t *testing.T) {
+	t.Run("RejectSelectWhenEliminated", func(t *testing.T) {
 		t.Parallel()
-		listenerMock := new(listenerMock)
+		listenerMock := newListenerMock()
 		listenerMock.acceptCh = make(chan net.Conn)
-		shutdown := make(chan os.Signal)
-		srv := server.NewServer(listenerMock, shutdown)
-		go srv.Run()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conns := []*fullConnMock{newFullConnMock(), newFullConnMock(), newFullConnMock()}
+		players := make([]*server.Client, 0, len(conns))
+		for _, conn := range conns {
+			srv.Conns[conn] = server.NewClient(conn)
+			players = append(players, srv.Conns[conn])
+		}
+		session := server.NewSession(players, server.DefaultConfig())
+		session.Round.Playing[2] = false
+		go srv.Run(ctx)
 
-		conn1 := new(fullConnMock)
-		conn2 := new(fullConnMock)
-		srv.Conns[conn1] = server.NewClient(conn1)
-		srv.Conns[conn2] = server.NewClient(conn2)
-		server.NewSession(srv.Conns[conn1], srv.Conns[conn2])
-		srv.LeaveCh <- conn1
+		srv.SelectCh <- server.Message[com.SelectContent]{
+			Conn:    conns[2],
+			Content: com.SelectContent{Round: 1, Selection: game.SelectionPaper},
+		}
 		time.Sleep(time.Second)
 
-		if len(srv.Conns) != 1 {
-			t.Fatalf("Expected connections list to contain one item, but had %v!", srv.Conns)
+		if selection := selectionOf(&srv, session, 2); selection != game.SelectionNone {
+			t.Fatalf("Expected selection3 to be none, but was %q!", selection)
 		}
-		if srv.Conns[conn2].Session != nil {
-			t.Fatalf("Expected conn2 to contain nil session, but had %v!", srv.Conns[conn2].Session)
-		}
-		shutdown <- os.Kill
+		cancel()
 	})
+	t.Run("RejectDuplicateSelection", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		listenerMock.acceptCh = make(chan net.Conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		session := server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, server.DefaultConfig())
+		session.Round.Selections[0] = game.SelectionRock
+		go srv.Run(ctx)
+
+		srv.SelectCh <- server.Message[com.SelectContent]{
+			Conn:    conn1,
+			Content: com.SelectContent{Round: 1, Selection: game.SelectionPaper},
+		}
+		time.Sleep(time.Second)
+
+		if selection := selectionOf(&srv, session, 0); selection != game.SelectionRock {
+			t.Fatalf("Expected selection1 to be rock, but was %q!", selection)
+		}
+		cancel()
+	})
+	t.Run("IgnoreSelectionForPastRound", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		listenerMock.acceptCh = make(chan net.Conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		session := server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, server.DefaultConfig())
+		session.Round = server.NewRound(2, []bool{true, true}, time.Time{})
+		go srv.Run(ctx)
+
+		srv.SelectCh <- server.Message[com.SelectContent]{
+			Conn:    conn1,
+			Content: com.SelectContent{Round: 1, Selection: game.SelectionRock},
+		}
+		time.Sleep(time.Second)
+
+		if selection := selectionOf(&srv, session, 0); selection != game.SelectionNone {
+			t.Fatalf("Expected selection1 to be none, but was %q!", selection)
+		}
+		if clientOf(&srv, conn1).Session != session {
+			t.Fatal("Expected conn1 to remain in the session!")
+		}
+		cancel()
+	})
+	t.Run("RejectSelectionForFutureRound", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		listenerMock.acceptCh = make(chan net.Conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		session := server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, server.DefaultConfig())
+		go srv.Run(ctx)
+
+		srv.SelectCh <- server.Message[com.SelectContent]{
+			Conn:    conn1,
+			Content: com.SelectContent{Round: 2, Selection: game.SelectionRock},
+		}
+		time.Sleep(time.Second)
+
+		if selection := selectionOf(&srv, session, 0); selection != game.SelectionNone {
+			t.Fatalf("Expected selection1 to be none, but was %q!", selection)
+		}
+		cancel()
+	})
+	t.Run("ForfeitRoundOnTimeout", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		listenerMock.acceptCh = make(chan net.Conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		config := server.DefaultConfig()
+		config.Format = game.BestOf(3)
+		config.RoundTimeout = time.Millisecond
+		srv := server.NewServer(listenerMock, config)
+
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		session := server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, config)
+		session.Round.Selections[1] = game.SelectionRock
+		session.RoundTimeout = 0
+		srv.Sessions[1] = session
+		go srv.Run(ctx)
+		time.Sleep(time.Second)
+
+		if scores := scoresOf(&srv, session); scores[0] != 0 || scores[1] != 1 {
+			t.Fatalf("Expected score to be 0-1, but was %d-%d!", scores[0], scores[1])
+		}
+		if round := inspect(&srv, func() int { return session.Round.Number }); round != 2 {
+			t.Fatalf("Expected round to be 2, but was %d!", round)
+		}
+		cancel()
+	})
+	t.Run("AbortSessionOnTimeoutWithoutSelections", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		listenerMock.acceptCh = make(chan net.Conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		config := server.DefaultConfig()
+		config.RoundTimeout = time.Millisecond
+		srv := server.NewServer(listenerMock, config)
+
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		srv.Sessions[1] = server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, config)
+		go srv.Run(ctx)
+		time.Sleep(time.Second)
+
+		if clientOf(&srv, conn1).Session != nil {
+			t.Fatal("Expected conn1 session to be closed and nil!")
+		}
+		if clientOf(&srv, conn2).Session != nil {
+			t.Fatal("Expected conn2 session to be closed and nil!")
+		}
+		cancel()
+	})
+	t.Run("ForfeitRoundOnTimeoutWhenPlayersAreDetached", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		listenerMock.acceptCh = make(chan net.Conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		config := server.DefaultConfig()
+		config.Format = game.BestOf(3)
+		config.RoundTimeout = time.Millisecond
+		config.ResumeGrace = time.Hour
+		srv := server.NewServer(listenerMock, config)
+
+		players := []*server.Client{server.NewClient(newFullConnMock()), server.NewClient(newFullConnMock())}
+		session := server.NewSession(players, config)
+		session.Round.Selections[1] = game.SelectionRock
+		session.RoundTimeout = 0
+		srv.Sessions[1] = session
+		go srv.Run(ctx)
+		time.Sleep(time.Second)
+
+		if scores := scoresOf(&srv, session); scores[0] != 0 || scores[1] != 1 {
+			t.Fatalf("Expected score to be 0-1, but was %d-%d!", scores[0], scores[1])
+		}
+		cancel()
+	})
+	t.Run("AbortSessionOnFailedTimeoutForfeit", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		listenerMock.acceptCh = make(chan net.Conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		config := server.DefaultConfig()
+		config.RoundTimeout = time.Millisecond
+		srv := server.NewServer(listenerMock, config)
+
+		conn1 := newFullConnMock()
+		conn1.writeErr = errMock
+		conn2 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		session := server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, config)
+		session.Round.Selections[0] = game.SelectionRock
+		srv.Sessions[1] = session
+		go srv.Run(ctx)
+		time.Sleep(time.Second)
+
+		if clientOf(&srv, conn1).Session != nil {
+			t.Fatal("Expected conn1 session to be closed and nil!")
+		}
+		if clientOf(&srv, conn2).Session != nil {
+			t.Fatal("Expected conn2 session to be closed and nil!")
+		}
+		cancel()
+	})
+	t.Run("StartRematchOnOfferAndAccept", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		session := server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, server.DefaultConfig())
+		session.Scores[0] = 1
+		go srv.Run(ctx)
+
+		srv.OfferCh <- server.Message[com.RematchOfferContent]{Conn: conn1, Content: com.RematchOfferContent{}}
+		srv.AcceptCh <- server.Message[com.RematchAcceptContent]{Conn: conn2, Content: com.RematchAcceptContent{}}
+		time.Sleep(time.Second)
+
+		if inspect(&srv, session.Ended) {
+			scores := scoresOf(&srv, session)
+			t.Fatalf("Expected rematch to be started, but score was %d-%d!", scores[0], scores[1])
+		}
+		if clientOf(&srv, conn1).Session != session || clientOf(&srv, conn2).Session != session {
+			t.Fatal("Expected clients to stay in the same session, but they did not!")
+		}
+		cancel()
+	})
+	t.Run("RejectRematchBeforeMatchIsDecided", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		conn3 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		srv.Conns[conn3] = server.NewClient(conn3)
+		session := server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, server.DefaultConfig())
+		go srv.Run(ctx)
+
+		srv.OfferCh <- server.Message[com.RematchOfferContent]{Conn: conn1, Content: com.RematchOfferContent{}}
+		srv.OfferCh <- server.Message[com.RematchOfferContent]{Conn: conn3, Content: com.RematchOfferContent{}}
+		time.Sleep(time.Second)
+
+		if inspect(&srv, func() bool { return session.Offered(srv.Conns[conn1]) }) {
+			t.Fatal("Expected rematch offer to be rejected, but it was not!")
+		}
+		if conns := connCount(&srv); conns != 3 {
+			t.Fatalf("Expected connections to contain three items, but had %d!", conns)
+		}
+		cancel()
+	})
+	t.Run("RejectRematchAcceptWithoutOffer", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		session := server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, server.DefaultConfig())
+		session.Scores[1] = 1
+		go srv.Run(ctx)
+
+		srv.AcceptCh <- server.Message[com.RematchAcceptContent]{Conn: conn1, Content: com.RematchAcceptContent{}}
+		srv.OfferCh <- server.Message[com.RematchOfferContent]{Conn: conn2, Content: com.RematchOfferContent{}}
+		srv.OfferCh <- server.Message[com.RematchOfferContent]{Conn: conn2, Content: com.RematchOfferContent{}}
+		time.Sleep(time.Second)
+
+		if !inspect(&srv, session.Ended) {
+			t.Fatal("Expected rematch to not be started, but it was!")
+		}
+		if !inspect(&srv, func() bool { return session.Offered(srv.Conns[conn2]) }) {
+			t.Fatal("Expected conn2 to have offered a rematch, but it had not!")
+		}
+		cancel()
+	})
+	t.Run("CloseSessionOnFailedRematchOffer", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		conn2.writeErr = errMock
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		session := server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, server.DefaultConfig())
+		session.Scores[0] = 1
+		go srv.Run(ctx)
+
+		srv.OfferCh <- server.Message[com.RematchOfferContent]{Conn: conn1, Content: com.RematchOfferContent{}}
+		time.Sleep(time.Second)
+
+		if clientOf(&srv, conn1).Session != nil {
+			t.Fatal("Expected conn1 session to be closed and nil!")
+		}
+		cancel()
+	})
+	t.Run("IgnoreSelectionAfterSessionIsClosed", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, server.DefaultConfig()).Close()
+		go srv.Run(ctx)
+
+		srv.SelectCh <- server.Message[com.SelectContent]{
+			Conn:    conn1,
+			Content: com.SelectContent{Round: 1, Selection: game.SelectionRock},
+		}
+		srv.OfferCh <- server.Message[com.RematchOfferContent]{Conn: conn2, Content: com.RematchOfferContent{}}
+		time.Sleep(time.Second)
+
+		if clientOf(&srv, conn1).State != server.StateLobby || clientOf(&srv, conn2).State != server.StateLobby {
+			t.Fatal("Expected clients to stay in the lobby, but they did not!")
+		}
+		cancel()
+	})
+	t.Run("PairClientsAgainOnQueue", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		conn3 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		srv.Conns[conn3] = server.NewClient(conn3)
+		session := server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, server.DefaultConfig())
+		session.Scores[0] = 1
+		srv.Conns[conn3].State = server.StateLobby
+		go srv.Run(ctx)
+
+		srv.QueueCh <- server.Message[com.QueueContent]{Conn: conn1, Content: com.QueueContent{}}
+		srv.QueueCh <- server.Message[com.QueueContent]{Conn: conn3, Content: com.QueueContent{}}
+		time.Sleep(time.Second)
+
+		if session := clientOf(&srv, conn1).Session; session == nil || session != clientOf(&srv, conn3).Session {
+			t.Fatal("Expected conn1 and conn3 to be paired in a new session, but they were not!")
+		}
+		if cli := clientOf(&srv, conn2); cli.Session != nil || cli.State != server.StateLobby {
+			t.Fatal("Expected conn2 to be returned to the lobby, but it was not!")
+		}
+		cancel()
+	})
+	t.Run("RejectInvalidQueue", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		conn3 := newFullConnMock()
+		conn4 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		srv.Conns[conn3] = server.NewClient(conn3)
+		srv.Conns[conn4] = server.NewClient(conn4)
+		session := server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, server.DefaultConfig())
+		srv.Conns[conn4].State = server.StateQueued
+		go srv.Run(ctx)
+
+		for _, conn := range []*fullConnMock{conn1, conn3, conn4} {
+			srv.QueueCh <- server.Message[com.QueueContent]{Conn: conn, Content: com.QueueContent{}}
+		}
+		time.Sleep(time.Second)
+
+		if clientOf(&srv, conn1).Session != session {
+			t.Fatal("Expected conn1 to stay in the undecided session, but it did not!")
+		}
+		if state := clientOf(&srv, conn3).State; state != server.StateConnected {
+			t.Fatalf("Expected conn3 to stay connected, but was %s!", state)
+		}
+		if queued := inspect(&srv, srv.Queue.Len); queued != 0 {
+			t.Fatalf("Expected queue to be empty, but had %d clients!", queued)
+		}
+		cancel()
+	})
+	t.Run("CloseSessionOnFailedSelect", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		listenerMock.acceptCh = make(chan net.Conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		conn1.writeErr = errMock
+		conn2 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		srv.Conns[conn1].Session = &server.Session{
+			ID:      0,
+			Players: []*server.Client{srv.Conns[conn1], srv.Conns[conn2]},
+			Round: &server.Round{
+				Number:      1,
+				Playing:     []bool{true, true},
+				Selections:  []game.Selection{game.SelectionNone, game.SelectionRock},
+				Commitments: make([]string, 2),
+				Mismatches:  make([]bool, 2),
+				Deadline:    time.Time{},
+			},
+			Format:       game.BestOf(1),
+			Rules:        game.Classic(),
+			RoundTimeout: 0,
+			Scores:       []int{0, 0},
+			Rematches:    []bool{false, false},
+			Spectators:   nil,
+			Record:       new(server.MatchRecord),
+			History:      nil,
+		}
+		go srv.Run(ctx)
+
+		srv.SelectCh <- server.Message[com.SelectContent]{
+			Conn:    conn1,
+			Content: com.SelectContent{Round: 1, Selection: game.SelectionRock},
+		}
+		time.Sleep(time.Second)
+
+		if clientOf(&srv, conn1).Session != nil {
+			t.Fatal("Expected conn1 session to be closed and nil!")
+		}
+		if clientOf(&srv, conn2).Session != nil {
+			t.Fatal("Expected conn2 session to be closed and nil!")
+		}
+		cancel()
+	})
+	t.Run("CloseConnectionsOnShutdown", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		done := make(chan struct{})
+		go func() {
+			if err := srv.Run(ctx); !errors.Is(err, context.Canceled) {
+				t.Errorf("Expected %q error in the chain %q, but did not exists!", context.Canceled, err)
+			}
+			close(done)
+		}()
+
+		conn := newFullConnMock()
+		listenerMock.acceptCh <- conn
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Expected server to shut down, but it did not!")
+		}
+
+		if len(srv.Conns) != 0 {
+			t.Fatalf("Expected connections to be empty, but was %v!", srv.Conns)
+		}
+		select {
+		case <-conn.closed:
+		default:
+			t.Fatal("Expected connection to be closed, but it was not!")
+		}
+		select {
+		case <-listenerMock.closed:
+		default:
+			t.Fatal("Expected listener to be closed, but it was not!")
+		}
+	})
+	t.Run("FinishOngoingRoundOnShutdown", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		config := server.DefaultConfig()
+		config.ShutdownGrace = time.Minute
+		srv := server.NewServer(listenerMock, config)
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		session := server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, config)
+		session.Round.Selections[1] = game.SelectionRock
+		done := make(chan struct{})
+		go func() {
+			if err := srv.Run(ctx); !errors.Is(err, context.Canceled) {
+				t.Errorf("Expected %q error in the chain %q, but did not exists!", context.Canceled, err)
+			}
+			close(done)
+		}()
+
+		cancel()
+		time.Sleep(100 * time.Millisecond)
+		srv.SelectCh <- server.Message[com.SelectContent]{
+			Conn:    conn1,
+			Content: com.SelectContent{Round: 1, Selection: game.SelectionPaper},
+		}
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Expected server to shut down after the round, but it did not!")
+		}
+
+		if session.Scores[0] != 1 || session.Scores[1] != 0 {
+			t.Fatalf("Expected score to be 1-0, but was %d-%d!", session.Scores[0], session.Scores[1])
+		}
+	})
+	t.Run("RejectJoinOnShutdown", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		config := server.DefaultConfig()
+		config.ShutdownGrace = time.Minute
+		srv := server.NewServer(listenerMock, config)
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		conn3 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		srv.Conns[conn3] = server.NewClient(conn3)
+		srv.Conns[conn3].Version = com.ProtocolVersion
+		server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, config)
+		cancel()
+		go srv.Run(ctx)
+
+		time.Sleep(100 * time.Millisecond)
+		srv.JoinCh <- server.Message[com.JoinContent]{Conn: conn3, Content: com.JoinContent{Name: "donald", Bot: ""}}
+		srv.CreateRoomCh <- server.Message[com.CreateRoomContent]{Conn: conn3, Content: com.CreateRoomContent{Name: "donald"}}
+		time.Sleep(time.Second)
+
+		if name := clientOf(&srv, conn3).Name; name != "" {
+			t.Fatalf("Expected join to be rejected, but client was named %q!", name)
+		}
+	})
+	t.Run("CloseConnectionsAfterShutdownGrace", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		config := server.DefaultConfig()
+		config.ShutdownGrace = 100 * time.Millisecond
+		srv := server.NewServer(listenerMock, config)
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, config)
+		done := make(chan struct{})
+		go func() {
+			if err := srv.Run(ctx); !errors.Is(err, context.Canceled) {
+				t.Errorf("Expected %q error in the chain %q, but did not exists!", context.Canceled, err)
+			}
+			close(done)
+		}()
+
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Expected server to shut down after the grace period, but it did not!")
+		}
+		select {
+		case <-conn1.closed:
+		default:
+			t.Fatal("Expected connection to be closed, but it was not!")
+		}
+	})
+	t.Run("HoldSeatOnLeaveAndResumeWithToken", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		conn3 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		srv.Conns[conn3] = server.NewClient(conn3)
+		srv.Conns[conn3].Version = com.ProtocolVersion
+		cli1 := srv.Conns[conn1]
+		cli1.ResumeToken = "token"
+		session := server.NewSession([]*server.Client{cli1, srv.Conns[conn2]}, server.DefaultConfig())
+		go srv.Run(ctx)
+
+		srv.LeaveCh <- conn1
+		time.Sleep(time.Second)
+
+		if !inspect(&srv, cli1.Detached) || clientOf(&srv, conn2).Session != session {
+			t.Fatal("Expected seat to be held for the leaving client, but it was not!")
+		}
+		srv.ResumeCh <- server.Message[com.ResumeContent]{Conn: conn3, Content: com.ResumeContent{Token: "token"}}
+		time.Sleep(time.Second)
+
+		if inspect(&srv, func() bool { return srv.Conns[conn3] != cli1 || cli1.Conn != conn3 }) {
+			t.Fatal("Expected resuming connection to take back the seat, but it did not!")
+		}
+		if inspect(&srv, func() bool { return cli1.Detached() || cli1.Session != session }) {
+			t.Fatal("Expected resumed client to continue in the session, but it did not!")
+		}
+		cancel()
+	})
+	t.Run("ReleaseSeatsOnShutdown", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		cli2 := srv.Conns[conn2]
+		srv.Conns[conn1].ResumeToken = "token"
+		server.NewSession([]*server.Client{srv.Conns[conn1], cli2}, server.DefaultConfig())
+		done := make(chan struct{})
+		go func() {
+			srv.Run(ctx)
+			close(done)
+		}()
+
+		srv.LeaveCh <- conn1
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+		<-done
+
+		if len(srv.Seats) != 0 || cli2.Session != nil {
+			t.Fatal("Expected seats to be released on shutdown, but they were not!")
+		}
+		if grace := srv.Config.ResumeGrace; grace != server.DefaultConfig().ResumeGrace {
+			t.Fatalf("Expected resume grace to be left unchanged on shutdown, but was %s!", grace)
+		}
+	})
+	t.Run("RejectResumeOfJoinedClient", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		conn3 := newFullConnMock()
+		conn3.writeErr = errMock
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		srv.Conns[conn3] = server.NewClient(conn3)
+		srv.Conns[conn3].Version = com.ProtocolVersion
+		cli1 := srv.Conns[conn1]
+		cli1.ResumeToken = "token"
+		srv.Conns[conn2].Version = com.ProtocolVersion
+		srv.Conns[conn2].State = server.StateLobby
+		session := server.NewSession([]*server.Client{cli1, srv.Conns[conn2]}, server.DefaultConfig())
+		go srv.Run(ctx)
+
+		srv.LeaveCh <- conn1
+		srv.ResumeCh <- server.Message[com.ResumeContent]{Conn: conn2, Content: com.ResumeContent{Token: "token"}}
+		srv.ResumeCh <- server.Message[com.ResumeContent]{Conn: conn3, Content: com.ResumeContent{Token: "token"}}
+		time.Sleep(time.Second)
+
+		if inspect(&srv, func() bool { return cli1.Session != nil || session.Players[1].Session != nil }) {
+			t.Fatal("Expected session to be aborted after failed resume, but it was not!")
+		}
+		cancel()
+	})
+	t.Run("RejectResumeWithUnknownToken", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		srv.Conns[conn1].Version = com.ProtocolVersion
+		go srv.Run(ctx)
+
+		srv.ResumeCh <- server.Message[com.ResumeContent]{Conn: conn1, Content: com.ResumeContent{Token: "unknown"}}
+		srv.ResumeCh <- server.Message[com.ResumeContent]{Conn: conn2, Content: com.ResumeContent{Token: "unknown"}}
+		time.Sleep(time.Second)
+
+		if state := clientOf(&srv, conn1).State; state != server.StateConnected {
+			t.Fatalf("Expected client to stay connected, but was %s!", state)
+		}
+		select {
+		case <-conn2.closed:
+		default:
+			t.Fatal("Expected connection without handshake to be closed, but it was not!")
+		}
+		cancel()
+	})
+	t.Run("CloseSessionWhenSeatExpires", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		ctx, cancel := context.WithCancel(context.Background())
+		config := server.DefaultConfig()
+		config.ResumeGrace = 200 * time.Millisecond
+		srv := server.NewServer(listenerMock, config)
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		srv.Conns[conn1].ResumeToken = "token"
+		server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, config)
+		go srv.Run(ctx)
+
+		srv.LeaveCh <- conn1
+		time.Sleep(time.Second)
+
+		if cli := clientOf(&srv, conn2); cli.Session != nil || cli.State != server.StateLobby {
+			t.Fatal("Expected opponent to be returned to the lobby, but it was not!")
+		}
+		if seats := inspect(&srv, func() int { return len(srv.Seats) }); seats != 0 {
+			t.Fatalf("Expected seats to be released, but had %d!", seats)
+		}
+		cancel()
+	})
+	t.Run("RemoveConnectionOnLeave", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		listenerMock.acceptCh = make(chan net.Conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn := newFullConnMock()
+		srv.Conns[conn] = server.NewClient(conn)
+		go srv.Run(ctx)
+
+		srv.LeaveCh <- conn
+		time.Sleep(time.Second)
+
+		if conns := connCount(&srv); conns != 0 {
+			t.Fatalf("Expected connections list to be empty, but had %d!", conns)
+		}
+		cancel()
+	})
+	t.Run("CloseSessionOnLeave", func(t *testing.T) {
+		t.Parallel()
+		listenerMock := newListenerMock()
+		listenerMock.acceptCh = make(chan net.Conn)
+		ctx, cancel := context.WithCancel(context.Background())
+		srv := server.NewServer(listenerMock, server.DefaultConfig())
+		conn1 := newFullConnMock()
+		conn2 := newFullConnMock()
+		srv.Conns[conn1] = server.NewClient(conn1)
+		srv.Conns[conn2] = server.NewClient(conn2)
+		server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, server.DefaultConfig())
+		go srv.Run(ctx)
+
+		srv.LeaveCh <- conn1
+		time.Sleep(time.Second)
+
+		if conns := connCount(&srv); conns != 1 {
+			t.Fatalf("Expected connections list to contain one item, but had %d!", conns)
+		}
+		if session := clientOf(&srv, conn2).Session; session != nil {
+			t.Fatalf("Expected conn2 to contain nil session, but had %p!", session)
+		}
+		cancel()
+	})
+}
+
+// startCommitReveal runs a server with a session between two clients which have negotiated the commit-reveal
+// protocol. The server is stopped when the test ends.
+func startCommitReveal(t *testing.T) (*server.Server, *fullConnMock, *fullConnMock, *server.Session) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	srv := server.NewServer(newListenerMock(), server.DefaultConfig())
+	conn1 := newFullConnMock()
+	conn2 := newFullConnMock()
+	srv.Conns[conn1] = server.NewClient(conn1)
+	srv.Conns[conn2] = server.NewClient(conn2)
+	for _, conn := range []*fullConnMock{conn1, conn2} {
+		srv.Conns[conn].Capabilities = []com.Capability{com.CapabilityCommitReveal}
+	}
+	session := server.NewSession([]*server.Client{srv.Conns[conn1], srv.Conns[conn2]}, server.DefaultConfig())
+	go srv.Run(ctx)
+	return &srv, conn1, conn2, session
+}
+
+func commitMessage(conn *fullConnMock, selection game.Selection, nonce string) server.Message[com.CommitContent] {
+	return server.Message[com.CommitContent]{
+		Conn:    conn,
+		Content: com.CommitContent{Round: 1, Commitment: com.Commit(selection, nonce)},
+	}
+}
+
+func revealMessage(conn *fullConnMock, selection game.Selection, nonce string) server.Message[com.RevealContent] {
+	return server.Message[com.RevealContent]{
+		Conn:    conn,
+		Content: com.RevealContent{Round: 1, Selection: selection, Nonce: nonce},
+	}
+}
+
+func TestServerRunCommitReveal(t *testing.T) {
+	t.Parallel()
+	t.Run("ResolveRoundWhenCommitmentsAreRevealed", func(t *testing.T) {
+		t.Parallel()
+		srv, conn1, conn2, session := startCommitReveal(t)
+		srv.CommitCh <- commitMessage(conn1, game.SelectionRock, "nonce1")
+		srv.CommitCh <- commitMessage(conn2, game.SelectionScissors, "nonce2")
+		srv.RevealCh <- revealMessage(conn1, game.SelectionRock, "nonce1")
+		srv.RevealCh <- revealMessage(conn2, game.SelectionScissors, "nonce2")
+		time.Sleep(time.Second)
+
+		if scores := scoresOf(srv, session); scores[0] != 1 || scores[1] != 0 {
+			t.Fatalf("Expected scores to be 1-0, but were %v!", scores)
+		}
+	})
+	t.Run("ForfeitRoundWhenRevealDoesNotMatch", func(t *testing.T) {
+		t.Parallel()
+		srv, conn1, conn2, session := startCommitReveal(t)
+		srv.CommitCh <- commitMessage(conn1, game.SelectionRock, "nonce1")
+		srv.CommitCh <- commitMessage(conn2, game.SelectionScissors, "nonce2")
+		srv.RevealCh <- revealMessage(conn1, game.SelectionPaper, "nonce1")
+		srv.RevealCh <- revealMessage(conn2, game.SelectionScissors, "nonce2")
+		time.Sleep(time.Second)
+
+		if scores := scoresOf(srv, session); scores[0] != 0 || scores[1] != 1 {
+			t.Fatalf("Expected scores to be 0-1, but were %v!", scores)
+		}
+	})
+	t.Run("CloseSessionWhenNoRevealMatches", func(t *testing.T) {
+		t.Parallel()
+		srv, conn1, conn2, session := startCommitReveal(t)
+		srv.CommitCh <- commitMessage(conn1, game.SelectionRock, "nonce1")
+		srv.CommitCh <- commitMessage(conn2, game.SelectionScissors, "nonce2")
+		srv.RevealCh <- revealMessage(conn1, game.SelectionRock, "nonce2")
+		srv.RevealCh <- revealMessage(conn2, game.SelectionScissors, "nonce1")
+		time.Sleep(time.Second)
+
+		if !inspect(srv, session.Closed) {
+			t.Fatal("Expected session to be closed, but it was not!")
+		}
+	})
+	t.Run("RejectInvalidCommitAndReveal", func(t *testing.T) {
+		t.Parallel()
+		srv, conn1, conn2, session := startCommitReveal(t)
+		update(srv, func() { srv.Conns[conn2].Capabilities = nil })
+		srv.RevealCh <- revealMessage(conn1, game.SelectionRock, "nonce1")
+		srv.CommitCh <- commitMessage(conn2, game.SelectionRock, "nonce2")
+		srv.CommitCh <- server.Message[com.CommitContent]{
+			Conn:    conn1,
+			Content: com.CommitContent{Round: 1, Commitment: "non-hash"},
+		}
+		srv.CommitCh <- commitMessage(conn1, game.SelectionRock, "nonce1")
+		srv.RevealCh <- revealMessage(conn1, game.SelectionRock, "nonce1")
+		time.Sleep(time.Second)
+
+		commitments := inspect(srv, func() []string { return append([]string(nil), session.Round.Commitments...) })
+		if commitments[1] != "" {
+			t.Fatal("Expected commitment of a client without commit-reveal to be rejected, but it was not!")
+		}
+		if commitments[0] != com.Commit(game.SelectionRock, "nonce1") {
+			t.Fatalf("Expected only the valid commitment to be applied, but was %q!", commitments[0])
+		}
+		mismatch := inspect(srv, func() bool { return session.Round.Mismatches[0] })
+		if selectionOf(srv, session, 0) != game.SelectionNone || mismatch {
+			t.Fatal("Expected reveals before every commitment to be rejected, but they were not!")
+		}
+	})
+	t.Run("RejectRevealOutsideOngoingRound", func(t *testing.T) {
+		t.Parallel()
+		srv, conn1, conn2, session := startCommitReveal(t)
+		srv.CommitCh <- commitMessage(conn1, game.SelectionRock, "nonce1")
+		srv.CommitCh <- commitMessage(conn2, game.SelectionScissors, "nonce2")
+		for _, round := range []int{0, 2} {
+			srv.RevealCh <- server.Message[com.RevealContent]{
+				Conn:    conn1,
+				Content: com.RevealContent{Round: round, Selection: game.SelectionRock, Nonce: "nonce1"},
+			}
+		}
+		srv.RevealCh <- revealMessage(conn1, game.SelectionRock, "nonce1")
+		srv.RevealCh <- revealMessage(conn1, game.SelectionRock, "nonce1")
+		srv.RevealCh <- revealMessage(conn2, game.SelectionScissors, "nonce2")
+		srv.RevealCh <- revealMessage(conn2, game.SelectionScissors, "nonce2")
+		update(srv, session.Close)
+		srv.RevealCh <- revealMessage(conn1, game.SelectionRock, "nonce1")
+		update(srv, func() { srv.Conns[conn1].State = server.StateConnected })
+		srv.RevealCh <- revealMessage(conn1, game.SelectionRock, "nonce1")
+		time.Sleep(time.Second)
+
+		if scores := scoresOf(srv, session); scores[0] != 1 || scores[1] != 0 {
+			t.Fatalf("Expected only the reveals of the ongoing round to count, but scores were %v!", scores)
+		}
+	})
+}
+
+// startAccounts runs a server with the given configuration and two handshaked clients which have not yet
+// joined. The server is stopped when the test ends.
+func startAccounts(t *testing.T, config server.Config) (*server.Server, *fullConnMock, *fullConnMock) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	srv := server.NewServer(newListenerMock(), config)
+	conn1 := newFullConnMock()
+	conn2 := newFullConnMock()
+	for _, conn := range []*fullConnMock{conn1, conn2} {
+		srv.Conns[conn] = server.NewClient(conn)
+		srv.Conns[conn].Version = com.ProtocolVersion
+	}
+	go srv.Run(ctx)
+	return &srv, conn1, conn2
+}
+
+func registerMessage(conn *fullConnMock, name, password string) server.Message[com.RegisterContent] {
+	return server.Message[com.RegisterContent]{Conn: conn, Content: com.RegisterContent{Name: name, Password: password}}
+}
+
+func loginMessage(conn *fullConnMock, name, password, token string) server.Message[com.LoginContent] {
+	return server.Message[com.LoginContent]{
+		Conn:    conn,
+		Content: com.LoginContent{Name: name, Password: password, Token: token},
+	}
+}
+
+func joinMessage(conn *fullConnMock, name string) server.Message[com.JoinContent] {
+	return server.Message[com.JoinContent]{Conn: conn, Content: com.JoinContent{Name: name, Bot: ""}}
+}
+
+//nolint:funlen
+func TestServerRunAccounts(t *testing.T) {
+	t.Parallel()
+	t.Run("JoinWithAccountNameAfterRegister", func(t *testing.T) {
+		t.Parallel()
+		srv, conn, _ := startAccounts(t, server.DefaultConfig())
+		srv.RegisterCh <- registerMessage(conn, "donald", "password")
+		time.Sleep(time.Second)
+		srv.JoinCh <- joinMessage(conn, "mickey")
+		time.Sleep(time.Second)
+
+		if !srv.Accounts.Registered("donald") {
+			t.Fatal("Expected account to be registered, but it was not!")
+		}
+		if name := clientOf(srv, conn).Name; name != "donald" {
+			t.Fatalf("Expected client to join with the account name, but joined as %q!", name)
+		}
+	})
+	t.Run("LoginWithPasswordAndToken", func(t *testing.T) {
+		t.Parallel()
+		srv, conn1, conn2 := startAccounts(t, server.DefaultConfig())
+		if _, err := srv.Accounts.Register("donald", "password"); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		token, err := srv.Accounts.Register("mickey", "password")
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		srv.LoginCh <- loginMessage(conn1, "donald", "password", "")
+		srv.LoginCh <- loginMessage(conn2, "", "", token)
+		time.Sleep(time.Second)
+
+		for i, conn := range []*fullConnMock{conn1, conn2} {
+			if name, account := []string{"donald", "mickey"}[i], clientOf(srv, conn).Account; account != name {
+				t.Fatalf("Expected client to be logged in as %q, but was %q!", name, account)
+			}
+		}
+	})
+	t.Run("RejectLoginWhenAccountIsLoggedIn", func(t *testing.T) {
+		t.Parallel()
+		srv, conn1, conn2 := startAccounts(t, server.DefaultConfig())
+		token, err := srv.Accounts.Register("donald", "password")
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		srv.LoginCh <- loginMessage(conn1, "donald", "password", "")
+		time.Sleep(time.Second)
+		srv.LoginCh <- loginMessage(conn2, "", "", token)
+		time.Sleep(time.Second)
+
+		if account := clientOf(srv, conn2).Account; clientOf(srv, conn1).Account != "donald" || account != "" {
+			t.Fatalf("Expected only the first client to log in, but second was %q!", account)
+		}
+	})
+	t.Run("ThrottleLoginAfterFailedAttempt", func(t *testing.T) {
+		t.Parallel()
+		srv, conn1, conn2 := startAccounts(t, server.DefaultConfig())
+		if _, err := srv.Accounts.Register("donald", "password"); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		srv.LoginCh <- loginMessage(conn1, "donald", "wrong password", "")
+		time.Sleep(500 * time.Millisecond)
+		srv.LoginCh <- loginMessage(conn2, "donald", "password", "")
+		time.Sleep(100 * time.Millisecond)
+
+		if client := clientOf(srv, conn2); client.Account != "" || client.Authenticating {
+			t.Fatalf("Expected login to be throttled, but was logged in as %q!", client.Account)
+		}
+		time.Sleep(time.Second)
+		srv.LoginCh <- loginMessage(conn2, "donald", "password", "")
+		time.Sleep(time.Second)
+
+		if account := clientOf(srv, conn2).Account; account != "donald" {
+			t.Fatalf("Expected login to be allowed after the delay, but was %q!", account)
+		}
+	})
+	t.Run("RejectInvalidLogin", func(t *testing.T) {
+		t.Parallel()
+		srv, conn1, conn2 := startAccounts(t, server.DefaultConfig())
+		if _, err := srv.Accounts.Register("donald", "password"); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		srv.LoginCh <- loginMessage(conn1, "donald", "wrong password", "")
+		srv.LoginCh <- loginMessage(conn2, "", "", "unknown token")
+		time.Sleep(time.Second)
+
+		for _, conn := range []*fullConnMock{conn1, conn2} {
+			if client := clientOf(srv, conn); client.Account != "" || client.Authenticating {
+				t.Fatalf("Expected client to not be logged in, but was logged in as %q!", client.Account)
+			}
+		}
+	})
+	t.Run("RejectInvalidRegister", func(t *testing.T) {
+		t.Parallel()
+		srv, conn1, conn2 := startAccounts(t, server.DefaultConfig())
+		if _, err := srv.Accounts.Register("donald", "password"); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		srv.RegisterCh <- registerMessage(conn1, "don\nald", "password")
+		srv.RegisterCh <- registerMessage(conn1, "mickey", "short")
+		srv.RegisterCh <- registerMessage(conn1, "donald", "password")
+		srv.JoinCh <- joinMessage(conn2, "goofy")
+		srv.RegisterCh <- registerMessage(conn2, "goofy", "password")
+		time.Sleep(time.Second)
+
+		for _, name := range []string{"don\nald", "mickey", "goofy"} {
+			if srv.Accounts.Registered(name) {
+				t.Fatalf("Expected account %q to not be registered, but it was!", name)
+			}
+		}
+		if account := clientOf(srv, conn1).Account; account != "" {
+			t.Fatalf("Expected client to not be logged in, but was logged in as %q!", account)
+		}
+	})
+	t.Run("RejectConcurrentRegisterOfSameName", func(t *testing.T) {
+		t.Parallel()
+		srv, conn1, conn2 := startAccounts(t, server.DefaultConfig())
+		srv.RegisterCh <- registerMessage(conn1, "donald", "password")
+		srv.RegisterCh <- registerMessage(conn2, "donald", "password")
+		time.Sleep(time.Second)
+
+		if accounts := clientOf(srv, conn1).Account + clientOf(srv, conn2).Account; accounts != "donald" {
+			t.Fatalf("Expected only one client to register the account, but accounts were %q!", accounts)
+		}
+	})
+	t.Run("RejectRegisterWhenAccountCannotBeStored", func(t *testing.T) {
+		t.Parallel()
+		accounts, err := server.LoadAccounts(filepath.Join(t.TempDir(), "missing", "accounts.json"))
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		srv := server.NewServer(newListenerMock(), server.DefaultConfig())
+		srv.Accounts = accounts
+		conn := newFullConnMock()
+		srv.Conns[conn] = server.NewClient(conn)
+		srv.Conns[conn].Version = com.ProtocolVersion
+		go srv.Run(ctx)
+		srv.RegisterCh <- registerMessage(conn, "donald", "password")
+		time.Sleep(time.Second)
+
+		if clientOf(&srv, conn).Account != "" || srv.Accounts.Registered("donald") {
+			t.Fatal("Expected account to not be registered, but it was!")
+		}
+	})
+	t.Run("RejectLoginBeforeHelloAndAfterLogin", func(t *testing.T) {
+		t.Parallel()
+		srv, conn1, conn2 := startAccounts(t, server.DefaultConfig())
+		if _, err := srv.Accounts.Register("donald", "password"); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		update(srv, func() { srv.Conns[conn2].Version = 0 })
+		srv.LoginCh <- loginMessage(conn2, "donald", "password", "")
+		srv.LoginCh <- loginMessage(conn1, "donald", "password", "")
+		srv.RegisterCh <- registerMessage(conn1, "mickey", "password")
+		time.Sleep(time.Second)
+		srv.RegisterCh <- registerMessage(conn1, "mickey", "password")
+		time.Sleep(time.Second)
+
+		if account := clientOf(srv, conn2).Account; account != "" {
+			t.Fatalf("Expected client to not be logged in, but was logged in as %q!", account)
+		}
+		if clientOf(srv, conn1).Account != "donald" || srv.Accounts.Registered("mickey") {
+			t.Fatal("Expected logged in client to not authenticate again, but it did!")
+		}
+	})
+	t.Run("RejectGuestJoinWhenAccountIsRequired", func(t *testing.T) {
+		t.Parallel()
+		config := server.DefaultConfig()
+		config.Guests = false
+		srv, conn1, conn2 := startAccounts(t, config)
+		srv.JoinCh <- joinMessage(conn1, "donald")
+		srv.RegisterCh <- registerMessage(conn2, "mickey", "password")
+		time.Sleep(time.Second)
+		srv.JoinCh <- joinMessage(conn2, "mickey")
+		time.Sleep(time.Second)
+
+		if name := clientOf(srv, conn1).Name; name != "" {
+			t.Fatalf("Expected guest to not join, but joined as %q!", name)
+		}
+		if name := clientOf(srv, conn2).Name; name != "mickey" {
+			t.Fatalf("Expected logged in client to join as \"mickey\", but joined as %q!", name)
+		}
+	})
+	t.Run("RejectGuestJoinWithRegisteredName", func(t *testing.T) {
+		t.Parallel()
+		srv, conn, _ := startAccounts(t, server.DefaultConfig())
+		if _, err := srv.Accounts.Register("donald", "password"); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		srv.JoinCh <- joinMessage(conn, "donald")
+		time.Sleep(time.Second)
+
+		if name := clientOf(srv, conn).Name; name != "" {
+			t.Fatalf("Expected guest to not join, but joined as %q!", name)
+		}
+	})
+}
+
+func TestServerRunHistory(t *testing.T) {
+	t.Parallel()
+	srv, conn1, conn2 := startAccounts(t, server.DefaultConfig())
+	srv.JoinCh <- joinMessage(conn1, "donald")
+	srv.JoinCh <- joinMessage(conn2, "mickey")
+	for i, selection := range []game.Selection{game.SelectionRock, game.SelectionScissors} {
+		content := com.SelectContent{Round: 1, Selection: selection}
+		srv.SelectCh <- server.Message[com.SelectContent]{Conn: []*fullConnMock{conn1, conn2}[i], Content: content}
+	}
+	time.Sleep(time.Second)
+
+	matches, err := srv.History.Matches()
+	if err != nil {
+		t.Fatalf("Expected no error, but error was returned: %s", err)
+	}
+	if len(matches) != 1 || !reflect.DeepEqual(matches[0].Players, []string{"donald", "mickey"}) {
+		t.Fatalf("Expected the match between donald and mickey to be recorded, but was %v!", matches)
+	}
 }
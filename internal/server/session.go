@@ -1,72 +1,651 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/toivjon/go-rps/internal/com"
 	"github.com/toivjon/go-rps/internal/game"
 )
 
-// Session represents a single game session where to clients battle against each other in RPS rounds.
+// revealTimeout specifies how long the players have to reveal their committed selections once the round has
+// been sealed. The reveal time is never longer than the round timeout.
+const revealTimeout = 10 * time.Second
+
+var (
+	// ErrRoundTimeout is an error occurring when the round deadline passes without any selections.
+	ErrRoundTimeout = errors.New("round selection deadline passed without any selections")
+	// ErrRevealMismatch is an error occurring when none of the revealed selections match their commitments.
+	ErrRevealMismatch = errors.New("none of the revealed selections matched their commitments")
+)
+
+// Session represents a single game session where two or more clients battle against each other in RPS rounds.
+// Every round is a free-for-all where the players who lose the round are eliminated from the ongoing game
+// until only one player remains and wins the game. The players are kept in the seat order, which is also the
+// order of their scores and rematch offers. The spectators receive read-only events about the matches played
+// in the session. The record collects the rounds of the ongoing match, and the decided and the aborted matches
+// are written to the history unless it is nil.
 type Session struct {
-	Cli1  *Client
-	Cli2  *Client
-	Round *Round
+	ID           int
+	Players      []*Client
+	Round        *Round
+	Format       game.Format
+	Rules        game.RuleSet
+	RoundTimeout time.Duration
+	Scores       []int
+	Rematches    []bool
+	Spectators   []*Client
+	Record       *MatchRecord
+	History      History
 }
 
-// NewSession builds a new session for the given clients and attachs the session relation.
-func NewSession(cli1, cli2 *Client) *Session {
+// NewSession builds a new session for the given clients with the match settings from the given
+// configuration and attachs the session relation, which also marks the clients as playing.
+func NewSession(players []*Client, config Config) *Session {
 	session := &Session{
-		Cli1:  cli1,
-		Cli2:  cli2,
-		Round: NewRound(),
+		ID:           0,
+		Players:      players,
+		Round:        nil,
+		Format:       config.Format,
+		Rules:        config.Rules,
+		RoundTimeout: config.RoundTimeout,
+		Scores:       make([]int, len(players)),
+		Rematches:    make([]bool, len(players)),
+		Spectators:   nil,
+		Record:       nil,
+		History:      nil,
+	}
+	now := time.Now()
+	session.Round = NewRound(1, session.everyone(), session.deadline(now))
+	session.Record = session.newRecord(now)
+	for _, cli := range players {
+		cli.Session = session
+		cli.State = StatePlaying
 	}
-	cli1.Session = session
-	cli2.Session = session
 	return session
 }
 
 // Start starts the target session by notifying target clients to start the actual gaming.
 func (s *Session) Start() error {
-	if err := s.Cli1.WriteStart(s.Cli2.Name); err != nil {
-		return fmt.Errorf("failed to write START message for %s. %w", s.Cli1, err)
+	for _, cli := range s.Players {
+		if err := cli.WriteStart(s.startContent(cli)); err != nil {
+			return fmt.Errorf("failed to write START message for %s. %w", cli, err)
+		}
+	}
+	log.Printf("Session %#p started (%s, %s, %s)", s, s, s.Format, s.Rules)
+	for _, spectator := range s.Spectators {
+		s.writeSpectateStart(spectator)
+	}
+	return nil
+}
+
+// Info returns a description of the session for the spectators.
+func (s *Session) Info() com.SessionInfo {
+	names := make([]string, len(s.Players))
+	for i, cli := range s.Players {
+		names[i] = cli.Name
+	}
+	return com.SessionInfo{
+		ID:      s.ID,
+		Players: names,
+		Round:   s.Round.Number,
+		Scores:  append([]int(nil), s.Scores...),
+	}
+}
+
+// Watch subscribes the target client to the events of the session and reports the ongoing match state.
+func (s *Session) Watch(cli *Client) {
+	cli.Spectating = s
+	cli.State = StateSpectating
+	s.Spectators = append(s.Spectators, cli)
+	log.Printf("Session %#p watched by %s (spectators: %d)", s, cli, len(s.Spectators))
+	s.writeSpectateStart(cli)
+}
+
+// Unwatch unsubscribes the target client from the events of the session.
+func (s *Session) Unwatch(cli *Client) {
+	for i, spectator := range s.Spectators {
+		if spectator == cli {
+			s.Spectators = append(s.Spectators[:i], s.Spectators[i+1:]...)
+			break
+		}
+	}
+	cli.Spectating = nil
+	cli.State = StateConnected
+}
+
+// Closed checks whether the session has been closed.
+func (s *Session) Closed() bool {
+	return s.Players[0].Session != s
+}
+
+func (s *Session) writeSpectateStart(cli *Client) {
+	content := com.SpectateStartContent{Session: s.Info(), Format: s.Format, Rules: s.Rules.Name}
+	if err := cli.WriteSpectateStart(content); err != nil {
+		log.Printf("Failed to write SPECTATE_START message for %s. %s", cli, err)
 	}
-	if err := s.Cli2.WriteStart(s.Cli1.Name); err != nil {
-		return fmt.Errorf("failed to write START message for %s. %w", s.Cli2, err)
+}
+
+// Resume replays the session state for the target client which has just taken back its seat.
+func (s *Session) Resume(cli *Client) error {
+	seat := s.seat(cli)
+	scores := s.opponentScores(seat)
+	content := com.ResumedContent{
+		Start:          s.startContent(cli),
+		Round:          s.Round.Number,
+		Selection:      s.Round.Selections[seat],
+		Commitment:     s.Round.Commitments[seat],
+		Score:          s.Scores[seat],
+		OpponentScore:  highest(scores),
+		OpponentScores: scores,
+		Eliminated:     !s.Round.Playing[seat],
+		Ended:          s.Ended(),
+		Offered:        s.Offered(cli),
+	}
+	if err := cli.WriteResumed(content); err != nil {
+		return fmt.Errorf("failed to write RESUMED message for %s. %w", cli, err)
+	}
+	log.Printf("Session %#p resumed by %s (round: %d)", s, cli, s.Round.Number)
+	if s.Round.Sealed() && s.Unrevealed(cli) {
+		if err := cli.WriteCommitted(s.Round.Number); err != nil {
+			return fmt.Errorf("failed to write COMMITTED message for %s. %w", cli, err)
+		}
 	}
-	log.Printf("Session %#p started (%s & %s)", s, s.Cli1, s.Cli2)
 	return nil
 }
 
+func (s *Session) startContent(cli *Client) com.StartContent {
+	opponents := make([]string, 0, len(s.Players)-1)
+	for _, opponent := range s.Opponents(cli) {
+		opponents = append(opponents, opponent.Name)
+	}
+	return com.StartContent{
+		OpponentName: opponents[0],
+		Opponents:    opponents,
+		Format:       s.Format,
+		Rules:        s.Rules.Name,
+		Options:      s.Rules.Options,
+		RoundTimeout: s.RoundTimeout,
+		ResumeToken:  cli.ResumeToken,
+		Tournament:   cli.Tournament != nil,
+		CommitReveal: com.HasCapability(cli.Capabilities, com.CapabilityCommitReveal),
+	}
+}
+
+// HasSelected checks whether the target client has already made or committed to a selection for the ongoing
+// round.
+func (s *Session) HasSelected(cli *Client) bool {
+	seat := s.seat(cli)
+	return seat >= 0 && (s.Round.Selections[seat] != game.SelectionNone || s.Round.Commitments[seat] != "")
+}
+
+// Unrevealed checks whether the target client has committed to a selection for the ongoing round but has not
+// revealed it yet.
+func (s *Session) Unrevealed(cli *Client) bool {
+	seat := s.seat(cli)
+	return seat >= 0 && s.Round.Commitments[seat] != "" && s.Round.Selections[seat] == game.SelectionNone &&
+		!s.Round.Mismatches[seat]
+}
+
+// Playing checks whether the target client plays the ongoing round i.e. it has not been eliminated from the
+// ongoing game.
+func (s *Session) Playing(cli *Client) bool {
+	seat := s.seat(cli)
+	return seat >= 0 && s.Round.Playing[seat]
+}
+
+// Ended checks whether the match of the session has been decided.
+func (s *Session) Ended() bool {
+	return s.Format.Decided(s.Scores...)
+}
+
 // Select applies the given selection for the target client for the ongoing RPS game round.
 func (s *Session) Select(cli *Client, selection game.Selection) error {
-	switch cli {
-	case s.Cli1:
-		s.Round.Selection1 = selection
-	case s.Cli2:
-		s.Round.Selection2 = selection
+	sealed := s.Round.Sealed()
+	if seat := s.seat(cli); seat >= 0 {
+		s.Round.Selections[seat] = selection
 	}
+	return s.seal(sealed)
+}
+
+// Commit applies the given commitment for the target client for the ongoing RPS game round.
+func (s *Session) Commit(cli *Client, commitment string) error {
+	sealed := s.Round.Sealed()
+	if seat := s.seat(cli); seat >= 0 {
+		s.Round.Commitments[seat] = commitment
+	}
+	return s.seal(sealed)
+}
+
+// seal asks the clients which have committed to reveal their selections once every playing seat has made or
+// committed to a selection, or resolves the round if none of the seats has to reveal a selection. The reveals
+// get a deadline of their own when the round becomes sealed, so that a late commit does not leave the others
+// without time to reveal. The sealed tells whether the round was already sealed before the latest selection.
+func (s *Session) seal(sealed bool) error {
 	if s.Round.Ended() {
-		result1, result2 := s.Round.Result()
-		if err := s.Cli1.WriteResult(s.Round.Selection2, result1); err != nil {
-			return fmt.Errorf("failed to write RESULT message for %s. %w", s.Cli1, err)
+		return s.settle()
+	}
+	if !s.Round.Sealed() {
+		return nil
+	}
+	if !sealed {
+		s.Round.Deadline = s.revealDeadline(time.Now())
+	}
+	for _, cli := range s.Players {
+		if !s.Unrevealed(cli) {
+			continue
 		}
-		if err := s.Cli2.WriteResult(s.Round.Selection1, result2); err != nil {
-			return fmt.Errorf("failed to write RESULT message for %s. %w", s.Cli2, err)
+		if err := cli.WriteCommitted(s.Round.Number); err != nil {
+			return fmt.Errorf("failed to write COMMITTED message for %s. %w", cli, err)
 		}
-		log.Printf("Session %#p round result %s:%s and %s:%s", s, s.Cli1, result1, s.Cli2, result2)
-		if result1 == game.ResultDraw && result2 == game.ResultDraw {
-			s.Round = NewRound()
+	}
+	log.Printf("Session %#p round %d sealed", s, s.Round.Number)
+	return nil
+}
+
+// Reveal applies the revealed selection for the target client if it matches the commitment of the client.
+// Otherwise the client forfeits the round. The round is resolved once every committed selection is revealed.
+func (s *Session) Reveal(cli *Client, selection game.Selection, nonce string) error {
+	if seat := s.seat(cli); seat >= 0 {
+		if com.Commit(selection, nonce) == s.Round.Commitments[seat] && s.Rules.Validate(selection) == nil {
+			s.Round.Selections[seat] = selection
+		} else {
+			s.Round.Mismatches[seat] = true
+			log.Printf("Session %#p round %d reveal of %s did not match the commitment", s, s.Round.Number, cli)
 		}
 	}
+	if s.Round.Ended() {
+		return s.settle()
+	}
 	return nil
 }
 
-// Close closes the target session by removing session references and closing attached connections.
+// settle resolves the ended round. The round is forfeited by the seats whose reveal did not match their
+// commitment. Returns ErrRevealMismatch when none of the reveals matched.
+func (s *Session) settle() error {
+	if !s.Round.Mismatched() {
+		return s.resolve(s.Round.Result(s.Rules), false)
+	}
+	results, ok := s.Round.Forfeit()
+	if !ok {
+		return ErrRevealMismatch
+	}
+	return s.resolve(results, true)
+}
+
+// Expire resolves the ongoing round as a forfeit if its selection deadline has passed. The clients which have
+// not made a selection lose the round. Returns ErrRoundTimeout when none of the clients selected.
+func (s *Session) Expire(now time.Time) error {
+	if s.Ended() || !s.Round.Expired(now) {
+		return nil
+	}
+	results, ok := s.Round.Forfeit()
+	if !ok {
+		return ErrRoundTimeout
+	}
+	return s.resolve(results, true)
+}
+
+// resolve applies the given round results which are in the seat order. The losers are eliminated from the
+// ongoing game and the last remaining player wins the game, after which everyone plays the next game.
+func (s *Session) resolve(results []game.Result, forfeit bool) error {
+	playing, remaining := make([]bool, len(s.Players)), 0
+	for i, result := range results {
+		playing[i] = s.Round.Playing[i] && result != game.ResultLose
+		if playing[i] {
+			remaining++
+		}
+	}
+	if remaining == 1 {
+		for i := range playing {
+			if playing[i] {
+				s.Scores[i]++
+			}
+		}
+		playing = s.everyone()
+	}
+	for i, cli := range s.Players {
+		if err := cli.WriteResult(s.resultContent(i, results, forfeit, !playing[i])); err != nil {
+			return fmt.Errorf("failed to write RESULT message for %s. %w", cli, err)
+		}
+	}
+	log.Printf("Session %#p round %d result %s (%s, forfeit: %t)", s, s.Round.Number, s.describeResults(results),
+		describeScores(s.Scores), forfeit)
+	for _, spectator := range s.Spectators {
+		if err := spectator.WriteSpectateRound(com.SpectateRoundContent{
+			Round:      s.Round.Number,
+			Selections: s.Round.Selections,
+			Results:    results,
+			Forfeit:    forfeit,
+			Scores:     s.Scores,
+		}); err != nil {
+			log.Printf("Failed to write SPECTATE_ROUND message for %s. %s", spectator, err)
+		}
+	}
+	s.Record.Rounds = append(s.Record.Rounds, RoundRecord{
+		Number:     s.Round.Number,
+		Selections: s.Round.Selections,
+		Results:    results,
+		Forfeit:    forfeit,
+		Resolved:   time.Now(),
+	})
+	if s.Ended() {
+		return s.end()
+	}
+	s.Round = NewRound(s.Round.Number+1, playing, s.deadline(time.Now()))
+	return nil
+}
+
+func (s *Session) resultContent(seat int, results []game.Result, forfeit, eliminated bool) com.ResultContent {
+	opponents := make([]com.OpponentResult, 0, len(s.Players)-1)
+	for i, cli := range s.Players {
+		if i != seat {
+			opponents = append(opponents, com.OpponentResult{
+				Name:      cli.Name,
+				Selection: s.Round.Selections[i],
+				Result:    results[i],
+				Score:     s.Scores[i],
+			})
+		}
+	}
+	return com.ResultContent{
+		Round:             s.Round.Number,
+		OpponentSelection: opponents[0].Selection,
+		Result:            results[seat],
+		Forfeit:           forfeit,
+		Score:             s.Scores[seat],
+		OpponentScore:     highest(s.opponentScores(seat)),
+		Opponents:         opponents,
+		Eliminated:        eliminated,
+	}
+}
+
+func (s *Session) end() error {
+	winner := 0
+	for i, score := range s.Scores {
+		if score > s.Scores[winner] {
+			winner = i
+		}
+	}
+	results := make([]game.Result, len(s.Players))
+	for i := range results {
+		results[i] = game.ResultLose
+		if i == winner {
+			results[i] = game.ResultWin
+		}
+	}
+	s.record(results, "")
+	for i, cli := range s.Players {
+		if err := cli.WriteMatchEnd(results[i], s.Scores[i], highest(s.opponentScores(i))); err != nil {
+			return fmt.Errorf("failed to write MATCH_END message for %s. %w", cli, err)
+		}
+	}
+	log.Printf("Session %#p match result %s", s, s.describeResults(results))
+	for _, spectator := range s.Spectators {
+		content := com.SpectateEndContent{Results: results, Scores: s.Scores}
+		if err := spectator.WriteSpectateEnd(content); err != nil {
+			log.Printf("Failed to write SPECTATE_END message for %s. %s", spectator, err)
+		}
+	}
+	return nil
+}
+
+// newRecord builds a record without any rounds for a match started at the given time.
+func (s *Session) newRecord(started time.Time) *MatchRecord {
+	players := make([]string, len(s.Players))
+	for i, cli := range s.Players {
+		players[i] = cli.Name
+	}
+	return &MatchRecord{
+		Players: players,
+		Format:  s.Format,
+		Rules:   s.Rules.Name,
+		Rounds:  nil,
+		Results: nil,
+		Scores:  nil,
+		Aborted: "",
+		Started: started,
+		Ended:   time.Time{},
+	}
+}
+
+// record completes the record of the match with the given match results, or with the error code when the match
+// was aborted before it was decided, and writes it to the history. A failure to write the history is only
+// logged so that it never prevents notifying the players.
+func (s *Session) record(results []game.Result, aborted com.ErrorCode) {
+	s.Record.Results = results
+	s.Record.Aborted = aborted
+	s.Record.Scores = append([]int(nil), s.Scores...)
+	s.Record.Ended = time.Now()
+	if s.History == nil {
+		return
+	}
+	if err := s.History.Record(*s.Record); err != nil {
+		log.Printf("Failed to record match of session %#p. %s", s, err)
+	}
+}
+
+// Offered checks whether the target client has offered a rematch after the match was decided.
+func (s *Session) Offered(cli *Client) bool {
+	seat := s.seat(cli)
+	return seat >= 0 && s.Rematches[seat]
+}
+
+// Proposed checks whether any of the opponents of the target client has offered a rematch.
+func (s *Session) Proposed(cli *Client) bool {
+	for _, opponent := range s.Opponents(cli) {
+		if s.Offered(opponent) {
+			return true
+		}
+	}
+	return false
+}
+
+// Offer marks the target client to offer a rematch. The opponents who have not offered a rematch yet get
+// notified about the offer. The rematch gets started immediately if every opponent has already offered it.
+func (s *Session) Offer(cli *Client) error {
+	s.setRematch(cli)
+	if s.agreed() {
+		return s.rematch(cli)
+	}
+	for _, opponent := range s.Opponents(cli) {
+		if s.Offered(opponent) {
+			continue
+		}
+		if err := opponent.WriteRematchOffer(); err != nil {
+			return fmt.Errorf("failed to write REMATCH_OFFER message for %s. %w", opponent, err)
+		}
+	}
+	log.Printf("Session %#p rematch offered by %s", s, cli)
+	return nil
+}
+
+// Accept accepts the rematch offered by an opponent of the target client. A new match with the same settings
+// and a reset score is started once every player has agreed to the rematch.
+func (s *Session) Accept(cli *Client) error {
+	s.setRematch(cli)
+	if s.agreed() {
+		return s.rematch(cli)
+	}
+	log.Printf("Session %#p rematch accepted by %s, waiting for the other players", s, cli)
+	return nil
+}
+
+func (s *Session) rematch(cli *Client) error {
+	now := time.Now()
+	s.Scores = make([]int, len(s.Players))
+	s.Rematches = make([]bool, len(s.Players))
+	s.Round = NewRound(1, s.everyone(), s.deadline(now))
+	s.Record = s.newRecord(now)
+	log.Printf("Session %#p rematch accepted by %s", s, cli)
+	return s.Start()
+}
+
+// Opponents returns the clients which play against the target client in the session in the seat order.
+func (s *Session) Opponents(cli *Client) []*Client {
+	opponents := make([]*Client, 0, len(s.Players))
+	for _, player := range s.Players {
+		if player != cli {
+			opponents = append(opponents, player)
+		}
+	}
+	return opponents
+}
+
+func (s *Session) setRematch(cli *Client) {
+	if seat := s.seat(cli); seat >= 0 {
+		s.Rematches[seat] = true
+	}
+}
+
+// agreed checks whether every player has offered or accepted a rematch.
+func (s *Session) agreed() bool {
+	for _, rematch := range s.Rematches {
+		if !rematch {
+			return false
+		}
+	}
+	return true
+}
+
+// seat returns the index of the target client in the seat order or -1 when the client is not a player.
+func (s *Session) seat(cli *Client) int {
+	for i, player := range s.Players {
+		if player == cli {
+			return i
+		}
+	}
+	return -1
+}
+
+// everyone builds the playing seats of a round where every player plays.
+func (s *Session) everyone() []bool {
+	playing := make([]bool, len(s.Players))
+	for i := range playing {
+		playing[i] = true
+	}
+	return playing
+}
+
+// opponentScores returns the scores of the other seats than the given one in the seat order.
+func (s *Session) opponentScores(seat int) []int {
+	scores := make([]int, 0, len(s.Scores))
+	for i, score := range s.Scores {
+		if i != seat {
+			scores = append(scores, score)
+		}
+	}
+	return scores
+}
+
+func (s *Session) deadline(now time.Time) time.Time {
+	if s.RoundTimeout <= 0 {
+		return time.Time{}
+	}
+	return now.Add(s.RoundTimeout)
+}
+
+// revealDeadline returns the deadline for revealing the committed selections of a round sealed at the given time.
+func (s *Session) revealDeadline(now time.Time) time.Time {
+	if s.RoundTimeout <= 0 {
+		return time.Time{}
+	}
+	if s.RoundTimeout < revealTimeout {
+		return now.Add(s.RoundTimeout)
+	}
+	return now.Add(revealTimeout)
+}
+
+// Abort reports the given failure to all clients and closes the target session. The ongoing match is recorded
+// as aborted with the code unless it has already been decided.
+func (s *Session) Abort(code com.ErrorCode, message string) {
+	if !s.Ended() {
+		s.record(nil, code)
+	}
+	for _, cli := range s.Players {
+		if err := cli.WriteError(code, message); err != nil {
+			log.Printf("Failed to write ERROR message for %s. %s", cli, err)
+		}
+	}
+	s.Close()
+}
+
+// Leave notifies the opponents of the target client about the client leaving and closes the target session.
+// The ongoing match is recorded as aborted unless it has already been decided.
+func (s *Session) Leave(cli *Client) {
+	if !s.Ended() {
+		s.record(nil, com.CodeOpponentLeft)
+	}
+	message := fmt.Sprintf("opponent %q left the game", cli.Name)
+	for _, opponent := range s.Opponents(cli) {
+		if err := opponent.WriteError(com.CodeOpponentLeft, message); err != nil {
+			log.Printf("Failed to write ERROR message for %s. %s", opponent, err)
+		}
+	}
+	s.Close()
+}
+
+// Close closes the target session by removing session references and returning all clients to the lobby or
+// to the tournament they participate in. The spectators are notified and unsubscribed from the session.
 func (s *Session) Close() {
-	s.Cli1.Session = nil
-	s.Cli2.Session = nil
-	log.Printf("Session %#p closed (%s & %s)", s, s.Cli1, s.Cli2)
-	s.Cli1.Close()
-	s.Cli2.Close()
+	for _, cli := range s.Players {
+		cli.Session = nil
+		cli.State = StateLobby
+		if cli.Tournament != nil {
+			cli.State = StateTournament
+		}
+	}
+	for _, spectator := range s.Spectators {
+		if err := spectator.WriteError(com.CodeSessionClosed, "spectated game session was closed"); err != nil {
+			log.Printf("Failed to write ERROR message for %s. %s", spectator, err)
+		}
+		spectator.Spectating = nil
+		spectator.State = StateConnected
+	}
+	s.Spectators = nil
+	log.Printf("Session %#p closed (%s)", s, s)
+}
+
+// String returns a string representing the players of the session.
+func (s *Session) String() string {
+	players := make([]string, len(s.Players))
+	for i, cli := range s.Players {
+		players[i] = cli.String()
+	}
+	return strings.Join(players, " & ")
+}
+
+// describeResults describes the results of the players who played the round.
+func (s *Session) describeResults(results []game.Result) string {
+	descriptions := make([]string, 0, len(s.Players))
+	for i, cli := range s.Players {
+		if results[i] != "" {
+			descriptions = append(descriptions, fmt.Sprintf("%s:%s", cli, results[i]))
+		}
+	}
+	return strings.Join(descriptions, " and ")
+}
+
+// describeScores describes the scores in the seat order e.g. "2-1-0".
+func describeScores(scores []int) string {
+	descriptions := make([]string, len(scores))
+	for i, score := range scores {
+		descriptions[i] = strconv.Itoa(score)
+	}
+	return strings.Join(descriptions, "-")
+}
+
+// highest returns the highest of the given scores or zero when there are no scores.
+func highest(scores []int) int {
+	best := 0
+	for _, score := range scores {
+		if score > best {
+			best = score
+		}
+	}
+	return best
 }
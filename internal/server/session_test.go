@@ -1,27 +1,64 @@
 package server_test
 
 import (
+	"bytes"
 	"errors"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/toivjon/go-rps/internal/com"
 	"github.com/toivjon/go-rps/internal/game"
 	"github.com/toivjon/go-rps/internal/server"
 )
 
+// matchEndFailingConnMock is a connection which fails only when a MATCH_END message is written.
+type matchEndFailingConnMock struct {
+	*connMock
+	fail bool
+}
+
+func (m *matchEndFailingConnMock) Write(p []byte) (int, error) {
+	if m.fail && bytes.Contains(p, []byte(com.TypeMatchEnd)) {
+		return 0, errMock
+	}
+	return len(p), nil
+}
+
+// historyMock is a match history which fails to record the matches.
+type historyMock struct {
+	err error
+}
+
+func (m historyMock) Record(server.MatchRecord) error {
+	return m.err
+}
+
+func (m historyMock) Matches() ([]server.MatchRecord, error) {
+	return nil, m.err
+}
+
+func (m historyMock) Close() error {
+	return m.err
+}
+
 func TestNewSession(t *testing.T) {
 	t.Parallel()
 	cli1 := server.NewClient(new(connMock))
 	cli2 := server.NewClient(new(connMock))
-	session := server.NewSession(cli1, cli2)
-	if session.Cli1 != cli1 {
-		t.Fatalf("Expected cli1 to be %#p, but was %#p!", cli1, session.Cli1)
+	session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+	if session.Players[0] != cli1 {
+		t.Fatalf("Expected cli1 to be %#p, but was %#p!", cli1, session.Players[0])
 	}
-	if session.Cli2 != cli2 {
-		t.Fatalf("Expected cli2 to be %#p, but was %#p!", cli2, session.Cli2)
+	if session.Players[1] != cli2 {
+		t.Fatalf("Expected cli2 to be %#p, but was %#p!", cli2, session.Players[1])
 	}
 	if session.Round == nil {
 		t.Fatal("Expected round to be non-nil, but was nil!")
 	}
+	if session.Rules.Name != game.Classic().Name {
+		t.Fatalf("Expected rules to be %q, but was %q!", game.Classic(), session.Rules)
+	}
 }
 
 func TestSessionStart(t *testing.T) {
@@ -32,7 +69,7 @@ func TestSessionStart(t *testing.T) {
 		errConn.writerMock.err = errMock
 		cli1 := server.NewClient(errConn)
 		cli2 := server.NewClient(new(connMock))
-		session := server.NewSession(cli1, cli2)
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
 		if err := session.Start(); !errors.Is(err, errMock) {
 			t.Fatalf("Expected %q in the chain %q, but did not exists!", errMock, err)
 		}
@@ -43,7 +80,7 @@ func TestSessionStart(t *testing.T) {
 		errConn.writerMock.err = errMock
 		cli1 := server.NewClient(new(connMock))
 		cli2 := server.NewClient(errConn)
-		session := server.NewSession(cli1, cli2)
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
 		if err := session.Start(); !errors.Is(err, errMock) {
 			t.Fatalf("Expected %q in the chain %q, but did not exists!", errMock, err)
 		}
@@ -52,13 +89,106 @@ func TestSessionStart(t *testing.T) {
 		t.Parallel()
 		cli1 := server.NewClient(new(connMock))
 		cli2 := server.NewClient(new(connMock))
-		session := server.NewSession(cli1, cli2)
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
 		if err := session.Start(); err != nil {
 			t.Fatalf("Expected no error, but an error %q was returned!", err)
 		}
 	})
 }
 
+func TestSessionHasSelected(t *testing.T) {
+	t.Parallel()
+	cli1 := server.NewClient(new(connMock))
+	cli2 := server.NewClient(new(connMock))
+	cli3 := server.NewClient(new(connMock))
+	session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+	session.Round.Selections[1] = game.SelectionRock
+	if session.HasSelected(cli1) {
+		t.Fatal("Expected cli1 to not have selected, but it had!")
+	}
+	if !session.HasSelected(cli2) {
+		t.Fatal("Expected cli2 to have selected, but it had not!")
+	}
+	if session.HasSelected(cli3) {
+		t.Fatal("Expected non-participant to not have selected, but it had!")
+	}
+	session.Round.Commitments[0] = "commitment"
+	if !session.HasSelected(cli1) || !session.Unrevealed(cli1) {
+		t.Fatal("Expected cli1 to have committed without revealing, but it had not!")
+	}
+	if session.Unrevealed(cli2) || session.Unrevealed(cli3) {
+		t.Fatal("Expected only cli1 to have an unrevealed commitment, but others had!")
+	}
+}
+
+func TestSessionCommit(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenCommittedWriteFails", func(t *testing.T) {
+		t.Parallel()
+		errConn := new(connMock)
+		errConn.writerMock.err = errMock
+		cli1 := server.NewClient(errConn)
+		cli2 := server.NewClient(new(connMock))
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+		if err := session.Commit(cli1, com.Commit(game.SelectionRock, "nonce")); err != nil {
+			t.Fatalf("Expected no error before the round is sealed, but %q was returned!", err)
+		}
+		if err := session.Select(cli2, game.SelectionPaper); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("GiveRevealsOwnDeadlineWhenCommittedCloseToDeadline", func(t *testing.T) {
+		t.Parallel()
+		cli1 := server.NewClient(new(connMock))
+		cli2 := server.NewClient(new(connMock))
+		config := server.DefaultConfig()
+		config.RoundTimeout = time.Minute
+		session := server.NewSession([]*server.Client{cli1, cli2}, config)
+		if err := session.Commit(cli1, com.Commit(game.SelectionRock, "nonce")); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		now := time.Now()
+		session.Round.Deadline = now.Add(time.Millisecond)
+		if err := session.Commit(cli2, com.Commit(game.SelectionPaper, "nonce")); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if err := session.Expire(now.Add(time.Second)); err != nil || session.Round.Number != 1 {
+			t.Fatalf("Expected the reveals to have time left, but round was %d with %v!", session.Round.Number, err)
+		}
+		if err := session.Reveal(cli1, game.SelectionRock, "nonce"); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if err := session.Expire(now.Add(time.Minute)); err != nil || session.Scores[0] != 1 {
+			t.Fatalf("Expected the unrevealed player to forfeit, but scores were %v with %v!", session.Scores, err)
+		}
+	})
+	t.Run("AskUnrevealedClientsToRevealWhenSealed", func(t *testing.T) {
+		t.Parallel()
+		buffer := new(bytes.Buffer)
+		cli1 := server.NewClient(struct {
+			*bytes.Buffer
+			*closerMock
+		}{buffer, new(closerMock)})
+		errConn := new(connMock)
+		errConn.writerMock.err = errMock
+		cli2 := server.NewClient(errConn)
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+		if err := session.Select(cli2, game.SelectionPaper); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if err := session.Commit(cli1, com.Commit(game.SelectionRock, "nonce")); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		committed, err := com.ReadMessage[com.CommittedContent](buffer)
+		if err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if committed.Round != 1 {
+			t.Fatalf("Expected COMMITTED for round 1, but was for round %d!", committed.Round)
+		}
+	})
+}
+
 //nolint:funlen,cyclop
 func TestSessionSelect(t *testing.T) {
 	t.Parallel()
@@ -70,15 +200,15 @@ func TestSessionSelect(t *testing.T) {
 		conn2.writerMock.err = errMock
 		cli1 := server.NewClient(conn1)
 		cli2 := server.NewClient(conn2)
-		session := server.NewSession(cli1, cli2)
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
 		if err := session.Select(cli1, game.SelectionRock); err != nil {
 			t.Fatalf("Expected no error, but %q was returned!", err)
 		}
-		if session.Round.Selection1 != game.SelectionRock {
+		if session.Round.Selections[0] != game.SelectionRock {
 			t.Fatal("Expected selection1 to be assigned, but it was not!")
 		}
-		if session.Round.Selection2 != game.SelectionNone {
-			t.Fatalf("Expected selection2 to still be none, but it was %q!", session.Round.Selection2)
+		if session.Round.Selections[1] != game.SelectionNone {
+			t.Fatalf("Expected selection2 to still be none, but it was %q!", session.Round.Selections[1])
 		}
 	})
 	t.Run("NoFurtherActionsWhenSelection2StaysNone", func(t *testing.T) {
@@ -89,14 +219,14 @@ func TestSessionSelect(t *testing.T) {
 		conn2.writerMock.err = errMock
 		cli1 := server.NewClient(conn1)
 		cli2 := server.NewClient(conn2)
-		session := server.NewSession(cli1, cli2)
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
 		if err := session.Select(cli2, game.SelectionRock); err != nil {
 			t.Fatalf("Expected no error, but %q was returned!", err)
 		}
-		if session.Round.Selection1 != game.SelectionNone {
-			t.Fatalf("Expected selection1 to still be none, but it was %q!", session.Round.Selection1)
+		if session.Round.Selections[0] != game.SelectionNone {
+			t.Fatalf("Expected selection1 to still be none, but it was %q!", session.Round.Selections[0])
 		}
-		if session.Round.Selection2 != game.SelectionRock {
+		if session.Round.Selections[1] != game.SelectionRock {
 			t.Fatal("Expected selection1 to be assigned, but it was not!")
 		}
 	})
@@ -106,8 +236,8 @@ func TestSessionSelect(t *testing.T) {
 		errConn.writerMock.err = errMock
 		cli1 := server.NewClient(errConn)
 		cli2 := server.NewClient(new(connMock))
-		session := server.NewSession(cli1, cli2)
-		session.Round.Selection2 = game.SelectionRock
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+		session.Round.Selections[1] = game.SelectionRock
 		if err := session.Select(cli1, game.SelectionRock); !errors.Is(err, errMock) {
 			t.Fatalf("Expected %q in the chain %q, but did not exists!", errMock, err)
 		}
@@ -118,8 +248,8 @@ func TestSessionSelect(t *testing.T) {
 		errConn.writerMock.err = errMock
 		cli1 := server.NewClient(new(connMock))
 		cli2 := server.NewClient(errConn)
-		session := server.NewSession(cli1, cli2)
-		session.Round.Selection2 = game.SelectionRock
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+		session.Round.Selections[1] = game.SelectionRock
 		if err := session.Select(cli1, game.SelectionRock); !errors.Is(err, errMock) {
 			t.Fatalf("Expected %q in the chain %q, but did not exists!", errMock, err)
 		}
@@ -128,41 +258,173 @@ func TestSessionSelect(t *testing.T) {
 		t.Parallel()
 		cli1 := server.NewClient(new(connMock))
 		cli2 := server.NewClient(new(connMock))
-		session := server.NewSession(cli1, cli2)
-		session.Round.Selection2 = game.SelectionRock
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+		session.Round.Selections[1] = game.SelectionRock
+		if err := session.Select(cli1, game.SelectionRock); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if session.Round.Selections[0] != game.SelectionNone {
+			t.Fatalf("Expected round selection1 to be none, but was %q!", session.Round.Selections[0])
+		}
+		if session.Round.Selections[1] != game.SelectionNone {
+			t.Fatalf("Expected round selection2 to be none, but was %q!", session.Round.Selections[1])
+		}
+	})
+	t.Run("StartNewRoundWhenMatchIsNotDecided", func(t *testing.T) {
+		t.Parallel()
+		cli1 := server.NewClient(new(connMock))
+		cli2 := server.NewClient(new(connMock))
+		config := server.DefaultConfig()
+		config.Format = game.BestOf(3)
+		session := server.NewSession([]*server.Client{cli1, cli2}, config)
+		session.Round.Selections[1] = game.SelectionScissors
 		if err := session.Select(cli1, game.SelectionRock); err != nil {
 			t.Fatalf("Expected no error, but %q was returned!", err)
 		}
-		if session.Round.Selection1 != game.SelectionNone {
-			t.Fatalf("Expected round selection1 to be none, but was %q!", session.Round.Selection1)
+		if session.Scores[0] != 1 || session.Scores[1] != 0 {
+			t.Fatalf("Expected score to be 1-0, but was %d-%d!", session.Scores[0], session.Scores[1])
 		}
-		if session.Round.Selection2 != game.SelectionNone {
-			t.Fatalf("Expected round selection2 to be none, but was %q!", session.Round.Selection2)
+		if session.Round.Selections[0] != game.SelectionNone {
+			t.Fatalf("Expected round selection1 to be none, but was %q!", session.Round.Selections[0])
+		}
+	})
+	t.Run("ReturnErrorWhenMatchEndWriteFails", func(t *testing.T) {
+		t.Parallel()
+		for _, errCli := range []int{1, 2} {
+			cli1 := server.NewClient(&matchEndFailingConnMock{connMock: new(connMock), fail: errCli == 1})
+			cli2 := server.NewClient(&matchEndFailingConnMock{connMock: new(connMock), fail: errCli == 2})
+			session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+			session.Round.Selections[1] = game.SelectionPaper
+			if err := session.Select(cli1, game.SelectionRock); !errors.Is(err, errMock) {
+				t.Fatalf("Expected %q in the chain %q, but did not exists!", errMock, err)
+			}
 		}
 	})
 	t.Run("ReturnNilAfterNonDrawResult", func(t *testing.T) {
 		t.Parallel()
 		cli1 := server.NewClient(new(connMock))
 		cli2 := server.NewClient(new(connMock))
-		session := server.NewSession(cli1, cli2)
-		session.Round.Selection2 = game.SelectionPaper
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+		session.Round.Selections[1] = game.SelectionPaper
 		if err := session.Select(cli1, game.SelectionRock); err != nil {
 			t.Fatalf("Expected no error, but %q was returned!", err)
 		}
-		if session.Round.Selection1 != game.SelectionRock {
-			t.Fatalf("Expected selection1 to be rock, but was %q!", session.Round.Selection1)
+		if session.Round.Selections[0] != game.SelectionRock {
+			t.Fatalf("Expected selection1 to be rock, but was %q!", session.Round.Selections[0])
 		}
-		if session.Round.Selection2 != game.SelectionPaper {
-			t.Fatalf("Expected selection2 to be rock, but was %q!", session.Round.Selection2)
+		if session.Round.Selections[1] != game.SelectionPaper {
+			t.Fatalf("Expected selection2 to be rock, but was %q!", session.Round.Selections[1])
+		}
+	})
+	t.Run("RecordMatchToHistoryWhenMatchIsDecided", func(t *testing.T) {
+		t.Parallel()
+		cli1 := server.NewClient(new(connMock))
+		cli2 := server.NewClient(new(connMock))
+		cli1.Name, cli2.Name = "donald", "mickey"
+		history := server.NewMemoryHistory()
+		config := server.DefaultConfig()
+		config.Format = game.BestOf(3)
+		session := server.NewSession([]*server.Client{cli1, cli2}, config)
+		session.History = history
+		for _, selection := range []game.Selection{game.SelectionRock, game.SelectionPaper, game.SelectionPaper} {
+			session.Round.Selections[1] = game.SelectionRock
+			if err := session.Select(cli1, selection); err != nil {
+				t.Fatalf("Expected no error, but %q was returned!", err)
+			}
+		}
+		matches, _ := history.Matches()
+		if len(matches) != 1 {
+			t.Fatalf("Expected 1 recorded match, but %d were recorded!", len(matches))
+		}
+		match := matches[0]
+		if !reflect.DeepEqual(match.Players, []string{"donald", "mickey"}) || match.Rules != game.Classic().Name {
+			t.Fatalf("Expected match between donald and mickey, but was %v (%s)!", match.Players, match.Rules)
+		}
+		if !reflect.DeepEqual(match.Results, []game.Result{game.ResultWin, game.ResultLose}) {
+			t.Fatalf("Expected donald to win, but results were %v!", match.Results)
+		}
+		if !reflect.DeepEqual(match.Scores, []int{2, 0}) || len(match.Rounds) != 3 {
+			t.Fatalf("Expected 3 rounds with 2-0 score, but was %d with %v!", len(match.Rounds), match.Scores)
+		}
+		if match.Rounds[0].Results[0] != game.ResultDraw || match.Rounds[2].Selections[0] != game.SelectionPaper {
+			t.Fatalf("Expected rounds to be recorded in order, but were %v!", match.Rounds)
+		}
+		if match.Ended.Before(match.Started) || match.Aborted != "" {
+			t.Fatalf("Expected match to be decided after %s, but was %+v!", match.Started, match)
+		}
+		session.Leave(cli2)
+		if matches, _ := history.Matches(); len(matches) != 1 {
+			t.Fatalf("Expected the decided match to be recorded once, but %d were recorded!", len(matches))
+		}
+	})
+	t.Run("ReturnNilWhenHistoryRecordFails", func(t *testing.T) {
+		t.Parallel()
+		cli1 := server.NewClient(new(connMock))
+		cli2 := server.NewClient(new(connMock))
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+		session.History = historyMock{err: errMock}
+		session.Round.Selections[1] = game.SelectionScissors
+		if err := session.Select(cli1, game.SelectionRock); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if !session.Ended() {
+			t.Fatal("Expected match to be decided, but it was not!")
+		}
+	})
+	t.Run("EliminateLosersWhenManyPlayers", func(t *testing.T) {
+		t.Parallel()
+		cli1 := server.NewClient(new(connMock))
+		cli2 := server.NewClient(new(connMock))
+		cli3 := server.NewClient(new(connMock))
+		config := server.DefaultConfig()
+		config.Format = game.BestOf(3)
+		session := server.NewSession([]*server.Client{cli1, cli2, cli3}, config)
+		session.Round.Selections[0] = game.SelectionRock
+		session.Round.Selections[1] = game.SelectionRock
+		if err := session.Select(cli3, game.SelectionScissors); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if !session.Playing(cli1) || !session.Playing(cli2) || session.Playing(cli3) {
+			t.Fatalf("Expected only cli3 to be eliminated, but playing seats were %v!", session.Round.Playing)
+		}
+		if session.Round.Number != 2 || !reflect.DeepEqual(session.Scores, []int{0, 0, 0}) {
+			t.Fatalf("Expected round 2 without scores, but was %d with %v!", session.Round.Number, session.Scores)
+		}
+		session.Round.Selections[1] = game.SelectionScissors
+		if err := session.Select(cli1, game.SelectionRock); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if !reflect.DeepEqual(session.Scores, []int{1, 0, 0}) {
+			t.Fatalf("Expected cli1 to score, but scores were %v!", session.Scores)
+		}
+		if !reflect.DeepEqual(session.Round.Playing, []bool{true, true, true}) {
+			t.Fatalf("Expected everyone to play the next game, but playing seats were %v!", session.Round.Playing)
 		}
 	})
 }
 
+func TestSessionEnded(t *testing.T) {
+	t.Parallel()
+	config := server.DefaultConfig()
+	config.Format = game.BestOf(3)
+	session := server.NewSession([]*server.Client{
+		server.NewClient(new(connMock)),
+		server.NewClient(new(connMock)),
+	}, config)
+	if session.Ended() {
+		t.Fatal("Expected new session to not be ended, but it was!")
+	}
+	session.Scores[1] = 2
+	if !session.Ended() {
+		t.Fatal("Expected session to be ended after two wins, but it was not!")
+	}
+}
+
 func TestSessionClose(t *testing.T) {
 	t.Parallel()
 	cli1 := server.NewClient(new(connMock))
 	cli2 := server.NewClient(new(connMock))
-	session := server.NewSession(cli1, cli2)
+	session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
 	session.Close()
 	if cli1.Session != nil {
 		t.Fatalf("Expected cli1 session to be nil, but was %v!", cli1.Session)
@@ -170,4 +432,421 @@ func TestSessionClose(t *testing.T) {
 	if cli2.Session != nil {
 		t.Fatalf("Expected cli2 session to be nil, but was %v!", cli2.Session)
 	}
+	if cli1.State != server.StateLobby || cli2.State != server.StateLobby {
+		t.Fatalf("Expected clients to be in the lobby, but were %s and %s!", cli1.State, cli2.State)
+	}
+}
+
+func TestSessionLeave(t *testing.T) {
+	t.Parallel()
+	errConn := new(connMock)
+	errConn.writerMock.err = errMock
+	cli1 := server.NewClient(new(connMock))
+	cli2 := server.NewClient(errConn)
+	session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+	history := server.NewMemoryHistory()
+	session.History = history
+	session.Leave(cli1)
+	if cli1.Session != nil {
+		t.Fatalf("Expected cli1 session to be nil, but was %v!", cli1.Session)
+	}
+	if cli2.Session != nil {
+		t.Fatalf("Expected cli2 session to be nil, but was %v!", cli2.Session)
+	}
+	if matches, _ := history.Matches(); len(matches) != 1 || matches[0].Aborted != com.CodeOpponentLeft {
+		t.Fatalf("Expected the match to be recorded as aborted, but matches were %+v!", matches)
+	}
+}
+
+func TestSessionAbort(t *testing.T) {
+	t.Parallel()
+	errConn := new(connMock)
+	errConn.writerMock.err = errMock
+	cli1 := server.NewClient(errConn)
+	cli2 := server.NewClient(new(connMock))
+	session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+	history := server.NewMemoryHistory()
+	session.History = history
+	session.Abort(com.CodeRoundTimeout, "")
+	if cli1.Session != nil {
+		t.Fatalf("Expected cli1 session to be nil, but was %v!", cli1.Session)
+	}
+	if cli2.Session != nil {
+		t.Fatalf("Expected cli2 session to be nil, but was %v!", cli2.Session)
+	}
+	matches, _ := history.Matches()
+	if len(matches) != 1 || matches[0].Aborted != com.CodeRoundTimeout || matches[0].Results != nil {
+		t.Fatalf("Expected the match to be recorded as aborted without results, but matches were %+v!", matches)
+	}
+}
+
+//nolint:funlen
+func TestSessionExpire(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	t.Run("ReturnNilWhenRoundIsNotExpired", func(t *testing.T) {
+		t.Parallel()
+		session := server.NewSession([]*server.Client{
+			server.NewClient(new(connMock)),
+			server.NewClient(new(connMock)),
+		}, server.DefaultConfig())
+		if err := session.Expire(now); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if session.Round.Number != 1 {
+			t.Fatalf("Expected round to still be 1, but was %d!", session.Round.Number)
+		}
+	})
+	t.Run("ReturnErrorWhenNeitherSelected", func(t *testing.T) {
+		t.Parallel()
+		session := server.NewSession([]*server.Client{
+			server.NewClient(new(connMock)),
+			server.NewClient(new(connMock)),
+		}, server.DefaultConfig())
+		session.Round.Deadline = now.Add(-time.Second)
+		if err := session.Expire(now); !errors.Is(err, server.ErrRoundTimeout) {
+			t.Fatalf("Expected %q in the chain %q, but did not exists!", server.ErrRoundTimeout, err)
+		}
+	})
+	t.Run("ForfeitRoundForClientWithoutSelection", func(t *testing.T) {
+		t.Parallel()
+		for _, selector := range []int{1, 2} {
+			config := server.DefaultConfig()
+			config.Format = game.BestOf(3)
+			session := server.NewSession([]*server.Client{
+				server.NewClient(new(connMock)),
+				server.NewClient(new(connMock)),
+			}, config)
+			session.Round.Deadline = now.Add(-time.Second)
+			if selector == 1 {
+				session.Round.Selections[0] = game.SelectionRock
+			} else {
+				session.Round.Selections[1] = game.SelectionRock
+			}
+			if err := session.Expire(now); err != nil {
+				t.Fatalf("Expected no error, but %q was returned!", err)
+			}
+			if selector == 1 && (session.Scores[0] != 1 || session.Scores[1] != 0) {
+				t.Fatalf("Expected score to be 1-0, but was %d-%d!", session.Scores[0], session.Scores[1])
+			}
+			if selector == 2 && (session.Scores[0] != 0 || session.Scores[1] != 1) {
+				t.Fatalf("Expected score to be 0-1, but was %d-%d!", session.Scores[0], session.Scores[1])
+			}
+			if session.Round.Number != 2 {
+				t.Fatalf("Expected round to be 2, but was %d!", session.Round.Number)
+			}
+		}
+	})
+	t.Run("ReturnErrorWhenResultWriteFails", func(t *testing.T) {
+		t.Parallel()
+		errConn := new(connMock)
+		errConn.writerMock.err = errMock
+		session := server.NewSession([]*server.Client{
+			server.NewClient(errConn),
+			server.NewClient(new(connMock)),
+		}, server.DefaultConfig())
+		session.Round.Deadline = now.Add(-time.Second)
+		session.Round.Selections[1] = game.SelectionRock
+		if err := session.Expire(now); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+}
+
+func TestSessionOffer(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenOpponentWriteFails", func(t *testing.T) {
+		t.Parallel()
+		errConn := new(connMock)
+		errConn.writerMock.err = errMock
+		cli1 := server.NewClient(new(connMock))
+		cli2 := server.NewClient(errConn)
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+		if err := session.Offer(cli1); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("MarkOfferWhenOpponentHasNotOffered", func(t *testing.T) {
+		t.Parallel()
+		cli1 := server.NewClient(new(connMock))
+		cli2 := server.NewClient(new(connMock))
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+		session.Scores[0] = 1
+		if err := session.Offer(cli2); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if !session.Offered(cli2) || session.Offered(cli1) {
+			t.Fatal("Expected only cli2 to have offered a rematch, but it was not!")
+		}
+		if session.Scores[0] != 1 {
+			t.Fatalf("Expected score to be kept until the rematch is accepted, but was %d!", session.Scores[0])
+		}
+	})
+	t.Run("StartRematchWhenBothHaveOffered", func(t *testing.T) {
+		t.Parallel()
+		cli1 := server.NewClient(new(connMock))
+		cli2 := server.NewClient(new(connMock))
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+		session.Scores[0] = 1
+		session.Round = server.NewRound(3, []bool{true, true}, time.Time{})
+		if err := session.Offer(cli1); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if err := session.Offer(cli2); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if session.Offered(cli1) || session.Offered(cli2) {
+			t.Fatal("Expected rematch offers to be reset, but they were not!")
+		}
+		if session.Scores[0] != 0 || session.Scores[1] != 0 {
+			t.Fatalf("Expected scores to be reset, but were %d-%d!", session.Scores[0], session.Scores[1])
+		}
+		if session.Round.Number != 1 {
+			t.Fatalf("Expected round to be reset to 1, but was %d!", session.Round.Number)
+		}
+	})
+}
+
+func TestSessionAccept(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenStartFails", func(t *testing.T) {
+		t.Parallel()
+		errConn := new(connMock)
+		errConn.writerMock.err = errMock
+		cli1 := server.NewClient(errConn)
+		cli2 := server.NewClient(new(connMock))
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+		session.Rematches[1] = true
+		if err := session.Accept(cli1); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ResetMatchWhenStartSucceeds", func(t *testing.T) {
+		t.Parallel()
+		cli1 := server.NewClient(new(connMock))
+		cli2 := server.NewClient(new(connMock))
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+		session.Scores[1] = 1
+		session.Rematches[0] = true
+		if err := session.Accept(cli2); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if session.Scores[1] != 0 || session.Offered(cli1) || len(session.Record.Rounds) != 0 {
+			t.Fatal("Expected match to be reset, but it was not!")
+		}
+	})
+	t.Run("WaitForOtherPlayersWhenManyPlayers", func(t *testing.T) {
+		t.Parallel()
+		cli1 := server.NewClient(new(connMock))
+		cli2 := server.NewClient(new(connMock))
+		cli3 := server.NewClient(new(connMock))
+		session := server.NewSession([]*server.Client{cli1, cli2, cli3}, server.DefaultConfig())
+		session.Scores[0] = 1
+		session.Rematches[0] = true
+		if !session.Proposed(cli2) || session.Proposed(cli1) {
+			t.Fatal("Expected rematch to be proposed only for the opponents of cli1, but it was not!")
+		}
+		if err := session.Accept(cli2); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if session.Scores[0] != 1 || !session.Offered(cli2) {
+			t.Fatal("Expected match to wait for cli3, but it did not!")
+		}
+		if err := session.Accept(cli3); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if session.Scores[0] != 0 || session.Offered(cli1) {
+			t.Fatal("Expected match to be reset, but it was not!")
+		}
+	})
+}
+
+func TestSessionOpponents(t *testing.T) {
+	t.Parallel()
+	cli1 := server.NewClient(new(connMock))
+	cli2 := server.NewClient(new(connMock))
+	cli3 := server.NewClient(new(connMock))
+	session := server.NewSession([]*server.Client{cli1, cli2, cli3}, server.DefaultConfig())
+	if opponents := session.Opponents(cli1); !reflect.DeepEqual(opponents, []*server.Client{cli2, cli3}) {
+		t.Fatalf("Expected opponents of cli1 to be cli2 and cli3, but were %v!", opponents)
+	}
+	if opponents := session.Opponents(cli2); !reflect.DeepEqual(opponents, []*server.Client{cli1, cli3}) {
+		t.Fatalf("Expected opponents of cli2 to be cli1 and cli3, but were %v!", opponents)
+	}
+}
+
+func TestSessionResume(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenWriteFails", func(t *testing.T) {
+		t.Parallel()
+		errConn := new(connMock)
+		errConn.writerMock.err = errMock
+		cli1 := server.NewClient(errConn)
+		cli2 := server.NewClient(new(connMock))
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+		if err := session.Resume(cli1); !errors.Is(err, errMock) {
+			t.Fatalf("Expected %q in the chain %q, but did not exists!", errMock, err)
+		}
+	})
+	t.Run("ReplayStateFromClientPerspective", func(t *testing.T) {
+		t.Parallel()
+		buffer := new(bytes.Buffer)
+		cli1 := server.NewClient(new(connMock))
+		cli2 := server.NewClient(struct {
+			*bytes.Buffer
+			*closerMock
+		}{buffer, new(closerMock)})
+		cli1.Name = "donald"
+		cli2.ResumeToken = "token"
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+		session.Round = server.NewRound(2, []bool{true, true}, time.Time{})
+		session.Round.Selections[1] = game.SelectionPaper
+		session.Scores[0] = 1
+		if err := session.Resume(cli2); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		resumed, err := com.ReadMessage[com.ResumedContent](buffer)
+		if err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if resumed.Start.OpponentName != "donald" || resumed.Start.ResumeToken != "token" {
+			t.Fatalf("Expected START replay for cli2, but was %+v!", resumed.Start)
+		}
+		if resumed.Round != 2 || resumed.Selection != game.SelectionPaper {
+			t.Fatalf("Expected round 2 with paper, but was %d with %q!", resumed.Round, resumed.Selection)
+		}
+		if resumed.Score != 0 || resumed.OpponentScore != 1 {
+			t.Fatalf("Expected score 0-1, but was %d-%d!", resumed.Score, resumed.OpponentScore)
+		}
+	})
+	t.Run("AskToRevealWhenSealedRoundIsResumed", func(t *testing.T) {
+		t.Parallel()
+		buffer := new(bytes.Buffer)
+		cli1 := server.NewClient(new(connMock))
+		cli2 := server.NewClient(struct {
+			*bytes.Buffer
+			*closerMock
+		}{buffer, new(closerMock)})
+		cli1.Name = "donald"
+		cli2.Capabilities = []com.Capability{com.CapabilityCommitReveal}
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+		session.Round.Selections[0] = game.SelectionRock
+		session.Round.Commitments[1] = com.Commit(game.SelectionPaper, "nonce")
+		if err := session.Resume(cli2); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		resumed, err := com.ReadMessage[com.ResumedContent](buffer)
+		if err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if !resumed.Start.CommitReveal || resumed.Commitment != session.Round.Commitments[1] {
+			t.Fatalf("Expected commit-reveal replay with the commitment, but was %+v!", resumed)
+		}
+		if _, err := com.ReadMessage[com.CommittedContent](buffer); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+	})
+}
+
+func TestSessionWatch(t *testing.T) {
+	t.Parallel()
+	t.Run("SendEventsToSpectator", func(t *testing.T) {
+		t.Parallel()
+		buffer := new(bytes.Buffer)
+		cli1 := server.NewClient(new(connMock))
+		cli2 := server.NewClient(new(connMock))
+		spectator := server.NewClient(struct {
+			*bytes.Buffer
+			*closerMock
+		}{buffer, new(closerMock)})
+		cli1.Name, cli2.Name = "donald", "mickey"
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+		session.ID = 7
+		session.Watch(spectator)
+		if spectator.Spectating != session || spectator.State != server.StateSpectating {
+			t.Fatalf("Expected spectator to watch the session, but was %q with %v!", spectator.State, spectator.Spectating)
+		}
+		if err := session.Select(cli1, game.SelectionRock); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if err := session.Select(cli2, game.SelectionPaper); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		session.Close()
+
+		start, err := com.ReadMessage[com.SpectateStartContent](buffer)
+		if err != nil || start.Session.ID != 7 ||
+			!reflect.DeepEqual(start.Session.Players, []string{"donald", "mickey"}) {
+			t.Fatalf("Expected SPECTATE_START of session 7, but was %+v (%v)!", start, err)
+		}
+		round, err := com.ReadMessage[com.SpectateRoundContent](buffer)
+		if err != nil || round.Selections[0] != game.SelectionRock || round.Selections[1] != game.SelectionPaper {
+			t.Fatalf("Expected SPECTATE_ROUND with rock and paper, but was %+v (%v)!", round, err)
+		}
+		end, err := com.ReadMessage[com.SpectateEndContent](buffer)
+		if err != nil || end.Results[0] != game.ResultLose || end.Scores[1] != 1 {
+			t.Fatalf("Expected SPECTATE_END with a lose, but was %+v (%v)!", end, err)
+		}
+		var serverErr *com.ErrorContent
+		if _, err := com.ReadMessage[com.SpectateEndContent](buffer); !errors.As(err, &serverErr) ||
+			serverErr.Code != com.CodeSessionClosed {
+			t.Fatalf("Expected %s error after closing, but was %v!", com.CodeSessionClosed, err)
+		}
+		if spectator.Spectating != nil || spectator.State != server.StateConnected {
+			t.Fatalf("Expected spectator to be released, but was %q with %v!", spectator.State, spectator.Spectating)
+		}
+	})
+	t.Run("IgnoreSpectatorWriteFailures", func(t *testing.T) {
+		t.Parallel()
+		errConn := new(connMock)
+		errConn.writerMock.err = errMock
+		cli1 := server.NewClient(new(connMock))
+		cli2 := server.NewClient(new(connMock))
+		session := server.NewSession([]*server.Client{cli1, cli2}, server.DefaultConfig())
+		session.Watch(server.NewClient(errConn))
+		if err := session.Start(); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if err := session.Select(cli1, game.SelectionRock); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		if err := session.Select(cli2, game.SelectionPaper); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+		session.Close()
+	})
+}
+
+func TestSessionUnwatch(t *testing.T) {
+	t.Parallel()
+	session := server.NewSession([]*server.Client{
+		server.NewClient(new(connMock)),
+		server.NewClient(new(connMock)),
+	}, server.DefaultConfig())
+	spectator1 := server.NewClient(new(connMock))
+	spectator2 := server.NewClient(new(connMock))
+	session.Watch(spectator1)
+	session.Watch(spectator2)
+	session.Unwatch(spectator1)
+	if len(session.Spectators) != 1 || session.Spectators[0] != spectator2 {
+		t.Fatalf("Expected only the second spectator to remain, but were %v!", session.Spectators)
+	}
+	if spectator1.Spectating != nil || spectator1.State != server.StateConnected {
+		t.Fatalf("Expected spectator to be released, but was %q with %v!", spectator1.State, spectator1.Spectating)
+	}
+}
+
+func TestSessionClosed(t *testing.T) {
+	t.Parallel()
+	session := server.NewSession([]*server.Client{
+		server.NewClient(new(connMock)),
+		server.NewClient(new(connMock)),
+	}, server.DefaultConfig())
+	if session.Closed() {
+		t.Fatal("Expected new session to be open, but it was closed!")
+	}
+	session.Close()
+	if !session.Closed() {
+		t.Fatal("Expected session to be closed, but it was open!")
+	}
 }
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/toivjon/go-rps/internal/game"
+)
+
+// ErrUnknownStrategy is an error occurring when a bot strategy cannot be found with the given name.
+var ErrUnknownStrategy = errors.New("the provided value is not a known bot strategy")
+
+// Strategy decides the selections of a bot player. The history contains the selections of the opponent in the
+// rounds played so far in the order they were played.
+type Strategy interface {
+	fmt.Stringer
+	Select(rules game.RuleSet, history []game.Selection) game.Selection
+}
+
+// Strategies returns the built-in bot strategies.
+func Strategies() []Strategy {
+	return []Strategy{RandomStrategy{}, FrequencyStrategy{}, MarkovStrategy{}, BeatLastStrategy{}}
+}
+
+// LookupStrategy returns the built-in bot strategy with the given name.
+func LookupStrategy(name string) (Strategy, error) {
+	names := make([]string, 0, len(Strategies()))
+	for _, strategy := range Strategies() {
+		if strategy.String() == name {
+			return strategy, nil
+		}
+		names = append(names, strategy.String())
+	}
+	return nil, fmt.Errorf("%w: %q (supported %s)", ErrUnknownStrategy, name, strings.Join(names, ", "))
+}
+
+// RandomStrategy selects uniformly at random without looking at the history.
+type RandomStrategy struct{}
+
+// Select returns a random selection from the rule set.
+func (RandomStrategy) Select(rules game.RuleSet, history []game.Selection) game.Selection {
+	return game.RandomSelection(rules.Selections())
+}
+
+// String returns a string representing the strategy.
+func (RandomStrategy) String() string {
+	return "random"
+}
+
+// FrequencyStrategy expects the opponent to repeat its most frequent selection and selects what beats it.
+type FrequencyStrategy struct{}
+
+// Select returns a selection which beats the most frequent selection of the opponent.
+func (FrequencyStrategy) Select(rules game.RuleSet, history []game.Selection) game.Selection {
+	return rules.Counter(rules.MostFrequent(history))
+}
+
+// String returns a string representing the strategy.
+func (FrequencyStrategy) String() string {
+	return "frequency"
+}
+
+// MarkovStrategy expects the opponent to follow its previous selection with the selection it has most often
+// followed it with before and selects what beats it. The most frequent selection is expected when the previous
+// selection has not been followed yet.
+type MarkovStrategy struct{}
+
+// Select returns a selection which beats the most likely next selection of the opponent.
+func (MarkovStrategy) Select(rules game.RuleSet, history []game.Selection) game.Selection {
+	if len(history) == 0 {
+		return game.RandomSelection(rules.Selections())
+	}
+	previous := history[len(history)-1]
+	followers := make([]game.Selection, 0, len(history))
+	for i := 1; i < len(history); i++ {
+		if history[i-1] == previous {
+			followers = append(followers, history[i])
+		}
+	}
+	if len(followers) == 0 {
+		followers = history
+	}
+	return rules.Counter(rules.MostFrequent(followers))
+}
+
+// String returns a string representing the strategy.
+func (MarkovStrategy) String() string {
+	return "markov"
+}
+
+// BeatLastStrategy expects the opponent to repeat its previous selection and selects what beats it.
+type BeatLastStrategy struct{}
+
+// Select returns a selection which beats the previous selection of the opponent.
+func (BeatLastStrategy) Select(rules game.RuleSet, history []game.Selection) game.Selection {
+	if len(history) == 0 {
+		return game.RandomSelection(rules.Selections())
+	}
+	return rules.Counter(history[len(history)-1])
+}
+
+// String returns a string representing the strategy.
+func (BeatLastStrategy) String() string {
+	return "beat-last"
+}
@@ -0,0 +1,74 @@
+package server_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/toivjon/go-rps/internal/game"
+	"github.com/toivjon/go-rps/internal/server"
+)
+
+func TestLookupStrategy(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnStrategyWhenNameIsKnown", func(t *testing.T) {
+		t.Parallel()
+		for _, expected := range server.Strategies() {
+			strategy, err := server.LookupStrategy(expected.String())
+			if err != nil {
+				t.Fatalf("Expected nil error, but %q was returned!", err)
+			}
+			if strategy != expected {
+				t.Fatalf("Expected strategy %s, but was %s!", expected, strategy)
+			}
+		}
+	})
+	t.Run("ReturnErrorWhenNameIsUnknown", func(t *testing.T) {
+		t.Parallel()
+		if _, err := server.LookupStrategy("cheater"); !errors.Is(err, server.ErrUnknownStrategy) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", server.ErrUnknownStrategy, err)
+		}
+	})
+}
+
+func TestStrategySelect(t *testing.T) {
+	t.Parallel()
+	history := []game.Selection{
+		game.SelectionRock,
+		game.SelectionPaper,
+		game.SelectionRock,
+		game.SelectionPaper,
+		game.SelectionScissors,
+		game.SelectionScissors,
+		game.SelectionRock,
+	}
+	for _, test := range []struct {
+		strategy server.Strategy
+		history  []game.Selection
+		expected game.Selection
+	}{
+		// Rock is the most frequent selection, so paper beats it.
+		{strategy: server.FrequencyStrategy{}, history: history, expected: game.SelectionPaper},
+		// Rock has been followed by paper the most, so scissors beats it.
+		{strategy: server.MarkovStrategy{}, history: history, expected: game.SelectionScissors},
+		// Paper has not been followed yet, so the first of the most frequent selections is expected.
+		{strategy: server.MarkovStrategy{}, history: history[:2], expected: game.SelectionPaper},
+		// Rock was the last selection, so paper beats it.
+		{strategy: server.BeatLastStrategy{}, history: history, expected: game.SelectionPaper},
+	} {
+		if selection := test.strategy.Select(game.Classic(), test.history); selection != test.expected {
+			t.Fatalf("Expected %s strategy to select %q, but was %q!", test.strategy, test.expected, selection)
+		}
+	}
+}
+
+func TestStrategySelectWithoutHistory(t *testing.T) {
+	t.Parallel()
+	rules := game.RPSLS()
+	for _, strategy := range server.Strategies() {
+		for _, history := range [][]game.Selection{nil, {game.SelectionFire}} {
+			if err := rules.Validate(strategy.Select(rules, history)); err != nil {
+				t.Fatalf("Expected %s strategy to select from the rule set, but validation failed: %s", strategy, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	// throttleDelay specifies how long the authentication is refused after the first failed attempt. The delay
+	// doubles with every consecutive failure up to the throttleMaxDelay.
+	throttleDelay    = time.Second
+	throttleMaxDelay = 5 * time.Minute
+	// throttleWindow specifies how long the failed attempts are remembered after the latest failure.
+	throttleWindow = 15 * time.Minute
+)
+
+// Throttle refuses the authentication attempts for a while after failed attempts, so that the passwords cannot
+// be guessed by trying them rapidly. The attempts are tracked by keys such as the account name and the address
+// of the connection, and each key is refused for an exponentially growing delay after its consecutive
+// failures.
+type Throttle struct {
+	failures map[string]failure
+}
+
+// failure describes the consecutive failed attempts of a key and the time of the latest failure.
+type failure struct {
+	count  int
+	latest time.Time
+}
+
+// NewThrottle builds a new throttle without any failed attempts.
+func NewThrottle() *Throttle {
+	return &Throttle{failures: make(map[string]failure)}
+}
+
+// Wait returns how long the attempts with the given keys are still refused at the given time. Returns zero
+// when the attempt is allowed. Empty keys are ignored.
+func (t *Throttle) Wait(now time.Time, keys ...string) time.Duration {
+	wait := time.Duration(0)
+	for _, key := range keys {
+		failure, ok := t.failures[key]
+		if !ok || key == "" {
+			continue
+		}
+		if remaining := failure.latest.Add(failure.delay()).Sub(now); remaining > wait {
+			wait = remaining
+		}
+	}
+	return wait
+}
+
+// Fail marks a failed attempt with the given keys at the given time. Empty keys are ignored.
+func (t *Throttle) Fail(now time.Time, keys ...string) {
+	for _, key := range keys {
+		if key != "" {
+			t.failures[key] = failure{count: t.failures[key].count + 1, latest: now}
+		}
+	}
+}
+
+// Reset forgets the failed attempts with the given keys.
+func (t *Throttle) Reset(keys ...string) {
+	for _, key := range keys {
+		delete(t.failures, key)
+	}
+}
+
+// Prune forgets the failed attempts whose latest failure is older than the throttle window.
+func (t *Throttle) Prune(now time.Time) {
+	for key, failure := range t.failures {
+		if now.Sub(failure.latest) > throttleWindow {
+			delete(t.failures, key)
+		}
+	}
+}
+
+// delay returns how long the attempts are refused after the latest failure.
+func (f failure) delay() time.Duration {
+	delay := throttleDelay
+	for i := 1; i < f.count && delay < throttleMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > throttleMaxDelay {
+		return throttleMaxDelay
+	}
+	return delay
+}
+
+// remoteHost returns the host of the remote address of the connection or an empty string when the address is
+// not known. Connections which wrap another connection are unwrapped with their NetConn method.
+func remoteHost(conn io.ReadWriter) string {
+	for {
+		switch wrapper := conn.(type) {
+		case interface{ RemoteAddr() net.Addr }:
+			address := wrapper.RemoteAddr().String()
+			if host, _, err := net.SplitHostPort(address); err == nil {
+				return host
+			}
+			return address
+		case interface{ NetConn() net.Conn }:
+			conn = wrapper.NetConn()
+		default:
+			return ""
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package server_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/toivjon/go-rps/internal/server"
+)
+
+func TestThrottleWait(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnZeroWhenNoAttemptHasFailed", func(t *testing.T) {
+		t.Parallel()
+		throttle := server.NewThrottle()
+		if wait := throttle.Wait(time.Now(), "name:donald"); wait != 0 {
+			t.Fatalf("Expected no wait, but was %s!", wait)
+		}
+	})
+	t.Run("DoubleDelayWithConsecutiveFailures", func(t *testing.T) {
+		t.Parallel()
+		throttle := server.NewThrottle()
+		now := time.Now()
+		for i, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+			throttle.Fail(now, "name:donald", "")
+			if wait := throttle.Wait(now, "address:127.0.0.1", "name:donald"); wait != expected {
+				t.Fatalf("Expected wait after %d failures to be %s, but was %s!", i+1, expected, wait)
+			}
+		}
+		if wait := throttle.Wait(now.Add(4*time.Second), "name:donald"); wait != 0 {
+			t.Fatalf("Expected no wait after the delay, but was %s!", wait)
+		}
+	})
+	t.Run("LimitDelayToMaximum", func(t *testing.T) {
+		t.Parallel()
+		throttle := server.NewThrottle()
+		now := time.Now()
+		for i := 0; i < 20; i++ {
+			throttle.Fail(now, "name:donald")
+		}
+		if wait := throttle.Wait(now, "name:donald"); wait != 5*time.Minute {
+			t.Fatalf("Expected wait to be limited to 5m0s, but was %s!", wait)
+		}
+	})
+}
+
+func TestThrottleReset(t *testing.T) {
+	t.Parallel()
+	throttle := server.NewThrottle()
+	now := time.Now()
+	throttle.Fail(now, "name:donald")
+	throttle.Reset("name:donald")
+	if wait := throttle.Wait(now, "name:donald"); wait != 0 {
+		t.Fatalf("Expected no wait after reset, but was %s!", wait)
+	}
+}
+
+func TestThrottlePrune(t *testing.T) {
+	t.Parallel()
+	throttle := server.NewThrottle()
+	now := time.Now()
+	throttle.Fail(now, "name:donald")
+	throttle.Fail(now, "name:donald")
+	throttle.Prune(now.Add(time.Minute))
+	throttle.Fail(now.Add(time.Minute), "name:donald")
+	if wait := throttle.Wait(now.Add(time.Minute), "name:donald"); wait != 4*time.Second {
+		t.Fatalf("Expected recent failures to be kept, but wait was %s!", wait)
+	}
+	throttle.Prune(now.Add(time.Hour))
+	throttle.Fail(now.Add(time.Hour), "name:donald")
+	if wait := throttle.Wait(now.Add(time.Hour), "name:donald"); wait != time.Second {
+		t.Fatalf("Expected old failures to be forgotten, but wait was %s!", wait)
+	}
+}
@@ -0,0 +1,333 @@
+package server
+
+import (
+	"log"
+
+	"github.com/toivjon/go-rps/internal/com"
+)
+
+// Tournament represents a single-elimination tournament. The players register until the tournament is full,
+// after which they are seeded into a bracket in the registration order. The winner of each pairing advances
+// to the next bracket round until a single champion remains. The top seeds get a bye in the first bracket
+// round when the number of players is not a power of two. The players knocked out of the tournament are
+// released from it and kept as eliminated until they have been notified about the elimination.
+type Tournament struct {
+	Size       int
+	Players    []*Client
+	Rounds     [][]*Pairing
+	Champion   *Client
+	withdrawn  map[*Client]bool
+	eliminated []*Client
+}
+
+// Pairing represents a single match of a tournament bracket round. A nil player marks a bye. A decided pairing
+// without a winner is a bye in the next bracket round, which happens when both players have withdrawn.
+type Pairing struct {
+	Players []*Client
+	Session *Session
+	Winner  *Client
+	Decided bool
+}
+
+// NewTournament builds a new tournament which starts once the given number of players have registered.
+func NewTournament(size int) *Tournament {
+	return &Tournament{
+		Size:       size,
+		Players:    nil,
+		Rounds:     nil,
+		Champion:   nil,
+		withdrawn:  make(map[*Client]bool),
+		eliminated: nil,
+	}
+}
+
+// Register adds the client to the tournament and marks the client as a tournament participant.
+func (t *Tournament) Register(cli *Client) {
+	cli.Tournament = t
+	cli.State = StateTournament
+	t.Players = append(t.Players, cli)
+}
+
+// Withdraw removes the client from the tournament. A client which withdraws before the tournament has started
+// loses its registration, while a client which withdraws later loses its remaining pairings by a walkover.
+func (t *Tournament) Withdraw(cli *Client) {
+	cli.Tournament = nil
+	if t.Started() {
+		t.withdrawn[cli] = true
+		return
+	}
+	for i, player := range t.Players {
+		if player == cli {
+			t.Players = append(t.Players[:i], t.Players[i+1:]...)
+			return
+		}
+	}
+}
+
+// Full checks whether the tournament has as many players as it was sized for.
+func (t *Tournament) Full() bool {
+	return len(t.Players) >= t.Size
+}
+
+// Started checks whether the players have been seeded into the bracket.
+func (t *Tournament) Started() bool {
+	return len(t.Rounds) > 0
+}
+
+// Ended checks whether the final of the tournament has been decided. The tournament ends without a champion
+// when both finalists have withdrawn.
+func (t *Tournament) Ended() bool {
+	if !t.Started() {
+		return false
+	}
+	round := t.Rounds[len(t.Rounds)-1]
+	return len(round) == 1 && round[0].Decided
+}
+
+// Start seeds the registered players into the first bracket round where the first seed plays against the
+// last seed, the second seed against the second last seed and so on. The pairings are ordered so that the
+// higher seeds meet only in the later bracket rounds e.g. the top two seeds can only meet in the final.
+func (t *Tournament) Start() {
+	size := 1
+	for size < len(t.Players) {
+		size *= 2
+	}
+	order := bracketOrder(size)
+	round := make([]*Pairing, size/2)
+	for i := range round {
+		players := []*Client{t.seed(order[2*i]), t.seed(order[2*i+1])}
+		round[i] = &Pairing{Players: players, Session: nil, Winner: nil, Decided: false}
+	}
+	t.Rounds = [][]*Pairing{round}
+	log.Printf("Tournament %#p started (players: %d, pairings: %d)", t, len(t.Players), len(round))
+}
+
+// bracketOrder returns the seeds of a bracket with the given power of two size in the order of the first
+// bracket round pairings e.g. 0, 7, 3, 4, 2, 5, 1, 6 for eight seeds. The order is built by doubling the
+// bracket where each seed gets paired with its counterpart in the doubled bracket, so the adjacent pairings
+// feed the same pairing of the next bracket round. Each pairing has the higher seed first.
+func bracketOrder(size int) []int {
+	order := []int{0}
+	for length := 2; length <= size; length *= 2 {
+		next := make([]int, 0, length)
+		for i, seed := range order {
+			if i%2 == 0 {
+				next = append(next, seed, length-1-seed)
+			} else {
+				next = append(next, length-1-seed, seed)
+			}
+		}
+		order = next
+	}
+	for i := 0; i+1 < len(order); i += 2 {
+		if order[i] > order[i+1] {
+			order[i], order[i+1] = order[i+1], order[i]
+		}
+	}
+	return order
+}
+
+// seeded returns the given players of a pairing with the higher seed first. A bye is always the second player.
+func (t *Tournament) seeded(first, second *Client) []*Client {
+	if first == nil || (second != nil && t.seedOf(second) < t.seedOf(first)) {
+		return []*Client{second, first}
+	}
+	return []*Client{first, second}
+}
+
+// seedOf returns the seed of the given player, which is the index of the player in the registration order.
+func (t *Tournament) seedOf(cli *Client) int {
+	for i, player := range t.Players {
+		if player == cli {
+			return i
+		}
+	}
+	return len(t.Players)
+}
+
+// seed returns the player with the given seed or nil when the seed is a bye.
+func (t *Tournament) seed(index int) *Client {
+	if index < len(t.Players) {
+		return t.Players[index]
+	}
+	return nil
+}
+
+// Advance decides the winners of the pairings in the ongoing bracket round and closes their decided sessions.
+// The next bracket round is seeded from the winners once every pairing of the ongoing round has a winner.
+// Returns true if the bracket has changed.
+func (t *Tournament) Advance() bool {
+	changed := false
+	for t.Started() && !t.Ended() {
+		round := t.Rounds[len(t.Rounds)-1]
+		decided := true
+		for _, pairing := range round {
+			if pairing.Decided {
+				continue
+			}
+			if pairing.Winner, pairing.Decided = t.winner(pairing); !pairing.Decided {
+				decided = false
+				continue
+			}
+			changed = true
+			t.eliminate(pairing)
+			if pairing.Session != nil && !pairing.Session.Closed() {
+				pairing.Session.Close()
+			}
+		}
+		if !decided {
+			break
+		}
+		if len(round) == 1 {
+			t.Champion = round[0].Winner
+			log.Printf("Tournament %#p ended (champion: %s)", t, nameOf(t.Champion))
+			break
+		}
+		next := make([]*Pairing, len(round)/2)
+		for i := range next {
+			players := t.seeded(round[2*i].Winner, round[2*i+1].Winner)
+			next[i] = &Pairing{Players: players, Session: nil, Winner: nil, Decided: false}
+		}
+		t.Rounds = append(t.Rounds, next)
+	}
+	return changed
+}
+
+// winner returns the player who advances from the pairing and whether the pairing has been decided. A bye or
+// a withdrawn opponent advances the other player without playing, and nobody advances when both players are
+// byes or have withdrawn. A decided match advances the player with the most round wins, and a decided match
+// with equal wins advances the higher seed, who is always the first player of the pairing. A session which
+// was aborted before the match was decided is not replayed, as the players who failed to play it would most
+// likely fail again, so the higher seed advances as well.
+func (t *Tournament) winner(pairing *Pairing) (*Client, bool) {
+	first, second := t.active(pairing.Players[0]), t.active(pairing.Players[1])
+	switch {
+	case first == nil:
+		return second, true
+	case second == nil:
+		return first, true
+	case pairing.Session == nil:
+		return nil, false
+	case pairing.Session.Ended():
+		scores := pairing.Session.Scores
+		if scores[1] > scores[0] {
+			return second, true
+		}
+		if scores[1] == scores[0] {
+			log.Printf("Tournament %#p match %s ended in a tie, higher seed advances.", t, pairing.Session)
+		}
+		return first, true
+	case pairing.Session.Closed():
+		log.Printf("Tournament %#p match %s was aborted, higher seed advances.", t, pairing.Session)
+		return first, true
+	}
+	return nil, false
+}
+
+// active returns the player unless the player is a bye or has withdrawn from the tournament.
+func (t *Tournament) active(cli *Client) *Client {
+	if cli == nil || t.withdrawn[cli] {
+		return nil
+	}
+	return cli
+}
+
+// eliminate releases the players of the decided pairing who did not advance from the tournament, so that they
+// may play other games while the tournament goes on. The players return to the lobby once their tournament
+// session is closed.
+func (t *Tournament) eliminate(pairing *Pairing) {
+	for _, player := range pairing.Players {
+		if t.active(player) == nil || player == pairing.Winner {
+			continue
+		}
+		player.Tournament = nil
+		if player.Session == nil || player.Session.Closed() {
+			player.State = StateLobby
+		}
+		t.eliminated = append(t.eliminated, player)
+	}
+}
+
+// TakeEliminated returns the players who have been knocked out of the tournament since the previous call.
+func (t *Tournament) TakeEliminated() []*Client {
+	eliminated := t.eliminated
+	t.eliminated = nil
+	return eliminated
+}
+
+// Ready returns the pairings of the ongoing bracket round which wait for their session to be started.
+func (t *Tournament) Ready() []*Pairing {
+	if !t.Started() || t.Ended() {
+		return nil
+	}
+	ready := make([]*Pairing, 0)
+	for _, pairing := range t.Rounds[len(t.Rounds)-1] {
+		if !pairing.Decided && pairing.Session == nil {
+			ready = append(ready, pairing)
+		}
+	}
+	return ready
+}
+
+// Participants returns the players which have neither withdrawn nor been knocked out of the tournament.
+func (t *Tournament) Participants() []*Client {
+	participants := make([]*Client, 0, len(t.Players))
+	for _, player := range t.Players {
+		if player.Tournament == t {
+			participants = append(participants, player)
+		}
+	}
+	return participants
+}
+
+// Finish returns the participants of the decided tournament to the lobby.
+func (t *Tournament) Finish() {
+	for _, player := range t.Participants() {
+		player.Tournament = nil
+		if player.Session == nil {
+			player.State = StateLobby
+		}
+	}
+}
+
+// Bracket describes the state of the tournament bracket for the target client.
+func (t *Tournament) Bracket(cli *Client) com.BracketContent {
+	content := com.BracketContent{
+		Players:    make([]string, len(t.Players)),
+		Rounds:     make([][]com.BracketPairing, len(t.Rounds)),
+		Opponent:   "",
+		Eliminated: t.withdrawn[cli],
+		Champion:   nameOf(t.Champion),
+	}
+	for i, player := range t.Players {
+		content.Players[i] = player.Name
+	}
+	for i, round := range t.Rounds {
+		content.Rounds[i] = make([]com.BracketPairing, len(round))
+		for j, pairing := range round {
+			first, second := pairing.Players[0], pairing.Players[1]
+			content.Rounds[i][j] = com.BracketPairing{
+				Players: []string{nameOf(first), nameOf(second)},
+				Winner:  nameOf(pairing.Winner),
+			}
+			switch {
+			case cli != first && cli != second:
+			case pairing.Decided && pairing.Winner != cli:
+				content.Eliminated = true
+			case !pairing.Decided && cli == first:
+				content.Opponent = nameOf(second)
+			case !pairing.Decided && cli == second:
+				content.Opponent = nameOf(first)
+			}
+		}
+	}
+	return content
+}
+
+// nameOf returns the name of the client or an empty string when there is no client.
+func nameOf(cli *Client) string {
+	if cli == nil {
+		return ""
+	}
+	return cli.Name
+}
@@ -0,0 +1,261 @@
+package server_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/toivjon/go-rps/internal/com"
+	"github.com/toivjon/go-rps/internal/game"
+	"github.com/toivjon/go-rps/internal/server"
+)
+
+// newTournament builds a tournament with the given players registered in the given order.
+func newTournament(names ...string) (*server.Tournament, []*server.Client) {
+	tournament := server.NewTournament(len(names))
+	players := make([]*server.Client, len(names))
+	for i, name := range names {
+		players[i] = server.NewClient(new(connMock))
+		players[i].Name = name
+		tournament.Register(players[i])
+	}
+	return tournament, players
+}
+
+// decide plays the session of the pairing so that the given player wins the match.
+func decide(t *testing.T, pairing *server.Pairing, winner *server.Client) {
+	t.Helper()
+	pairing.Session = server.NewSession(pairing.Players, server.DefaultConfig())
+	for _, player := range pairing.Players {
+		selection := game.SelectionScissors
+		if player == winner {
+			selection = game.SelectionRock
+		}
+		if err := pairing.Session.Select(player, selection); err != nil {
+			t.Fatalf("Expected no error, but %q was returned!", err)
+		}
+	}
+}
+
+func TestTournamentRegister(t *testing.T) {
+	t.Parallel()
+	tournament, players := newTournament("donald")
+	if players[0].Tournament != tournament || players[0].State != server.StateTournament {
+		t.Fatalf("Expected client to participate in the tournament, but was %q!", players[0].State)
+	}
+	if !tournament.Full() || tournament.Started() {
+		t.Fatal("Expected tournament to be full but not started!")
+	}
+}
+
+func TestTournamentWithdraw(t *testing.T) {
+	t.Parallel()
+	t.Run("RemoveRegistrationWhenNotStarted", func(t *testing.T) {
+		t.Parallel()
+		tournament, players := newTournament("donald", "mickey")
+		tournament.Withdraw(players[0])
+		if players[0].Tournament != nil || !reflect.DeepEqual(tournament.Players, players[1:]) {
+			t.Fatalf("Expected registration to be removed, but players were %v!", tournament.Players)
+		}
+	})
+	t.Run("AdvanceOpponentWhenStarted", func(t *testing.T) {
+		t.Parallel()
+		tournament, players := newTournament("donald", "mickey")
+		tournament.Start()
+		tournament.Withdraw(players[0])
+		if !tournament.Advance() || tournament.Champion != players[1] {
+			t.Fatalf("Expected the opponent to win by a walkover, but champion was %v!", tournament.Champion)
+		}
+		if participants := tournament.Participants(); !reflect.DeepEqual(participants, players[1:]) {
+			t.Fatalf("Expected only the opponent to participate, but participants were %v!", participants)
+		}
+	})
+}
+
+func TestTournamentStart(t *testing.T) {
+	t.Parallel()
+	tournament, players := newTournament("donald", "mickey", "goofy")
+	tournament.Start()
+	if len(tournament.Rounds) != 1 || len(tournament.Rounds[0]) != 2 {
+		t.Fatalf("Expected a single round with two pairings, but was %v!", tournament.Rounds)
+	}
+	if first := tournament.Rounds[0][0].Players; first[0] != players[0] || first[1] != nil {
+		t.Fatalf("Expected the first seed to have a bye, but pairing was %v!", first)
+	}
+	if second := tournament.Rounds[0][1].Players; second[0] != players[1] || second[1] != players[2] {
+		t.Fatalf("Expected the other seeds to be paired, but pairing was %v!", second)
+	}
+}
+
+func TestTournamentStartOrdersBracket(t *testing.T) {
+	t.Parallel()
+	tournament, players := newTournament("1", "2", "3", "4", "5", "6", "7", "8")
+	tournament.Start()
+	expected := [][]*server.Client{
+		{players[0], players[7]},
+		{players[3], players[4]},
+		{players[2], players[5]},
+		{players[1], players[6]},
+	}
+	for i, pairing := range tournament.Rounds[0] {
+		if !reflect.DeepEqual(pairing.Players, expected[i]) {
+			t.Fatalf("Expected pairing %d to be %v, but was %v!", i, expected[i], pairing.Players)
+		}
+	}
+	// The higher seed wins every match, so the top two seeds must not meet before the final.
+	for !tournament.Ended() {
+		for _, pairing := range tournament.Ready() {
+			if reflect.DeepEqual(pairing.Players, players[:2]) && len(tournament.Rounds) != 3 {
+				t.Fatalf("Expected the top two seeds to meet only in the final, but met in round %d!",
+					len(tournament.Rounds))
+			}
+			decide(t, pairing, pairing.Players[0])
+		}
+		tournament.Advance()
+	}
+	if final := tournament.Rounds[2][0]; !reflect.DeepEqual(final.Players, players[:2]) {
+		t.Fatalf("Expected the final to be played by the top two seeds, but was %v!", final.Players)
+	}
+}
+
+func TestTournamentAdvance(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnFalseWhenNotStarted", func(t *testing.T) {
+		t.Parallel()
+		tournament, _ := newTournament("donald", "mickey")
+		if tournament.Advance() {
+			t.Fatal("Expected bracket to not change before the start, but it did!")
+		}
+	})
+	t.Run("AdvanceWinnersUntilChampionIsDecided", func(t *testing.T) {
+		t.Parallel()
+		tournament, players := newTournament("donald", "mickey", "goofy")
+		tournament.Start()
+		if !tournament.Advance() || tournament.Rounds[0][0].Winner != players[0] {
+			t.Fatal("Expected the bye to advance the first seed, but it did not!")
+		}
+		if ready := tournament.Ready(); len(ready) != 1 || ready[0] != tournament.Rounds[0][1] {
+			t.Fatalf("Expected the second pairing to be ready, but ready pairings were %v!", ready)
+		}
+		if tournament.Advance() {
+			t.Fatal("Expected bracket to not change without decided matches, but it did!")
+		}
+		decide(t, tournament.Rounds[0][1], players[2])
+		if !tournament.Advance() || len(tournament.Rounds) != 2 {
+			t.Fatalf("Expected the final to be seeded, but rounds were %v!", tournament.Rounds)
+		}
+		if session := tournament.Rounds[0][1].Session; !session.Closed() || players[2].State != server.StateTournament {
+			t.Fatalf("Expected the decided session to be closed, but player state was %q!", players[2].State)
+		}
+		if players[1].Tournament != nil || players[1].State != server.StateLobby {
+			t.Fatalf("Expected the knocked out player to return to the lobby, but was %q!", players[1].State)
+		}
+		if eliminated := tournament.TakeEliminated(); !reflect.DeepEqual(eliminated, players[1:2]) {
+			t.Fatalf("Expected the knocked out player to be eliminated, but eliminated were %v!", eliminated)
+		}
+		if eliminated := tournament.TakeEliminated(); len(eliminated) != 0 {
+			t.Fatalf("Expected the eliminated players to be taken once, but were %v!", eliminated)
+		}
+		final := tournament.Rounds[1][0]
+		if !reflect.DeepEqual(final.Players, []*server.Client{players[0], players[2]}) {
+			t.Fatalf("Expected the final to be played by the winners, but players were %v!", final.Players)
+		}
+		decide(t, final, players[2])
+		if !tournament.Advance() || !tournament.Ended() || tournament.Champion != players[2] {
+			t.Fatalf("Expected the winner of the final to be the champion, but was %v!", tournament.Champion)
+		}
+		if ready := tournament.Ready(); ready != nil {
+			t.Fatalf("Expected no ready pairings after the tournament, but were %v!", ready)
+		}
+	})
+	t.Run("AdvanceByeWhenBothPlayersHaveWithdrawn", func(t *testing.T) {
+		t.Parallel()
+		tournament, players := newTournament("donald", "mickey", "goofy", "pluto")
+		tournament.Start()
+		tournament.Withdraw(players[0])
+		tournament.Withdraw(players[3])
+		if !tournament.Advance() || !tournament.Rounds[0][0].Decided || tournament.Rounds[0][0].Winner != nil {
+			t.Fatalf("Expected nobody to advance from the pairing, but %v advanced!", tournament.Rounds[0][0].Winner)
+		}
+		decide(t, tournament.Rounds[0][1], players[2])
+		if !tournament.Advance() || tournament.Champion != players[2] {
+			t.Fatalf("Expected the opponent of the bye to be the champion, but was %v!", tournament.Champion)
+		}
+	})
+	t.Run("EndWithoutChampionWhenFinalistsHaveWithdrawn", func(t *testing.T) {
+		t.Parallel()
+		tournament, players := newTournament("donald", "mickey")
+		tournament.Start()
+		tournament.Withdraw(players[0])
+		tournament.Withdraw(players[1])
+		if !tournament.Advance() || !tournament.Ended() || tournament.Champion != nil {
+			t.Fatalf("Expected the tournament to end without a champion, but champion was %v!", tournament.Champion)
+		}
+	})
+	t.Run("AdvanceHigherSeedWhenMatchEndsInTie", func(t *testing.T) {
+		t.Parallel()
+		tournament, players := newTournament("donald", "mickey")
+		tournament.Start()
+		pairing := tournament.Rounds[0][0]
+		pairing.Session = server.NewSession(pairing.Players, server.DefaultConfig())
+		pairing.Session.Scores = []int{1, 1}
+		if !tournament.Advance() || tournament.Champion != players[0] {
+			t.Fatalf("Expected the higher seed to be the champion, but was %v!", tournament.Champion)
+		}
+	})
+	t.Run("AdvanceHigherSeedWhenSessionIsClosed", func(t *testing.T) {
+		t.Parallel()
+		tournament, players := newTournament("donald", "mickey")
+		tournament.Start()
+		pairing := tournament.Rounds[0][0]
+		pairing.Session = server.NewSession(pairing.Players, server.DefaultConfig())
+		pairing.Session.Close()
+		if !tournament.Advance() || tournament.Champion != players[0] {
+			t.Fatalf("Expected the higher seed to be the champion, but was %v!", tournament.Champion)
+		}
+	})
+}
+
+func TestTournamentFinish(t *testing.T) {
+	t.Parallel()
+	tournament, players := newTournament("donald", "mickey")
+	tournament.Start()
+	tournament.Withdraw(players[1])
+	tournament.Advance()
+	tournament.Finish()
+	if players[0].Tournament != nil || players[0].State != server.StateLobby {
+		t.Fatalf("Expected the champion to return to the lobby, but was %q!", players[0].State)
+	}
+}
+
+func TestTournamentBracket(t *testing.T) {
+	t.Parallel()
+	tournament, players := newTournament("donald", "mickey", "goofy")
+	if bracket := tournament.Bracket(players[0]); len(bracket.Rounds) != 0 || len(bracket.Players) != 3 {
+		t.Fatalf("Expected bracket to list the registrations, but was %+v!", bracket)
+	}
+	tournament.Start()
+	tournament.Advance()
+	decide(t, tournament.Rounds[0][1], players[1])
+	tournament.Advance()
+	expected := com.BracketContent{
+		Players: []string{"donald", "mickey", "goofy"},
+		Rounds: [][]com.BracketPairing{
+			{
+				{Players: []string{"donald", ""}, Winner: "donald"},
+				{Players: []string{"mickey", "goofy"}, Winner: "mickey"},
+			},
+			{
+				{Players: []string{"donald", "mickey"}, Winner: ""},
+			},
+		},
+		Opponent:   "donald",
+		Eliminated: false,
+		Champion:   "",
+	}
+	if bracket := tournament.Bracket(players[1]); !reflect.DeepEqual(bracket, expected) {
+		t.Fatalf("Expected bracket to be %+v, but was %+v!", expected, bracket)
+	}
+	if bracket := tournament.Bracket(players[2]); !bracket.Eliminated || bracket.Opponent != "" {
+		t.Fatalf("Expected the loser to be eliminated, but bracket was %+v!", bracket)
+	}
+}
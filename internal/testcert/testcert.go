@@ -0,0 +1,123 @@
+// Package testcert generates certificate authorities and certificates for testing the TLS connections.
+package testcert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// validity specifies how long the generated certificates are valid before and after they are generated.
+const validity = time.Hour
+
+// Files contains the paths of the PEM encoded files of a certificate and its private key.
+type Files struct {
+	Cert string
+	Key  string
+}
+
+// Authority is a self-signed certificate authority which signs the server and the client certificates of the
+// tests. The certificates and the keys are written to the directory of the authority.
+type Authority struct {
+	Files  Files
+	dir    string
+	cert   *x509.Certificate
+	key    *ecdsa.PrivateKey
+	serial int64
+}
+
+// NewAuthority generates a new certificate authority and writes its certificate and key to the given directory.
+func NewAuthority(dir string) (*Authority, error) {
+	template := newTemplate(1, "rps test ca")
+	template.IsCA = true
+	template.KeyUsage = x509.KeyUsageCertSign
+	template.BasicConstraintsValid = true
+	authority := &Authority{Files: Files{Cert: "", Key: ""}, dir: dir, cert: template, key: nil, serial: 1}
+	key, files, der, err := authority.issue("ca", template)
+	if err != nil {
+		return nil, err
+	}
+	if authority.cert, err = x509.ParseCertificate(der); err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate. %w", err)
+	}
+	authority.key, authority.Files = key, files
+	return authority, nil
+}
+
+// IssueServer generates a server certificate for the given host signed by the authority.
+func (a *Authority) IssueServer(host string) (Files, error) {
+	template := a.nextTemplate(host)
+	template.DNSNames = []string{host}
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	_, files, _, err := a.issue("server-"+host, template)
+	return files, err
+}
+
+// IssueClient generates a client certificate with the given common name signed by the authority.
+func (a *Authority) IssueClient(name string) (Files, error) {
+	template := a.nextTemplate(name)
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	_, files, _, err := a.issue("client-"+name, template)
+	return files, err
+}
+
+// nextTemplate builds a certificate template with the next serial number of the authority.
+func (a *Authority) nextTemplate(name string) *x509.Certificate {
+	a.serial++
+	return newTemplate(a.serial, name)
+}
+
+// issue generates a key and a certificate from the template and writes them to the files with the given name.
+// The certificate is signed by the authority or self-signed when the authority does not have a key yet.
+func (a *Authority) issue(name string, template *x509.Certificate) (*ecdsa.PrivateKey, Files, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, Files{Cert: "", Key: ""}, nil, fmt.Errorf("failed to generate key. %w", err)
+	}
+	parentKey := a.key
+	if parentKey == nil {
+		parentKey = key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, Files{Cert: "", Key: ""}, nil, fmt.Errorf("failed to create certificate. %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, Files{Cert: "", Key: ""}, nil, fmt.Errorf("failed to marshal key. %w", err)
+	}
+	files := Files{Cert: filepath.Join(a.dir, name+".crt"), Key: filepath.Join(a.dir, name+".key")}
+	if err := writePEM(files.Cert, "CERTIFICATE", der); err != nil {
+		return nil, Files{Cert: "", Key: ""}, nil, err
+	}
+	if err := writePEM(files.Key, "EC PRIVATE KEY", keyDER); err != nil {
+		return nil, Files{Cert: "", Key: ""}, nil, err
+	}
+	return key, files, der, nil
+}
+
+func newTemplate(serial int64, name string) *x509.Certificate {
+	template := new(x509.Certificate)
+	template.SerialNumber = big.NewInt(serial)
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore = time.Now().Add(-validity)
+	template.NotAfter = time.Now().Add(validity)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	return template
+}
+
+func writePEM(file, blockType string, bytes []byte) error {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Headers: nil, Bytes: bytes})
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write PEM file %q. %w", file, err)
+	}
+	return nil
+}
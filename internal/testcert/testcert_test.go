@@ -0,0 +1,69 @@
+package testcert_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/toivjon/go-rps/internal/testcert"
+)
+
+// loadCert loads the certificate of the given files and fails the test if the files are not valid.
+func loadCert(t *testing.T, files testcert.Files) *x509.Certificate {
+	t.Helper()
+	pair, err := tls.LoadX509KeyPair(files.Cert, files.Key)
+	if err != nil {
+		t.Fatalf("Expected no error, but error was returned: %s", err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatalf("Expected no error, but error was returned: %s", err)
+	}
+	return cert
+}
+
+func TestNewAuthority(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenDirectoryIsMissing", func(t *testing.T) {
+		t.Parallel()
+		if _, err := testcert.NewAuthority(filepath.Join(t.TempDir(), "missing")); err == nil {
+			t.Fatal("Expected error, but nil was returned!")
+		}
+	})
+	t.Run("IssueCertificatesSignedByAuthority", func(t *testing.T) {
+		t.Parallel()
+		ca, err := testcert.NewAuthority(t.TempDir())
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		serverFiles, err := ca.IssueServer("localhost")
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		clientFiles, err := ca.IssueClient("donald")
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		data, err := os.ReadFile(ca.Files.Cert)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		roots := x509.NewCertPool()
+		roots.AppendCertsFromPEM(data)
+		options := new(x509.VerifyOptions)
+		options.Roots = roots
+		options.DNSName = "localhost"
+		if _, err := loadCert(t, serverFiles).Verify(*options); err != nil {
+			t.Fatalf("Expected server certificate to be verified, but error was returned: %s", err)
+		}
+		client := loadCert(t, clientFiles)
+		options.DNSName = ""
+		options.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+		if _, err := client.Verify(*options); err != nil || client.Subject.CommonName != "donald" {
+			t.Fatalf("Expected client certificate of donald to be verified, but was %q with %v",
+				client.Subject.CommonName, err)
+		}
+	})
+}
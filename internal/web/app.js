@@ -0,0 +1,312 @@
+"use strict";
+
+// The protocol version implemented by this client.
+const PROTOCOL_VERSION = 1;
+
+// The error codes which close the game session and return the player to the lobby.
+const SESSION_CLOSED_CODES = ["OPPONENT_LEFT", "ROUND_TIMEOUT", "SESSION_FAILED"];
+
+// The error codes which reject the player account and let the player try again.
+const ACCOUNT_REJECTED_CODES = ["AUTH_FAILED", "NAME_TAKEN", "INVALID_PASSWORD", "INVALID_NAME"];
+
+// The key of the session token in the session storage of the browser.
+const TOKEN_KEY = "rps-session-token";
+
+const elements = {};
+for (const id of ["status", "join", "name", "login", "account", "username", "password", "match", "opponents",
+  "format", "round", "countdown", "score", "options", "result", "history", "actions", "rematch", "queue"]) {
+  elements[id] = document.getElementById(id);
+}
+
+const state = {
+  socket: null,
+  authenticating: false,
+  start: null,
+  round: 0,
+  offered: false,
+  deadline: 0,
+  timer: 0,
+};
+
+function send(type, content) {
+  state.socket.send(JSON.stringify({ type: type, content: content }));
+}
+
+function setStatus(text) {
+  elements.status.textContent = text;
+}
+
+function optionName(selection) {
+  const option = state.start.Options.find((option) => option.Selection === selection);
+  return option ? option.Name : "nothing";
+}
+
+function describeNames(names) {
+  if (names.length <= 1) {
+    return names.join("");
+  }
+  return names.slice(0, -1).join(", ") + " and " + names[names.length - 1];
+}
+
+function setSelectable(selectable) {
+  for (const button of elements.options.querySelectorAll("button")) {
+    button.disabled = !selectable;
+  }
+}
+
+function startRound(round) {
+  state.round = round;
+  elements.round.textContent = "Round " + round + ".";
+  setSelectable(true);
+  stopCountdown();
+  if (state.start.RoundTimeout > 0) {
+    state.deadline = Date.now() + state.start.RoundTimeout / 1e6;
+    updateCountdown();
+    state.timer = window.setInterval(updateCountdown, 250);
+  }
+}
+
+function updateCountdown() {
+  const seconds = Math.max(0, Math.ceil((state.deadline - Date.now()) / 1000));
+  elements.countdown.textContent = seconds + " s left to select.";
+  if (seconds === 0) {
+    stopCountdown();
+  }
+}
+
+function stopCountdown() {
+  window.clearInterval(state.timer);
+  state.timer = 0;
+  elements.countdown.textContent = "";
+}
+
+function showActions(rematch) {
+  setSelectable(false);
+  stopCountdown();
+  state.offered = false;
+  elements.rematch.textContent = "Offer rematch";
+  elements.rematch.hidden = !rematch;
+  elements.rematch.disabled = false;
+  elements.actions.hidden = false;
+}
+
+function onWelcome(content) {
+  const token = window.sessionStorage.getItem(TOKEN_KEY);
+  if (token) {
+    setStatus("Connected. Logging in...");
+    state.authenticating = true;
+    send("LOGIN", { Name: "", Password: "", Token: token });
+  } else if (content.AccountRequired) {
+    showAccount("Connected. Log in or register to play.");
+  } else {
+    setStatus("Connected. Enter your name to play.");
+    elements.join.hidden = false;
+    elements.name.focus();
+  }
+}
+
+function showAccount(status) {
+  setStatus(status);
+  elements.join.hidden = true;
+  elements.account.hidden = false;
+  elements.password.value = "";
+  elements.username.focus();
+}
+
+function onAuthenticated(content) {
+  state.authenticating = false;
+  window.sessionStorage.setItem(TOKEN_KEY, content.Token);
+  setStatus("Logged in as " + content.Name + ". Join to play.");
+  elements.account.hidden = true;
+  elements.login.hidden = true;
+  elements.name.value = content.Name;
+  elements.name.readOnly = true;
+  elements.join.hidden = false;
+}
+
+function onStart(content) {
+  state.start = content;
+  elements.join.hidden = true;
+  elements.actions.hidden = true;
+  elements.match.hidden = false;
+  elements.opponents.textContent = "Playing against " + describeNames(content.Opponents) + ".";
+  elements.format.textContent = "The match is played as " + content.Format.Name + " with " + content.Rules +
+    " rules.";
+  elements.score.textContent = "";
+  elements.result.textContent = "";
+  elements.history.replaceChildren();
+  elements.options.replaceChildren();
+  for (const option of content.Options) {
+    const button = document.createElement("button");
+    button.type = "button";
+    button.textContent = option.Name;
+    button.addEventListener("click", () => select(option.Selection));
+    elements.options.append(button);
+  }
+  setStatus("The match has started. Make your selection.");
+  startRound(1);
+}
+
+function select(selection) {
+  send("SELECT", { Round: state.round, Selection: selection });
+  setSelectable(false);
+  stopCountdown();
+  setStatus("You selected " + optionName(selection) + ". Waiting for the opponents...");
+}
+
+function onResult(content) {
+  const opponents = content.Opponents.map((opponent) => opponent.Name + " selected " +
+    optionName(opponent.Selection)).join(", ");
+  let text;
+  switch (content.Result) {
+    case "WIN":
+      text = "You won the round";
+      break;
+    case "LOSE":
+      text = "You lost the round";
+      break;
+    case "DRAW":
+      text = "The round was a draw";
+      break;
+    default:
+      text = "You sat out the round";
+  }
+  if (content.Forfeit) {
+    text += " as a selection was not made in time";
+  }
+  elements.result.textContent = text + ".";
+  const item = document.createElement("li");
+  item.textContent = "Round " + content.Round + ": " + text + " (" + opponents + ").";
+  elements.history.append(item);
+  elements.score.textContent = "Score " + content.Score + " - " + content.OpponentScore + ".";
+  const decided = Math.max(content.Score, content.OpponentScore) >= state.start.Format.WinsNeeded;
+  if (content.Eliminated) {
+    setStatus("You were eliminated from the game. Waiting for the others...");
+    setSelectable(false);
+    stopCountdown();
+  } else if (!decided) {
+    setStatus("Make your selection.");
+    startRound(content.Round + 1);
+  }
+}
+
+function onMatchEnd(content) {
+  const results = { WIN: "You won the match", LOSE: "You lost the match", DRAW: "The match was a draw" };
+  elements.result.textContent = (results[content.Result] || "The match ended") + " " + content.Score + " - " +
+    content.OpponentScore + ".";
+  setStatus("The match has ended.");
+  showActions(!state.start.Tournament);
+}
+
+function onRematchOffer() {
+  if (!state.offered) {
+    elements.rematch.textContent = "Accept rematch";
+    setStatus("The opponent offers a rematch.");
+  }
+}
+
+function onError(content) {
+  if (state.authenticating && ACCOUNT_REJECTED_CODES.includes(content.Code)) {
+    state.authenticating = false;
+    window.sessionStorage.removeItem(TOKEN_KEY);
+    showAccount("The account was not accepted (" + content.Message + ").");
+  } else if (content.Code === "AUTH_REQUIRED") {
+    showAccount("Log in or register to play.");
+  } else if (SESSION_CLOSED_CODES.includes(content.Code)) {
+    setStatus("The game session was closed (" + content.Message + ").");
+    showActions(false);
+  } else if (content.Code === "INVALID_NAME" || content.Code === "NAME_TAKEN") {
+    setStatus("The name was not accepted (" + content.Message + ").");
+    elements.join.hidden = false;
+  } else {
+    setStatus("The server reported an error (" + content.Message + ").");
+  }
+}
+
+function onMessage(event) {
+  const message = JSON.parse(event.data);
+  const content = message.content;
+  switch (message.type) {
+    case "WELCOME":
+      onWelcome(content);
+      break;
+    case "AUTHENTICATED":
+      onAuthenticated(content);
+      break;
+    case "REJECT":
+      setStatus("The server rejected the client (" + content.Reason + ").");
+      break;
+    case "START":
+      onStart(content);
+      break;
+    case "RESULT":
+      onResult(content);
+      break;
+    case "MATCH_END":
+      onMatchEnd(content);
+      break;
+    case "REMATCH_OFFER":
+      onRematchOffer();
+      break;
+    case "SHUTDOWN":
+      setStatus("The server is shutting down (" + content.Reason + ").");
+      break;
+    case "ERROR":
+      onError(content);
+      break;
+  }
+}
+
+function connect() {
+  const protocol = window.location.protocol === "https:" ? "wss:" : "ws:";
+  state.socket = new WebSocket(protocol + "//" + window.location.host + "/ws");
+  state.socket.addEventListener("open", () => send("HELLO", { Version: PROTOCOL_VERSION, Capabilities: [] }));
+  state.socket.addEventListener("message", onMessage);
+  state.socket.addEventListener("close", () => {
+    setStatus("The connection to the server was closed. Reload the page to play again.");
+    elements.join.hidden = true;
+    elements.account.hidden = true;
+    elements.actions.hidden = true;
+    setSelectable(false);
+    stopCountdown();
+  });
+}
+
+elements.join.addEventListener("submit", (event) => {
+  event.preventDefault();
+  send("JOIN", { Name: elements.name.value.trim(), Bot: "" });
+  elements.join.hidden = true;
+  setStatus("Waiting for an opponent. Please wait...");
+});
+
+elements.login.addEventListener("click", () => showAccount("Log in or register a player account."));
+
+elements.account.addEventListener("submit", (event) => {
+  event.preventDefault();
+  const type = event.submitter && event.submitter.value === "REGISTER" ? "REGISTER" : "LOGIN";
+  const content = { Name: elements.username.value.trim(), Password: elements.password.value };
+  send(type, type === "LOGIN" ? Object.assign(content, { Token: "" }) : content);
+  state.authenticating = true;
+  elements.account.hidden = true;
+  setStatus(type === "LOGIN" ? "Logging in..." : "Registering the account...");
+});
+
+elements.rematch.addEventListener("click", () => {
+  if (elements.rematch.textContent === "Accept rematch") {
+    send("REMATCH_ACCEPT", {});
+  } else {
+    send("REMATCH_OFFER", {});
+    state.offered = true;
+  }
+  elements.rematch.disabled = true;
+  setStatus("Waiting for the opponent to accept the rematch...");
+});
+
+elements.queue.addEventListener("click", () => {
+  send("QUEUE", {});
+  elements.actions.hidden = true;
+  elements.match.hidden = true;
+  setStatus("Waiting for a new opponent. Please wait...");
+});
+
+connect();
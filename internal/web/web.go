@@ -0,0 +1,16 @@
+package web
+
+import (
+	"embed"
+	"net/http"
+)
+
+// files contains the embedded web client which plays over the WebSocket transport of the server.
+//
+//go:embed index.html app.js style.css
+var files embed.FS
+
+// Handler returns an HTTP handler which serves the embedded web client.
+func Handler() http.Handler {
+	return http.FileServer(http.FS(files))
+}
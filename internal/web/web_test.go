@@ -0,0 +1,43 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/toivjon/go-rps/internal/web"
+)
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+	for name, file := range map[string]struct {
+		path     string
+		expected string
+	}{
+		"ServeIndex":      {path: "/", expected: "<title>Rock Paper Scissors</title>"},
+		"ServeScript":     {path: "/app.js", expected: `send("SELECT"`},
+		"ServeStylesheet": {path: "/style.css", expected: "#countdown"},
+	} {
+		path, expected := file.path, file.expected
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			recorder := httptest.NewRecorder()
+			web.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, but was %d", http.StatusOK, recorder.Code)
+			}
+			if !strings.Contains(recorder.Body.String(), expected) {
+				t.Fatalf("Expected the body to contain %q, but it did not!", expected)
+			}
+		})
+	}
+	t.Run("ReturnNotFoundWhenFileDoesNotExist", func(t *testing.T) {
+		t.Parallel()
+		recorder := httptest.NewRecorder()
+		web.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/missing.js", nil))
+		if recorder.Code != http.StatusNotFound {
+			t.Fatalf("Expected status %d, but was %d", http.StatusNotFound, recorder.Code)
+		}
+	})
+}
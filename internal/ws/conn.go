@@ -0,0 +1,280 @@
+package ws
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sync"
+
+	"github.com/toivjon/go-rps/internal/com"
+)
+
+// The opcodes of the WebSocket frames.
+const (
+	opContinuation byte = 0x0
+	opText         byte = 0x1
+	opBinary       byte = 0x2
+	opClose        byte = 0x8
+	opPing         byte = 0x9
+	opPong         byte = 0xA
+)
+
+const (
+	finBit         byte = 0x80
+	reservedBits   byte = 0x70
+	opcodeBits     byte = 0x0F
+	maskBit        byte = 0x80
+	lengthBits     byte = 0x7F
+	length16       byte = 126
+	length64       byte = 127
+	maskSize            = 4
+	maxControlSize      = 125
+)
+
+// closeNormal is the close frame payload with the status code of a normal closure.
+var closeNormal = []byte{0x03, 0xE8}
+
+var (
+	// ErrProtocol is an error occurring when the peer violates the WebSocket protocol.
+	ErrProtocol = errors.New("WebSocket protocol violation")
+	// ErrMessageTooLarge is an error occurring when a message exceeds the maximum frame size of the protocol.
+	ErrMessageTooLarge = errors.New("WebSocket message exceeds the maximum frame size")
+)
+
+// frame represents a single WebSocket frame.
+type frame struct {
+	fin     bool
+	opcode  byte
+	payload []byte
+}
+
+// Conn represents a WebSocket connection which carries a single protocol message in each WebSocket message.
+// The connection implements the net.Conn by adapting the WebSocket messages into the length-prefixed frames
+// of the protocol, so it can be used like any other protocol connection. The frames written to the
+// connection are sent as text messages once they are complete, and the received messages are read as frames.
+// The client side masks the frames it sends as required by the WebSocket protocol.
+type Conn struct {
+	net.Conn
+	reader    *bufio.Reader
+	client    bool
+	pending   []byte
+	written   []byte
+	writeMu   *sync.Mutex
+	closeOnce *sync.Once
+}
+
+// newConn builds a new WebSocket connection on an upgraded network connection.
+func newConn(conn net.Conn, reader *bufio.Reader, client bool) *Conn {
+	return &Conn{
+		Conn:      conn,
+		reader:    reader,
+		client:    client,
+		pending:   nil,
+		written:   nil,
+		writeMu:   new(sync.Mutex),
+		closeOnce: new(sync.Once),
+	}
+}
+
+// NetConn returns the underlying network connection.
+func (c *Conn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// Read reads the received messages as length-prefixed protocol frames.
+func (c *Conn) Read(b []byte) (int, error) {
+	for len(c.pending) == 0 {
+		message, err := c.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		buffer := new(bytes.Buffer)
+		if err := com.WriteFrame(buffer, message, com.MaxFrameSize); err != nil {
+			return 0, fmt.Errorf("failed to frame WebSocket message. %w", err)
+		}
+		c.pending = buffer.Bytes()
+	}
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Write buffers the length-prefixed protocol frames and sends the payload of each complete frame as a text
+// message.
+func (c *Conn) Write(b []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.written = append(c.written, b...)
+	for {
+		reader := bytes.NewReader(c.written)
+		payload, err := com.ReadFrame(reader, com.MaxFrameSize)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return len(b), nil
+		} else if err != nil {
+			return 0, fmt.Errorf("failed to read frame from written data. %w", err)
+		}
+		if err := c.writeFrame(opText, payload); err != nil {
+			return 0, err
+		}
+		c.written = c.written[len(c.written)-reader.Len():]
+	}
+}
+
+// Close sends a close frame and closes the underlying connection.
+func (c *Conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.writeMu.Lock()
+		_ = c.writeFrame(opClose, closeNormal)
+		c.writeMu.Unlock()
+		if closeErr := c.Conn.Close(); closeErr != nil {
+			err = fmt.Errorf("failed to close WebSocket connection. %w", closeErr)
+		}
+	})
+	return err
+}
+
+// ReadMessage reads the payload of the next text or binary message. Fragmented messages are reassembled and
+// the control frames are handled while reading: pings are answered with pongs and a close frame ends the
+// reading with io.EOF. The close frame is answered when the connection is closed.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var message []byte
+	started := false
+	for {
+		frame, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch frame.opcode {
+		case opPing:
+			if err := c.writeControl(opPong, frame.payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return nil, io.EOF
+		case opText, opBinary:
+			if started {
+				return nil, fmt.Errorf("failed to read message as a new message started. %w", ErrProtocol)
+			}
+			started = true
+			message = frame.payload
+		case opContinuation:
+			if !started {
+				return nil, fmt.Errorf("failed to read message as continuation has no message. %w", ErrProtocol)
+			}
+			message = append(message, frame.payload...)
+		default:
+			return nil, fmt.Errorf("failed to read message with opcode %#x. %w", frame.opcode, ErrProtocol)
+		}
+		if len(message) > com.MaxFrameSize {
+			return nil, fmt.Errorf("failed to read %d byte message. %w", len(message), ErrMessageTooLarge)
+		}
+		if frame.fin {
+			return message, nil
+		}
+	}
+}
+
+// readFrame reads and unmasks a single frame. The frames sent by a client must be masked and the frames sent
+// by a server must not be masked.
+func (c *Conn) readFrame() (frame, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return frame{}, fmt.Errorf("failed to read WebSocket frame header. %w", err)
+	}
+	fin, opcode := header[0]&finBit != 0, header[0]&opcodeBits
+	if header[0]&reservedBits != 0 {
+		return frame{}, fmt.Errorf("failed to read frame with reserved bits. %w", ErrProtocol)
+	}
+	if masked := header[1]&maskBit != 0; masked == c.client {
+		return frame{}, fmt.Errorf("failed to read frame with invalid masking (masked: %t). %w", masked, ErrProtocol)
+	}
+	size := uint64(header[1] & lengthBits)
+	switch size {
+	case uint64(length16):
+		extended := make([]byte, 2)
+		if _, err := io.ReadFull(c.reader, extended); err != nil {
+			return frame{}, fmt.Errorf("failed to read WebSocket frame length. %w", err)
+		}
+		size = uint64(binary.BigEndian.Uint16(extended))
+	case uint64(length64):
+		extended := make([]byte, 8)
+		if _, err := io.ReadFull(c.reader, extended); err != nil {
+			return frame{}, fmt.Errorf("failed to read WebSocket frame length. %w", err)
+		}
+		size = binary.BigEndian.Uint64(extended)
+	}
+	if opcode >= opClose && (size > maxControlSize || !fin) {
+		return frame{}, fmt.Errorf("failed to read fragmented or too large control frame. %w", ErrProtocol)
+	}
+	if size > uint64(com.MaxFrameSize) {
+		return frame{}, fmt.Errorf("failed to read %d byte frame. %w", size, ErrMessageTooLarge)
+	}
+	mask := make([]byte, maskSize)
+	if !c.client {
+		if _, err := io.ReadFull(c.reader, mask); err != nil {
+			return frame{}, fmt.Errorf("failed to read WebSocket frame mask. %w", err)
+		}
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return frame{}, fmt.Errorf("failed to read WebSocket frame payload. %w", err)
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%maskSize]
+	}
+	return frame{fin: fin, opcode: opcode, payload: payload}, nil
+}
+
+// writeControl writes a control frame while holding the write lock.
+func (c *Conn) writeControl(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.writeFrame(opcode, payload)
+}
+
+// writeFrame writes the payload as a single unfragmented frame with a single write call. The caller must hold
+// the write lock.
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{finBit | opcode, 0}
+	switch size := len(payload); {
+	case size < int(length16):
+		header[1] = byte(size)
+	case size <= math.MaxUint16:
+		header[1] = length16
+		extended := make([]byte, 2)
+		binary.BigEndian.PutUint16(extended, uint16(size))
+		header = append(header, extended...)
+	default:
+		header[1] = length64
+		extended := make([]byte, 8)
+		binary.BigEndian.PutUint64(extended, uint64(size))
+		header = append(header, extended...)
+	}
+	if c.client {
+		mask := make([]byte, maskSize)
+		if _, err := rand.Read(mask); err != nil {
+			return fmt.Errorf("failed to read random bytes for WebSocket frame mask. %w", err)
+		}
+		header[1] |= maskBit
+		header = append(header, mask...)
+		masked := make([]byte, len(payload))
+		for i := range payload {
+			masked[i] = payload[i] ^ mask[i%maskSize]
+		}
+		payload = masked
+	}
+	if _, err := c.Conn.Write(append(header, payload...)); err != nil {
+		return fmt.Errorf("failed to write WebSocket frame. %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package ws_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/toivjon/go-rps/internal/com"
+	"github.com/toivjon/go-rps/internal/ws"
+)
+
+// writeRaw writes raw frame bytes from the client side. Masked frames use a zero mask key, which leaves their
+// payload unchanged.
+func writeRaw(t *testing.T, conn *ws.Conn, frames ...[]byte) {
+	t.Helper()
+	for _, frame := range frames {
+		if _, err := conn.NetConn().Write(frame); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+	}
+}
+
+// maskedFrame builds a masked client frame with a zero mask key and a payload shorter than 126 bytes.
+func maskedFrame(first byte, payload string) []byte {
+	return append([]byte{first, 0x80 | byte(len(payload)), 0, 0, 0, 0}, payload...)
+}
+
+func TestConnReadMessage(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnReassembledFragmentedMessage", func(t *testing.T) {
+		t.Parallel()
+		client, server := connect(t)
+		writeRaw(t, client, maskedFrame(0x01, "he"), maskedFrame(0x00, "ll"), maskedFrame(0x80, "o"))
+		message, err := server.ReadMessage()
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if string(message) != "hello" {
+			t.Fatalf("Expected message %q, but was %q", "hello", message)
+		}
+	})
+	t.Run("AnswerPingWithPong", func(t *testing.T) {
+		t.Parallel()
+		client, server := connect(t)
+		writeRaw(t, client, maskedFrame(0x89, "hi"), maskedFrame(0x8A, ""), maskedFrame(0x82, "data"))
+		message, err := server.ReadMessage()
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if string(message) != "data" {
+			t.Fatalf("Expected message %q, but was %q", "data", message)
+		}
+		pong := make([]byte, 4)
+		if _, err := io.ReadFull(client.NetConn(), pong); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if !bytes.Equal(pong, []byte{0x8A, 2, 'h', 'i'}) {
+			t.Fatalf("Expected a pong frame, but was %v", pong)
+		}
+	})
+	t.Run("ReturnEOFWhenCloseIsReceived", func(t *testing.T) {
+		t.Parallel()
+		client, server := connect(t)
+		writeRaw(t, client, maskedFrame(0x88, "\x03\xe8"))
+		if _, err := server.ReadMessage(); !errors.Is(err, io.EOF) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", io.EOF, err)
+		}
+	})
+	t.Run("ReturnErrorWhenConnectionIsClosed", func(t *testing.T) {
+		t.Parallel()
+		client, server := connect(t)
+		client.NetConn().Close()
+		if _, err := server.ReadMessage(); !errors.Is(err, io.EOF) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", io.EOF, err)
+		}
+	})
+	for name, frames := range map[string][][]byte{
+		"ReturnErrorWhenFrameIsNotMasked":          {{0x81, 0x01, 'a'}},
+		"ReturnErrorWhenReservedBitsAreSet":        {maskedFrame(0xC1, "a")},
+		"ReturnErrorWhenControlFrameIsFragmented":  {maskedFrame(0x09, "a")},
+		"ReturnErrorWhenContinuationHasNoMessage":  {maskedFrame(0x80, "a")},
+		"ReturnErrorWhenMessageStartsMidMessage":   {maskedFrame(0x01, "a"), maskedFrame(0x81, "b")},
+		"ReturnErrorWhenOpcodeIsNotKnown":          {maskedFrame(0x83, "a")},
+		"ReturnErrorWhenControlFrameIsTooLarge":    {{0x89, 0x80 | 126, 0, 126, 0, 0, 0, 0}},
+		"ReturnErrorWhenFrameLengthIsTruncated":    {{0x81, 0x80 | 127, 0}},
+		"ReturnErrorWhenShortFrameLengthTruncated": {{0x81, 0x80 | 126, 0}},
+		"ReturnErrorWhenMaskIsTruncated":           {{0x81, 0x81, 0}},
+		"ReturnErrorWhenPayloadIsTruncated":        {{0x81, 0x82, 0, 0, 0, 0, 'a'}},
+	} {
+		frames := frames
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			client, server := connect(t)
+			writeRaw(t, client, frames...)
+			client.NetConn().Close()
+			if _, err := server.ReadMessage(); err == nil {
+				t.Fatal("Expected an error, but nil was returned!")
+			}
+		})
+	}
+	t.Run("ReturnErrorWhenFrameIsTooLarge", func(t *testing.T) {
+		t.Parallel()
+		client, server := connect(t)
+		writeRaw(t, client, []byte{0x82, 0x80 | 127, 0, 0, 0, 0, 0, 0x10, 0, 0})
+		if _, err := server.ReadMessage(); !errors.Is(err, ws.ErrMessageTooLarge) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", ws.ErrMessageTooLarge, err)
+		}
+	})
+	t.Run("ReturnErrorWhenMessageIsTooLarge", func(t *testing.T) {
+		t.Parallel()
+		client, server := connect(t)
+		go func() {
+			payload := make([]byte, com.MaxFrameSize)
+			for _, first := range []byte{0x02, 0x80} {
+				header := []byte{first, 0x80 | 127, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+				if _, err := client.NetConn().Write(append(header, payload...)); err != nil {
+					return
+				}
+			}
+		}()
+		if _, err := server.ReadMessage(); !errors.Is(err, ws.ErrMessageTooLarge) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", ws.ErrMessageTooLarge, err)
+		}
+	})
+}
+
+func TestConnWrite(t *testing.T) {
+	t.Parallel()
+	t.Run("SendEveryCompleteFrameAsMessage", func(t *testing.T) {
+		t.Parallel()
+		client, server := connect(t)
+		buffer := new(bytes.Buffer)
+		for _, payload := range []string{"first", "second"} {
+			if err := com.WriteFrame(buffer, []byte(payload), com.MaxFrameSize); err != nil {
+				t.Fatalf("Expected no error, but error was returned: %s", err)
+			}
+		}
+		frames := buffer.Bytes()
+		for _, part := range [][]byte{frames[:2], frames[2:7], frames[7:]} {
+			if _, err := server.Write(part); err != nil {
+				t.Fatalf("Expected no error, but error was returned: %s", err)
+			}
+		}
+		for _, expected := range []string{"first", "second"} {
+			message, err := client.ReadMessage()
+			if err != nil {
+				t.Fatalf("Expected no error, but error was returned: %s", err)
+			}
+			if string(message) != expected {
+				t.Fatalf("Expected message %q, but was %q", expected, message)
+			}
+		}
+	})
+	t.Run("SendLargeMessages", func(t *testing.T) {
+		t.Parallel()
+		client, server := connect(t)
+		for _, size := range []int{200, com.MaxFrameSize} {
+			payload := bytes.Repeat([]byte{'x'}, size)
+			go func() {
+				_ = com.WriteFrame(client, payload, com.MaxFrameSize)
+			}()
+			read, err := com.ReadFrame(server, com.MaxFrameSize)
+			if err != nil {
+				t.Fatalf("Expected no error, but error was returned: %s", err)
+			}
+			if !bytes.Equal(read, payload) {
+				t.Fatalf("Expected %d byte payload, but was %d bytes", size, len(read))
+			}
+		}
+	})
+	t.Run("ReturnErrorWhenFrameIsTooLarge", func(t *testing.T) {
+		t.Parallel()
+		_, server := connect(t)
+		if _, err := server.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF}); !errors.Is(err, com.ErrFrameTooLarge) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", com.ErrFrameTooLarge, err)
+		}
+	})
+	t.Run("ReturnErrorWhenConnectionIsClosed", func(t *testing.T) {
+		t.Parallel()
+		_, server := connect(t)
+		server.NetConn().Close()
+		if err := com.WriteFrame(server, []byte("data"), com.MaxFrameSize); err == nil {
+			t.Fatal("Expected an error, but nil was returned!")
+		}
+	})
+}
+
+func TestConnClose(t *testing.T) {
+	t.Parallel()
+	client, server := connect(t)
+	if err := server.Close(); err != nil {
+		t.Fatalf("Expected no error, but error was returned: %s", err)
+	}
+	if _, err := client.ReadMessage(); !errors.Is(err, io.EOF) {
+		t.Fatalf("Expected %q error in the chain %q, but did not exists!", io.EOF, err)
+	}
+	if err := server.Close(); err != nil {
+		t.Fatalf("Expected no error on the second close, but error was returned: %s", err)
+	}
+}
@@ -0,0 +1,160 @@
+package ws
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// acceptGUID is the globally unique identifier which the server appends to the key of the opening handshake.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// keySize specifies the number of random bytes in the key of the opening handshake.
+const keySize = 16
+
+// version is the only supported version of the WebSocket protocol.
+const version = "13"
+
+// ErrHandshake is an error occurring when the opening handshake of a WebSocket connection is invalid.
+var ErrHandshake = errors.New("invalid WebSocket handshake")
+
+// AnyOrigin is an allowed origin which accepts the requests from every origin.
+const AnyOrigin = "*"
+
+// Upgrade completes the opening handshake of a WebSocket connection from the HTTP request and takes over the
+// underlying connection. An invalid handshake is answered with an HTTP error. A request sent by a browser from
+// a page of another origin is refused, unless its origin is one of the given allowed origins, so that other
+// sites cannot open connections on behalf of their visitors. The requests without an origin are not sent by
+// browsers and are always accepted.
+func Upgrade(writer http.ResponseWriter, request *http.Request, origins ...string) (*Conn, error) {
+	if request.Method != http.MethodGet || !headerContains(request.Header, "Connection", "upgrade") ||
+		!headerContains(request.Header, "Upgrade", "websocket") {
+		http.Error(writer, "expected a WebSocket upgrade request", http.StatusBadRequest)
+		return nil, fmt.Errorf("failed to upgrade %s request without upgrade headers. %w", request.Method, ErrHandshake)
+	}
+	if request.Header.Get("Sec-WebSocket-Version") != version {
+		writer.Header().Set("Sec-WebSocket-Version", version)
+		http.Error(writer, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("failed to upgrade request with unsupported version. %w", ErrHandshake)
+	}
+	if origin := request.Header.Get("Origin"); !allowedOrigin(origin, request.Host, origins) {
+		http.Error(writer, "origin is not allowed", http.StatusForbidden)
+		return nil, fmt.Errorf("failed to upgrade request from origin %q. %w", origin, ErrHandshake)
+	}
+	key := request.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(writer, "missing WebSocket key", http.StatusBadRequest)
+		return nil, fmt.Errorf("failed to upgrade request without key. %w", ErrHandshake)
+	}
+	hijacker, ok := writer.(http.Hijacker)
+	if !ok {
+		http.Error(writer, "connection cannot be upgraded", http.StatusInternalServerError)
+		return nil, fmt.Errorf("failed to upgrade request as connection cannot be hijacked. %w", ErrHandshake)
+	}
+	conn, readWriter, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to hijack connection. %w", err)
+	}
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := readWriter.WriteString(response); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to write handshake response. %w", err)
+	}
+	if err := readWriter.Flush(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to flush handshake response. %w", err)
+	}
+	return newConn(conn, readWriter.Reader, false), nil
+}
+
+// Dial opens a client side WebSocket connection to the given address and completes the opening handshake for
+// the given path.
+func Dial(ctx context.Context, address, path string) (*Conn, error) {
+	conn, err := new(net.Dialer).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open TCP connection. %w", err)
+	}
+	ws, err := handshake(conn, address, path)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ws, nil
+}
+
+// handshake sends the opening handshake request over the connection and verifies the response.
+func handshake(conn net.Conn, host, path string) (*Conn, error) {
+	bytes := make([]byte, keySize)
+	if _, err := rand.Read(bytes); err != nil {
+		return nil, fmt.Errorf("failed to read random bytes for WebSocket key. %w", err)
+	}
+	key := base64.StdEncoding.EncodeToString(bytes)
+	request := "GET " + path + " HTTP/1.1\r\n" +
+		"Host: " + host + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\n" +
+		"Sec-WebSocket-Version: " + version + "\r\n\r\n"
+	if _, err := conn.Write([]byte(request)); err != nil {
+		return nil, fmt.Errorf("failed to write handshake request. %w", err)
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read handshake response. %w", err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("failed to upgrade with response status %q. %w", response.Status, ErrHandshake)
+	}
+	if response.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		return nil, fmt.Errorf("failed to upgrade with invalid accept key. %w", ErrHandshake)
+	}
+	return newConn(conn, reader, true), nil
+}
+
+// acceptKey returns the accept key of the opening handshake response for the given key.
+func acceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + acceptGUID)) //nolint:gosec
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// allowedOrigin checks whether the request from the given origin to the given host may be upgraded. The origin
+// is allowed when it is empty, when its host is the requested host or when it is one of the allowed origins.
+func allowedOrigin(origin, host string, origins []string) bool {
+	if origin == "" {
+		return true
+	}
+	if parsed, err := url.Parse(origin); err == nil && strings.EqualFold(parsed.Host, host) {
+		return true
+	}
+	for _, allowed := range origins {
+		if allowed == AnyOrigin || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerContains checks whether the comma-separated values of the header contain the given token.
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
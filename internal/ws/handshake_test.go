@@ -0,0 +1,138 @@
+package ws_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/toivjon/go-rps/internal/ws"
+)
+
+func newUpgradeRequest() *http.Request {
+	request := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	request.Header.Set("Connection", "keep-alive, Upgrade")
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	request.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	return request
+}
+
+func TestUpgrade(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenRequestIsNotUpgrade", func(t *testing.T) {
+		t.Parallel()
+		recorder := httptest.NewRecorder()
+		_, err := ws.Upgrade(recorder, httptest.NewRequest(http.MethodGet, "/ws", nil))
+		if !errors.Is(err, ws.ErrHandshake) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", ws.ErrHandshake, err)
+		}
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status %d, but was %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+	t.Run("ReturnErrorWhenVersionIsNotSupported", func(t *testing.T) {
+		t.Parallel()
+		recorder := httptest.NewRecorder()
+		request := newUpgradeRequest()
+		request.Header.Set("Sec-WebSocket-Version", "8")
+		if _, err := ws.Upgrade(recorder, request); !errors.Is(err, ws.ErrHandshake) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", ws.ErrHandshake, err)
+		}
+		if recorder.Code != http.StatusUpgradeRequired || recorder.Header().Get("Sec-WebSocket-Version") != "13" {
+			t.Fatalf("Expected status %d with version 13, but was %d", http.StatusUpgradeRequired, recorder.Code)
+		}
+	})
+	t.Run("ReturnErrorWhenKeyIsMissing", func(t *testing.T) {
+		t.Parallel()
+		recorder := httptest.NewRecorder()
+		request := newUpgradeRequest()
+		request.Header.Del("Sec-WebSocket-Key")
+		if _, err := ws.Upgrade(recorder, request); !errors.Is(err, ws.ErrHandshake) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", ws.ErrHandshake, err)
+		}
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status %d, but was %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+	t.Run("ReturnErrorWhenOriginIsNotAllowed", func(t *testing.T) {
+		t.Parallel()
+		recorder := httptest.NewRecorder()
+		request := newUpgradeRequest()
+		request.Header.Set("Origin", "https://attacker.example")
+		if _, err := ws.Upgrade(recorder, request, "https://friend.example"); !errors.Is(err, ws.ErrHandshake) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", ws.ErrHandshake, err)
+		}
+		if recorder.Code != http.StatusForbidden {
+			t.Fatalf("Expected status %d, but was %d", http.StatusForbidden, recorder.Code)
+		}
+	})
+	t.Run("AcceptOwnAndAllowedOrigins", func(t *testing.T) {
+		t.Parallel()
+		for _, test := range []struct{ origin, allowed string }{
+			{origin: "http://EXAMPLE.com", allowed: ""},
+			{origin: "https://friend.example", allowed: "https://Friend.example"},
+			{origin: "https://attacker.example", allowed: ws.AnyOrigin},
+		} {
+			recorder := httptest.NewRecorder()
+			request := newUpgradeRequest()
+			request.Header.Set("Origin", test.origin)
+			// The recorder cannot be hijacked, so an accepted origin fails only after the origin check.
+			if _, err := ws.Upgrade(recorder, request, test.allowed); recorder.Code != http.StatusInternalServerError {
+				t.Fatalf("Expected origin %q to be accepted, but was rejected with %q", test.origin, err)
+			}
+		}
+	})
+	t.Run("ReturnErrorWhenConnectionCannotBeHijacked", func(t *testing.T) {
+		t.Parallel()
+		recorder := httptest.NewRecorder()
+		if _, err := ws.Upgrade(recorder, newUpgradeRequest()); !errors.Is(err, ws.ErrHandshake) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", ws.ErrHandshake, err)
+		}
+		if recorder.Code != http.StatusInternalServerError {
+			t.Fatalf("Expected status %d, but was %d", http.StatusInternalServerError, recorder.Code)
+		}
+	})
+}
+
+func TestDial(t *testing.T) {
+	t.Parallel()
+	t.Run("ReturnErrorWhenConnectionFails", func(t *testing.T) {
+		t.Parallel()
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		address := listener.Addr().String()
+		listener.Close()
+		if _, err := ws.Dial(context.Background(), address, "/"); err == nil {
+			t.Fatal("Expected an error, but nil was returned!")
+		}
+	})
+	t.Run("ReturnErrorWhenServerDoesNotUpgrade", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.NotFoundHandler())
+		defer server.Close()
+		_, err := ws.Dial(context.Background(), strings.TrimPrefix(server.URL, "http://"), "/")
+		if !errors.Is(err, ws.ErrHandshake) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", ws.ErrHandshake, err)
+		}
+	})
+	t.Run("ReturnErrorWhenAcceptKeyIsInvalid", func(t *testing.T) {
+		t.Parallel()
+		server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, _ *http.Request) {
+			writer.Header().Set("Connection", "Upgrade")
+			writer.Header().Set("Upgrade", "websocket")
+			writer.Header().Set("Sec-WebSocket-Accept", "invalid")
+			writer.WriteHeader(http.StatusSwitchingProtocols)
+		}))
+		defer server.Close()
+		_, err := ws.Dial(context.Background(), strings.TrimPrefix(server.URL, "http://"), "/")
+		if !errors.Is(err, ws.ErrHandshake) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", ws.ErrHandshake, err)
+		}
+	})
+}
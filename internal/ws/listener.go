@@ -0,0 +1,66 @@
+package ws
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// Listener is an HTTP handler which upgrades the requests into WebSocket connections and a net.Listener which
+// accepts the upgraded connections, so the WebSocket connections can be accepted like the TCP connections.
+type Listener struct {
+	addr      net.Addr
+	origins   []string
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce *sync.Once
+}
+
+// NewListener builds a new listener which reports the given address as its network address. Besides its own
+// origin, the listener accepts the requests from the given allowed origins.
+func NewListener(addr net.Addr, origins ...string) *Listener {
+	return &Listener{
+		addr:      addr,
+		origins:   origins,
+		conns:     make(chan net.Conn),
+		closed:    make(chan struct{}),
+		closeOnce: new(sync.Once),
+	}
+}
+
+// ServeHTTP upgrades the request into a WebSocket connection and waits until the connection is accepted or the
+// listener is closed.
+func (l *Listener) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	conn, err := Upgrade(writer, request, l.origins...)
+	if err != nil {
+		log.Printf("Failed to upgrade WebSocket connection from %s. %s", request.RemoteAddr, err)
+		return
+	}
+	select {
+	case l.conns <- conn:
+	case <-l.closed:
+		conn.Close()
+	}
+}
+
+// Accept waits for and returns the next upgraded connection. Returns net.ErrClosed when the listener is closed.
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops accepting the upgraded connections.
+func (l *Listener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return nil
+}
+
+// Addr returns the network address of the listener.
+func (l *Listener) Addr() net.Addr {
+	return l.addr
+}
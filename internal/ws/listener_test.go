@@ -0,0 +1,121 @@
+package ws_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/toivjon/go-rps/internal/com"
+	"github.com/toivjon/go-rps/internal/ws"
+)
+
+// serve starts an HTTP server which upgrades every request with the returned listener.
+func serve(t *testing.T) (*ws.Listener, string) {
+	t.Helper()
+	listener := ws.NewListener(nil)
+	server := httptest.NewServer(listener)
+	t.Cleanup(func() {
+		listener.Close()
+		server.Close()
+	})
+	return listener, strings.TrimPrefix(server.URL, "http://")
+}
+
+// connect opens a WebSocket connection and returns its client and server sides.
+func connect(t *testing.T) (*ws.Conn, *ws.Conn) {
+	t.Helper()
+	listener, address := serve(t)
+	client, err := ws.Dial(context.Background(), address, "/")
+	if err != nil {
+		t.Fatalf("Expected no error, but error was returned: %s", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Expected no error, but error was returned: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	server, ok := conn.(*ws.Conn)
+	if !ok {
+		t.Fatalf("Expected a WebSocket connection, but was %T", conn)
+	}
+	return client, server
+}
+
+func TestListener(t *testing.T) {
+	t.Parallel()
+	t.Run("AcceptUpgradedConnections", func(t *testing.T) {
+		t.Parallel()
+		client, server := connect(t)
+		if err := com.WriteMessage(client, com.TypeHello, com.HelloContent{Version: 1, Capabilities: nil}); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		message, err := com.Read[com.Message](server)
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if message.Type != com.TypeHello {
+			t.Fatalf("Expected %s message, but was %s", com.TypeHello, message.Type)
+		}
+		if err := com.WriteMessage(server, com.TypeWelcome, com.WelcomeContent{Version: 1, Capabilities: nil}); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if message, err = com.Read[com.Message](client); err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		if message.Type != com.TypeWelcome {
+			t.Fatalf("Expected %s message, but was %s", com.TypeWelcome, message.Type)
+		}
+	})
+	t.Run("RejectRequestsWhichAreNotUpgrades", func(t *testing.T) {
+		t.Parallel()
+		recorder := httptest.NewRecorder()
+		ws.NewListener(nil).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/ws", nil))
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("Expected status %d, but was %d", http.StatusBadRequest, recorder.Code)
+		}
+	})
+	t.Run("RejectRequestsFromOtherOrigins", func(t *testing.T) {
+		t.Parallel()
+		recorder := httptest.NewRecorder()
+		request := newUpgradeRequest()
+		request.Header.Set("Origin", "https://attacker.example")
+		ws.NewListener(nil, "https://friend.example").ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusForbidden {
+			t.Fatalf("Expected status %d, but was %d", http.StatusForbidden, recorder.Code)
+		}
+	})
+	t.Run("ReturnErrorWhenClosed", func(t *testing.T) {
+		t.Parallel()
+		listener := ws.NewListener(nil)
+		listener.Close()
+		if _, err := listener.Accept(); !errors.Is(err, net.ErrClosed) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", net.ErrClosed, err)
+		}
+	})
+	t.Run("CloseUpgradedConnectionWhenClosed", func(t *testing.T) {
+		t.Parallel()
+		listener, address := serve(t)
+		listener.Close()
+		client, err := ws.Dial(context.Background(), address, "/")
+		if err != nil {
+			t.Fatalf("Expected no error, but error was returned: %s", err)
+		}
+		defer client.Close()
+		if _, err := client.ReadMessage(); !errors.Is(err, io.EOF) {
+			t.Fatalf("Expected %q error in the chain %q, but did not exists!", io.EOF, err)
+		}
+	})
+	t.Run("ReturnAddress", func(t *testing.T) {
+		t.Parallel()
+		addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 7777, Zone: ""}
+		if listener := ws.NewListener(addr); listener.Addr() != addr {
+			t.Fatalf("Expected address %s, but was %s", addr, listener.Addr())
+		}
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"time"
 
 	"github.com/toivjon/go-rps/internal/com"
 	"github.com/toivjon/go-rps/internal/game"
@@ -25,6 +26,15 @@ func main() {
 	testReturnErrorWhenConnectingFails()
 	testPlaySessionWithOneRound()
 	testPlaySessionWithManyRounds()
+	testPlayRematch()
+	testPlayAgainstNewOpponentAfterOpponentLeaves()
+	testResumeSessionAfterConnectionDrops()
+	testCreatePrivateRoom()
+	testJoinPrivateRoom()
+	testSpectateSession()
+	testPlayHeadless()
+	testPlayWithCommitReveal()
+	testReturnErrorWhenServerRejects()
 }
 
 func testReturnErrorWhenConnectingFails() {
@@ -48,12 +58,34 @@ func testPlaySessionWithOneRound() {
 	conn := accept(server)
 	defer conn.Close()
 
+	expectHandshake(conn)
 	mustWrite(input, name)
-	expectRead(conn, com.TypeJoin, com.JoinContent{Name: name})
-	mustSend(conn, com.TypeStart, com.StartContent{OpponentName: "mickey"})
+	expectRead(conn, com.TypeJoin, com.JoinContent{Name: name, Bot: ""})
+	mustSend(conn, com.TypeStart, com.StartContent{
+		OpponentName: "mickey",
+		Opponents:    []string{"mickey"},
+		Format:       game.BestOf(1),
+		Rules:        game.Classic().Name,
+		Options:      game.Classic().Options,
+		RoundTimeout: time.Minute,
+		ResumeToken:  "",
+		Tournament:   false,
+		CommitReveal: false,
+	})
 	mustWrite(input, game.SelectionRock)
-	expectRead(conn, com.TypeSelect, com.SelectContent{Selection: game.SelectionRock})
-	mustSend(conn, com.TypeResult, com.ResultContent{OpponentSelection: game.SelectionPaper, Result: game.ResultLose})
+	expectRead(conn, com.TypeSelect, com.SelectContent{Round: 1, Selection: game.SelectionRock})
+	mustSend(conn, com.TypeResult, com.ResultContent{
+		Round:             1,
+		OpponentSelection: game.SelectionPaper,
+		Result:            game.ResultLose,
+		Forfeit:           false,
+		Score:             0,
+		OpponentScore:     1,
+		Opponents:         nil,
+		Eliminated:        false,
+	})
+	mustSend(conn, com.TypeMatchEnd, com.MatchEndContent{Result: game.ResultLose, Score: 0, OpponentScore: 1})
+	mustWrite(input, "q")
 
 	if err := client.Wait(); err != nil {
 		log.Panicf("Unable to wait until client disconnects and closes. %s", err)
@@ -71,33 +103,495 @@ func testPlaySessionWithManyRounds() {
 	conn := accept(server)
 	defer conn.Close()
 
+	expectHandshake(conn)
 	mustWrite(input, name)
-	expectRead(conn, com.TypeJoin, com.JoinContent{Name: name})
-	mustSend(conn, com.TypeStart, com.StartContent{OpponentName: "mickey"})
+	expectRead(conn, com.TypeJoin, com.JoinContent{Name: name, Bot: ""})
+	mustSend(conn, com.TypeStart, com.StartContent{
+		OpponentName: "mickey",
+		Opponents:    []string{"mickey"},
+		Format:       game.BestOf(1),
+		Rules:        game.Classic().Name,
+		Options:      game.Classic().Options,
+		RoundTimeout: time.Minute,
+		ResumeToken:  "",
+		Tournament:   false,
+		CommitReveal: false,
+	})
 
 	mustWrite(input, game.SelectionRock)
-	expectRead(conn, com.TypeSelect, com.SelectContent{Selection: game.SelectionRock})
-	mustSend(conn, com.TypeResult, com.ResultContent{OpponentSelection: game.SelectionRock, Result: game.ResultDraw})
+	expectRead(conn, com.TypeSelect, com.SelectContent{Round: 1, Selection: game.SelectionRock})
+	mustSend(conn, com.TypeResult, com.ResultContent{
+		Round:             1,
+		OpponentSelection: game.SelectionRock,
+		Result:            game.ResultDraw,
+		Forfeit:           false,
+		Score:             0,
+		OpponentScore:     0,
+		Opponents:         nil,
+		Eliminated:        false,
+	})
 
 	mustWrite(input, game.SelectionPaper)
-	expectRead(conn, com.TypeSelect, com.SelectContent{Selection: game.SelectionPaper})
-	mustSend(conn, com.TypeResult, com.ResultContent{OpponentSelection: game.SelectionPaper, Result: game.ResultDraw})
+	expectRead(conn, com.TypeSelect, com.SelectContent{Round: 2, Selection: game.SelectionPaper})
+	mustSend(conn, com.TypeResult, com.ResultContent{
+		Round:             2,
+		OpponentSelection: game.SelectionPaper,
+		Result:            game.ResultDraw,
+		Forfeit:           false,
+		Score:             0,
+		OpponentScore:     0,
+		Opponents:         nil,
+		Eliminated:        false,
+	})
 
 	mustWrite(input, game.SelectionScissors)
-	expectRead(conn, com.TypeSelect, com.SelectContent{Selection: game.SelectionScissors})
-	mustSend(conn, com.TypeResult, com.ResultContent{OpponentSelection: game.SelectionScissors, Result: game.ResultDraw})
+	expectRead(conn, com.TypeSelect, com.SelectContent{Round: 3, Selection: game.SelectionScissors})
+	mustSend(conn, com.TypeResult, com.ResultContent{
+		Round:             3,
+		OpponentSelection: game.SelectionScissors,
+		Result:            game.ResultDraw,
+		Forfeit:           false,
+		Score:             0,
+		OpponentScore:     0,
+		Opponents:         nil,
+		Eliminated:        false,
+	})
 
 	mustWrite(input, game.SelectionRock)
-	expectRead(conn, com.TypeSelect, com.SelectContent{Selection: game.SelectionRock})
-	mustSend(conn, com.TypeResult, com.ResultContent{OpponentSelection: game.SelectionScissors, Result: game.ResultWin})
+	expectRead(conn, com.TypeSelect, com.SelectContent{Round: 4, Selection: game.SelectionRock})
+	mustSend(conn, com.TypeResult, com.ResultContent{
+		Round:             4,
+		OpponentSelection: game.SelectionScissors,
+		Result:            game.ResultWin,
+		Forfeit:           false,
+		Score:             1,
+		OpponentScore:     0,
+		Opponents:         nil,
+		Eliminated:        false,
+	})
+	mustSend(conn, com.TypeMatchEnd, com.MatchEndContent{Result: game.ResultWin, Score: 1, OpponentScore: 0})
+	mustWrite(input, "q")
 
 	if err := client.Wait(); err != nil {
 		log.Panicf("Unable to wait until client disconnects and closes. %s", err)
 	}
 }
 
-func startClient() (*exec.Cmd, io.WriteCloser) {
-	cmd := exec.Command("./bin/client")
+func testPlayRematch() {
+	log.Println("Test that client can play a rematch against the same opponent.")
+	server := startServer()
+	defer closeServer(server)
+
+	client, input := startClient()
+	defer closeClient(client)
+
+	conn := accept(server)
+	defer conn.Close()
+
+	expectHandshake(conn)
+	mustWrite(input, name)
+	expectRead(conn, com.TypeJoin, com.JoinContent{Name: name, Bot: ""})
+	for i := 0; i < 2; i++ {
+		mustSend(conn, com.TypeStart, com.StartContent{
+			OpponentName: "mickey",
+			Opponents:    []string{"mickey"},
+			Format:       game.BestOf(1),
+			Rules:        game.Classic().Name,
+			Options:      game.Classic().Options,
+			RoundTimeout: time.Minute,
+			ResumeToken:  "",
+			Tournament:   false,
+			CommitReveal: false,
+		})
+		mustWrite(input, game.SelectionRock)
+		expectRead(conn, com.TypeSelect, com.SelectContent{Round: 1, Selection: game.SelectionRock})
+		mustSend(conn, com.TypeResult, com.ResultContent{
+			Round:             1,
+			OpponentSelection: game.SelectionScissors,
+			Result:            game.ResultWin,
+			Forfeit:           false,
+			Score:             1,
+			OpponentScore:     0,
+			Opponents:         nil,
+			Eliminated:        false,
+		})
+		mustSend(conn, com.TypeMatchEnd, com.MatchEndContent{Result: game.ResultWin, Score: 1, OpponentScore: 0})
+		if i == 0 {
+			mustWrite(input, "r")
+			expectRead(conn, com.TypeRematchOffer, com.RematchOfferContent{})
+		}
+	}
+	mustWrite(input, "q")
+
+	if err := client.Wait(); err != nil {
+		log.Panicf("Unable to wait until client disconnects and closes. %s", err)
+	}
+}
+
+func testPlayAgainstNewOpponentAfterOpponentLeaves() {
+	log.Println("Test that client can play against a new opponent after the opponent leaves.")
+	server := startServer()
+	defer closeServer(server)
+
+	client, input := startClient()
+	defer closeClient(client)
+
+	conn := accept(server)
+	defer conn.Close()
+
+	expectHandshake(conn)
+	mustWrite(input, name)
+	expectRead(conn, com.TypeJoin, com.JoinContent{Name: name, Bot: ""})
+	mustSend(conn, com.TypeStart, com.StartContent{
+		OpponentName: "mickey",
+		Opponents:    []string{"mickey"},
+		Format:       game.BestOf(1),
+		Rules:        game.Classic().Name,
+		Options:      game.Classic().Options,
+		RoundTimeout: time.Minute,
+		ResumeToken:  "",
+		Tournament:   false,
+		CommitReveal: false,
+	})
+	mustWrite(input, game.SelectionRock)
+	expectRead(conn, com.TypeSelect, com.SelectContent{Round: 1, Selection: game.SelectionRock})
+	mustSend(conn, com.TypeError, com.ErrorContent{Code: com.CodeOpponentLeft, Message: "opponent left"})
+	mustWrite(input, "n")
+	expectRead(conn, com.TypeQueue, com.QueueContent{})
+	mustSend(conn, com.TypeStart, com.StartContent{
+		OpponentName: "goofy",
+		Opponents:    []string{"goofy"},
+		Format:       game.BestOf(1),
+		Rules:        game.Classic().Name,
+		Options:      game.Classic().Options,
+		RoundTimeout: time.Minute,
+		ResumeToken:  "",
+		Tournament:   false,
+		CommitReveal: false,
+	})
+	mustWrite(input, game.SelectionPaper)
+	expectRead(conn, com.TypeSelect, com.SelectContent{Round: 1, Selection: game.SelectionPaper})
+	mustSend(conn, com.TypeResult, com.ResultContent{
+		Round:             1,
+		OpponentSelection: game.SelectionRock,
+		Result:            game.ResultWin,
+		Forfeit:           false,
+		Score:             1,
+		OpponentScore:     0,
+		Opponents:         nil,
+		Eliminated:        false,
+	})
+	mustSend(conn, com.TypeMatchEnd, com.MatchEndContent{Result: game.ResultWin, Score: 1, OpponentScore: 0})
+	mustWrite(input, "q")
+
+	if err := client.Wait(); err != nil {
+		log.Panicf("Unable to wait until client disconnects and closes. %s", err)
+	}
+}
+
+func testResumeSessionAfterConnectionDrops() {
+	log.Println("Test that client reconnects and resumes the session after the connection drops.")
+	server := startServer()
+	defer closeServer(server)
+
+	client, input := startClient()
+	defer closeClient(client)
+
+	conn := accept(server)
+	expectHandshake(conn)
+	mustWrite(input, name)
+	expectRead(conn, com.TypeJoin, com.JoinContent{Name: name, Bot: ""})
+	start := com.StartContent{
+		OpponentName: "mickey",
+		Opponents:    []string{"mickey"},
+		Format:       game.BestOf(1),
+		Rules:        game.Classic().Name,
+		Options:      game.Classic().Options,
+		RoundTimeout: time.Minute,
+		ResumeToken:  "token",
+		Tournament:   false,
+		CommitReveal: false,
+	}
+	mustSend(conn, com.TypeStart, start)
+	conn.Close()
+	mustWrite(input, game.SelectionRock)
+
+	resumed := accept(server)
+	defer resumed.Close()
+	expectHandshake(resumed)
+	expectRead(resumed, com.TypeResume, com.ResumeContent{Token: "token"})
+	mustSend(resumed, com.TypeResumed, com.ResumedContent{
+		Start:          start,
+		Round:          1,
+		Selection:      game.SelectionRock,
+		Score:          0,
+		OpponentScore:  0,
+		OpponentScores: nil,
+		Eliminated:     false,
+		Ended:          false,
+		Offered:        false,
+	})
+	mustSend(resumed, com.TypeResult, com.ResultContent{
+		Round:             1,
+		OpponentSelection: game.SelectionScissors,
+		Result:            game.ResultWin,
+		Forfeit:           false,
+		Score:             1,
+		OpponentScore:     0,
+		Opponents:         nil,
+		Eliminated:        false,
+	})
+	mustSend(resumed, com.TypeMatchEnd, com.MatchEndContent{Result: game.ResultWin, Score: 1, OpponentScore: 0})
+	mustWrite(input, "q")
+
+	if err := client.Wait(); err != nil {
+		log.Panicf("Unable to wait until client disconnects and closes. %s", err)
+	}
+}
+
+func testCreatePrivateRoom() {
+	log.Println("Test that client creates a private room and waits for the opponent in it.")
+	server := startServer()
+	defer closeServer(server)
+
+	client, input := startClient("-create-room")
+	defer closeClient(client)
+
+	conn := accept(server)
+	defer conn.Close()
+
+	expectHandshake(conn)
+	mustWrite(input, name)
+	expectRead(conn, com.TypeCreateRoom, com.CreateRoomContent{Name: name})
+	mustSend(conn, com.TypeRoomCreated, com.RoomCreatedContent{Code: "ABC123"})
+	playOneRound(conn, input)
+	mustWrite(input, "q")
+
+	if err := client.Wait(); err != nil {
+		log.Panicf("Unable to wait until client disconnects and closes. %s", err)
+	}
+}
+
+func testJoinPrivateRoom() {
+	log.Println("Test that client joins a private room with the given room code.")
+	server := startServer()
+	defer closeServer(server)
+
+	client, input := startClient("-room", "ABC123")
+	defer closeClient(client)
+
+	conn := accept(server)
+	defer conn.Close()
+
+	expectHandshake(conn)
+	mustWrite(input, name)
+	expectRead(conn, com.TypeJoinRoom, com.JoinRoomContent{Name: name, Code: "ABC123"})
+	playOneRound(conn, input)
+	mustWrite(input, "q")
+
+	if err := client.Wait(); err != nil {
+		log.Panicf("Unable to wait until client disconnects and closes. %s", err)
+	}
+}
+
+func testSpectateSession() {
+	log.Println("Test that client spectates a session and returns to the session list when it closes.")
+	server := startServer()
+	defer closeServer(server)
+
+	client, input := startClient("-spectate")
+	defer closeClient(client)
+
+	conn := accept(server)
+	defer conn.Close()
+
+	expectHandshake(conn)
+	expectRead(conn, com.TypeSpectateList, com.SpectateListContent{})
+	session := com.SessionInfo{ID: 1, Players: []string{"donald", "mickey"}, Round: 1, Scores: []int{0, 0}}
+	mustSend(conn, com.TypeSpectateSessions, com.SpectateSessionsContent{Sessions: []com.SessionInfo{session}})
+	mustWrite(input, "1")
+	expectRead(conn, com.TypeSpectate, com.SpectateContent{SessionID: 1})
+	mustSend(conn, com.TypeSpectateStart, com.SpectateStartContent{
+		Session: session,
+		Format:  game.BestOf(1),
+		Rules:   game.Classic().Name,
+	})
+	mustSend(conn, com.TypeSpectateRound, com.SpectateRoundContent{
+		Round:      1,
+		Selections: []game.Selection{game.SelectionRock, game.SelectionPaper},
+		Results:    []game.Result{game.ResultLose, game.ResultWin},
+		Forfeit:    false,
+		Scores:     []int{0, 1},
+	})
+	mustSend(conn, com.TypeSpectateEnd, com.SpectateEndContent{
+		Results: []game.Result{game.ResultLose, game.ResultWin},
+		Scores:  []int{0, 1},
+	})
+	mustSend(conn, com.TypeError, com.ErrorContent{Code: com.CodeSessionClosed, Message: "closed"})
+	expectRead(conn, com.TypeSpectateList, com.SpectateListContent{})
+	mustSend(conn, com.TypeSpectateSessions, com.SpectateSessionsContent{Sessions: nil})
+	mustWrite(input, "q")
+
+	if err := client.Wait(); err != nil {
+		log.Panicf("Unable to wait until client disconnects and closes. %s", err)
+	}
+}
+
+func testPlayHeadless() {
+	log.Println("Test that client plays headless with a strategy and exits after the given matches.")
+	server := startServer()
+	defer closeServer(server)
+
+	client, _ := startClient("-strategy", "cycle", "-matches", "1")
+	defer closeClient(client)
+
+	conn := accept(server)
+	defer conn.Close()
+
+	expectHandshake(conn)
+	expectRead(conn, com.TypeJoin, com.JoinContent{Name: "cycle bot", Bot: ""})
+	mustSend(conn, com.TypeStart, com.StartContent{
+		OpponentName: "mickey",
+		Opponents:    []string{"mickey"},
+		Format:       game.BestOf(1),
+		Rules:        game.Classic().Name,
+		Options:      game.Classic().Options,
+		RoundTimeout: time.Minute,
+		ResumeToken:  "",
+		Tournament:   false,
+		CommitReveal: false,
+	})
+	expectRead(conn, com.TypeSelect, com.SelectContent{Round: 1, Selection: game.SelectionRock})
+	mustSend(conn, com.TypeResult, com.ResultContent{
+		Round:             1,
+		OpponentSelection: game.SelectionScissors,
+		Result:            game.ResultWin,
+		Forfeit:           false,
+		Score:             1,
+		OpponentScore:     0,
+		Opponents:         nil,
+		Eliminated:        false,
+	})
+	mustSend(conn, com.TypeMatchEnd, com.MatchEndContent{Result: game.ResultWin, Score: 1, OpponentScore: 0})
+
+	if err := client.Wait(); err != nil {
+		log.Panicf("Unable to wait until client disconnects and closes. %s", err)
+	}
+}
+
+func testPlayWithCommitReveal() {
+	log.Println("Test that client commits to its selection and reveals it with the nonce of the commitment.")
+	server := startServer()
+	defer closeServer(server)
+
+	client, input := startClient()
+	defer closeClient(client)
+
+	conn := accept(server)
+	defer conn.Close()
+
+	hello := mustRead[com.HelloContent](conn, com.TypeHello)
+	if !com.HasCapability(hello.Capabilities, com.CapabilityCommitReveal) {
+		log.Panicf("Expected client to support commit-reveal, but capabilities were %v", hello.Capabilities)
+	}
+	mustSend(conn, com.TypeWelcome, com.WelcomeContent{
+		Version:         hello.Version,
+		Capabilities:    hello.Capabilities,
+		AccountRequired: false,
+	})
+	mustWrite(input, name)
+	expectRead(conn, com.TypeJoin, com.JoinContent{Name: name, Bot: ""})
+	mustSend(conn, com.TypeStart, com.StartContent{
+		OpponentName: "mickey",
+		Opponents:    []string{"mickey"},
+		Format:       game.BestOf(1),
+		Rules:        game.Classic().Name,
+		Options:      game.Classic().Options,
+		RoundTimeout: time.Minute,
+		ResumeToken:  "",
+		Tournament:   false,
+		CommitReveal: true,
+	})
+	mustWrite(input, game.SelectionRock)
+	commit := mustRead[com.CommitContent](conn, com.TypeCommit)
+	mustSend(conn, com.TypeCommitted, com.CommittedContent{Round: 1})
+	reveal := mustRead[com.RevealContent](conn, com.TypeReveal)
+	if reveal.Selection != game.SelectionRock || com.Commit(reveal.Selection, reveal.Nonce) != commit.Commitment {
+		log.Panicf("Expected reveal %+v to match commitment %+v", reveal, commit)
+	}
+	mustSend(conn, com.TypeResult, com.ResultContent{
+		Round:             1,
+		OpponentSelection: game.SelectionScissors,
+		Result:            game.ResultWin,
+		Forfeit:           false,
+		Score:             1,
+		OpponentScore:     0,
+		Opponents:         nil,
+		Eliminated:        false,
+	})
+	mustSend(conn, com.TypeMatchEnd, com.MatchEndContent{Result: game.ResultWin, Score: 1, OpponentScore: 0})
+	mustWrite(input, "q")
+
+	if err := client.Wait(); err != nil {
+		log.Panicf("Unable to wait until client disconnects and closes. %s", err)
+	}
+}
+
+func playOneRound(conn net.Conn, input io.Writer) {
+	mustSend(conn, com.TypeStart, com.StartContent{
+		OpponentName: "mickey",
+		Opponents:    []string{"mickey"},
+		Format:       game.BestOf(1),
+		Rules:        game.Classic().Name,
+		Options:      game.Classic().Options,
+		RoundTimeout: time.Minute,
+		ResumeToken:  "",
+		Tournament:   false,
+		CommitReveal: false,
+	})
+	mustWrite(input, game.SelectionRock)
+	expectRead(conn, com.TypeSelect, com.SelectContent{Round: 1, Selection: game.SelectionRock})
+	mustSend(conn, com.TypeResult, com.ResultContent{
+		Round:             1,
+		OpponentSelection: game.SelectionScissors,
+		Result:            game.ResultWin,
+		Forfeit:           false,
+		Score:             1,
+		OpponentScore:     0,
+		Opponents:         nil,
+		Eliminated:        false,
+	})
+	mustSend(conn, com.TypeMatchEnd, com.MatchEndContent{Result: game.ResultWin, Score: 1, OpponentScore: 0})
+}
+
+func testReturnErrorWhenServerRejects() {
+	log.Println("Test that client exits with error code (1) if server rejects the handshake.")
+	server := startServer()
+	defer closeServer(server)
+
+	client, _ := startClient()
+	conn := accept(server)
+	defer conn.Close()
+
+	mustRead[com.HelloContent](conn, com.TypeHello)
+	mustSend(conn, com.TypeReject, com.RejectContent{Reason: "test", MinVersion: 0, MaxVersion: 0})
+
+	state, err := client.Process.Wait()
+	assertNoError(err)
+	assertExited(state)
+	assertExitCode(1, state.ExitCode())
+}
+
+func expectHandshake(conn net.Conn) {
+	hello := mustRead[com.HelloContent](conn, com.TypeHello)
+	if hello.Version != com.ProtocolVersion {
+		log.Panicf("Unexpected protocol version. Expected: %d Was: %d", com.ProtocolVersion, hello.Version)
+	}
+	mustSend(conn, com.TypeWelcome, com.WelcomeContent{Version: hello.Version, Capabilities: nil, AccountRequired: false})
+}
+
+func startClient(args ...string) (*exec.Cmd, io.WriteCloser) {
+	cmd := exec.Command("./bin/client", args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	writer, err := cmd.StdinPipe()
@@ -191,7 +685,7 @@ func mustSend[T any](writer io.Writer, messageType com.MessageType, val T) {
 	}
 }
 
-func mustWrite(writer io.Writer, val game.Selection) {
+func mustWrite[T ~string](writer io.Writer, val T) {
 	if _, err := writer.Write([]byte(val + "\n")); err != nil {
 		log.Panicf("Failed to write %s data to stdin. %s", val, err)
 	}
@@ -1,24 +1,36 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/toivjon/go-rps/internal/com"
 	"github.com/toivjon/go-rps/internal/game"
+	"github.com/toivjon/go-rps/internal/ws"
 )
 
 const (
 	serverHost    = "localhost"
 	serverPort    = 7777
+	webSocketPort = 7778
 	startupDelay  = 2 * time.Second
 	serverTimeout = 10 * time.Second
+	roundTimeout  = time.Second
 	name1         = "donald"
 	name2         = "mickey"
+	name3         = "goofy"
+	password      = "password"
 )
 
 func main() {
@@ -28,6 +40,20 @@ func main() {
 	testPlaySessionWithManyRounds()
 	testPlayManySessionsConcurrently()
 	testSessionEndsWhenClientDisconnects()
+	testRoundIsForfeitedOnTimeout()
+	testPlayRematchAfterMatchEnd()
+	testSessionResumesAfterReconnect()
+	testPlaySessionInPrivateRoom()
+	testSpectateSession()
+	testPlayFreeForAllSession()
+	testPlayTournament()
+	testPlayAgainstBot()
+	testPlaySessionWithCommitReveal()
+	testPlaySessionOverMutualTLS()
+	testPlaySessionOverWebSocket()
+	testPlaySessionWithAccounts()
+	testMatchHistoryOutlivesRestart()
+	testClientsAreNotifiedOnShutdown()
 }
 
 func testPlaySessionWithOneRound() {
@@ -49,13 +75,18 @@ func testPlaySessionWithOneRound() {
 	assertOpponentName(start1, name2)
 	assertOpponentName(start2, name1)
 
-	sendSelect(client1, game.SelectionRock)
-	sendSelect(client2, game.SelectionPaper)
+	sendSelect(client1, 1, game.SelectionRock)
+	sendSelect(client2, 1, game.SelectionPaper)
 
 	result1 := readResult(client1)
 	result2 := readResult(client2)
 	assertResult(result1, game.SelectionPaper, game.ResultLose)
 	assertResult(result2, game.SelectionRock, game.ResultWin)
+
+	matchEnd1 := readMatchEnd(client1)
+	matchEnd2 := readMatchEnd(client2)
+	assertMatchEnd(matchEnd1, game.ResultLose, 0, 1)
+	assertMatchEnd(matchEnd2, game.ResultWin, 1, 0)
 }
 
 func testPlaySessionWithManyRounds() {
@@ -77,29 +108,29 @@ func testPlaySessionWithManyRounds() {
 	assertOpponentName(start1, name2)
 	assertOpponentName(start2, name1)
 
-	sendSelect(client1, game.SelectionRock)
-	sendSelect(client2, game.SelectionRock)
+	sendSelect(client1, 1, game.SelectionRock)
+	sendSelect(client2, 1, game.SelectionRock)
 	result1 := readResult(client1)
 	result2 := readResult(client2)
 	assertResult(result1, game.SelectionRock, game.ResultDraw)
 	assertResult(result2, game.SelectionRock, game.ResultDraw)
 
-	sendSelect(client1, game.SelectionPaper)
-	sendSelect(client2, game.SelectionPaper)
+	sendSelect(client1, 2, game.SelectionPaper)
+	sendSelect(client2, 2, game.SelectionPaper)
 	result1 = readResult(client1)
 	result2 = readResult(client2)
 	assertResult(result1, game.SelectionPaper, game.ResultDraw)
 	assertResult(result2, game.SelectionPaper, game.ResultDraw)
 
-	sendSelect(client1, game.SelectionScissors)
-	sendSelect(client2, game.SelectionScissors)
+	sendSelect(client1, 3, game.SelectionScissors)
+	sendSelect(client2, 3, game.SelectionScissors)
 	result1 = readResult(client1)
 	result2 = readResult(client2)
 	assertResult(result1, game.SelectionScissors, game.ResultDraw)
 	assertResult(result2, game.SelectionScissors, game.ResultDraw)
 
-	sendSelect(client1, game.SelectionScissors)
-	sendSelect(client2, game.SelectionPaper)
+	sendSelect(client1, 4, game.SelectionScissors)
+	sendSelect(client2, 4, game.SelectionPaper)
 	result1 = readResult(client1)
 	result2 = readResult(client2)
 	assertResult(result1, game.SelectionPaper, game.ResultWin)
@@ -138,16 +169,16 @@ func testPlayManySessionsConcurrently() {
 	assertOpponentName(start3, name2)
 	assertOpponentName(start4, name1)
 
-	sendSelect(client1, game.SelectionRock)
-	sendSelect(client2, game.SelectionPaper)
-	sendSelect(client3, game.SelectionRock)
+	sendSelect(client1, 1, game.SelectionRock)
+	sendSelect(client2, 1, game.SelectionPaper)
+	sendSelect(client3, 1, game.SelectionRock)
 
 	result1 := readResult(client1)
 	result2 := readResult(client2)
 	assertResult(result1, game.SelectionPaper, game.ResultLose)
 	assertResult(result2, game.SelectionRock, game.ResultWin)
 
-	sendSelect(client4, game.SelectionPaper)
+	sendSelect(client4, 1, game.SelectionPaper)
 
 	result3 := readResult(client3)
 	result4 := readResult(client4)
@@ -157,7 +188,7 @@ func testPlayManySessionsConcurrently() {
 
 func testSessionEndsWhenClientDisconnects() {
 	log.Println("Test Session Ends When Client Disconnects")
-	server, cancel := startServer()
+	server, cancel := startServer("-resume-grace", "0")
 	defer closeServer(server, cancel)
 	time.Sleep(startupDelay)
 
@@ -174,16 +205,594 @@ func testSessionEndsWhenClientDisconnects() {
 	assertOpponentName(start1, name2)
 	assertOpponentName(start2, name1)
 
-	errCh := make(chan error)
-	go func() {
-		_, err := com.Read[com.Message](client1)
-		errCh <- err
-	}()
 	client2.Close()
-	err := <-errCh
-	if err == nil {
-		log.Panicf("Expected non-nil error, but received nil!")
+	message, err := com.ReadMessage[com.StartContent](client1)
+	var serverErr *com.ErrorContent
+	if !errors.As(err, &serverErr) {
+		log.Panicf("Expected ERROR message, but received %+v (err: %v)!", message, err)
+	}
+	if serverErr.Code != com.CodeOpponentLeft {
+		log.Panicf("Invalid error code. Expected: %q Was: %q", com.CodeOpponentLeft, serverErr.Code)
+	}
+
+	client3 := newClient()
+	defer client3.Close()
+	sendMessage(client1, com.TypeQueue)
+	sendJoin(client3, name2)
+	assertOpponentName(readStart(client1), name2)
+	assertOpponentName(readStart(client3), name1)
+}
+
+func testRoundIsForfeitedOnTimeout() {
+	log.Println("Test Round Is Forfeited On Timeout")
+	server, cancel := startServer("-round-timeout", roundTimeout.String())
+	defer closeServer(server, cancel)
+	time.Sleep(startupDelay)
+
+	client1 := newClient()
+	defer client1.Close()
+	client2 := newClient()
+	defer client2.Close()
+
+	sendJoin(client1, name1)
+	sendJoin(client2, name2)
+
+	start1 := readStart(client1)
+	readStart(client2)
+	if start1.RoundTimeout != roundTimeout {
+		log.Panicf("Invalid round timeout. Expected: %s Was: %s", roundTimeout, start1.RoundTimeout)
 	}
+
+	sendSelect(client1, 1, game.SelectionRock)
+
+	result1 := readResult(client1)
+	result2 := readResult(client2)
+	if !result1.Forfeit || !result2.Forfeit {
+		log.Panicf("Expected forfeited results, but received %+v and %+v!", result1, result2)
+	}
+	assertResult(result1, game.SelectionNone, game.ResultWin)
+	assertResult(result2, game.SelectionRock, game.ResultLose)
+}
+
+func testClientsAreNotifiedOnShutdown() {
+	log.Println("Test Clients Are Notified On Shutdown")
+	server, cancel := startServer()
+	defer cancel()
+	time.Sleep(startupDelay)
+
+	client1 := newClient()
+	defer client1.Close()
+	client2 := newClient()
+	defer client2.Close()
+
+	sendJoin(client1, name1)
+	sendJoin(client2, name2)
+	readStart(client1)
+	readStart(client2)
+
+	interruptServer(server)
+	for _, client := range []net.Conn{client1, client2} {
+		if _, err := com.ReadMessage[com.ShutdownContent](client); err != nil {
+			log.Panicf("failed to read SHUTDOWN message. %s", err)
+		}
+		if _, err := com.Read[com.Message](client); err == nil {
+			log.Panicf("Expected non-nil error, but received nil!")
+		}
+	}
+	if err := server.Wait(); err != nil {
+		log.Panicf("Expected server to exit successfully, but it failed. %s", err)
+	}
+}
+
+func testPlayRematchAfterMatchEnd() {
+	log.Println("Test Play Rematch After Match End")
+	server, cancel := startServer()
+	defer closeServer(server, cancel)
+	time.Sleep(startupDelay)
+
+	client1 := newClient()
+	defer client1.Close()
+	client2 := newClient()
+	defer client2.Close()
+
+	sendJoin(client1, name1)
+	sendJoin(client2, name2)
+	readStart(client1)
+	readStart(client2)
+	sendSelect(client1, 1, game.SelectionRock)
+	sendSelect(client2, 1, game.SelectionPaper)
+	readResult(client1)
+	readResult(client2)
+	assertMatchEnd(readMatchEnd(client1), game.ResultLose, 0, 1)
+	assertMatchEnd(readMatchEnd(client2), game.ResultWin, 1, 0)
+
+	sendMessage(client1, com.TypeRematchOffer)
+	readRematchOffer(client2)
+	sendMessage(client2, com.TypeRematchAccept)
+	assertOpponentName(readStart(client1), name2)
+	assertOpponentName(readStart(client2), name1)
+
+	sendSelect(client1, 1, game.SelectionScissors)
+	sendSelect(client2, 1, game.SelectionPaper)
+	assertResult(readResult(client1), game.SelectionPaper, game.ResultWin)
+	assertResult(readResult(client2), game.SelectionScissors, game.ResultLose)
+	assertMatchEnd(readMatchEnd(client1), game.ResultWin, 1, 0)
+	assertMatchEnd(readMatchEnd(client2), game.ResultLose, 0, 1)
+}
+
+func testSessionResumesAfterReconnect() {
+	log.Println("Test Session Resumes After Reconnect")
+	server, cancel := startServer()
+	defer closeServer(server, cancel)
+	time.Sleep(startupDelay)
+
+	client1 := newClient()
+	defer client1.Close()
+	client2 := newClient()
+	defer client2.Close()
+
+	sendJoin(client1, name1)
+	sendJoin(client2, name2)
+	start1 := readStart(client1)
+	readStart(client2)
+	sendSelect(client1, 1, game.SelectionRock)
+	client1.Close()
+
+	resumed := newClient()
+	defer resumed.Close()
+	sendResume(resumed, start1.ResumeToken)
+	content := readResumed(resumed)
+	if content.Round != 1 || content.Selection != game.SelectionRock {
+		log.Panicf("Invalid resumed state. Expected round 1 with rock. Was: %+v", content)
+	}
+	assertOpponentName(content.Start, name2)
+
+	sendSelect(client2, 1, game.SelectionScissors)
+	assertResult(readResult(resumed), game.SelectionScissors, game.ResultWin)
+	assertResult(readResult(client2), game.SelectionRock, game.ResultLose)
+	assertMatchEnd(readMatchEnd(resumed), game.ResultWin, 1, 0)
+	assertMatchEnd(readMatchEnd(client2), game.ResultLose, 0, 1)
+}
+
+func testPlaySessionInPrivateRoom() {
+	log.Println("Test Play Session In Private Room")
+	server, cancel := startServer()
+	defer closeServer(server, cancel)
+	time.Sleep(startupDelay)
+
+	client1 := newClient()
+	defer client1.Close()
+	client2 := newClient()
+	defer client2.Close()
+	client3 := newClient()
+	defer client3.Close()
+
+	if err := com.WriteMessage(client1, com.TypeCreateRoom, com.CreateRoomContent{Name: name1}); err != nil {
+		log.Panicf("failed to write CREATE_ROOM message to connection. %s", err)
+	}
+	room, err := com.ReadMessage[com.RoomCreatedContent](client1)
+	if err != nil {
+		log.Panicf("failed to read ROOM_CREATED message. %s", err)
+	}
+	sendJoin(client3, "goofy")
+	content := com.JoinRoomContent{Name: name2, Code: room.Code}
+	if err := com.WriteMessage(client2, com.TypeJoinRoom, content); err != nil {
+		log.Panicf("failed to write JOIN_ROOM message to connection. %s", err)
+	}
+	assertOpponentName(readStart(client1), name2)
+	assertOpponentName(readStart(client2), name1)
+
+	sendSelect(client1, 1, game.SelectionRock)
+	sendSelect(client2, 1, game.SelectionScissors)
+	assertResult(readResult(client1), game.SelectionScissors, game.ResultWin)
+	assertResult(readResult(client2), game.SelectionRock, game.ResultLose)
+	assertMatchEnd(readMatchEnd(client1), game.ResultWin, 1, 0)
+	assertMatchEnd(readMatchEnd(client2), game.ResultLose, 0, 1)
+}
+
+func testSpectateSession() {
+	log.Println("Test Spectate Session")
+	server, cancel := startServer()
+	defer closeServer(server, cancel)
+	time.Sleep(startupDelay)
+
+	client1 := newClient()
+	defer client1.Close()
+	client2 := newClient()
+	defer client2.Close()
+	spectator := newClient()
+	defer spectator.Close()
+
+	sendJoin(client1, name1)
+	sendJoin(client2, name2)
+	readStart(client1)
+	readStart(client2)
+
+	sendMessage(spectator, com.TypeSpectateList)
+	sessions, err := com.ReadMessage[com.SpectateSessionsContent](spectator)
+	if err != nil {
+		log.Panicf("failed to read SPECTATE_SESSIONS message. %s", err)
+	}
+	if len(sessions.Sessions) != 1 {
+		log.Panicf("Invalid sessions. Expected one session of %q and %q. Was: %+v", name1, name2, sessions.Sessions)
+	}
+	// The joins are handled in the arrival order so the player which is seated first varies between runs.
+	selection1, selection2, result1 := game.SelectionRock, game.SelectionPaper, game.ResultLose
+	if sessions.Sessions[0].Players[0] == name2 {
+		selection1, selection2, result1 = game.SelectionPaper, game.SelectionRock, game.ResultWin
+	}
+	content := com.SpectateContent{SessionID: sessions.Sessions[0].ID}
+	if err := com.WriteMessage(spectator, com.TypeSpectate, content); err != nil {
+		log.Panicf("failed to write SPECTATE message to connection. %s", err)
+	}
+	if _, err := com.ReadMessage[com.SpectateStartContent](spectator); err != nil {
+		log.Panicf("failed to read SPECTATE_START message. %s", err)
+	}
+
+	sendSelect(client1, 1, game.SelectionRock)
+	sendSelect(client2, 1, game.SelectionPaper)
+	round, err := com.ReadMessage[com.SpectateRoundContent](spectator)
+	if err != nil {
+		log.Panicf("failed to read SPECTATE_ROUND message. %s", err)
+	}
+	if len(round.Selections) != 2 || round.Selections[0] != selection1 || round.Selections[1] != selection2 {
+		log.Panicf("Invalid spectated round. Expected %q and %q. Was: %+v", selection1, selection2, round)
+	}
+	end, err := com.ReadMessage[com.SpectateEndContent](spectator)
+	if err != nil {
+		log.Panicf("failed to read SPECTATE_END message. %s", err)
+	}
+	if len(end.Results) != 2 || end.Results[0] != result1 || end.Scores[0]+end.Scores[1] != 1 {
+		log.Panicf("Invalid spectated match end. Was: %+v", end)
+	}
+}
+
+func testPlayFreeForAllSession() {
+	log.Println("Test Play Free-For-All Session")
+	server, cancel := startServer("-players", "3")
+	defer closeServer(server, cancel)
+	time.Sleep(startupDelay)
+
+	client1 := newClient()
+	defer client1.Close()
+	client2 := newClient()
+	defer client2.Close()
+	client3 := newClient()
+	defer client3.Close()
+
+	sendJoin(client1, name1)
+	sendJoin(client2, name2)
+	sendJoin(client3, name3)
+
+	opponents := readStart(client1).Opponents
+	readStart(client2)
+	readStart(client3)
+	sort.Strings(opponents)
+	if !reflect.DeepEqual(opponents, []string{name3, name2}) {
+		log.Panicf("Invalid opponents. Expected: %q Was: %q", []string{name3, name2}, opponents)
+	}
+
+	sendSelect(client1, 1, game.SelectionRock)
+	sendSelect(client2, 1, game.SelectionRock)
+	sendSelect(client3, 1, game.SelectionScissors)
+
+	result1 := readResult(client1)
+	readResult(client2)
+	result3 := readResult(client3)
+	if result1.Result != game.ResultWin || result1.Eliminated || result3.Result != game.ResultLose || !result3.Eliminated {
+		log.Panicf("Invalid results. Expected only the third player to be eliminated: %+v %+v", result1, result3)
+	}
+
+	sendSelect(client1, 2, game.SelectionPaper)
+	sendSelect(client2, 2, game.SelectionRock)
+
+	result1 = readResult(client1)
+	result2 := readResult(client2)
+	readResult(client3)
+	if result1.Result != game.ResultWin || result2.Result != game.ResultLose {
+		log.Panicf("Invalid results. Expected the first player to win the game: %+v %+v", result1, result2)
+	}
+
+	assertMatchEnd(readMatchEnd(client1), game.ResultWin, 1, 0)
+	assertMatchEnd(readMatchEnd(client2), game.ResultLose, 0, 1)
+	assertMatchEnd(readMatchEnd(client3), game.ResultLose, 0, 1)
+}
+
+func testPlayTournament() {
+	log.Println("Test Play Tournament")
+	server, cancel := startServer("-tournament-size", "2")
+	defer closeServer(server, cancel)
+	time.Sleep(startupDelay)
+
+	client1 := newClient()
+	defer client1.Close()
+	client2 := newClient()
+	defer client2.Close()
+
+	sendTournamentJoin(client1, name1)
+	if bracket := readBracket(client1); !reflect.DeepEqual(bracket.Players, []string{name1}) || len(bracket.Rounds) != 0 {
+		log.Panicf("Invalid bracket. Expected only the registration of %q: %+v", name1, bracket)
+	}
+	sendTournamentJoin(client2, name2)
+	if bracket := readBracket(client1); bracket.Opponent != name2 || len(bracket.Rounds) != 1 {
+		log.Panicf("Invalid bracket. Expected a single pairing against %q: %+v", name2, bracket)
+	}
+	readBracket(client2)
+	if start := readStart(client1); start.OpponentName != name2 || !start.Tournament {
+		log.Panicf("Invalid START. Expected a tournament match against %q: %+v", name2, start)
+	}
+	readStart(client2)
+
+	sendSelect(client1, 1, game.SelectionRock)
+	sendSelect(client2, 1, game.SelectionScissors)
+	assertResult(readResult(client1), game.SelectionScissors, game.ResultWin)
+	assertResult(readResult(client2), game.SelectionRock, game.ResultLose)
+	assertMatchEnd(readMatchEnd(client1), game.ResultWin, 1, 0)
+	assertMatchEnd(readMatchEnd(client2), game.ResultLose, 0, 1)
+
+	if bracket := readBracket(client1); bracket.Champion != name1 {
+		log.Panicf("Invalid bracket. Expected %q to be the champion: %+v", name1, bracket)
+	}
+	if bracket := readBracket(client2); bracket.Champion != name1 || !bracket.Eliminated {
+		log.Panicf("Invalid bracket. Expected %q to be eliminated: %+v", name2, bracket)
+	}
+}
+
+func testPlayAgainstBot() {
+	log.Println("Test Play Against Bot")
+	server, cancel := startServer("-bot-wait", "100ms")
+	defer closeServer(server, cancel)
+	time.Sleep(startupDelay)
+
+	client1 := newClient()
+	defer client1.Close()
+
+	sendJoin(client1, name1)
+	start := readStart(client1)
+	if !strings.HasPrefix(start.OpponentName, "bot") {
+		log.Panicf("Invalid opponent. Expected a bot: %q", start.OpponentName)
+	}
+
+	sendSelect(client1, 1, game.SelectionRock)
+	result := readResult(client1)
+	for result.Result == game.ResultDraw {
+		sendSelect(client1, result.Round+1, game.SelectionRock)
+		result = readResult(client1)
+	}
+	if result.OpponentSelection == game.SelectionNone || result.Forfeit {
+		log.Panicf("Invalid result. Expected the bot to select: %+v", result)
+	}
+	readMatchEnd(client1)
+}
+
+func testPlaySessionWithCommitReveal() {
+	log.Println("Test Play Session With Commit Reveal")
+	server, cancel := startServer()
+	defer closeServer(server, cancel)
+	time.Sleep(startupDelay)
+
+	client1 := newClient(com.CapabilityCommitReveal)
+	defer client1.Close()
+	client2 := newClient(com.CapabilityCommitReveal)
+	defer client2.Close()
+
+	sendJoin(client1, name1)
+	sendJoin(client2, name2)
+	if start1, start2 := readStart(client1), readStart(client2); !start1.CommitReveal || !start2.CommitReveal {
+		log.Panicf("Invalid start. Expected commit-reveal: %+v and %+v", start1, start2)
+	}
+
+	sendCommit(client1, 1, com.Commit(game.SelectionRock, "nonce1"))
+	sendCommit(client2, 1, com.Commit(game.SelectionScissors, "nonce2"))
+	readCommitted(client1)
+	readCommitted(client2)
+
+	sendReveal(client1, 1, game.SelectionRock, "nonce1")
+	sendReveal(client2, 1, game.SelectionPaper, "nonce2")
+	result1 := readResult(client1)
+	result2 := readResult(client2)
+	if result1.Result != game.ResultWin || result2.Result != game.ResultLose || !result1.Forfeit {
+		log.Panicf("Invalid result. Expected mismatched reveal to forfeit: %+v and %+v", result1, result2)
+	}
+	assertMatchEnd(readMatchEnd(client1), game.ResultWin, 1, 0)
+	assertMatchEnd(readMatchEnd(client2), game.ResultLose, 0, 1)
+}
+
+func testPlaySessionOverMutualTLS() {
+	log.Println("Test Play Session Over Mutual TLS")
+	ca := newAuthority()
+	defer ca.close()
+	serverFiles := ca.issueServer()
+	server, cancel := startServer("-tls-cert", serverFiles.Cert, "-tls-key", serverFiles.Key,
+		"-tls-client-ca", ca.Files.Cert)
+	defer closeServer(server, cancel)
+	time.Sleep(startupDelay)
+
+	client1 := newTLSClient(ca, ca.issueClient(name1))
+	defer client1.Close()
+	client2 := newTLSClient(ca, ca.issueClient(name2))
+	defer client2.Close()
+
+	// The names in the client certificates override the names the clients declare.
+	sendJoin(client1, name3)
+	sendJoin(client2, name3)
+	assertOpponentName(readStart(client1), name2)
+	assertOpponentName(readStart(client2), name1)
+
+	sendSelect(client1, 1, game.SelectionRock)
+	sendSelect(client2, 1, game.SelectionScissors)
+	assertResult(readResult(client1), game.SelectionScissors, game.ResultWin)
+	assertResult(readResult(client2), game.SelectionRock, game.ResultLose)
+	assertMatchEnd(readMatchEnd(client1), game.ResultWin, 1, 0)
+	assertMatchEnd(readMatchEnd(client2), game.ResultLose, 0, 1)
+}
+
+func testPlaySessionOverWebSocket() {
+	log.Println("Test Play Session Over WebSocket")
+	server, cancel := startServer("-ws-port", fmt.Sprint(webSocketPort))
+	defer closeServer(server, cancel)
+	time.Sleep(startupDelay)
+
+	client1 := newClient()
+	defer client1.Close()
+	client2 := newWebSocketClient()
+	defer client2.Close()
+
+	sendJoin(client1, name1)
+	sendJoin(client2, name2)
+	assertOpponentName(readStart(client1), name2)
+	assertOpponentName(readStart(client2), name1)
+
+	sendSelect(client1, 1, game.SelectionPaper)
+	sendSelect(client2, 1, game.SelectionRock)
+	assertResult(readResult(client1), game.SelectionRock, game.ResultWin)
+	assertResult(readResult(client2), game.SelectionPaper, game.ResultLose)
+	assertMatchEnd(readMatchEnd(client1), game.ResultWin, 1, 0)
+	assertMatchEnd(readMatchEnd(client2), game.ResultLose, 0, 1)
+}
+
+func testPlaySessionWithAccounts() {
+	log.Println("Test Play Session With Accounts")
+	server, cancel := startServer("-guests=false")
+	defer closeServer(server, cancel)
+	time.Sleep(startupDelay)
+
+	client1 := newClient()
+	defer client1.Close()
+	client2 := newClient()
+	defer client2.Close()
+
+	// Guests are not accepted, so the players must register or log in before joining.
+	sendJoin(client1, name1)
+	assertErrorCode(client1, com.CodeAuthRequired)
+	sendRegister(client1, name1)
+	token := readAuthenticated(client1, name1)
+	sendRegister(client2, name1)
+	assertErrorCode(client2, com.CodeNameTaken)
+	sendLogin(client2, name2, password, "")
+	assertErrorCode(client2, com.CodeAuthFailed)
+	sendRegister(client2, name2)
+	readAuthenticated(client2, name2)
+
+	// The names of the accounts override the names the players declare.
+	sendJoin(client1, name3)
+	sendJoin(client2, name3)
+	assertOpponentName(readStart(client1), name2)
+	assertOpponentName(readStart(client2), name1)
+
+	sendSelect(client1, 1, game.SelectionScissors)
+	sendSelect(client2, 1, game.SelectionPaper)
+	assertResult(readResult(client1), game.SelectionPaper, game.ResultWin)
+	assertResult(readResult(client2), game.SelectionScissors, game.ResultLose)
+	assertMatchEnd(readMatchEnd(client1), game.ResultWin, 1, 0)
+	assertMatchEnd(readMatchEnd(client2), game.ResultLose, 0, 1)
+
+	// The account can be logged in on a single connection at a time. The delay lets the throttling of the
+	// failed login to pass, as all clients connect from the same address.
+	time.Sleep(time.Second)
+	client3 := newClient()
+	defer client3.Close()
+	sendLogin(client3, "", "", token)
+	assertErrorCode(client3, com.CodeAuthFailed)
+
+	// The session token logs in to the account without the password once the account is no longer in use.
+	client1.Close()
+	time.Sleep(time.Second)
+	sendLogin(client3, "", "", token)
+	readAuthenticated(client3, name1)
+}
+
+func testMatchHistoryOutlivesRestart() {
+	log.Println("Test Match History Outlives Restart")
+	dir, err := os.MkdirTemp("", "rps-systest-")
+	if err != nil {
+		log.Panicf("Failed to create temporary directory. %s", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "history.jsonl")
+
+	// Each server appends the decided matches to the same history file.
+	for _, names := range [][]string{{name1, name2}, {name2, name3}} {
+		playOneRoundMatch(file, names[0], names[1])
+	}
+
+	matches := readHistory(file)
+	if len(matches) != 2 {
+		log.Panicf("Invalid number of recorded matches. Expected: %d Was: %d", 2, len(matches))
+	}
+	// The seat order depends on the order in which the server handled the joins.
+	for i, winner := range []string{name1, name2} {
+		match := matches[i]
+		if len(match.Players) != 2 || len(match.Results) != 2 || len(match.Rounds) != 1 {
+			log.Panicf("Invalid recorded match. Expected 2 players and 1 round. Was: %+v", match)
+		}
+		seat := 0
+		if match.Players[1] == winner {
+			seat = 1
+		}
+		if match.Players[seat] != winner || match.Results[seat] != game.ResultWin {
+			log.Panicf("Invalid recorded winner. Expected: %q Was: %+v", winner, match)
+		}
+		if match.Rounds[0].Selections[seat] != game.SelectionRock {
+			log.Panicf("Invalid recorded selection. Expected: %q Was: %+v", game.SelectionRock, match.Rounds[0])
+		}
+	}
+}
+
+// playOneRoundMatch starts a server which records the match history in the given file and plays a single
+// round match where the first player wins, after which the server is shut down gracefully so that the history
+// gets written to the file.
+func playOneRoundMatch(file, winner, loser string) {
+	server, cancel := startServer("-history", file)
+	defer cancel()
+	time.Sleep(startupDelay)
+
+	client1 := newClient()
+	client2 := newClient()
+
+	sendJoin(client1, winner)
+	sendJoin(client2, loser)
+	readStart(client1)
+	readStart(client2)
+	sendSelect(client1, 1, game.SelectionRock)
+	sendSelect(client2, 1, game.SelectionScissors)
+	readResult(client1)
+	readResult(client2)
+	readMatchEnd(client1)
+	readMatchEnd(client2)
+	client1.Close()
+	client2.Close()
+
+	interruptServer(server)
+	if err := server.Wait(); err != nil {
+		log.Panicf("Expected server to exit successfully, but it failed. %s", err)
+	}
+}
+
+// recordedMatch contains the recorded match fields which are asserted from the match history file.
+type recordedMatch struct {
+	Players []string
+	Results []game.Result
+	Rounds  []struct {
+		Selections []game.Selection
+	}
+}
+
+// readHistory reads the matches from the given match history file where each line contains a single match.
+func readHistory(file string) []recordedMatch {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		log.Panicf("Failed to read match history. %s", err)
+	}
+	matches := []recordedMatch{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		match := recordedMatch{Players: nil, Results: nil, Rounds: nil}
+		if err := json.Unmarshal([]byte(line), &match); err != nil {
+			log.Panicf("Failed to parse match history line %q. %s", line, err)
+		}
+		matches = append(matches, match)
+	}
+	return matches
 }
 
 func assertOpponentName(start com.StartContent, expected string) {
@@ -207,16 +816,61 @@ func assertResult(result com.ResultContent, expectedOpponentSelection game.Selec
 	}
 }
 
-func newClient() net.Conn {
-	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%d", serverHost, serverPort))
+func assertMatchEnd(matchEnd com.MatchEndContent, result game.Result, score, opponentScore int) {
+	expected := com.MatchEndContent{Result: result, Score: score, OpponentScore: opponentScore}
+	if matchEnd != expected {
+		log.Panicf("Invalid match end. Expected: %+v Was: %+v", expected, matchEnd)
+	}
+}
+
+func newClient(capabilities ...com.Capability) net.Conn {
+	conn, err := net.Dial("tcp", net.JoinHostPort(serverHost, fmt.Sprint(serverPort)))
 	if err != nil {
 		log.Panicf("Failed to open TCP connection to server. %s", err)
 	}
+	sendHello(conn, capabilities...)
+	readWelcome(conn)
+	return conn
+}
+
+func newWebSocketClient() net.Conn {
+	address := net.JoinHostPort(serverHost, fmt.Sprint(webSocketPort))
+	conn, err := ws.Dial(context.Background(), address, "/ws")
+	if err != nil {
+		log.Panicf("Failed to open WebSocket connection to server. %s", err)
+	}
+	sendHello(conn)
+	readWelcome(conn)
 	return conn
 }
 
+func sendHello(writer io.Writer, capabilities ...com.Capability) {
+	content, err := json.Marshal(com.HelloContent{Version: com.ProtocolVersion, Capabilities: capabilities})
+	if err != nil {
+		log.Panicf("failed marshal HELLO content into JSON. %s", err)
+	}
+	if err := com.Write(writer, com.Message{Type: com.TypeHello, Content: content}); err != nil {
+		log.Panicf("failed to write HELLO message to connection. %s", err)
+	}
+}
+
+func readWelcome(reader io.Reader) com.WelcomeContent {
+	message, err := com.Read[com.Message](reader)
+	if err != nil {
+		log.Panicf("failed to read WELCOME message. %s", err)
+	}
+	if message.Type != com.TypeWelcome {
+		log.Panicf("Unexpected message type received. Expected: %s Was: %s", com.TypeWelcome, message.Type)
+	}
+	content := com.WelcomeContent{Version: 0, Capabilities: nil, AccountRequired: false}
+	if err := json.Unmarshal(message.Content, &content); err != nil {
+		log.Panicf("failed to read WELCOME content. %s", err)
+	}
+	return content
+}
+
 func sendJoin(writer io.Writer, name string) {
-	content, err := json.Marshal(com.JoinContent{Name: name})
+	content, err := json.Marshal(com.JoinContent{Name: name, Bot: ""})
 	if err != nil {
 		log.Panicf("failed marshal JOIN content into JSON. %s", err)
 	}
@@ -230,15 +884,25 @@ func readStart(reader io.Reader) com.StartContent {
 	if err != nil {
 		log.Panicf("failed to read START message. %s", err)
 	}
-	content := com.StartContent{OpponentName: ""}
+	content := com.StartContent{
+		OpponentName: "",
+		Opponents:    nil,
+		Format:       game.Format{Name: "", WinsNeeded: 0},
+		Rules:        "",
+		Options:      nil,
+		RoundTimeout: 0,
+		ResumeToken:  "",
+		Tournament:   false,
+		CommitReveal: false,
+	}
 	if err := json.Unmarshal(message.Content, &content); err != nil {
 		log.Panicf("failed to read START content. %s", err)
 	}
 	return content
 }
 
-func sendSelect(writer io.Writer, selection game.Selection) {
-	content, err := json.Marshal(com.SelectContent{Selection: selection})
+func sendSelect(writer io.Writer, round int, selection game.Selection) {
+	content, err := json.Marshal(com.SelectContent{Round: round, Selection: selection})
 	if err != nil {
 		log.Panicf("failed to marshal SELECT content into JSON. %s", err)
 	}
@@ -247,14 +911,126 @@ func sendSelect(writer io.Writer, selection game.Selection) {
 	}
 }
 
+func sendCommit(writer io.Writer, round int, commitment string) {
+	content := com.CommitContent{Round: round, Commitment: commitment}
+	if err := com.WriteMessage(writer, com.TypeCommit, content); err != nil {
+		log.Panicf("failed to write COMMIT message to connection. %s", err)
+	}
+}
+
+func readCommitted(reader io.Reader) {
+	if _, err := com.ReadMessage[com.CommittedContent](reader); err != nil {
+		log.Panicf("failed to read COMMITTED message. %s", err)
+	}
+}
+
+func sendReveal(writer io.Writer, round int, selection game.Selection, nonce string) {
+	content := com.RevealContent{Round: round, Selection: selection, Nonce: nonce}
+	if err := com.WriteMessage(writer, com.TypeReveal, content); err != nil {
+		log.Panicf("failed to write REVEAL message to connection. %s", err)
+	}
+}
+
 func readResult(reader io.Reader) com.ResultContent {
 	message, err := com.Read[com.Message](reader)
 	if err != nil {
 		log.Panicf("failed to read RESULT message. %s", err)
 	}
-	content := com.ResultContent{OpponentSelection: "", Result: ""}
+	content := com.ResultContent{
+		Round:             0,
+		OpponentSelection: "",
+		Result:            "",
+		Forfeit:           false,
+		Score:             0,
+		OpponentScore:     0,
+		Opponents:         nil,
+		Eliminated:        false,
+	}
 	if err := json.Unmarshal(message.Content, &content); err != nil {
 		log.Panicf("failed to read RESULT content. %s", err)
 	}
 	return content
 }
+
+func readMatchEnd(reader io.Reader) com.MatchEndContent {
+	content, err := com.ReadMessage[com.MatchEndContent](reader)
+	if err != nil {
+		log.Panicf("failed to read MATCH_END message. %s", err)
+	}
+	return *content
+}
+
+func sendMessage(writer io.Writer, messageType com.MessageType) {
+	if err := com.WriteMessage(writer, messageType, struct{}{}); err != nil {
+		log.Panicf("failed to write %s message to connection. %s", messageType, err)
+	}
+}
+
+func readRematchOffer(reader io.Reader) {
+	if _, err := com.ReadMessage[com.RematchOfferContent](reader); err != nil {
+		log.Panicf("failed to read REMATCH_OFFER message. %s", err)
+	}
+}
+
+func sendResume(writer io.Writer, token string) {
+	if err := com.WriteMessage(writer, com.TypeResume, com.ResumeContent{Token: token}); err != nil {
+		log.Panicf("failed to write RESUME message to connection. %s", err)
+	}
+}
+
+func readResumed(reader io.Reader) com.ResumedContent {
+	content, err := com.ReadMessage[com.ResumedContent](reader)
+	if err != nil {
+		log.Panicf("failed to read RESUMED message. %s", err)
+	}
+	return *content
+}
+
+func sendTournamentJoin(writer io.Writer, name string) {
+	if err := com.WriteMessage(writer, com.TypeTournamentJoin, com.TournamentJoinContent{Name: name}); err != nil {
+		log.Panicf("failed to write TOURNAMENT_JOIN message to connection. %s", err)
+	}
+}
+
+func readBracket(reader io.Reader) com.BracketContent {
+	content, err := com.ReadMessage[com.BracketContent](reader)
+	if err != nil {
+		log.Panicf("failed to read BRACKET message. %s", err)
+	}
+	return *content
+}
+
+func sendRegister(writer io.Writer, name string) {
+	if err := com.WriteMessage(writer, com.TypeRegister, com.RegisterContent{Name: name, Password: password}); err != nil {
+		log.Panicf("failed to write REGISTER message to connection. %s", err)
+	}
+}
+
+func sendLogin(writer io.Writer, name, password, token string) {
+	content := com.LoginContent{Name: name, Password: password, Token: token}
+	if err := com.WriteMessage(writer, com.TypeLogin, content); err != nil {
+		log.Panicf("failed to write LOGIN message to connection. %s", err)
+	}
+}
+
+func readAuthenticated(reader io.Reader, expectedName string) string {
+	content, err := com.ReadMessage[com.AuthenticatedContent](reader)
+	if err != nil {
+		log.Panicf("failed to read AUTHENTICATED message. %s", err)
+	}
+	if content.Name != expectedName || content.Token == "" {
+		log.Panicf("Invalid authentication. Expected account %q with a token, but was %+v", expectedName, content)
+	}
+	return content.Token
+}
+
+func assertErrorCode(reader io.Reader, expected com.ErrorCode) {
+	message, err := com.ReadMessage[com.Message](reader)
+	var serverErr *com.ErrorContent
+	if !errors.As(err, &serverErr) {
+		log.Panicf("Expected ERROR message, but received %+v (err: %v)!", message, err)
+	}
+	if serverErr.Code != expected {
+		log.Panicf("Invalid error code. Expected: %q Was: %q", expected, serverErr.Code)
+	}
+}
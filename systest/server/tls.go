@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"os"
+
+	"github.com/toivjon/go-rps/internal/com"
+	"github.com/toivjon/go-rps/internal/testcert"
+)
+
+// authority is a certificate authority which signs the server and the client certificates of the tests.
+type authority struct {
+	*testcert.Authority
+	dir string
+}
+
+func newAuthority() *authority {
+	dir, err := os.MkdirTemp("", "rps-systest-")
+	if err != nil {
+		log.Panicf("Failed to create certificate directory. %s", err)
+	}
+	ca, err := testcert.NewAuthority(dir)
+	if err != nil {
+		log.Panicf("Failed to generate certificate authority. %s", err)
+	}
+	return &authority{Authority: ca, dir: dir}
+}
+
+func (a *authority) close() {
+	if err := os.RemoveAll(a.dir); err != nil {
+		log.Panicf("Failed to remove certificate directory. %s", err)
+	}
+}
+
+func (a *authority) issueServer() testcert.Files {
+	files, err := a.IssueServer(serverHost)
+	if err != nil {
+		log.Panicf("Failed to issue server certificate. %s", err)
+	}
+	return files
+}
+
+func (a *authority) issueClient(name string) testcert.Files {
+	files, err := a.IssueClient(name)
+	if err != nil {
+		log.Panicf("Failed to issue client certificate. %s", err)
+	}
+	return files
+}
+
+func newTLSClient(ca *authority, files testcert.Files) net.Conn {
+	config, err := com.ClientTLSConfig(ca.Files.Cert, files.Cert, files.Key, false)
+	if err != nil {
+		log.Panicf("Failed to build client TLS configuration. %s", err)
+	}
+	conn, err := tls.Dial("tcp", net.JoinHostPort(serverHost, fmt.Sprint(serverPort)), config)
+	if err != nil {
+		log.Panicf("Failed to open TLS connection to server. %s", err)
+	}
+	sendHello(conn)
+	readWelcome(conn)
+	return conn
+}
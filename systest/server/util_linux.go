@@ -9,11 +9,11 @@ import (
 	"os/exec"
 )
 
-func startServer() (*exec.Cmd, context.CancelFunc) {
+func startServer(args ...string) (*exec.Cmd, context.CancelFunc) {
 	// We want to automatically kill the server if the process jams or if it cannot be gracefully closed.
 	ctx, cancel := context.WithTimeout(context.Background(), serverTimeout)
 
-	cmd := exec.CommandContext(ctx, "./bin/server")
+	cmd := exec.CommandContext(ctx, "./bin/server", args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
@@ -29,3 +29,9 @@ func closeServer(server *exec.Cmd, cancel context.CancelFunc) {
 		log.Panicf("Failed to kill server process. %s", err)
 	}
 }
+
+func interruptServer(server *exec.Cmd) {
+	if err := server.Process.Signal(os.Interrupt); err != nil {
+		log.Panicf("Failed to interrupt server process. %s", err)
+	}
+}
@@ -10,11 +10,11 @@ import (
 	"syscall"
 )
 
-func startServer() (*exec.Cmd, context.CancelFunc) {
+func startServer(args ...string) (*exec.Cmd, context.CancelFunc) {
 	// We want to automatically kill the server if the process jams or if it cannot be gracefully closed.
 	ctx, cancel := context.WithTimeout(context.Background(), serverTimeout)
 
-	cmd := exec.CommandContext(ctx, "./bin/server")
+	cmd := exec.CommandContext(ctx, "./bin/server", args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.SysProcAttr = new(syscall.SysProcAttr)
@@ -28,6 +28,13 @@ func startServer() (*exec.Cmd, context.CancelFunc) {
 
 func closeServer(server *exec.Cmd, cancel context.CancelFunc) {
 	defer cancel()
+	interruptServer(server)
+	if _, err := server.Process.Wait(); err != nil {
+		log.Panicf("Failed to wait process. %s", err)
+	}
+}
+
+func interruptServer(server *exec.Cmd) {
 	dll, err := syscall.LoadDLL("kernel32.dll")
 	if err != nil {
 		log.Panicf("Failed to load kernel32.dll. %s", err)
@@ -45,7 +52,4 @@ func closeServer(server *exec.Cmd, cancel context.CancelFunc) {
 	if result == 0 {
 		log.Panicf("Failed to call break event. %s", e)
 	}
-	if _, err := server.Process.Wait(); err != nil {
-		log.Panicf("Failed to wait process. %s", err)
-	}
 }